@@ -0,0 +1,334 @@
+package connectauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"connectrpc.com/connect"
+)
+
+// AdminConfig configures the handler returned by [NewAdminHandler]. Each
+// field is optional: endpoints for unconfigured components return
+// [connect.CodeUnimplemented] errors.
+type AdminConfig struct {
+	// Authenticator, if non-nil, is summarized by the config endpoint.
+	Authenticator *Authenticator
+	// Caches are the token caches to report on and flush, keyed by names
+	// that operators use to refer to them.
+	Caches map[string]*TokenCache
+	// Lockout, if non-nil, lets operators reset locked-out principals.
+	Lockout *Lockout
+	// Lockdown, if non-nil, lets operators toggle maintenance mode.
+	Lockdown *Lockdown
+	// MaintenancePolicy is applied to the Lockdown when maintenance mode is
+	// engaged.
+	MaintenancePolicy LockdownPolicy
+	// Logger, if non-nil, records each POST request at [slog.LevelInfo],
+	// along with the subject that made it (see [GetInfo]) and the outcome,
+	// so incident reviews can see who changed what.
+	Logger *slog.Logger
+}
+
+// NewAdminHandler constructs an HTTP handler for runtime operations. It
+// serves these JSON endpoints:
+//
+//	GET  /config              summary of the Authenticator's configuration
+//	GET  /caches              statistics for each TokenCache
+//	POST /caches/flush        purge one cache (?name=) or all of them
+//	POST /lockouts/reset      end a principal's lockout (?principal=)
+//	GET  /maintenance         whether maintenance mode is engaged
+//	POST /maintenance/engage  engage the Lockdown with MaintenancePolicy
+//	POST /maintenance/release release the Lockdown
+//
+// The handler doesn't authenticate requests. Mount it on an internal port,
+// or wrap it with an [HTTPMiddleware] that only admits operators:
+//
+//	admin := connectauth.NewAdminHandler(connectauth.AdminConfig{...})
+//	mux.Handle("/admin/", operators.Wrap(http.StripPrefix("/admin", admin)))
+//
+// Operators' browsers may hold cookies that the middleware accepts, so POST
+// requests must have the Content-Type "application/json", which browsers
+// don't send cross-origin without a CORS preflight, and are rejected with
+// [connect.CodePermissionDenied] if their Origin or Sec-Fetch-Site header
+// shows that they came from another site. Clients like curl should send
+// "Content-Type: application/json"; the body is ignored.
+//
+// It panics if the MaintenancePolicy is malformed.
+func NewAdminHandler(config AdminConfig) http.Handler {
+	if config.Lockdown != nil {
+		// Validate the policy now, rather than when it's needed in an
+		// incident.
+		if err := NewLockdown().Engage(config.MaintenancePolicy); err != nil {
+			panic(fmt.Sprintf("connectauth: invalid maintenance policy: %v", err))
+		}
+	}
+	a := &adminHandler{config: config}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", a.method(http.MethodGet, a.serveConfig))
+	mux.HandleFunc("/caches", a.method(http.MethodGet, a.serveCaches))
+	mux.HandleFunc("/caches/flush", a.method(http.MethodPost, a.action(a.flushCaches)))
+	mux.HandleFunc("/lockouts/reset", a.method(http.MethodPost, a.action(a.resetLockout)))
+	mux.HandleFunc("/maintenance", a.method(http.MethodGet, a.serveMaintenance))
+	mux.HandleFunc("/maintenance/engage", a.method(http.MethodPost, a.action(a.engageMaintenance)))
+	mux.HandleFunc("/maintenance/release", a.method(http.MethodPost, a.action(a.releaseMaintenance)))
+	return mux
+}
+
+type adminHandler struct {
+	config AdminConfig
+}
+
+// adminConfigSummary describes which of an Authenticator's features are
+// enabled. It doesn't include secrets or callbacks.
+type adminConfigSummary struct {
+	DryRun             bool     `json:"dryRun"`
+	AuthenticateAll    bool     `json:"authenticateAll"`
+	SkipPreflight      bool     `json:"skipPreflight"`
+	RedactErrors       bool     `json:"redactErrors"`
+	RequireTLS         bool     `json:"requireTls"`
+	Protocols          []string `json:"protocols,omitempty"`
+	ContentTypes       []string `json:"contentTypes,omitempty"`
+	BodyLimit          int64    `json:"bodyLimit,omitempty"`
+	MaxHeaderBytes     int      `json:"maxHeaderBytes,omitempty"`
+	MaxContentLength   int64    `json:"maxContentLength,omitempty"`
+	RequestIDHeader    string   `json:"requestIdHeader,omitempty"`
+	Enrichers          int      `json:"enrichers"`
+	Observers          int      `json:"observers"`
+	AuditSinks         int      `json:"auditSinks"`
+	ReputationCheckers int      `json:"reputationCheckers"`
+	Lockdown           bool     `json:"lockdown"`
+	LockdownEngaged    bool     `json:"lockdownEngaged"`
+	FailureLimiter     bool     `json:"failureLimiter"`
+	Lockout            bool     `json:"lockout"`
+	TrustedProxies     bool     `json:"trustedProxies"`
+	TLSPolicy          bool     `json:"tlsPolicy"`
+	Origins            bool     `json:"origins"`
+	Honeytokens        bool     `json:"honeytokens"`
+	CSRF               bool     `json:"csrf"`
+	Impersonation      bool     `json:"impersonation"`
+	Gateway            bool     `json:"gateway"`
+	ProcedureResolver  bool     `json:"procedureResolver"`
+	StreamExpiry       bool     `json:"streamExpiry"`
+	StreamLimiter      bool     `json:"streamLimiter"`
+	StreamRevalidation bool     `json:"streamRevalidation"`
+	StreamLifetime     bool     `json:"streamLifetime"`
+}
+
+func (a *adminHandler) serveConfig(w http.ResponseWriter, _ *http.Request) {
+	if a.config.Authenticator == nil {
+		a.writeError(w, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("no authenticator configured")))
+		return
+	}
+	c := a.config.Authenticator.config
+	contentTypes := make([]string, 0, len(c.ContentTypes))
+	for ct := range c.ContentTypes {
+		contentTypes = append(contentTypes, ct)
+	}
+	sort.Strings(contentTypes)
+	a.writeJSON(w, adminConfigSummary{
+		DryRun:             c.DryRun != nil,
+		AuthenticateAll:    c.AuthenticateAll,
+		SkipPreflight:      c.SkipPreflight,
+		RedactErrors:       c.RedactErrors,
+		RequireTLS:         c.RequireTLS,
+		Protocols:          c.Protocols,
+		ContentTypes:       contentTypes,
+		BodyLimit:          c.BodyLimit,
+		MaxHeaderBytes:     c.MaxHeaderBytes,
+		MaxContentLength:   c.MaxContentLength,
+		RequestIDHeader:    c.RequestIDHeader,
+		Enrichers:          len(c.Enrichers),
+		Observers:          len(c.Observers),
+		AuditSinks:         len(c.AuditSinks),
+		ReputationCheckers: len(c.ReputationCheckers),
+		Lockdown:           c.Lockdown != nil,
+		LockdownEngaged:    c.Lockdown != nil && c.Lockdown.Engaged(),
+		FailureLimiter:     c.FailureLimiter != nil,
+		Lockout:            c.Lockout != nil,
+		TrustedProxies:     c.TrustedProxies != nil,
+		TLSPolicy:          c.TLSPolicy != nil,
+		Origins:            c.Origins != nil,
+		Honeytokens:        c.Honeytokens != nil,
+		CSRF:               c.CSRF != nil,
+		Impersonation:      c.Impersonation != nil,
+		Gateway:            c.Gateway != nil,
+		ProcedureResolver:  c.ProcedureResolver != nil,
+		StreamExpiry:       c.StreamExpiry != nil,
+		StreamLimiter:      c.StreamLimiter != nil,
+		StreamRevalidation: c.StreamRevalidation != nil,
+		StreamLifetime:     c.StreamLifetime != nil,
+	})
+}
+
+func (a *adminHandler) serveCaches(w http.ResponseWriter, _ *http.Request) {
+	stats := make(map[string]TokenCacheStats, len(a.config.Caches))
+	for name, cache := range a.config.Caches {
+		stats[name] = cache.Stats()
+	}
+	a.writeJSON(w, stats)
+}
+
+func (a *adminHandler) flushCaches(w http.ResponseWriter, r *http.Request) {
+	caches := a.config.Caches
+	if name := r.URL.Query().Get("name"); name != "" {
+		cache, ok := caches[name]
+		if !ok {
+			a.writeError(w, connect.NewError(connect.CodeNotFound, fmt.Errorf("no cache named %q", name)))
+			return
+		}
+		caches = map[string]*TokenCache{name: cache}
+	}
+	flushed := make([]string, 0, len(caches))
+	for name, cache := range caches {
+		if err := cache.Purge(); err != nil {
+			a.writeError(w, connect.NewError(connect.CodeFailedPrecondition, fmt.Errorf("cache %q: %w", name, err)))
+			return
+		}
+		flushed = append(flushed, name)
+	}
+	sort.Strings(flushed)
+	a.writeJSON(w, struct {
+		Flushed []string `json:"flushed"`
+	}{flushed})
+}
+
+func (a *adminHandler) resetLockout(w http.ResponseWriter, r *http.Request) {
+	if a.config.Lockout == nil {
+		a.writeError(w, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("no lockout configured")))
+		return
+	}
+	principal := r.URL.Query().Get("principal")
+	if principal == "" {
+		a.writeError(w, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("missing principal")))
+		return
+	}
+	if err := a.config.Lockout.Reset(r.Context(), principal); err != nil {
+		a.writeError(w, NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, err))
+		return
+	}
+	a.writeJSON(w, struct {
+		Reset string `json:"reset"`
+	}{principal})
+}
+
+func (a *adminHandler) serveMaintenance(w http.ResponseWriter, _ *http.Request) {
+	if a.config.Lockdown == nil {
+		a.writeError(w, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("no lockdown configured")))
+		return
+	}
+	a.writeMaintenance(w)
+}
+
+func (a *adminHandler) engageMaintenance(w http.ResponseWriter, r *http.Request) {
+	if a.config.Lockdown == nil {
+		a.writeError(w, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("no lockdown configured")))
+		return
+	}
+	if err := a.config.Lockdown.Engage(a.config.MaintenancePolicy); err != nil {
+		// Unreachable, since NewAdminHandler validates the policy.
+		a.writeError(w, err)
+		return
+	}
+	a.writeMaintenance(w)
+}
+
+func (a *adminHandler) releaseMaintenance(w http.ResponseWriter, r *http.Request) {
+	if a.config.Lockdown == nil {
+		a.writeError(w, connect.NewError(connect.CodeUnimplemented, fmt.Errorf("no lockdown configured")))
+		return
+	}
+	a.config.Lockdown.Release()
+	a.writeMaintenance(w)
+}
+
+func (a *adminHandler) writeMaintenance(w http.ResponseWriter) {
+	a.writeJSON(w, struct {
+		Engaged bool `json:"engaged"`
+	}{a.config.Lockdown.Engaged()})
+}
+
+// method restricts a handler to a single HTTP method.
+func (a *adminHandler) method(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// action protects a state-changing handler from cross-site request forgery
+// and logs its use.
+func (a *adminHandler) action(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := &adminStatusRecorder{ResponseWriter: w, status: http.StatusOK}
+		err := checkAdminOrigin(r)
+		if err != nil {
+			a.writeError(rec, err)
+		} else {
+			h(rec, r)
+		}
+		if a.config.Logger == nil {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("path", r.URL.Path),
+			slog.String("query", r.URL.RawQuery),
+			slog.String("subject", SubjectOf(GetInfo(r.Context()))),
+			slog.String("client_ip", clientIP(r.RemoteAddr)),
+			slog.Int("status", rec.status),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		a.config.Logger.LogAttrs(r.Context(), slog.LevelInfo, "admin action", attrs...)
+	}
+}
+
+// checkAdminOrigin rejects requests that a browser could have sent from
+// another site.
+func checkAdminOrigin(r *http.Request) error {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "cross-site", "same-site":
+		return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("cross-site admin request"))
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, fmt.Errorf("origin %q not allowed", origin))
+		}
+	}
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("admin requests must have Content-Type application/json"))
+	}
+	return nil
+}
+
+// adminStatusRecorder records the status code written by an admin handler.
+type adminStatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *adminStatusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (a *adminHandler) writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// The only possible error is a failed write to the client.
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (a *adminHandler) writeError(w http.ResponseWriter, err error) {
+	writeJSONError(w, err, httpStatus)
+}
@@ -0,0 +1,176 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestAdminHandler(t *testing.T) {
+	ctx := context.Background()
+	cache, _, calls := newTestCache(TokenCacheConfig{TTL: time.Minute}, authenticate)
+	lockout := NewLockout(LockoutConfig{})
+	lockdown := NewLockdown()
+	var logs bytes.Buffer
+	auth := New(authenticate, WithLockout(lockout), WithLockdown(lockdown), WithRedactedErrors())
+	handler := NewAdminHandler(AdminConfig{
+		Authenticator:     auth,
+		Caches:            map[string]*TokenCache{"bearer": cache},
+		Lockout:           lockout,
+		Lockdown:          lockdown,
+		MaintenancePolicy: LockdownPolicy{Message: "down for maintenance"},
+		Logger:            slog.New(slog.NewJSONHandler(&logs, nil)),
+	})
+	serve := func(method, target string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, target, nil)
+		if method == http.MethodPost {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		handler.ServeHTTP(rec, req)
+		if body != nil {
+			attest.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+			attest.Ok(t, json.Unmarshal(rec.Body.Bytes(), body))
+		}
+		return rec
+	}
+
+	t.Run("config", func(t *testing.T) {
+		var summary adminConfigSummary
+		rec := serve(http.MethodGet, "/config", &summary)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.True(t, summary.RedactErrors)
+		attest.True(t, summary.Lockout)
+		attest.True(t, summary.Lockdown)
+		attest.False(t, summary.CSRF)
+		attest.Equal(t, summary.RequestIDHeader, "X-Request-Id")
+	})
+
+	t.Run("caches", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			_, err := cache.Authenticate(ctx, bearer(passphrase))
+			attest.Ok(t, err)
+		}
+		var stats map[string]TokenCacheStats
+		serve(http.MethodGet, "/caches", &stats)
+		attest.Equal(t, stats, map[string]TokenCacheStats{
+			"bearer": {Hits: 2, Misses: 1, Entries: 1},
+		})
+
+		var flushed struct{ Flushed []string }
+		rec := serve(http.MethodPost, "/caches/flush?name=bearer", &flushed)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, flushed.Flushed, []string{"bearer"})
+		attest.Equal(t, cache.Stats().Entries, 0)
+		_, err := cache.Authenticate(ctx, bearer(passphrase))
+		attest.Ok(t, err)
+		attest.Equal(t, calls.Load(), 2)
+
+		rec = serve(http.MethodPost, "/caches/flush?name=basic", nil)
+		attest.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("lockouts", func(t *testing.T) {
+		_, err := lockout.store.Lock(ctx, "alice", time.Now().Add(time.Hour))
+		attest.Ok(t, err)
+		rec := serve(http.MethodPost, "/lockouts/reset?principal=alice", nil)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		state, err := lockout.store.State(ctx, "alice")
+		attest.Ok(t, err)
+		attest.Zero(t, state)
+
+		rec = serve(http.MethodPost, "/lockouts/reset", nil)
+		attest.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("maintenance", func(t *testing.T) {
+		var status struct{ Engaged bool }
+		serve(http.MethodPost, "/maintenance/engage", &status)
+		attest.True(t, status.Engaged)
+		attest.True(t, lockdown.Engaged())
+		_, err := auth.authenticate(ctx, &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Header:    http.Header{"Authorization": []string{"Bearer " + passphrase}},
+		})
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+
+		serve(http.MethodPost, "/maintenance/release", &status)
+		attest.False(t, status.Engaged)
+		serve(http.MethodGet, "/maintenance", &status)
+		attest.False(t, status.Engaged)
+	})
+
+	t.Run("csrf", func(t *testing.T) {
+		for _, header := range []http.Header{
+			{},
+			{"Content-Type": {"text/plain"}},
+			{"Content-Type": {"application/json"}, "Sec-Fetch-Site": {"cross-site"}},
+			{"Content-Type": {"application/json"}, "Origin": {"https://evil.example"}},
+		} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/maintenance/engage", nil)
+			req.Header = header
+			handler.ServeHTTP(rec, req)
+			attest.Equal(t, rec.Code, http.StatusForbidden, attest.Sprintf("header %v", header))
+			attest.False(t, lockdown.Engaged())
+		}
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/maintenance/release", nil)
+		req.Header = http.Header{
+			"Content-Type":   {"application/json; charset=utf-8"},
+			"Origin":         {"http://example.com"}, // httptest's default host
+			"Sec-Fetch-Site": {"same-origin"},
+		}
+		handler.ServeHTTP(rec, req)
+		attest.Equal(t, rec.Code, http.StatusOK)
+	})
+
+	t.Run("log", func(t *testing.T) {
+		logs.Reset()
+		serve(http.MethodPost, "/lockouts/reset?principal=alice", nil)
+		line := logs.String()
+		attest.Subsequence(t, line, `"msg":"admin action"`)
+		attest.Subsequence(t, line, `"path":"/lockouts/reset"`)
+		attest.Subsequence(t, line, `"query":"principal=alice"`)
+		attest.Subsequence(t, line, `"status":200`)
+
+		logs.Reset()
+		serve(http.MethodGet, "/caches", nil)
+		attest.Zero(t, logs.String())
+	})
+
+	t.Run("method", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/maintenance/engage", nil)
+		attest.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+		attest.Equal(t, rec.Header().Get("Allow"), http.MethodPost)
+		attest.False(t, lockdown.Engaged())
+	})
+
+	t.Run("unconfigured", func(t *testing.T) {
+		handler := NewAdminHandler(AdminConfig{})
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/lockouts/reset?principal=alice", nil)
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(rec, req)
+		attest.Equal(t, rec.Code, http.StatusNotFound)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		attest.Panics(t, func() {
+			NewAdminHandler(AdminConfig{
+				Lockdown:          NewLockdown(),
+				MaintenancePolicy: LockdownPolicy{AllowProcedures: []string{"not a pattern"}},
+			})
+		})
+	})
+}
@@ -0,0 +1,194 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// An APIKeyRecord describes an issued API key. Stores hold only a hash of the
+// key's secret, so a leaked store doesn't leak usable keys.
+type APIKeyRecord struct {
+	ID      string    // the public part of the key
+	Hash    []byte    // see HashAPIKeySecret
+	Info    any       // authentication information for requests using the key
+	Expires time.Time // zero if the key doesn't expire
+}
+
+// A KeyStore looks up API keys by ID. LookupAPIKey returns nil and no error if
+// the ID doesn't exist. Implementations must be safe to call concurrently.
+type KeyStore interface {
+	LookupAPIKey(ctx context.Context, id string) (*APIKeyRecord, error)
+}
+
+// GenerateAPIKey generates a new API key with the supplied ID, which may
+// include a prefix identifying the issuer or environment (like
+// "acme_live_1234"). It returns the key, which should be shown to the user
+// once, and a hash of its secret for the KeyStore.
+//
+// Keys have the form "<id>.<secret>", where the secret is 256 random bits
+// encoded as unpadded base64url.
+func GenerateAPIKey(id string) (key string, hash []byte, err error) {
+	if id == "" || strings.Contains(id, ".") {
+		return "", nil, fmt.Errorf("invalid API key ID %q", id)
+	}
+	var secret [32]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret[:])
+	return id + "." + encoded, HashAPIKeySecret(encoded), nil
+}
+
+// HashAPIKeySecret hashes the secret part of an API key with SHA-256. API key
+// secrets have enough entropy that a slow password hash isn't necessary.
+func HashAPIKeySecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// APIKeyConfig configures an [APIKeyVerifier].
+type APIKeyConfig struct {
+	// Credential extracts the API key from a request. By default, it's the
+	// bearer token (see [BearerToken]).
+	Credential func(*Request) string
+	// Compare reports whether a secret matches a stored hash. It must take
+	// time independent of where the inputs differ. The default compares
+	// SHA-256 hashes (see HashAPIKeySecret) in constant time. Applications
+	// storing slow hashes, like bcrypt, should supply their own Compare and
+	// a DummyHash produced by the same algorithm.
+	Compare func(secret string, hash []byte) bool
+	// DummyHash is compared against the secret when a key ID doesn't exist,
+	// so that misses cost as much as hits. The default is the SHA-256 hash
+	// of a random secret.
+	DummyHash []byte
+	// Clock is used to check key expiry. The default is [SystemClock].
+	Clock Clock
+}
+
+// An APIKeyVerifier authenticates requests bearing API keys issued with
+// [GenerateAPIKey].
+//
+// Verification is designed not to leak whether a key ID exists. Every
+// request, including requests with unknown IDs, does the same work: one
+// store lookup and one constant-time comparison of the secret's hash.
+// Unknown IDs and wrong secrets return identical errors. Whether a key has
+// expired is only revealed after its secret is verified. The remaining
+// timing differences come from the KeyStore itself: remote stores often
+// answer misses faster than hits, and applications that consider key IDs
+// sensitive should pad lookups or cache negative results.
+type APIKeyVerifier struct {
+	store      KeyStore
+	credential func(*Request) string
+	compare    func(string, []byte) bool
+	dummy      []byte
+	now        func() time.Time
+}
+
+// NewAPIKeyVerifier constructs an APIKeyVerifier.
+func NewAPIKeyVerifier(store KeyStore, config APIKeyConfig) *APIKeyVerifier {
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			token, _ := BearerToken(req.Header)
+			return token
+		}
+	}
+	if config.Compare == nil {
+		config.Compare = compareAPIKeySecret
+	}
+	if config.DummyHash == nil {
+		var secret [32]byte
+		_, _ = rand.Read(secret[:])
+		config.DummyHash = HashAPIKeySecret(base64.RawURLEncoding.EncodeToString(secret[:]))
+	}
+	return &APIKeyVerifier{
+		store:      store,
+		credential: config.Credential,
+		compare:    config.Compare,
+		dummy:      config.DummyHash,
+		now:        nowFunc(config.Clock),
+	}
+}
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// Authenticate is an AuthFunc that verifies the request's API key and returns
+// the Info from its record.
+func (v *APIKeyVerifier) Authenticate(ctx context.Context, req *Request) (any, error) {
+	key := v.credential(req)
+	if key == "" {
+		return nil, ReasonErrorf(ReasonMissingCredentials, "missing API key")
+	}
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ReasonErrorf(ReasonMalformedCredentials, "malformed API key")
+	}
+	record, err := v.store.LookupAPIKey(ctx, id)
+	if err != nil {
+		return nil, NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, fmt.Errorf("look up API key: %w", err))
+	}
+	hash := v.dummy
+	if record != nil {
+		hash = record.Hash
+	}
+	// Always compare, even on a miss, so misses and hits take equal time.
+	if match := v.compare(secret, hash); !match || record == nil {
+		return nil, NewReasonError(connect.CodeUnauthenticated, ReasonInvalidCredentials, errInvalidAPIKey)
+	}
+	if !record.Expires.IsZero() && !v.now().Before(record.Expires) {
+		return nil, ReasonErrorf(ReasonExpired, "API key expired")
+	}
+	return record.Info, nil
+}
+
+func compareAPIKeySecret(secret string, hash []byte) bool {
+	sum := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(sum[:], hash) == 1
+}
+
+// A MemoryKeyStore is an in-memory [KeyStore].
+type MemoryKeyStore struct {
+	mu      sync.RWMutex
+	records map[string]*APIKeyRecord
+}
+
+var _ KeyStore = (*MemoryKeyStore)(nil)
+
+// NewMemoryKeyStore constructs a MemoryKeyStore holding the supplied records.
+func NewMemoryKeyStore(records ...*APIKeyRecord) *MemoryKeyStore {
+	s := &MemoryKeyStore{records: make(map[string]*APIKeyRecord, len(records))}
+	for _, r := range records {
+		s.Put(r)
+	}
+	return s
+}
+
+// LookupAPIKey implements [KeyStore].
+func (s *MemoryKeyStore) LookupAPIKey(_ context.Context, id string) (*APIKeyRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.records[id], nil
+}
+
+// Put adds or replaces a record.
+func (s *MemoryKeyStore) Put(record *APIKeyRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.ID] = record
+}
+
+// Delete revokes a key.
+func (s *MemoryKeyStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+}
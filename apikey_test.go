@@ -0,0 +1,112 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestAPIKeyVerifier(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	key, hash, err := GenerateAPIKey("acme_live_1")
+	attest.Ok(t, err)
+	attest.True(t, strings.HasPrefix(key, "acme_live_1."))
+	expiring, expiringHash, err := GenerateAPIKey("acme_live_2")
+	attest.Ok(t, err)
+	store := NewMemoryKeyStore(
+		&APIKeyRecord{ID: "acme_live_1", Hash: hash, Info: "alice"},
+		&APIKeyRecord{ID: "acme_live_2", Hash: expiringHash, Info: "bob", Expires: clock.Now().Add(time.Hour)},
+	)
+	verifier := NewAPIKeyVerifier(store, APIKeyConfig{Clock: clock})
+
+	info, err := verifier.Authenticate(ctx, bearer(key))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("alice"))
+	info, err = verifier.Authenticate(ctx, bearer(expiring))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("bob"))
+
+	// Unknown IDs and wrong secrets are indistinguishable.
+	_, wrongSecret := verifier.Authenticate(ctx, bearer("acme_live_1.wrong"))
+	_, unknownID := verifier.Authenticate(ctx, bearer("acme_live_9.wrong"))
+	for _, err := range []error{wrongSecret, unknownID} {
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+	}
+	attest.Equal(t, wrongSecret.Error(), unknownID.Error())
+
+	_, err = verifier.Authenticate(ctx, &Request{})
+	attest.Equal(t, ReasonOf(err), ReasonMissingCredentials)
+	_, err = verifier.Authenticate(ctx, bearer("nodot"))
+	attest.Equal(t, ReasonOf(err), ReasonMalformedCredentials)
+
+	clock.Advance(time.Hour)
+	_, err = verifier.Authenticate(ctx, bearer(expiring))
+	attest.Equal(t, ReasonOf(err), ReasonExpired)
+
+	store.Delete("acme_live_1")
+	_, err = verifier.Authenticate(ctx, bearer(key))
+	attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+
+	failing := NewAPIKeyVerifier(failingKeyStore{}, APIKeyConfig{})
+	_, err = failing.Authenticate(ctx, bearer(key))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+
+	_, _, err = GenerateAPIKey("has.dot")
+	attest.Error(t, err)
+}
+
+func TestAPIKeyVerifierComparesOnMiss(t *testing.T) {
+	var compared [][]byte
+	dummy := []byte("dummy")
+	verifier := NewAPIKeyVerifier(NewMemoryKeyStore(), APIKeyConfig{
+		Compare: func(_ string, hash []byte) bool {
+			compared = append(compared, hash)
+			return true // even a "match" against the dummy must fail
+		},
+		DummyHash: dummy,
+	})
+	_, err := verifier.Authenticate(context.Background(), bearer("missing.secret"))
+	attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+	attest.Equal(t, compared, [][]byte{dummy})
+}
+
+// BenchmarkAPIKeyVerifier shows that hits, misses, and wrong secrets cost
+// the same.
+func BenchmarkAPIKeyVerifier(b *testing.B) {
+	key, hash, err := GenerateAPIKey("acme_live_1")
+	attest.Ok(b, err)
+	verifier := NewAPIKeyVerifier(
+		NewMemoryKeyStore(&APIKeyRecord{ID: "acme_live_1", Hash: hash, Info: "alice"}),
+		APIKeyConfig{},
+	)
+	secret := key[strings.IndexByte(key, '.')+1:]
+	for _, bb := range []struct {
+		name string
+		key  string
+	}{
+		{"hit", key},
+		{"wrong_secret", "acme_live_1." + strings.Repeat("x", len(secret))},
+		{"unknown_id", "acme_live_9." + secret},
+	} {
+		req := bearer(bb.key)
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = verifier.Authenticate(context.Background(), req)
+			}
+		})
+	}
+}
+
+type failingKeyStore struct{}
+
+func (failingKeyStore) LookupAPIKey(context.Context, string) (*APIKeyRecord, error) {
+	return nil, errors.New("oh no")
+}
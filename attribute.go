@@ -0,0 +1,124 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// AttributeConfig configures [NewAttributeInterceptor].
+type AttributeConfig struct {
+	// SubjectHashKey, if set, enables propagating a keyed hash of the
+	// caller's subject (see [SubjectOf]), so that downstream services can
+	// correlate requests by caller without learning who the caller is.
+	// Subjects like email addresses are easy to guess, so an unkeyed hash
+	// would hide little; the key should be shared only with the systems
+	// that need to reverse hashes by recomputing them.
+	SubjectHashKey []byte
+	// Tenant returns the caller's tenant, or an empty string. By default,
+	// it's the tenant resolved by a [TenantResolver] (see [GetTenant]) or,
+	// failing that, the "tenant" claim of an [Identity], if it's a string.
+	Tenant func(ctx context.Context) string
+	// RequestIDHeader carries the ID of the request being served (see
+	// [GetRequestID]). The default is X-Request-Id.
+	RequestIDHeader string
+	// Baggage sends the subject hash, tenant, and request ID as W3C baggage
+	// members (connectauth.subject_hash, connectauth.tenant, and
+	// connectauth.request_id) rather than as headers. Baggage is usually
+	// propagated further, by tracing instrumentation, than headers are.
+	Baggage bool
+}
+
+// NewAttributeInterceptor returns a client interceptor that copies
+// non-sensitive attributes of the request being served to outgoing requests,
+// so that downstream services and their logs can correlate calls without
+// receiving the caller's credentials: a keyed hash of the subject in the
+// Connectauth-Subject-Hash header, the tenant in Connectauth-Tenant, and the
+// request ID. Unlike an [IdentityPropagator], it doesn't authenticate
+// anything, so downstream services must not make authorization decisions
+// based on the attributes. Attributes that are empty aren't sent.
+func NewAttributeInterceptor(config AttributeConfig) connect.Interceptor {
+	if config.Tenant == nil {
+		config.Tenant = tenantClaim
+	}
+	if config.RequestIDHeader == "" {
+		config.RequestIDHeader = "X-Request-Id"
+	}
+	return &attributeInterceptor{config: config}
+}
+
+type attributeInterceptor struct {
+	config AttributeConfig
+}
+
+func (i *attributeInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			i.copy(ctx, req.Header())
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *attributeInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		i.copy(ctx, conn.RequestHeader())
+		return conn
+	}
+}
+
+func (i *attributeInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func (i *attributeInterceptor) copy(ctx context.Context, header http.Header) {
+	var hash string
+	if i.config.SubjectHashKey != nil {
+		if subject := SubjectOf(GetInfo(ctx)); subject != "" {
+			mac := hmac.New(sha256.New, i.config.SubjectHashKey)
+			mac.Write([]byte(subject))
+			hash = hex.EncodeToString(mac.Sum(nil)[:16])
+		}
+	}
+	attrs := [...]struct{ header, member, value string }{
+		{"Connectauth-Subject-Hash", "connectauth.subject_hash", hash},
+		{"Connectauth-Tenant", "connectauth.tenant", i.config.Tenant(ctx)},
+		{i.config.RequestIDHeader, "connectauth.request_id", GetRequestID(ctx)},
+	}
+	var members []string
+	for _, attr := range attrs {
+		if attr.value == "" {
+			continue
+		}
+		if i.config.Baggage {
+			members = append(members, attr.member+"="+url.PathEscape(attr.value))
+		} else {
+			header.Set(attr.header, attr.value)
+		}
+	}
+	if len(members) > 0 {
+		if baggage := header.Get("Baggage"); baggage != "" {
+			members = append([]string{baggage}, members...)
+		}
+		header.Set("Baggage", strings.Join(members, ","))
+	}
+}
+
+func tenantClaim(ctx context.Context) string {
+	if tenant := GetTenant(ctx); tenant != "" {
+		return tenant
+	}
+	identity := IdentityOf(GetInfo(ctx))
+	if identity == nil {
+		return ""
+	}
+	tenant, _ := identity.Claims["tenant"].(string)
+	return tenant
+}
@@ -0,0 +1,79 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestAttributeInterceptor(t *testing.T) {
+	const procedure = "/empty.v1/Ping"
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(
+		procedure,
+		func(_ context.Context, req *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			res := connect.NewResponse(&emptypb.Empty{})
+			for _, name := range []string{"Connectauth-Subject-Hash", "Connectauth-Tenant", "X-Request-Id", "Baggage"} {
+				res.Header().Set("Echo-"+name, req.Header().Get(name))
+			}
+			return res, nil
+		},
+	))
+	srv := memhttptest.New(t, mux)
+	call := func(ctx context.Context, config AttributeConfig) http.Header {
+		t.Helper()
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](
+			srv.Client(),
+			srv.URL()+procedure,
+			connect.WithInterceptors(NewAttributeInterceptor(config)),
+		)
+		res, err := client.CallUnary(ctx, connect.NewRequest(&emptypb.Empty{}))
+		attest.Ok(t, err)
+		return res.Header()
+	}
+
+	// Authenticate an incoming request to get a context with a request ID.
+	auth := New(func(context.Context, *Request) (any, error) {
+		return &Identity{Subject: hero, Claims: map[string]any{"tenant": "acme"}}, nil
+	})
+	ctx, err := auth.authenticate(context.Background(), &Request{
+		Header: http.Header{"X-Request-Id": []string{"req-123"}},
+	})
+	attest.Ok(t, err)
+	attest.Equal(t, GetRequestID(ctx), "req-123")
+	key := []byte("correlation")
+
+	t.Run("headers", func(t *testing.T) {
+		header := call(ctx, AttributeConfig{SubjectHashKey: key})
+		hash := header.Get("Echo-Connectauth-Subject-Hash")
+		attest.Equal(t, len(hash), 32)
+		attest.NotEqual(t, hash, hero)
+		attest.Equal(t, header.Get("Echo-Connectauth-Tenant"), "acme")
+		attest.Equal(t, header.Get("Echo-X-Request-Id"), "req-123")
+		attest.Zero(t, header.Get("Echo-Baggage"))
+
+		// Hashes are stable.
+		attest.Equal(t, call(ctx, AttributeConfig{SubjectHashKey: key}).Get("Echo-Connectauth-Subject-Hash"), hash)
+	})
+
+	t.Run("baggage", func(t *testing.T) {
+		header := call(ctx, AttributeConfig{
+			Baggage: true,
+			Tenant:  func(context.Context) string { return "acme corp" },
+		})
+		attest.Equal(t, header.Get("Echo-Baggage"), "connectauth.tenant=acme%20corp,connectauth.request_id=req-123")
+		attest.Zero(t, header.Get("Echo-Connectauth-Tenant"))
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		header := call(context.Background(), AttributeConfig{SubjectHashKey: key})
+		attest.Zero(t, header.Get("Echo-Connectauth-Subject-Hash"))
+		attest.Zero(t, header.Get("Echo-Connectauth-Tenant"))
+		attest.Zero(t, header.Get("Echo-X-Request-Id"))
+	})
+}
@@ -0,0 +1,163 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// Audit decisions.
+const (
+	DecisionAllow  = "allow"
+	DecisionDeny   = "deny"
+	DecisionExempt = "exempt"
+)
+
+// An AuditEvent is a complete record of the authentication decision for a
+// single request.
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Decision   string    `json:"decision"` // DecisionAllow, DecisionDeny, or DecisionExempt
+	Subject    string    `json:"subject,omitempty"`
+	Procedure  string    `json:"procedure"`
+	Protocol   string    `json:"protocol,omitempty"`
+	ClientAddr string    `json:"client_addr"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Code       string    `json:"code,omitempty"`     // for denials, the Connect error code
+	Reason     Reason    `json:"reason,omitempty"`   // for denials, the reason for the failure
+	Error      string    `json:"error,omitempty"`    // for denials, the complete error message
+	Identity   *Identity `json:"identity,omitempty"` // if the authentication information is an Identity
+	Actor      string    `json:"actor,omitempty"`    // for impersonation attempts, the caller's subject
+}
+
+// An AuditSink records audit events. Implementations must be safe to call
+// concurrently.
+type AuditSink interface {
+	Audit(context.Context, *AuditEvent) error
+}
+
+// WithAuditSink records an [AuditEvent] for every request. The audit log is
+// authoritative: if the sink returns an error for a request that would
+// otherwise be allowed, the request is rejected with
+// [connect.CodeUnavailable].
+//
+// Sinks run synchronously on the request path. Use [NewAsyncAuditSink] to
+// keep slow IO off the request path.
+func WithAuditSink(sink AuditSink) Option {
+	return optionFunc(func(c *config) {
+		c.AuditSinks = append(c.AuditSinks, sink)
+	})
+}
+
+// WithRequestIDHeader configures the request header used to populate
+// [AuditEvent].RequestID and [GetRequestID]. The default is X-Request-Id.
+func WithRequestIDHeader(name string) Option {
+	return optionFunc(func(c *config) {
+		c.RequestIDHeader = name
+	})
+}
+
+func (a *Authenticator) audit(ctx context.Context, ev *Event) error {
+	// Impersonation attempts are always audited.
+	if a.config.AuditSampler != nil && ev.Actor == nil && !a.config.AuditSampler(ev) {
+		return nil
+	}
+	req := ev.Request
+	audit := &AuditEvent{
+		Time:       ev.Start,
+		Decision:   DecisionAllow,
+		Subject:    SubjectOf(ev.Info),
+		Procedure:  req.Procedure,
+		Protocol:   req.Protocol,
+		ClientAddr: req.ClientAddr,
+		RequestID:  req.Header.Get(a.config.RequestIDHeader),
+		Actor:      SubjectOf(ev.Actor),
+	}
+	switch id := ev.Info.(type) {
+	case *Identity:
+		audit.Identity = id
+	case Identity:
+		audit.Identity = &id
+	}
+	if ev.Exempt {
+		audit.Decision = DecisionExempt
+	}
+	if ev.Err != nil {
+		audit.Decision = DecisionDeny
+		audit.Code = connect.CodeOf(ev.Err).String()
+		audit.Reason = ReasonOf(ev.Err)
+		audit.Error = ev.Err.Error()
+	}
+	for _, sink := range a.config.AuditSinks {
+		if err := sink.Audit(ctx, audit); err != nil {
+			return NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, fmt.Errorf("audit: %w", err))
+		}
+	}
+	return nil
+}
+
+// A JSONAuditSink writes audit events to an [io.Writer] as newline-delimited
+// JSON.
+type JSONAuditSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+var _ BatchAuditSink = (*JSONAuditSink)(nil)
+
+// NewJSONAuditSink constructs a JSONAuditSink. Writes to w are serialized.
+func NewJSONAuditSink(w io.Writer) *JSONAuditSink {
+	return &JSONAuditSink{w: w, enc: json.NewEncoder(w)}
+}
+
+// OpenJSONAuditFile opens a file for appending, creating it if necessary, and
+// returns a JSONAuditSink that writes to it. Close the sink to close the
+// file.
+func OpenJSONAuditFile(path string) (*JSONAuditSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONAuditSink(f), nil
+}
+
+// Audit implements [AuditSink].
+func (s *JSONAuditSink) Audit(_ context.Context, ev *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
+
+// AuditBatch implements [BatchAuditSink]. The whole batch is written to the
+// underlying writer at once.
+func (s *JSONAuditSink) AuditBatch(_ context.Context, events []*AuditEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// Close closes the underlying writer, if it implements [io.Closer].
+func (s *JSONAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errAuditClosed = errors.New("audit sink is closed")
+
+// ErrAuditDropped is reported to [AsyncAuditConfig].OnError when an event is
+// dropped because the buffer is full.
+var ErrAuditDropped = errors.New("audit buffer is full: event dropped")
+
+// A BatchAuditSink is an AuditSink that can efficiently record many events at
+// once. [AsyncAuditSink] uses AuditBatch when the sink it wraps implements
+// this interface.
+type BatchAuditSink interface {
+	AuditSink
+	AuditBatch(context.Context, []*AuditEvent) error
+}
+
+// AsyncAuditConfig configures an [AsyncAuditSink].
+type AsyncAuditConfig struct {
+	// BufferSize is the number of events that may be queued. When the buffer
+	// is full, Audit blocks until there's space or its context is done,
+	// unless DropWhenFull is set. The default is 1024.
+	BufferSize int
+	// DropWhenFull makes Audit drop events instead of blocking when the
+	// buffer is full, so a slow or unavailable sink can't delay requests.
+	// Each dropped event is reported to OnError as [ErrAuditDropped].
+	DropWhenFull bool
+	// BatchSize is the maximum number of events written at once. The default
+	// is 128.
+	BatchSize int
+	// FlushInterval is the maximum time an event waits in the buffer before
+	// being written. The default is one second.
+	FlushInterval time.Duration
+	// WriteTimeout bounds each write to the wrapped sink: the context passed
+	// to the sink is canceled after this long, so a hung collector can't stop
+	// the buffer from draining. The default is 10 seconds.
+	WriteTimeout time.Duration
+	// OnError, if non-nil, is called with errors from the wrapped sink and
+	// with [ErrAuditDropped]. It's called from the background goroutine and,
+	// for dropped events, from Audit, so it must be safe to call
+	// concurrently.
+	OnError func(error)
+}
+
+// An AsyncAuditSink buffers audit events and writes them to another sink on a
+// background goroutine, so audit IO latency doesn't land on the request path.
+// Events are written in batches.
+//
+// Because writes happen after the request has been authenticated, errors from
+// the wrapped sink can't fail the request: they're reported to
+// [AsyncAuditConfig].OnError instead. Applications must call Close before
+// exiting to avoid losing buffered events.
+type AsyncAuditSink struct {
+	sink    AuditSink
+	config  AsyncAuditConfig
+	events  chan *AuditEvent
+	flushes chan chan struct{}
+	done    chan struct{}
+
+	mu     sync.RWMutex // held for writing when closing
+	closed bool
+}
+
+var _ AuditSink = (*AsyncAuditSink)(nil)
+
+// NewAsyncAuditSink wraps an AuditSink and starts a background goroutine to
+// write events.
+func NewAsyncAuditSink(sink AuditSink, config AsyncAuditConfig) *AsyncAuditSink {
+	if config.BufferSize <= 0 {
+		config.BufferSize = 1024
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 128
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 10 * time.Second
+	}
+	s := &AsyncAuditSink{
+		sink:    sink,
+		config:  config,
+		events:  make(chan *AuditEvent, config.BufferSize),
+		flushes: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Audit implements [AuditSink] by queueing the event. It returns an error if
+// the sink is closed or if ctx is done before there's space in the buffer.
+// With DropWhenFull, it never blocks and doesn't return an error for dropped
+// events.
+func (s *AsyncAuditSink) Audit(ctx context.Context, ev *AuditEvent) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errAuditClosed
+	}
+	if s.config.DropWhenFull {
+		select {
+		case s.events <- ev:
+		default:
+			s.reportError(ErrAuditDropped)
+		}
+		return nil
+	}
+	select {
+	case s.events <- ev:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flush blocks until all events queued before the call have been written, or
+// until ctx is done.
+func (s *AsyncAuditSink) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case s.flushes <- flushed:
+	case <-s.done:
+		return errAuditClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new events, writes all buffered events, and stops the
+// background goroutine. It blocks until the buffer is drained or ctx is done.
+// Close doesn't close the wrapped sink.
+func (s *AsyncAuditSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *AsyncAuditSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]*AuditEvent, 0, s.config.BatchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.write(batch)
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case ev, ok := <-s.events:
+			if !ok {
+				write()
+				return
+			}
+			batch = append(batch, ev)
+			if len(batch) >= s.config.BatchSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case flushed := <-s.flushes:
+			for drained := false; !drained; {
+				select {
+				case ev, ok := <-s.events:
+					if !ok {
+						drained = true
+						break
+					}
+					batch = append(batch, ev)
+					if len(batch) >= s.config.BatchSize {
+						write()
+					}
+				default:
+					drained = true
+				}
+			}
+			write()
+			close(flushed)
+		}
+	}
+}
+
+func (s *AsyncAuditSink) write(batch []*AuditEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.WriteTimeout)
+	defer cancel()
+	if b, ok := s.sink.(BatchAuditSink); ok {
+		if err := b.AuditBatch(ctx, batch); err != nil {
+			s.reportError(err)
+		}
+		return
+	}
+	for i, ev := range batch {
+		if err := ctx.Err(); err != nil {
+			// The rest of the batch would fail immediately.
+			s.reportError(fmt.Errorf("%d audit events not written: %w", len(batch)-i, err))
+			return
+		}
+		if err := s.sink.Audit(ctx, ev); err != nil {
+			s.reportError(err)
+		}
+	}
+}
+
+func (s *AsyncAuditSink) reportError(err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
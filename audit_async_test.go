@@ -0,0 +1,150 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	events  []*AuditEvent
+	batches int
+	err     error
+}
+
+func (s *recordingSink) Audit(ctx context.Context, ev *AuditEvent) error {
+	return s.AuditBatch(ctx, []*AuditEvent{ev})
+}
+
+func (s *recordingSink) AuditBatch(_ context.Context, events []*AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	s.batches++
+	return s.err
+}
+
+func (s *recordingSink) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+func TestAsyncAuditSink(t *testing.T) {
+	ctx := context.Background()
+	rec := &recordingSink{}
+	sink := NewAsyncAuditSink(rec, AsyncAuditConfig{BatchSize: 4, FlushInterval: time.Hour})
+	for i := 0; i < 10; i++ {
+		attest.Ok(t, sink.Audit(ctx, &AuditEvent{Decision: DecisionAllow}))
+	}
+	attest.Ok(t, sink.Flush(ctx))
+	attest.Equal(t, rec.len(), 10)
+	attest.True(t, rec.batches <= 4, attest.Sprintf("expected batching, got %d batches", rec.batches))
+
+	attest.Ok(t, sink.Audit(ctx, &AuditEvent{Decision: DecisionDeny}))
+	attest.Ok(t, sink.Close(ctx))
+	attest.Equal(t, rec.len(), 11)
+	attest.Error(t, sink.Audit(ctx, &AuditEvent{}))
+	attest.Error(t, sink.Flush(ctx))
+	attest.Ok(t, sink.Close(ctx)) // idempotent
+}
+
+func TestAsyncAuditSinkInterval(t *testing.T) {
+	rec := &recordingSink{err: errors.New("disk full")}
+	errs := make(chan error, 1)
+	sink := NewAsyncAuditSink(rec, AsyncAuditConfig{
+		FlushInterval: time.Millisecond,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	defer sink.Close(context.Background())
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	select {
+	case err := <-errs:
+		attest.Equal(t, err.Error(), "disk full")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for background write")
+	}
+}
+
+func TestAsyncAuditSinkBackpressure(t *testing.T) {
+	block := make(chan struct{})
+	sink := NewAsyncAuditSink(blockingSink(block), AsyncAuditConfig{BufferSize: 1, BatchSize: 1})
+	defer func() {
+		close(block)
+		sink.Close(context.Background())
+	}()
+	// One event is held by the blocked sink, one fills the buffer.
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	attest.ErrorIs(t, sink.Audit(ctx, &AuditEvent{}), context.DeadlineExceeded)
+}
+
+type blockingSink chan struct{}
+
+func (s blockingSink) Audit(context.Context, *AuditEvent) error {
+	<-s
+	return nil
+}
+
+func TestAsyncAuditSinkDropWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	var dropped atomic.Int32
+	sink := NewAsyncAuditSink(blockingSink(block), AsyncAuditConfig{
+		BufferSize:   1,
+		BatchSize:    1,
+		DropWhenFull: true,
+		OnError: func(err error) {
+			if errors.Is(err, ErrAuditDropped) {
+				dropped.Add(1)
+			}
+		},
+	})
+	defer func() {
+		close(block)
+		sink.Close(context.Background())
+	}()
+	// Audit never blocks, even though the sink is stuck.
+	for i := 0; i < 10; i++ {
+		attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	}
+	attest.True(t, dropped.Load() >= 8, attest.Sprintf("dropped %d events", dropped.Load()))
+}
+
+func TestAsyncAuditSinkWriteTimeout(t *testing.T) {
+	errs := make(chan error, 4)
+	sink := NewAsyncAuditSink(hangingSink{}, AsyncAuditConfig{
+		BatchSize:    2,
+		WriteTimeout: 10 * time.Millisecond,
+		OnError:      func(err error) { errs <- err },
+	})
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	attest.Ok(t, sink.Close(ctx)) // the hung sink doesn't wedge the writer
+	attest.ErrorIs(t, <-errs, context.DeadlineExceeded)
+	err := <-errs
+	attest.ErrorIs(t, err, context.DeadlineExceeded)
+	attest.Subsequence(t, err.Error(), "1 audit events not written")
+}
+
+// hangingSink blocks until the write's context is done.
+type hangingSink struct{}
+
+func (hangingSink) Audit(ctx context.Context, _ *AuditEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,97 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+type failingSink struct{}
+
+func (failingSink) Audit(context.Context, *AuditEvent) error {
+	return errors.New("disk full")
+}
+
+func TestAuditSink(t *testing.T) {
+	var buf bytes.Buffer
+	auth := New(
+		authenticate,
+		WithAuditSink(NewJSONAuditSink(&buf)),
+		WithExemptProcedures("/acme.v1.Svc/Ping"),
+	)
+	call := func(procedure, authorization string) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure:  procedure,
+			Protocol:   connect.ProtocolConnect,
+			ClientAddr: "192.0.2.1:1234",
+			Header: http.Header{
+				"Authorization": []string{authorization},
+				"X-Request-Id":  []string{"req-123"},
+			},
+		})
+		return err
+	}
+	attest.Ok(t, call("/acme.v1.Svc/Get", "Bearer "+passphrase))
+	attest.Error(t, call("/acme.v1.Svc/Get", "Bearer wrong"))
+	attest.Ok(t, call("/acme.v1.Svc/Ping", ""))
+
+	var events []AuditEvent
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var ev AuditEvent
+		attest.Ok(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	attest.Equal(t, len(events), 3)
+	attest.Equal(t, events[0].Decision, DecisionAllow)
+	attest.Equal(t, events[0].Subject, hero)
+	attest.Equal(t, events[0].RequestID, "req-123")
+	attest.Equal(t, events[0].ClientAddr, "192.0.2.1:1234")
+	attest.False(t, events[0].Time.IsZero())
+	attest.Equal(t, events[1].Decision, DecisionDeny)
+	attest.Equal(t, events[1].Code, "unauthenticated")
+	attest.Zero(t, events[1].Subject)
+	attest.Equal(t, events[2].Decision, DecisionExempt)
+
+	t.Run("identity", func(t *testing.T) {
+		var buf bytes.Buffer
+		identity := &Identity{Subject: hero, Scopes: []string{"read"}, Claims: map[string]any{"tenant": "acme"}}
+		auth := New(func(context.Context, *Request) (any, error) {
+			return identity, nil
+		}, WithAuditSink(NewJSONAuditSink(&buf)))
+		_, err := auth.authenticate(context.Background(), &Request{Protocol: connect.ProtocolConnect})
+		attest.Ok(t, err)
+		attest.Subsequence(t, buf.String(), `"identity":{"subject":"Ali Baba","scopes":["read"],"claims":{"tenant":"acme"}}`)
+		var ev AuditEvent
+		attest.Ok(t, json.Unmarshal(buf.Bytes(), &ev))
+		attest.Equal(t, ev.Identity, identity)
+	})
+
+	t.Run("fail closed", func(t *testing.T) {
+		auth := New(authenticate, WithAuditSink(failingSink{}))
+		_, err := auth.authenticate(context.Background(), &Request{
+			Protocol: connect.ProtocolConnect,
+			Header:   http.Header{"Authorization": []string{"Bearer " + passphrase}},
+		})
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	})
+}
+
+func TestJSONAuditFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenJSONAuditFile(path)
+	attest.Ok(t, err)
+	attest.Ok(t, sink.Audit(context.Background(), &AuditEvent{Decision: DecisionAllow, Procedure: "/a.v1.B/C"}))
+	attest.Ok(t, sink.Close())
+	contents, err := os.ReadFile(path)
+	attest.Ok(t, err)
+	attest.Subsequence(t, string(contents), `"procedure":"/a.v1.B/C"`)
+}
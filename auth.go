@@ -3,17 +3,32 @@
 package connectauth
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 type key int
 
-const infoKey key = iota
+const (
+	infoKey key = iota
+	authenticatedKey
+	flagsKey
+	requestIDKey
+)
 
 // An AuthFunc authenticates an RPC. The function must return an error if the
 // request cannot be authenticated. The error is typically produced with
@@ -45,6 +60,46 @@ func WithoutInfo(ctx context.Context) context.Context {
 	return context.WithValue(ctx, infoKey, nil)
 }
 
+// GetFlags retrieves the labels of checks that flagged, but didn't reject,
+// the request (see [Request].Flags).
+func GetFlags(ctx context.Context) []string {
+	flags, _ := ctx.Value(flagsKey).([]string)
+	return flags
+}
+
+// GetRequestID retrieves the ID of the request being served, read from the
+// header configured with [WithRequestIDHeader]. It returns an empty string if
+// the request didn't have an ID.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// SubjectOf describes the principal identified by authentication
+// information. If the information is an [Identity], SubjectOf returns its
+// Subject. If the information has a Subject() string method, SubjectOf
+// returns its result. If the information is a string, SubjectOf returns it
+// directly. Otherwise, SubjectOf returns an empty string.
+//
+// Logging, metrics, and auditing features use SubjectOf to identify callers.
+func SubjectOf(info any) string {
+	switch i := info.(type) {
+	case *Identity:
+		if i == nil {
+			return ""
+		}
+		return i.Subject
+	case Identity:
+		return i.Subject
+	case interface{ Subject() string }:
+		return i.Subject()
+	case string:
+		return i
+	default:
+		return ""
+	}
+}
+
 // Errorf is a convenience function that returns an error coded with
 // [connect.CodeUnauthenticated].
 func Errorf(template string, args ...any) *connect.Error {
@@ -52,11 +107,384 @@ func Errorf(template string, args ...any) *connect.Error {
 }
 
 // Request describes a single RPC invocation.
+//
+// When using [WithAuthenticateAll], Procedure and Protocol are empty for
+// non-RPC requests. Body is only populated by [Middleware] configured with
+// [WithBufferedBody]; it contains the raw, possibly compressed, request body.
+//
+// StreamType is the RPC's stream type. [Interceptor] reads it from the
+// procedure's spec. [Middleware] looks up the procedure's descriptor in
+// [protoregistry.GlobalFiles], where generated code registers it, and
+// otherwise infers it from the Connect protocol's Content-Type; when neither
+// is conclusive, it assumes [connect.StreamTypeBidi], so AuthFuncs
+// distinguishing unary and streaming RPCs fail closed. StreamType is zero
+// ([connect.StreamTypeUnary]) for non-RPC requests.
+//
+// TraceParent and TraceState hold the request's W3C trace context headers, so
+// AuthFuncs making outbound calls can propagate the trace without re-parsing
+// headers. Baggage is only populated when using [WithBaggage]. ClientAddr and
+// PeerAddr differ only when using [WithTrustedProxies]. Connect handlers
+// don't expose the Host header, so Host is empty when using [Interceptor].
+//
+// AuthFuncs may add headers to ResponseHeader, for example to renew a session
+// cookie. [Middleware] sends them even if authentication fails; [Interceptor]
+// only sends them with successful unary responses and with streams.
 type Request struct {
-	Procedure  string // for example, "/acme.foo.v1.FooService/Bar"
-	ClientAddr string // client address, in IP:port format
-	Protocol   string // connect.ProtocolConnect, connect.ProtocolGRPC, or connect.ProtocolGRPCWeb
-	Header     http.Header
+	Procedure   string // for example, "/acme.foo.v1.FooService/Bar"
+	ClientAddr  string // client address, in IP:port format
+	PeerAddr    string // address of the immediate peer, which may be a proxy
+	Host        string // the Host header or :authority pseudo-header, if known
+	Protocol    string // connect.ProtocolConnect, connect.ProtocolGRPC, or connect.ProtocolGRPCWeb
+	StreamType  connect.StreamType
+	Header      http.Header
+	Body        []byte
+	TraceParent string               // the traceparent header
+	TraceState  string               // the tracestate header
+	Baggage     string               // the baggage header
+	Flags       []string             // labels from checks that flagged, but didn't reject, the request
+	TLS         *tls.ConnectionState // nil for cleartext requests and when using Interceptor
+	// ResponseHeader holds headers to add to the response.
+	ResponseHeader http.Header
+}
+
+// An Authenticator holds an AuthFunc and its configuration. It can produce
+// both HTTP [Middleware] and a Connect [Interceptor], which share
+// configuration, caches, and metrics. Applications that only need one or the
+// other may use [NewMiddleware] or [NewInterceptor] instead.
+type Authenticator struct {
+	auth        AuthFunc
+	config      *config
+	middleware  *Middleware
+	httpMW      *HTTPMiddleware
+	interceptor *Interceptor
+
+	mu           sync.Mutex // serializes updates to handlerOpts
+	handlerOpts  []connect.HandlerOption
+	errW         atomic.Pointer[connect.ErrorWriter]
+	markRequests atomic.Bool // whether the middleware marks authenticated requests
+}
+
+// New constructs an Authenticator using the supplied authentication function.
+func New(auth AuthFunc, opts ...Option) *Authenticator {
+	config := newConfig(opts)
+	if config.Impersonation != nil && len(config.AuditSinks) == 0 {
+		panic("connectauth: impersonation requires an audit sink")
+	}
+	a := &Authenticator{
+		auth:        auth,
+		config:      config,
+		handlerOpts: config.HandlerOptions,
+	}
+	a.errW.Store(connect.NewErrorWriter(a.handlerOpts...))
+	a.middleware = &Middleware{a}
+	a.httpMW = &HTTPMiddleware{a}
+	a.interceptor = &Interceptor{a}
+	return a
+}
+
+// HandlerOption returns a single Connect handler option that bundles the
+// supplied options with this Authenticator's interceptor. It also records the
+// options for use by the Authenticator's middleware, so applications using
+// HandlerOption don't need [WithHandlerOptions]. Pass the returned option to
+// every Connect handler:
+//
+//	auth := connectauth.New(authenticate)
+//	opt := auth.HandlerOption(connect.WithCompressMinBytes(1024))
+//	mux.Handle(foov1connect.NewFooServiceHandler(fooService, opt))
+//	mux.Handle(barv1connect.NewBarServiceHandler(barService, opt))
+//	http.ListenAndServe(":8080", auth.Middleware().Wrap(mux))
+//
+// When an application uses both the middleware and the interceptor, the
+// interceptor doesn't re-authenticate requests that the middleware has
+// already authenticated for the same procedure. Requests that the middleware
+// exempted, or that it attributed to a different procedure than the handler
+// serves, are authenticated again. HandlerOption should be called before the
+// Authenticator begins serving requests.
+func (a *Authenticator) HandlerOption(opts ...connect.HandlerOption) connect.HandlerOption {
+	a.mu.Lock()
+	a.handlerOpts = append(a.handlerOpts, opts...)
+	a.errW.Store(connect.NewErrorWriter(a.handlerOpts...))
+	a.mu.Unlock()
+	a.MarkAuthenticated()
+	return connect.WithHandlerOptions(
+		connect.WithInterceptors(a.interceptor),
+		connect.WithHandlerOptions(opts...),
+	)
+}
+
+// Middleware returns HTTP middleware that authenticates requests. See
+// [NewMiddleware] for details.
+func (a *Authenticator) Middleware() *Middleware {
+	return a.middleware
+}
+
+// HTTPMiddleware returns HTTP middleware that authenticates arbitrary HTTP
+// requests. See [NewHTTPMiddleware] for details.
+func (a *Authenticator) HTTPMiddleware() *HTTPMiddleware {
+	return a.httpMW
+}
+
+// Interceptor returns a Connect interceptor that authenticates requests. See
+// [NewInterceptor] for details.
+func (a *Authenticator) Interceptor() *Interceptor {
+	return a.interceptor
+}
+
+// AuthenticateRequest runs the complete authentication pipeline, including
+// exemptions, lockouts, enrichers, and observers, for a request that didn't
+// arrive through [Middleware] or [Interceptor]. It's intended for adapters
+// to other RPC frameworks, like stock gRPC servers: most applications
+// shouldn't need it. On success, it returns a context with the
+// authentication information attached.
+//
+// The Request is copied, and its Procedure and Protocol should be set as
+// they would be for a Connect request: requests without a Protocol are
+// treated as plain HTTP, so exemptions don't apply to them.
+// [WithPprofLabels] and the stream options don't apply: adapters that wrap
+// handlers should use [Authenticator.HandleUnary] or
+// [Authenticator.HandleStream] instead.
+func (a *Authenticator) AuthenticateRequest(ctx context.Context, req *Request) (context.Context, error) {
+	return a.authenticate(ctx, req)
+}
+
+// HandleUnary authenticates a unary request and, if authentication succeeds,
+// calls handle with the authenticated context. It applies everything the
+// [Interceptor] applies to unary requests, including [WithPprofLabels] and
+// [WithStreamExpiry], so adapters to other RPC frameworks behave like the
+// Interceptor. Most applications shouldn't need it.
+//
+// The Request is copied, and its fields should be set as for
+// [Authenticator.AuthenticateRequest]. Any response headers the AuthFunc sets
+// are written to req.ResponseHeader, which the adapter must send.
+func (a *Authenticator) HandleUnary(ctx context.Context, req *Request, handle func(context.Context) error) error {
+	ctx, err := a.authenticate(ctx, req)
+	if err != nil {
+		return err
+	}
+	a.run(ctx, req.Procedure, func(ctx context.Context) {
+		err = endedCause(ctx, handle(ctx))
+	})
+	return err
+}
+
+// HandleStream is like [Authenticator.HandleUnary], but for streaming
+// requests: it also applies [WithStreamLimiter], [WithStreamRevalidation],
+// and [WithStreamLifetime]. If the stream is ended early, HandleStream returns
+// the error that ended it.
+func (a *Authenticator) HandleStream(ctx context.Context, req *Request, handle func(context.Context) error) error {
+	ctx, err := a.authenticate(ctx, req)
+	if err != nil {
+		return err
+	}
+	if limiter := a.config.StreamLimiter; limiter != nil {
+		release, err := limiter.acquire(GetInfo(ctx))
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	if rc := a.config.StreamRevalidation; rc != nil {
+		// Revalidate with the Request the AuthFunc accepted, which
+		// includes the TLS state, host, and body that only the middleware
+		// can see. Exempt streams have no credentials to revalidate.
+		if call, ok := ctx.Value(authenticatedKey).(*authCall); ok && call.auth == a {
+			req := call.req
+			req.ResponseHeader = make(http.Header) // the stream's headers have been sent
+			var stop func()
+			ctx, stop = rc.watch(ctx, a.auth, &req)
+			defer stop()
+		}
+	}
+	if lifetime := a.config.StreamLifetime; lifetime != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = lifetime.bound(ctx, req.Procedure)
+		defer cancel()
+	}
+	a.run(ctx, req.Procedure, func(ctx context.Context) {
+		err = endedCause(ctx, handle(ctx))
+	})
+	return err
+}
+
+// MarkAuthenticated makes the Authenticator's middleware mark the requests it
+// authenticates, so that [Interceptor], [Authenticator.HandleUnary], and
+// [Authenticator.HandleStream] don't authenticate them again.
+// [Authenticator.HandlerOption] calls it automatically; adapters that install
+// their own interceptors should call it instead. Like HandlerOption, it
+// should be called before the Authenticator begins serving requests.
+func (a *Authenticator) MarkAuthenticated() {
+	a.markRequests.Store(true)
+}
+
+// IsExempt reports whether requests for the procedure skip authentication
+// (see [WithExemptProcedures]). It's intended for tests that check which
+// procedures are protected.
+func (a *Authenticator) IsExempt(procedure string) bool {
+	return a.config.isExempt(procedure)
+}
+
+// authCall holds a Request and its Event, so they can be allocated together.
+// Once the AuthFunc has accepted the Request, the authCall marks the context
+// so that interceptors chained after the middleware don't re-authenticate,
+// and so that streams can be revalidated with the fully resolved Request.
+type authCall struct {
+	auth *Authenticator
+	req  Request
+	ev   Event
+}
+
+// authenticate runs the complete authentication pipeline. On success, it
+// returns the context to use for the remainder of the request.
+//
+// The Request is copied, so callers may allocate it on the stack.
+func (a *Authenticator) authenticate(ctx context.Context, template *Request) (context.Context, error) {
+	if prev, ok := ctx.Value(authenticatedKey).(*authCall); ok && prev.auth == a && prev.req.Procedure == template.Procedure {
+		// Our middleware has already authenticated this request. If the
+		// middleware guessed a different procedure from the URL, the request
+		// must be authenticated again for the procedure actually called.
+		return ctx, nil
+	}
+	call := &authCall{auth: a, req: *template}
+	req, ev := &call.req, &call.ev
+	ev.Request, ev.Start = req, time.Now()
+	req.PeerAddr = req.ClientAddr
+	if a.config.TrustedProxies != nil {
+		req.ClientAddr = resolveClientAddr(a.config.TrustedProxies, req.ClientAddr, req.Header)
+	}
+	req.TraceParent = headerValue(req.Header, "Traceparent")
+	req.TraceState = headerValue(req.Header, "Tracestate")
+	if a.config.Baggage {
+		req.Baggage = headerValue(req.Header, "Baggage")
+	}
+	authCtx, err := a.evaluate(ctx, req, ev)
+	ev.Duration = time.Since(ev.Start)
+	ev.Err = err
+	if len(a.config.AuditSinks) > 0 {
+		if auditErr := a.audit(ctx, ev); auditErr != nil && err == nil {
+			authCtx, err = nil, auditErr
+			ev.Err = err
+		}
+	}
+	for _, observe := range a.config.Observers {
+		observe(ctx, ev)
+	}
+	if err == nil {
+		if !ev.Exempt && (a.markRequests.Load() || a.config.StreamRevalidation != nil) {
+			authCtx = context.WithValue(authCtx, authenticatedKey, call)
+		}
+		if id := req.Header.Get(a.config.RequestIDHeader); id != "" {
+			authCtx = context.WithValue(authCtx, requestIDKey, id)
+		}
+		return authCtx, nil
+	}
+	if a.config.DryRun != nil {
+		a.config.DryRun.LogAttrs(
+			ctx,
+			slog.LevelWarn,
+			"connectauth dry run: would reject request",
+			slog.String("procedure", req.Procedure),
+			slog.String("protocol", req.Protocol),
+			slog.String("client_addr", req.ClientAddr),
+			slog.String("error", err.Error()),
+		)
+		return ctx, nil
+	}
+	if a.config.FailureDelay != nil {
+		sleep(ctx, a.config.FailureDelay(req))
+	}
+	if a.config.RedactErrors {
+		return nil, redact(err)
+	}
+	return nil, err
+}
+
+func (a *Authenticator) evaluate(ctx context.Context, req *Request, ev *Event) (context.Context, error) {
+	if err := a.config.checkTLS(req); err != nil {
+		return nil, err
+	}
+	lockdown := a.config.Lockdown.active(req.Procedure)
+	if req.Protocol != "" { // RPC, not plain HTTP
+		if err := a.config.checkProtocol(req.Procedure, req.Protocol); err != nil {
+			return nil, err
+		}
+		if a.config.isExempt(req.Procedure) {
+			if lockdown != nil {
+				return nil, lockdown.err
+			}
+			ev.Exempt = true
+			return ctx, nil
+		}
+	}
+	if a.config.Honeytokens != nil {
+		if err := a.config.Honeytokens.check(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	if a.config.Origins != nil && req.Protocol != "" {
+		if err := a.config.checkOrigin(req); err != nil {
+			return nil, err
+		}
+	}
+	if len(a.config.ReputationCheckers) > 0 {
+		if err := a.config.checkReputation(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	limiter := a.config.FailureLimiter
+	if limiter != nil {
+		if err := limiter.check(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		lockout   = a.config.Lockout
+		principal string
+		history   LockoutState
+	)
+	if lockout != nil {
+		var err error
+		if principal, history, err = lockout.check(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	info, err := a.auth(ctx, req)
+	if lockout != nil {
+		lockout.record(ctx, req, principal, history, err)
+	}
+	if err != nil {
+		if limiter != nil && isDefinitiveFailure(err) {
+			limiter.record(ctx, req)
+		}
+		return nil, err
+	}
+	if lockdown != nil && !lockdown.allows(info) {
+		return nil, lockdown.err
+	}
+	if a.config.CSRF != nil {
+		if err := a.config.CSRF.check(req); err != nil {
+			return nil, err
+		}
+	}
+	if imp := a.config.Impersonation; imp != nil {
+		if subject := headerValue(req.Header, imp.Header); subject != "" {
+			ev.Actor = info
+			if info, err = imp.impersonate(ctx, info, subject); err != nil {
+				return nil, err
+			}
+		}
+	}
+	ev.Info = info
+	ctx = SetInfo(ctx, info)
+	if len(req.Flags) > 0 {
+		ctx = context.WithValue(ctx, flagsKey, req.Flags)
+	}
+	for i := range a.config.Enrichers {
+		ctx, err = a.config.Enrichers[i].run(ctx, req, info)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
 }
 
 // Middleware is server-side HTTP middleware that authenticates RPC requests.
@@ -69,8 +497,7 @@ type Request struct {
 // applications, Middleware is preferable because it defers decompressing and
 // unmarshaling the request until after the caller has been authenticated.
 type Middleware struct {
-	auth AuthFunc
-	errW *connect.ErrorWriter
+	auth *Authenticator
 }
 
 // NewMiddleware constructs HTTP middleware using the supplied authentication
@@ -80,34 +507,102 @@ type Middleware struct {
 //
 // In order to properly identify RPC requests and marshal errors, applications
 // must pass NewMiddleware the same handler options used when constructing
-// Connect handlers.
+// Connect handlers. To configure the middleware with [Option]s, like
+// [WithExemptProcedures], use [New] and [Authenticator.Middleware] instead:
+//
+//	middleware := connectauth.New(
+//		authenticate,
+//		connectauth.WithExemptProcedures("/acme.v1.AuthService/Login"),
+//		connectauth.WithHandlerOptions(handlerOpts...),
+//	).Middleware()
 func NewMiddleware(auth AuthFunc, opts ...connect.HandlerOption) *Middleware {
-	return &Middleware{
-		auth: auth,
-		errW: connect.NewErrorWriter(opts...),
-	}
+	return New(auth, WithHandlerOptions(opts...)).Middleware()
 }
 
 // Wrap decorates an HTTP handler with authentication logic.
 func (m *Middleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !m.errW.IsSupported(r) {
+		if m.auth.config.SkipPreflight && isPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if resolve := m.auth.config.ProcedureResolver; resolve != nil {
+			if procedure, ok := resolve(r); ok && procedure != procedureFromHTTP(r) {
+				m.serveTranscoded(w, r, next, procedure, ProtocolREST)
+				return
+			}
+		}
+		errW := m.auth.errW.Load()
+		if !errW.IsSupported(r) {
+			if resolve := m.auth.config.Gateway; resolve != nil {
+				if procedure, ok := resolve(r); ok {
+					m.serveTranscoded(w, r, next, procedure, ProtocolGateway)
+					return
+				}
+			}
+			if m.auth.config.AuthenticateAll {
+				m.auth.serveHTTP(w, r, next, func(w http.ResponseWriter, err error) {
+					writePlainError(w, err, m.auth.config.HTTPStatus)
+				})
+				return
+			}
 			next.ServeHTTP(w, r)
 			return
 		}
-		ctx := r.Context()
-		info, err := m.auth(ctx, &Request{
-			Procedure:  procedureFromHTTP(r),
-			ClientAddr: r.RemoteAddr,
-			Protocol:   protocolFromHTTP(r),
-			Header:     r.Header,
+		if err := m.auth.config.checkLimits(r); err != nil {
+			errW.Write(w, r, err)
+			return
+		}
+		var body []byte
+		if limit := m.auth.config.BodyLimit; limit > 0 {
+			var err error
+			body, err = bufferBody(r, limit)
+			if err != nil {
+				errW.Write(w, r, err)
+				return
+			}
+		}
+		procedure, protocol := procedureFromHTTP(r), protocolFromHTTP(r)
+		ctx, err := m.auth.authenticate(r.Context(), &Request{
+			Procedure:      procedure,
+			ClientAddr:     r.RemoteAddr,
+			Host:           r.Host,
+			Protocol:       protocol,
+			StreamType:     streamTypeFromHTTP(r, procedure, protocol),
+			Header:         r.Header,
+			Body:           body,
+			TLS:            r.TLS,
+			ResponseHeader: w.Header(),
 		})
 		if err != nil {
-			m.errW.Write(w, r, err)
+			errW.Write(w, r, err)
 			return
 		}
-		if info != nil {
-			r = r.WithContext(SetInfo(ctx, info))
+		m.auth.run(ctx, procedure, func(ctx context.Context) {
+			if ctx != r.Context() {
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// serveHTTP authenticates a non-RPC request.
+func (a *Authenticator) serveHTTP(w http.ResponseWriter, r *http.Request, next http.Handler, writeErr func(http.ResponseWriter, error)) {
+	ctx, err := a.authenticate(r.Context(), &Request{
+		ClientAddr:     r.RemoteAddr,
+		Host:           r.Host,
+		Header:         r.Header,
+		TLS:            r.TLS,
+		ResponseHeader: w.Header(),
+	})
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	a.run(ctx, "", func(ctx context.Context) {
+		if ctx != r.Context() {
+			r = r.WithContext(ctx)
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -130,7 +625,7 @@ func (m *Middleware) Wrap(next http.Handler) http.Handler {
 //
 // Attach interceptors to your RPC handlers using [connect.WithInterceptors].
 type Interceptor struct {
-	auth func(context.Context, *Request) (any, error)
+	auth *Authenticator
 }
 
 // NewInterceptor constructs a Connect interceptor using the supplied
@@ -139,8 +634,8 @@ type Interceptor struct {
 // interceptors and application code may access it with [GetInfo].
 //
 // Most applications should use [Middleware] instead.
-func NewInterceptor(auth AuthFunc) *Interceptor {
-	return &Interceptor{auth}
+func NewInterceptor(auth AuthFunc, opts ...Option) *Interceptor {
+	return New(auth, opts...).Interceptor()
 }
 
 // WrapUnary implements connect.Interceptor.
@@ -148,16 +643,26 @@ func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
 		spec := req.Spec()
 		peer := req.Peer()
-		info, err := i.auth(ctx, &Request{
-			Procedure:  spec.Procedure,
-			ClientAddr: peer.Addr,
-			Protocol:   peer.Protocol,
-			Header:     req.Header(),
+		header := make(http.Header)
+		var res connect.AnyResponse
+		err := i.auth.HandleUnary(ctx, &Request{
+			Procedure:      spec.Procedure,
+			ClientAddr:     peer.Addr,
+			Protocol:       peer.Protocol,
+			StreamType:     spec.StreamType,
+			Header:         req.Header(),
+			ResponseHeader: header,
+		}, func(ctx context.Context) error {
+			var err error
+			res, err = next(ctx, req)
+			return err
 		})
-		if err != nil {
-			return nil, err
+		if res != nil {
+			for k, vals := range header {
+				res.Header()[k] = append(res.Header()[k], vals...)
+			}
 		}
-		return next(SetInfo(ctx, info), req)
+		return res, err
 	}
 }
 
@@ -171,17 +676,86 @@ func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) co
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
 		spec := conn.Spec()
 		peer := conn.Peer()
-		info, err := i.auth(ctx, &Request{
-			Procedure:  spec.Procedure,
-			ClientAddr: peer.Addr,
-			Protocol:   peer.Protocol,
-			Header:     conn.RequestHeader(),
+		return i.auth.HandleStream(ctx, &Request{
+			Procedure:      spec.Procedure,
+			ClientAddr:     peer.Addr,
+			Protocol:       peer.Protocol,
+			StreamType:     spec.StreamType,
+			Header:         conn.RequestHeader(),
+			ResponseHeader: conn.ResponseHeader(),
+		}, func(ctx context.Context) error {
+			return next(ctx, conn)
 		})
-		if err != nil {
-			return err
+	}
+}
+
+// bufferBody reads the request body and replaces it with an in-memory copy.
+func bufferBody(r *http.Request, limit int64) ([]byte, error) {
+	if r.ContentLength > limit {
+		return nil, connect.NewError(
+			connect.CodeResourceExhausted,
+			fmt.Errorf("request body exceeds %d bytes", limit),
+		)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInvalidArgument, fmt.Errorf("read request body: %w", err))
+	}
+	if int64(len(body)) > limit {
+		return nil, connect.NewError(
+			connect.CodeResourceExhausted,
+			fmt.Errorf("request body exceeds %d bytes", limit),
+		)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func writePlainError(w http.ResponseWriter, err error, status func(connect.Code) int) {
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		header := w.Header()
+		for k, vals := range connectErr.Meta() {
+			header[k] = append(header[k], vals...)
 		}
-		return next(SetInfo(ctx, info), conn)
 	}
+	code := status(connect.CodeOf(err))
+	http.Error(w, http.StatusText(code), code)
+}
+
+// httpStatus maps Connect codes to HTTP status codes, following the Connect
+// protocol specification.
+func httpStatus(code connect.Code) int {
+	switch code {
+	case connect.CodeCanceled:
+		return 499
+	case connect.CodeInvalidArgument, connect.CodeOutOfRange:
+		return http.StatusBadRequest
+	case connect.CodeDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case connect.CodeNotFound, connect.CodeUnimplemented:
+		return http.StatusNotFound
+	case connect.CodeAlreadyExists, connect.CodeAborted:
+		return http.StatusConflict
+	case connect.CodePermissionDenied:
+		return http.StatusForbidden
+	case connect.CodeResourceExhausted:
+		return http.StatusTooManyRequests
+	case connect.CodeFailedPrecondition:
+		return http.StatusPreconditionFailed
+	case connect.CodeUnavailable:
+		return http.StatusServiceUnavailable
+	case connect.CodeUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
 }
 
 func procedureFromHTTP(r *http.Request) string {
@@ -201,8 +775,35 @@ func procedureFromHTTP(r *http.Request) string {
 	return procedure
 }
 
+// streamTypeFromHTTP determines an RPC's stream type, preferring the
+// procedure's registered descriptor. If the descriptor isn't registered, only
+// unary Connect requests can be identified.
+func streamTypeFromHTTP(r *http.Request, procedure, protocol string) connect.StreamType {
+	if procedure == "" {
+		return connect.StreamTypeUnary
+	}
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(procedure, "/"), "/", "."))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		if method, ok := desc.(protoreflect.MethodDescriptor); ok {
+			var st connect.StreamType
+			if method.IsStreamingClient() {
+				st |= connect.StreamTypeClient
+			}
+			if method.IsStreamingServer() {
+				st |= connect.StreamTypeServer
+			}
+			return st
+		}
+	}
+	if protocol == connect.ProtocolConnect &&
+		(r.Method == http.MethodGet || !strings.HasPrefix(headerValue(r.Header, "Content-Type"), "application/connect+")) {
+		return connect.StreamTypeUnary
+	}
+	return connect.StreamTypeBidi
+}
+
 func protocolFromHTTP(r *http.Request) string {
-	ct := r.Header.Get("Content-Type")
+	ct := headerValue(r.Header, "Content-Type")
 	switch {
 	case strings.HasPrefix(ct, "application/grpc-web"):
 		return connect.ProtocolGRPCWeb
@@ -212,3 +813,12 @@ func protocolFromHTTP(r *http.Request) string {
 		return connect.ProtocolConnect
 	}
 }
+
+// headerValue is like [http.Header.Get], but skips canonicalizing the key. The
+// key must already be in canonical form.
+func headerValue(h http.Header, key string) string {
+	if vals := h[key]; len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
@@ -4,12 +4,19 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"connectrpc.com/connect"
 	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
 	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -107,7 +114,8 @@ func TestMiddleware(t *testing.T) {
 		}
 		io.WriteString(w, "ok")
 	})
-	srv := memhttptest.New(t, NewMiddleware(authenticate).Wrap(mux))
+	// NewMiddleware accepts Connect handler options.
+	srv := memhttptest.New(t, NewMiddleware(authenticate, connect.WithCompressMinBytes(1024)).Wrap(mux))
 
 	assertResponse := func(headers http.Header, expectCode int) {
 		req, err := http.NewRequest(
@@ -142,3 +150,321 @@ func TestMiddleware(t *testing.T) {
 		http.StatusOK,
 	)
 }
+
+func TestAuthenticator(t *testing.T) {
+	auth := New(authenticate, WithExemptProcedures("/empty.v1/Ping"))
+	attest.True(t, auth.Middleware() == auth.Middleware())
+	attest.True(t, auth.Interceptor() == auth.Interceptor())
+
+	mux := http.NewServeMux()
+	mux.Handle("/empty.v1/Ping", connect.NewUnaryHandler(
+		"/empty.v1/Ping",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		connect.WithInterceptors(auth.Interceptor()),
+	))
+	mux.HandleFunc("/empty.v1/GetEmpty", func(w http.ResponseWriter, r *http.Request) {
+		assertInfo(t, r.Context())
+		io.WriteString(w, "{}")
+	})
+	srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+
+	// Both the middleware and the interceptor respect the shared exemptions.
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/empty.v1/Ping")
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	attest.Ok(t, err)
+
+	attest.Equal(t, callMiddleware(t, srv, "/empty.v1/GetEmpty", nil), http.StatusUnauthorized)
+	attest.Equal(
+		t,
+		callMiddleware(t, srv, "/empty.v1/GetEmpty", http.Header{"Authorization": []string{"Bearer " + passphrase}}),
+		http.StatusOK,
+	)
+}
+
+func TestHandlerOption(t *testing.T) {
+	var calls atomic.Int32
+	auth := New(func(ctx context.Context, r *Request) (any, error) {
+		calls.Add(1)
+		return authenticate(ctx, r)
+	})
+	opt := auth.HandlerOption(connect.WithReadMaxBytes(1024))
+	mux := http.NewServeMux()
+	mux.Handle("/empty.v1/GetEmpty", connect.NewUnaryHandler(
+		"/empty.v1/GetEmpty",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			assertInfo(t, ctx)
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		opt,
+	))
+
+	call := func(srv *memhttp.Server) error {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/empty.v1/GetEmpty")
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("Authorization", "Bearer "+passphrase)
+		_, err := client.CallUnary(context.Background(), req)
+		return err
+	}
+
+	t.Run("interceptor only", func(t *testing.T) {
+		calls.Store(0)
+		attest.Ok(t, call(memhttptest.New(t, mux)))
+		attest.Equal(t, calls.Load(), 1)
+	})
+	t.Run("middleware and interceptor", func(t *testing.T) {
+		calls.Store(0)
+		attest.Ok(t, call(memhttptest.New(t, auth.Middleware().Wrap(mux))))
+		attest.Equal(t, calls.Load(), 1)
+	})
+}
+
+func TestHandlerOptionProcedureMismatch(t *testing.T) {
+	// The middleware guesses procedures from URL paths, so a handler mounted
+	// on an exempt route mustn't inherit the exemption.
+	var calls atomic.Int32
+	auth := New(func(ctx context.Context, r *Request) (any, error) {
+		calls.Add(1)
+		return authenticate(ctx, r)
+	}, WithExemptProcedures("/acme.v1.Public/*"))
+	mux := http.NewServeMux()
+	mux.Handle("/acme.v1.Public/Ping", connect.NewUnaryHandler(
+		"/empty.v1/GetEmpty",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			assertInfo(t, ctx)
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+		auth.HandlerOption(),
+	))
+	srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/acme.v1.Public/Ping")
+
+	_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, calls.Load(), 1)
+
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+passphrase)
+	_, err = client.CallUnary(context.Background(), req)
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 2)
+}
+
+type subjecter string
+
+func (s subjecter) Subject() string { return "subject:" + string(s) }
+
+func TestSubjectOf(t *testing.T) {
+	attest.Equal(t, SubjectOf(hero), hero)
+	attest.Equal(t, SubjectOf(subjecter(hero)), "subject:"+hero)
+	attest.Equal(t, SubjectOf(42), "")
+	attest.Equal(t, SubjectOf(nil), "")
+}
+
+// callMiddleware sends a unary Connect request for the procedure to the
+// server and returns the HTTP status code.
+func callMiddleware(tb testing.TB, srv *memhttp.Server, procedure string, header http.Header) int {
+	tb.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL()+procedure, strings.NewReader("{}"))
+	attest.Ok(tb, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, vals := range header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	res, err := srv.Client().Do(req)
+	attest.Ok(tb, err)
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+	return res.StatusCode
+}
+
+func TestTraceContext(t *testing.T) {
+	const (
+		traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		tracestate  = "congo=t61rcWkgMzE"
+		baggage     = "userId=alice"
+	)
+	header := http.Header{
+		"Authorization": []string{"Bearer " + passphrase},
+		"Traceparent":   []string{traceparent},
+		"Tracestate":    []string{tracestate},
+		"Baggage":       []string{baggage},
+	}
+	for _, withBaggage := range []bool{false, true} {
+		var got *Request
+		capture := func(ctx context.Context, req *Request) (any, error) {
+			got = req
+			return authenticate(ctx, req)
+		}
+		var opts []Option
+		if withBaggage {
+			opts = append(opts, WithBaggage())
+		}
+		_, err := New(capture, opts...).authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolGRPC,
+			Header:    header,
+		})
+		attest.Ok(t, err)
+		attest.Equal(t, got.TraceParent, traceparent)
+		attest.Equal(t, got.TraceState, tracestate)
+		if withBaggage {
+			attest.Equal(t, got.Baggage, baggage)
+		} else {
+			attest.Zero(t, got.Baggage)
+		}
+	}
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	auth := func(_ context.Context, req *Request) (any, error) {
+		if req.Header.Get("Authorization") != "Bearer "+passphrase {
+			return nil, Errorf("wrong passphrase")
+		}
+		return hero, nil
+	}
+	handler := NewMiddleware(auth).Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/acme.foo.v1.FooService/Bar", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/proto")
+	req.Header.Set("Authorization", "Bearer "+passphrase)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(w, req)
+	}
+}
+
+func TestResponseHeader(t *testing.T) {
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		req.ResponseHeader.Add("Set-Cookie", "session=renewed")
+		return authenticate(ctx, req)
+	})
+	handler := func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/empty.v1/Middleware", connect.NewUnaryHandler("/empty.v1/Middleware", handler))
+	mux.Handle("/empty.v1/Interceptor", connect.NewUnaryHandler(
+		"/empty.v1/Interceptor",
+		handler,
+		connect.WithInterceptors(auth.Interceptor()),
+	))
+	srv := memhttptest.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty.v1/Middleware" {
+			auth.Middleware().Wrap(mux).ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	for _, procedure := range []string{"/empty.v1/Middleware", "/empty.v1/Interceptor"} {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("Authorization", "Bearer "+passphrase)
+		res, err := client.CallUnary(context.Background(), req)
+		attest.Ok(t, err, attest.Sprintf(procedure))
+		attest.Equal(t, res.Header().Get("Set-Cookie"), "session=renewed", attest.Sprintf(procedure))
+	}
+}
+
+func TestAuthenticateRequest(t *testing.T) {
+	auth := New(authenticate, WithExemptProcedures("/health.v1.Health/*"))
+	header := http.Header{"Authorization": []string{"Bearer " + passphrase}}
+	ctx, err := auth.AuthenticateRequest(context.Background(), &Request{Procedure: "/empty.v1/Get", Protocol: connect.ProtocolGRPC, Header: header})
+	attest.Ok(t, err)
+	attest.NotZero(t, GetInfo(ctx))
+
+	_, err = auth.AuthenticateRequest(context.Background(), &Request{Procedure: "/empty.v1/Get", Header: http.Header{}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+
+	ctx, err = auth.AuthenticateRequest(context.Background(), &Request{Procedure: "/health.v1.Health/Check", Protocol: connect.ProtocolGRPC})
+	attest.Ok(t, err)
+	attest.Zero(t, GetInfo(ctx))
+}
+
+func TestHandleStream(t *testing.T) {
+	limiter := NewStreamLimiter(StreamLimiterConfig{Max: 1})
+	auth := New(authenticate, WithStreamLimiter(limiter))
+	req := &Request{
+		Procedure:      "/empty.v1/Watch",
+		Protocol:       connect.ProtocolGRPC,
+		StreamType:     connect.StreamTypeServer,
+		Header:         http.Header{"Authorization": []string{"Bearer " + passphrase}},
+		ResponseHeader: http.Header{},
+	}
+	err := auth.HandleStream(context.Background(), req, func(ctx context.Context) error {
+		attest.Equal(t, GetInfo(ctx), any(hero))
+		attest.Equal(t, limiter.Open(hero), 1)
+		return auth.HandleStream(ctx, req, func(context.Context) error {
+			t.Fatal("second stream shouldn't be served")
+			return nil
+		})
+	})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+	attest.Equal(t, limiter.Open(hero), 0)
+
+	err = auth.HandleUnary(context.Background(), &Request{Procedure: "/empty.v1/Get", Protocol: connect.ProtocolGRPC}, func(context.Context) error {
+		t.Fatal("unauthenticated request shouldn't be served")
+		return nil
+	})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+}
+
+func init() {
+	// Register a service, as generated code would.
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("connectauth/registered.proto"),
+		Package:     proto.String("acme.registered.v1"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Svc"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".acme.registered.v1.Empty"),
+				OutputType: proto.String(".acme.registered.v1.Empty"),
+			}, {
+				Name:            proto.String("Watch"),
+				InputType:       proto.String(".acme.registered.v1.Empty"),
+				OutputType:      proto.String(".acme.registered.v1.Empty"),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
+
+func TestStreamTypeFromHTTP(t *testing.T) {
+	request := func(method, contentType string) *http.Request {
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+	tests := []struct {
+		name      string
+		req       *http.Request
+		procedure string
+		protocol  string
+		want      connect.StreamType
+	}{
+		{"registered unary", request(http.MethodPost, "application/grpc"), "/acme.registered.v1.Svc/Get", connect.ProtocolGRPC, connect.StreamTypeUnary},
+		{"registered stream", request(http.MethodPost, "application/grpc"), "/acme.registered.v1.Svc/Watch", connect.ProtocolGRPC, connect.StreamTypeServer},
+		{"connect unary", request(http.MethodPost, "application/proto"), "/acme.foo.v1.FooService/Bar", connect.ProtocolConnect, connect.StreamTypeUnary},
+		{"connect get", request(http.MethodGet, ""), "/acme.foo.v1.FooService/Bar", connect.ProtocolConnect, connect.StreamTypeUnary},
+		{"connect stream", request(http.MethodPost, "application/connect+proto"), "/acme.foo.v1.FooService/Bar", connect.ProtocolConnect, connect.StreamTypeBidi},
+		{"unknown grpc", request(http.MethodPost, "application/grpc"), "/acme.foo.v1.FooService/Bar", connect.ProtocolGRPC, connect.StreamTypeBidi},
+		{"http", request(http.MethodGet, ""), "", "", connect.StreamTypeUnary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attest.Equal(t, streamTypeFromHTTP(tt.req, tt.procedure, tt.protocol), tt.want)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// A BatchResult is the outcome of validating a single credential in a batch.
+type BatchResult struct {
+	Info any
+	Err  error
+}
+
+// BatchConfig configures a [Batcher].
+type BatchConfig struct {
+	// MaxSize is the maximum number of credentials validated at once. The
+	// default is 100.
+	MaxSize int
+	// MaxWait is the longest a request waits for other requests to join its
+	// batch. The default is one millisecond.
+	MaxWait time.Duration
+	// Credential extracts the credential from a request. By default, the
+	// credential is the value of the Authorization header.
+	Credential func(*Request) string
+	// OnBatch, if non-nil, is called before validating each batch with the
+	// number of distinct credentials and the time the oldest request spent
+	// queued. It's typically used to record metrics.
+	OnBatch func(size int, queued time.Duration)
+}
+
+// A Batcher collects concurrent validations into batches, so an upstream
+// service that can validate many credentials in one call (for example, an
+// introspection endpoint with a bulk API) sees fewer, larger requests. It
+// trades a small amount of latency, bounded by [BatchConfig].MaxWait, for
+// upstream efficiency.
+//
+// The validate function receives distinct credentials and must return one
+// result per credential, in the same order. If it returns an error, every
+// request in the batch fails with that error. Batches are validated with the
+// context of their first request, without its cancelation.
+//
+// Batchers are safe to use concurrently. They're often combined with a
+// [TokenCache], so only uncached credentials are batched.
+type Batcher struct {
+	validate   func(context.Context, []string) ([]BatchResult, error)
+	maxSize    int
+	maxWait    time.Duration
+	credential func(*Request) string
+	onBatch    func(int, time.Duration)
+
+	mu      sync.Mutex
+	pending *batch
+}
+
+type batch struct {
+	ctx         context.Context
+	start       time.Time
+	credentials []string
+	index       map[string]int
+	waiters     []batchWaiter
+	timer       *time.Timer
+}
+
+type batchWaiter struct {
+	index  int
+	result chan BatchResult
+}
+
+// NewBatcher constructs a Batcher.
+func NewBatcher(validate func(context.Context, []string) ([]BatchResult, error), config BatchConfig) *Batcher {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 100
+	}
+	if config.MaxWait <= 0 {
+		config.MaxWait = time.Millisecond
+	}
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			return headerValue(req.Header, "Authorization")
+		}
+	}
+	return &Batcher{
+		validate:   validate,
+		maxSize:    config.MaxSize,
+		maxWait:    config.MaxWait,
+		credential: config.Credential,
+		onBatch:    config.OnBatch,
+	}
+}
+
+// Authenticate is an AuthFunc that adds the request's credential to the
+// pending batch and waits for the result.
+func (b *Batcher) Authenticate(ctx context.Context, req *Request) (any, error) {
+	result := make(chan BatchResult, 1)
+	credential := b.credential(req)
+
+	b.mu.Lock()
+	bt := b.pending
+	if bt == nil {
+		bt = &batch{
+			ctx:   context.WithoutCancel(ctx),
+			start: time.Now(),
+			index: make(map[string]int),
+		}
+		b.pending = bt
+		bt.timer = time.AfterFunc(b.maxWait, func() { b.flush(bt) })
+	}
+	idx, ok := bt.index[credential]
+	if !ok {
+		idx = len(bt.credentials)
+		bt.index[credential] = idx
+		bt.credentials = append(bt.credentials, credential)
+	}
+	bt.waiters = append(bt.waiters, batchWaiter{index: idx, result: result})
+	full := len(bt.credentials) >= b.maxSize
+	if full {
+		// Detach the batch before unlocking, so no other request joins it.
+		b.pending = nil
+	}
+	b.mu.Unlock()
+
+	if full {
+		bt.timer.Stop()
+		b.run(bt)
+	}
+	select {
+	case res := <-result:
+		return res.Info, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *Batcher) flush(bt *batch) {
+	b.mu.Lock()
+	if b.pending != bt {
+		// Already flushed.
+		b.mu.Unlock()
+		return
+	}
+	b.pending = nil
+	b.mu.Unlock()
+	b.run(bt)
+}
+
+// run validates a batch that's no longer pending and delivers the results.
+func (b *Batcher) run(bt *batch) {
+	if b.onBatch != nil {
+		b.onBatch(len(bt.credentials), time.Since(bt.start))
+	}
+	results, err := b.validate(bt.ctx, bt.credentials)
+	if err == nil && len(results) != len(bt.credentials) {
+		err = connect.NewError(
+			connect.CodeInternal,
+			fmt.Errorf("batch validation returned %d results for %d credentials", len(results), len(bt.credentials)),
+		)
+	}
+	for _, w := range bt.waiters {
+		if err != nil {
+			w.result <- BatchResult{Err: err}
+			continue
+		}
+		w.result <- results[w.index]
+	}
+}
@@ -0,0 +1,156 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func validateBatch(calls *[][]string, mu *sync.Mutex) func(context.Context, []string) ([]BatchResult, error) {
+	return func(_ context.Context, credentials []string) ([]BatchResult, error) {
+		mu.Lock()
+		*calls = append(*calls, credentials)
+		mu.Unlock()
+		results := make([]BatchResult, len(credentials))
+		for i, c := range credentials {
+			if c == "Bearer "+passphrase {
+				results[i].Info = hero
+			} else {
+				results[i].Err = Errorf("invalid token")
+			}
+		}
+		return results, nil
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		calls   [][]string
+		batches []int
+	)
+	batcher := NewBatcher(validateBatch(&calls, &mu), BatchConfig{
+		MaxSize: 3,
+		MaxWait: time.Hour, // only flush when full
+		OnBatch: func(size int, queued time.Duration) {
+			batches = append(batches, size)
+			attest.True(t, queued >= 0)
+		},
+	})
+	authenticateAll := func(tokens ...string) []BatchResult {
+		var wg sync.WaitGroup
+		results := make([]BatchResult, len(tokens))
+		for i, token := range tokens {
+			wg.Add(1)
+			go func(i int, token string) {
+				defer wg.Done()
+				info, err := batcher.Authenticate(context.Background(), bearer(token))
+				results[i] = BatchResult{Info: info, Err: err}
+			}(i, token)
+		}
+		wg.Wait()
+		return results
+	}
+
+	// Duplicate credentials are validated once, and don't count toward the
+	// batch size.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var results []BatchResult
+	go func() {
+		defer wg.Done()
+		results = authenticateAll(passphrase, "wrong", passphrase)
+	}()
+	waitForWaiters := func(n int) *batch {
+		for {
+			batcher.mu.Lock()
+			bt := batcher.pending
+			if bt != nil && len(bt.waiters) == n {
+				batcher.mu.Unlock()
+				return bt
+			}
+			batcher.mu.Unlock()
+			time.Sleep(time.Millisecond)
+		}
+	}
+	batcher.flush(waitForWaiters(3))
+	wg.Wait()
+	attest.Equal(t, len(calls), 1)
+	attest.Equal(t, len(calls[0]), 2)
+	attest.Equal(t, batches, []int{2})
+	attest.Equal(t, results[0].Info, any(hero))
+	attest.Error(t, results[1].Err)
+	attest.Equal(t, results[2].Info, any(hero))
+
+	// Full batches are flushed immediately.
+	authenticateAll(passphrase, "a", "b")
+	attest.Equal(t, batches, []int{2, 3})
+}
+
+func TestBatcherMaxSize(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [][]string
+	)
+	const size, requests = 4, 200
+	batcher := NewBatcher(validateBatch(&calls, &mu), BatchConfig{
+		MaxSize: size,
+		MaxWait: time.Hour, // only flush when full
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := batcher.Authenticate(context.Background(), bearer(fmt.Sprintf("token-%d", i)))
+			attest.Error(t, err)
+		}(i)
+	}
+	wg.Wait()
+	attest.Equal(t, len(calls), requests/size)
+	for _, credentials := range calls {
+		attest.Equal(t, len(credentials), size)
+	}
+}
+
+func TestBatcherMaxWait(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [][]string
+	)
+	batcher := NewBatcher(validateBatch(&calls, &mu), BatchConfig{MaxWait: time.Millisecond})
+	info, err := batcher.Authenticate(context.Background(), bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	attest.Equal(t, len(calls), 1)
+}
+
+func TestBatcherErrors(t *testing.T) {
+	failing := NewBatcher(func(context.Context, []string) ([]BatchResult, error) {
+		return nil, errors.New("introspection endpoint down")
+	}, BatchConfig{})
+	_, err := failing.Authenticate(context.Background(), bearer(passphrase))
+	attest.Error(t, err)
+	attest.Equal(t, err.Error(), "introspection endpoint down")
+
+	short := NewBatcher(func(context.Context, []string) ([]BatchResult, error) {
+		return nil, nil
+	}, BatchConfig{})
+	_, err = short.Authenticate(context.Background(), bearer(passphrase))
+	attest.Error(t, err)
+	attest.True(t, strings.Contains(err.Error(), "0 results for 1 credentials"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := NewBatcher(func(context.Context, []string) ([]BatchResult, error) {
+		return []BatchResult{{}}, nil
+	}, BatchConfig{MaxWait: time.Hour})
+	_, err = slow.Authenticate(ctx, bearer(passphrase))
+	attest.ErrorIs(t, err, context.Canceled)
+}
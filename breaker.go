@@ -0,0 +1,224 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// A CircuitState is the state of a [CircuitBreaker].
+type CircuitState int
+
+// Circuit states.
+const (
+	CircuitClosed   CircuitState = iota // calls proceed normally
+	CircuitOpen                         // calls fail immediately
+	CircuitHalfOpen                     // a few probe calls test whether the dependency has recovered
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig configures a [CircuitBreaker].
+type CircuitBreakerConfig struct {
+	// Threshold is the number of consecutive failures that opens the
+	// circuit. The default is 5.
+	Threshold int
+	// Cooldown is how long the circuit stays open before allowing probes.
+	// The default is 10 seconds.
+	Cooldown time.Duration
+	// Probes is the number of concurrent calls allowed while the circuit is
+	// half-open. If they all succeed, the circuit closes; if any fails, it
+	// opens again. The default is 1.
+	Probes int
+	// IsFailure reports whether an error indicates that the dependency is
+	// struggling. By default, errors coded [connect.CodeUnavailable] or
+	// [connect.CodeDeadlineExceeded] and context deadline errors are
+	// failures. Definitive authentication failures, like invalid
+	// credentials, show that the dependency is healthy.
+	IsFailure func(error) bool
+	// OnStateChange, if non-nil, is called whenever the circuit changes
+	// state. It's called with the breaker's lock held, so it must be fast
+	// and must not call the breaker.
+	OnStateChange func(from, to CircuitState)
+	// Clock times the cooldown. The default is [SystemClock].
+	Clock Clock
+}
+
+// A CircuitBreaker protects requests from a struggling dependency, like an
+// identity provider's introspection endpoint, Kubernetes' TokenReview API,
+// OPA, or SpiceDB. After repeated failures, the circuit opens and calls fail
+// immediately with [connect.CodeUnavailable] and a retry delay, rather than
+// consuming each request's latency budget waiting for timeouts. After a
+// cooldown, a few probe calls test whether the dependency has recovered.
+//
+// Wrap an AuthFunc to protect all its remote calls, or use Do to protect a
+// single call inside an AuthFunc. Combine a CircuitBreaker with an
+// [OutagePolicy] to serve stale results while the circuit is open.
+//
+// CircuitBreakers are safe to use concurrently.
+type CircuitBreaker struct {
+	threshold     int
+	cooldown      time.Duration
+	probes        int
+	isFailure     func(error) bool
+	onStateChange func(from, to CircuitState)
+	now           func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the circuit last opened
+	inflight int       // probes in flight while half-open
+	passed   int       // successful probes while half-open
+	epoch    uint64    // incremented on every state change
+}
+
+// NewCircuitBreaker constructs a closed CircuitBreaker.
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 10 * time.Second
+	}
+	if config.Probes <= 0 {
+		config.Probes = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isOutage
+	}
+	return &CircuitBreaker{
+		threshold:     config.Threshold,
+		cooldown:      config.Cooldown,
+		probes:        config.Probes,
+		isFailure:     config.IsFailure,
+		onStateChange: config.OnStateChange,
+		now:           nowFunc(config.Clock),
+	}
+}
+
+// Wrap protects an AuthFunc with the circuit breaker.
+func (b *CircuitBreaker) Wrap(auth AuthFunc) AuthFunc {
+	return func(ctx context.Context, req *Request) (any, error) {
+		var info any
+		err := b.Do(ctx, func(ctx context.Context) error {
+			var err error
+			info, err = auth(ctx, req)
+			return err
+		})
+		return info, err
+	}
+}
+
+// Do calls f if the circuit allows it, and records the result. If the
+// circuit is open, Do returns an error without calling f.
+func (b *CircuitBreaker) Do(ctx context.Context, f func(context.Context) error) error {
+	epoch, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = f(ctx)
+	// The caller giving up says nothing about the dependency's health.
+	canceled := errors.Is(err, context.Canceled) && ctx.Err() != nil
+	b.record(epoch, err != nil && !canceled && b.isFailure(err), canceled)
+	return err
+}
+
+// State returns the circuit's current state.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && !b.now().Before(b.openedAt.Add(b.cooldown)) {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns the epoch in which the call was allowed.
+func (b *CircuitBreaker) allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen {
+		wait := b.openedAt.Add(b.cooldown).Sub(b.now())
+		if wait > 0 {
+			return 0, newRetryError(
+				connect.CodeUnavailable,
+				wait,
+				&reasonError{reason: ReasonUpstreamUnavailable, err: errors.New("circuit breaker open")},
+			)
+		}
+		b.transition(CircuitHalfOpen)
+	}
+	if b.state == CircuitHalfOpen {
+		if b.inflight+b.passed >= b.probes {
+			return 0, newRetryError(
+				connect.CodeUnavailable,
+				0,
+				&reasonError{reason: ReasonUpstreamUnavailable, err: errors.New("circuit breaker half-open")},
+			)
+		}
+		b.inflight++
+	}
+	return b.epoch, nil
+}
+
+func (b *CircuitBreaker) record(epoch uint64, failed, canceled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if epoch != b.epoch {
+		return // the circuit changed state while the call was in flight
+	}
+	if b.state == CircuitHalfOpen {
+		b.inflight--
+		switch {
+		case failed:
+			b.open()
+		case !canceled:
+			b.passed++
+			if b.passed >= b.probes {
+				b.transition(CircuitClosed)
+			}
+		}
+		return
+	}
+	if canceled {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.transition(CircuitOpen)
+}
+
+func (b *CircuitBreaker) transition(to CircuitState) {
+	from := b.state
+	b.state = to
+	b.failures, b.inflight, b.passed = 0, 0, 0
+	b.epoch++
+	if b.onStateChange != nil && from != to {
+		b.onStateChange(from, to)
+	}
+}
@@ -0,0 +1,106 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	var transitions []string
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{
+		Threshold: 2,
+		Cooldown:  time.Second,
+		Probes:    2,
+		OnStateChange: func(from, to CircuitState) {
+			transitions = append(transitions, from.String()+"->"+to.String())
+		},
+		Clock: clock,
+	})
+	unavailable := connect.NewError(connect.CodeUnavailable, errors.New("oh no"))
+	var calls int
+	call := func(err error) error {
+		return breaker.Do(ctx, func(context.Context) error {
+			calls++
+			return err
+		})
+	}
+
+	// Definitive failures and successes don't open the circuit.
+	attest.Error(t, call(unavailable))
+	attest.Error(t, call(Errorf("bad token")))
+	attest.Error(t, call(unavailable))
+	attest.Ok(t, call(nil))
+	attest.Equal(t, breaker.State(), CircuitClosed)
+
+	attest.Error(t, call(unavailable))
+	attest.Error(t, call(unavailable))
+	attest.Equal(t, breaker.State(), CircuitOpen)
+	calls = 0
+	err := call(nil)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	attest.Equal(t, ReasonOf(err), ReasonUpstreamUnavailable)
+	delay, ok := RetryDelay(err)
+	attest.True(t, ok)
+	attest.Equal(t, delay, time.Second)
+	attest.Zero(t, calls)
+
+	// A failed probe reopens the circuit.
+	clock.Advance(time.Second)
+	attest.Equal(t, breaker.State(), CircuitHalfOpen)
+	attest.Error(t, call(unavailable))
+	attest.Equal(t, breaker.State(), CircuitOpen)
+
+	// Probes are limited, and the circuit closes once they all succeed.
+	clock.Advance(time.Second)
+	var probes []chan struct{}
+	results := make(chan error, 3)
+	for i := 0; i < 2; i++ {
+		release := make(chan struct{})
+		started := make(chan struct{})
+		probes = append(probes, release)
+		go func() {
+			results <- breaker.Do(ctx, func(context.Context) error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+	}
+	err = call(nil)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	for _, release := range probes {
+		close(release)
+		attest.Ok(t, <-results)
+	}
+	attest.Equal(t, breaker.State(), CircuitClosed)
+	attest.Equal(t, transitions, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	})
+}
+
+func TestCircuitBreakerWrap(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{Threshold: 1})
+	auth := breaker.Wrap(authenticate)
+	info, err := auth(context.Background(), bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, info, hero)
+
+	// Callers giving up don't count as failures.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = breaker.Do(ctx, func(ctx context.Context) error { return ctx.Err() })
+	attest.ErrorIs(t, err, context.Canceled)
+	attest.Equal(t, breaker.State(), CircuitClosed)
+}
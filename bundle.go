@@ -0,0 +1,227 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Bundle is a versioned policy or configuration document, like a set of
+// authorization rules or a list of trusted issuers. Bundles are signed with a
+// [Keyring] (see [SignBundle]), so servers only run policies produced by a
+// trusted release process, and carry version metadata, so operators can tell
+// which policy is active and roll back a bad one (see [VersionedPolicy]).
+type Bundle struct {
+	// Version identifies the bundle. It's required, and should be unique: a
+	// release number, a commit hash, or a timestamp all work well.
+	Version string `json:"version"`
+	// Sequence orders bundles. It's required, and must increase with each
+	// release: [VersionedPolicy] refuses to install a bundle that isn't newer
+	// than every bundle it has installed, so an attacker who can replace the
+	// file can't downgrade to an older, validly signed policy.
+	Sequence uint64 `json:"sequence"`
+	// Created is when the bundle was built.
+	Created time.Time `json:"created"`
+	// Payload is the policy or configuration itself, in any format.
+	Payload []byte `json:"payload"`
+}
+
+// SignBundle encodes a bundle and signs it with the keyring's current key,
+// producing a document suitable for distributing as a file. The payload
+// isn't encrypted. SignBundle returns an error if the bundle doesn't have a
+// version or sequence number.
+func SignBundle(keyring *Keyring, bundle *Bundle) ([]byte, error) {
+	if bundle.Version == "" {
+		return nil, errors.New("bundle has no version")
+	}
+	if bundle.Sequence == 0 {
+		return nil, errors.New("bundle has no sequence number")
+	}
+	encoded, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(keyring.SignValue(encoded)), nil
+}
+
+// VerifyBundle verifies a document produced by SignBundle with any key in the
+// keyring, and decodes the bundle. Leading and trailing whitespace, like a
+// newline added by an editor, is ignored.
+func VerifyBundle(keyring *Keyring, signed []byte) (*Bundle, error) {
+	encoded, err := keyring.VerifyValue(string(bytes.TrimSpace(signed)))
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(encoded, &bundle); err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if bundle.Version == "" {
+		return nil, errors.New("bundle has no version")
+	}
+	return &bundle, nil
+}
+
+// A VersionedPolicy holds a policy parsed from a signed [Bundle], along with
+// the version it replaced, so a bad policy can be rolled back with a single
+// call. It's designed to be loaded by a [Reloader]:
+//
+//	policies := connectauth.NewVersionedPolicy(keyring, parsePolicy)
+//	reloader, err := connectauth.NewReloader(ctx, connectauth.ReloaderConfig[*connectauth.Bundle]{
+//		Load: func(ctx context.Context) (*connectauth.Bundle, error) {
+//			signed, err := os.ReadFile("/etc/acme/policy.bundle")
+//			if err != nil {
+//				return nil, err
+//			}
+//			return policies.Install(ctx, signed)
+//		},
+//		Files: []string{"/etc/acme/policy.bundle"},
+//	})
+//	// later, when the new policy causes an outage...
+//	restored, err := policies.Rollback()
+//
+// Install only accepts bundles with a higher [Bundle.Sequence] than any it
+// has installed before, so neither an older bundle nor one that has been
+// rolled back can be installed again: reloading an unchanged file doesn't
+// reinstate a bad policy, so ship the fix as a new bundle. This floor is kept
+// in memory. To enforce it across restarts, persist [VersionedPolicy.Floor]
+// after each Install and restore it with [VersionedPolicy.SetFloor] before
+// installing the first bundle.
+//
+// VersionedPolicies are safe to use concurrently, and requests in flight
+// finish with the policy they started with.
+type VersionedPolicy[T any] struct {
+	keyring *Keyring
+	parse   func(context.Context, *Bundle) (T, error)
+	state   atomic.Pointer[policyState[T]]
+
+	mu    sync.Mutex // serializes updates
+	floor uint64     // highest sequence installed
+}
+
+type policyState[T any] struct {
+	current, previous *policyVersion[T]
+}
+
+type policyVersion[T any] struct {
+	bundle *Bundle
+	value  T
+}
+
+// NewVersionedPolicy constructs a VersionedPolicy. Bundles are verified with
+// the keyring, then converted to policies with the parse function, which
+// should validate them thoroughly: whatever it returns without an error
+// becomes active immediately. It panics if either argument is nil.
+func NewVersionedPolicy[T any](keyring *Keyring, parse func(context.Context, *Bundle) (T, error)) *VersionedPolicy[T] {
+	if keyring == nil {
+		panic("connectauth: VersionedPolicy requires a Keyring")
+	}
+	if parse == nil {
+		panic("connectauth: VersionedPolicy requires a parse function")
+	}
+	p := &VersionedPolicy[T]{
+		keyring: keyring,
+		parse:   parse,
+	}
+	p.state.Store(&policyState[T]{})
+	return p
+}
+
+// Load returns the active policy. Until a bundle is installed, it returns the
+// zero value.
+func (p *VersionedPolicy[T]) Load() T {
+	if current := p.state.Load().current; current != nil {
+		return current.value
+	}
+	var zero T
+	return zero
+}
+
+// Current returns the active bundle, or nil if none has been installed.
+// Callers mustn't modify it.
+func (p *VersionedPolicy[T]) Current() *Bundle {
+	if current := p.state.Load().current; current != nil {
+		return current.bundle
+	}
+	return nil
+}
+
+// Previous returns the bundle that Rollback would restore, or nil if there
+// isn't one. Callers mustn't modify it.
+func (p *VersionedPolicy[T]) Previous() *Bundle {
+	if previous := p.state.Load().previous; previous != nil {
+		return previous.bundle
+	}
+	return nil
+}
+
+// Floor returns the highest sequence number installed so far. Install
+// rejects bundles whose sequence number isn't greater.
+func (p *VersionedPolicy[T]) Floor() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.floor
+}
+
+// SetFloor raises the floor, typically to a value persisted from
+// [VersionedPolicy.Floor] before a restart. It never lowers the floor.
+func (p *VersionedPolicy[T]) SetFloor(floor uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if floor > p.floor {
+		p.floor = floor
+	}
+}
+
+// Install verifies and parses a signed bundle, then makes it the active
+// policy and keeps the policy it replaces for Rollback. Installing the active
+// version again does nothing, so the previous version is retained. Other
+// bundles must have a sequence number above the floor (see
+// [VersionedPolicy.Floor]). If Install returns an error, the active policy is
+// unchanged.
+func (p *VersionedPolicy[T]) Install(ctx context.Context, signed []byte) (*Bundle, error) {
+	bundle, err := VerifyBundle(p.keyring, signed)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.state.Load()
+	if current := state.current; current != nil &&
+		current.bundle.Version == bundle.Version && current.bundle.Sequence == bundle.Sequence {
+		return current.bundle, nil
+	}
+	if bundle.Sequence <= p.floor {
+		return nil, fmt.Errorf("bundle version %q: sequence %d isn't above %d", bundle.Version, bundle.Sequence, p.floor)
+	}
+	value, err := p.parse(ctx, bundle)
+	if err != nil {
+		return nil, fmt.Errorf("bundle version %q: %w", bundle.Version, err)
+	}
+	p.floor = bundle.Sequence
+	p.state.Store(&policyState[T]{
+		current:  &policyVersion[T]{bundle: bundle, value: value},
+		previous: state.current,
+	})
+	return bundle, nil
+}
+
+// Rollback reactivates the previous policy and returns its bundle. The floor
+// isn't lowered, so the rolled-back bundle can't be installed again. Only one
+// version is retained, so rolling back twice in a row fails until another
+// bundle is installed.
+func (p *VersionedPolicy[T]) Rollback() (*Bundle, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state := p.state.Load()
+	if state.previous == nil {
+		return nil, errors.New("no previous bundle to roll back to")
+	}
+	p.state.Store(&policyState[T]{current: state.previous})
+	return state.previous.bundle, nil
+}
@@ -0,0 +1,119 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestBundle(t *testing.T) {
+	keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("a"), 32)})
+	attest.Ok(t, err)
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	signed, err := SignBundle(keyring, &Bundle{Version: "v1", Sequence: 1, Created: created, Payload: []byte(`{"allow":["alice"]}`)})
+	attest.Ok(t, err)
+
+	bundle, err := VerifyBundle(keyring, append(signed, '\n'))
+	attest.Ok(t, err)
+	attest.Equal(t, bundle, &Bundle{Version: "v1", Sequence: 1, Created: created, Payload: []byte(`{"allow":["alice"]}`)})
+
+	other, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("b"), 32)})
+	attest.Ok(t, err)
+	_, err = VerifyBundle(other, signed)
+	attest.Error(t, err)
+	_, err = VerifyBundle(keyring, []byte(keyring.SignValue([]byte(`{"payload":"e30="}`))))
+	attest.Error(t, err) // no version
+	_, err = SignBundle(keyring, &Bundle{})
+	attest.Error(t, err)
+	_, err = SignBundle(keyring, &Bundle{Version: "v1"})
+	attest.Error(t, err) // no sequence
+}
+
+func TestVersionedPolicy(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("a"), 32)})
+	attest.Ok(t, err)
+	sign := func(version string, sequence uint64, payload string) []byte {
+		signed, err := SignBundle(keyring, &Bundle{Version: version, Sequence: sequence, Payload: []byte(payload)})
+		attest.Ok(t, err)
+		return signed
+	}
+	policy := NewVersionedPolicy(keyring, func(_ context.Context, b *Bundle) ([]string, error) {
+		if len(b.Payload) == 0 {
+			return nil, errors.New("empty policy")
+		}
+		return strings.Split(string(b.Payload), ","), nil
+	})
+	attest.Zero(t, policy.Load())
+	attest.Zero(t, policy.Current())
+	_, err = policy.Rollback()
+	attest.Error(t, err)
+
+	installed, err := policy.Install(ctx, sign("v1", 1, "alice"))
+	attest.Ok(t, err)
+	attest.Equal(t, installed.Version, "v1")
+	attest.Equal(t, policy.Load(), []string{"alice"})
+	attest.Zero(t, policy.Previous())
+
+	_, err = policy.Install(ctx, sign("v2", 2, "alice,bob"))
+	attest.Ok(t, err)
+	attest.Equal(t, policy.Floor(), uint64(2))
+	attest.Equal(t, policy.Load(), []string{"alice", "bob"})
+	attest.Equal(t, policy.Current().Version, "v2")
+	attest.Equal(t, policy.Previous().Version, "v1")
+
+	// Reinstalling the active version keeps the previous one.
+	_, err = policy.Install(ctx, sign("v2", 2, "alice,bob"))
+	attest.Ok(t, err)
+	attest.Equal(t, policy.Previous().Version, "v1")
+
+	// Invalid bundles leave the active policy unchanged.
+	_, err = policy.Install(ctx, sign("v3", 3, ""))
+	attest.Error(t, err)
+	attest.Equal(t, policy.Floor(), uint64(2))
+	// Older bundles can't be installed, even if they're validly signed.
+	_, err = policy.Install(ctx, sign("v1", 1, "alice"))
+	attest.Error(t, err)
+	_, err = policy.Install(ctx, []byte("2024.garbage.garbage"))
+	attest.Error(t, err)
+	attest.Equal(t, policy.Current().Version, "v2")
+
+	restored, err := policy.Rollback()
+	attest.Ok(t, err)
+	attest.Equal(t, restored.Version, "v1")
+	attest.Equal(t, policy.Load(), []string{"alice"})
+	attest.Zero(t, policy.Previous())
+	_, err = policy.Rollback()
+	attest.Error(t, err)
+
+	// Rolled-back versions can't be reinstated, but new versions can be
+	// installed.
+	_, err = policy.Install(ctx, sign("v2", 2, "alice,bob"))
+	attest.Error(t, err)
+	attest.Equal(t, policy.Current().Version, "v1")
+	_, err = policy.Install(ctx, sign("v1", 1, "alice"))
+	attest.Ok(t, err) // active version
+	_, err = policy.Install(ctx, sign("v4", 4, "alice,carol"))
+	attest.Ok(t, err)
+	attest.Equal(t, policy.Load(), []string{"alice", "carol"})
+	attest.Equal(t, policy.Previous().Version, "v1")
+
+	// A restored floor survives restarts.
+	restarted := NewVersionedPolicy(keyring, func(_ context.Context, b *Bundle) (string, error) {
+		return string(b.Payload), nil
+	})
+	restarted.SetFloor(policy.Floor())
+	restarted.SetFloor(1) // never lowered
+	attest.Equal(t, restarted.Floor(), uint64(4))
+	_, err = restarted.Install(ctx, sign("v2", 2, "alice,bob"))
+	attest.Error(t, err)
+	_, err = restarted.Install(ctx, sign("v5", 5, "alice"))
+	attest.Ok(t, err)
+
+	attest.Panics(t, func() { NewVersionedPolicy[string](nil, nil) })
+}
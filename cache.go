@@ -0,0 +1,331 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"golang.org/x/sync/singleflight"
+)
+
+// A Cache stores authentication results. [TokenCache] uses a [ShardedCache]
+// by default, but applications running many instances can implement Cache
+// with Redis, memcached, or another shared store so that all instances
+// benefit from each validation.
+//
+// Keys are hex-encoded SHA-256 hashes, safe for use in any store. Get may
+// return expired entries, since callers always check Expires; shared stores
+// should use Expires to set a TTL. Implementations that serialize entries
+// must be able to round-trip the Info type, and may store an Err as its code
+// and message (see [connect.NewError]). Caches are best-effort, so
+// implementations should treat failures as misses.
+//
+// Implementations must be safe to call concurrently.
+type Cache interface {
+	Get(ctx context.Context, key string) (*CacheEntry, bool)
+	Set(ctx context.Context, key string, entry *CacheEntry)
+	Delete(ctx context.Context, key string)
+}
+
+// A CacheEntry is a cached authentication result. Entries are immutable once
+// stored.
+type CacheEntry struct {
+	Info    any
+	Err     error // for cached failures
+	Expires time.Time
+}
+
+// TokenCacheConfig configures a [TokenCache].
+type TokenCacheConfig struct {
+	// Store holds cached results. The default is a [ShardedCache] of the
+	// configured Size.
+	Store Cache
+	// Size is the maximum number of cached credentials in the default store.
+	// It's ignored if Store is set. The default is 10,000.
+	Size int
+	// TTL is the maximum time a successful validation is cached. The default
+	// is one minute.
+	TTL time.Duration
+	// NegativeTTL is the time a failed validation is cached, so a
+	// misconfigured client repeatedly sending the same bad credential doesn't
+	// trigger a remote lookup or expensive cryptography on every request.
+	// Only errors coded [connect.CodeUnauthenticated] or
+	// [connect.CodePermissionDenied] are cached: transient failures, like an
+	// unavailable introspection endpoint, are always retried. Keep
+	// NegativeTTL short, since a cached failure can't be fixed by the client.
+	// The default is zero, which disables negative caching.
+	NegativeTTL time.Duration
+	// RefreshAhead, if positive, revalidates credentials in the background
+	// when they're used within RefreshAhead of their entry's expiry. The
+	// request that triggers the refresh is served from the cache, so
+	// frequently used credentials never expire under load and tail latency
+	// doesn't spike when an entry lapses. Entries limited by the
+	// credential's own expiry aren't refreshed. If revalidation fails with
+	// a definitive error (for example, because the token was revoked), the
+	// entry is removed.
+	RefreshAhead time.Duration
+	// Credential extracts the credential from a request. Requests with an
+	// empty credential bypass the cache. By default, the credential is the
+	// value of the Authorization header.
+	Credential func(*Request) string
+	// Expiry returns the time at which the authentication information for a
+	// credential expires; entries are never cached beyond it. A zero time
+	// means that the information doesn't expire. By default, Expiry uses the
+	// information's Expiry method, if it has one:
+	//
+	//	Expiry() time.Time
+	Expiry func(info any) time.Time
+	// Clock determines when cached entries expire. The default is
+	// [SystemClock].
+	Clock Clock
+}
+
+// A TokenCache wraps an AuthFunc and caches its results, keyed by a SHA-256
+// hash of the request's credential. Repeated requests with the same bearer
+// token skip signature verification, remote introspection, and other
+// expensive validation.
+//
+// Concurrent requests presenting the same uncached credential share a single
+// call to the wrapped AuthFunc, so a burst of requests with a new token
+// triggers only one remote lookup. The shared call uses the context and a
+// copy of the [Request] of the first caller, but isn't canceled if that caller
+// gives up; AuthFuncs making remote calls should apply their own timeouts.
+// The copy has no Body, and response headers set by the wrapped AuthFunc are
+// copied back only if the first caller is still waiting for the result.
+//
+// By default, only successful validations are cached. Set
+// [TokenCacheConfig].NegativeTTL to also cache failures.
+//
+// Cached authentication information is shared by all requests presenting the
+// same credential, so it must not be modified. Because the cache key is only
+// the credential, the wrapped AuthFunc's result must not depend on other
+// properties of the request (like the procedure): wrap the function that
+// validates credentials, not a [Router].
+//
+// TokenCaches are safe to use concurrently.
+type TokenCache struct {
+	auth       AuthFunc
+	store      Cache
+	ttl        time.Duration
+	negTTL     time.Duration
+	refresh    time.Duration
+	credential func(*Request) string
+	expiry     func(any) time.Time
+	now        func() time.Time
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// TokenCacheStats describes a [TokenCache]'s effectiveness.
+type TokenCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"` // -1 if the store can't count its entries
+}
+
+// NewTokenCache constructs a TokenCache.
+func NewTokenCache(auth AuthFunc, config TokenCacheConfig) *TokenCache {
+	if config.Store == nil {
+		config.Store = NewShardedCache(config.Size)
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			return headerValue(req.Header, "Authorization")
+		}
+	}
+	if config.Expiry == nil {
+		config.Expiry = expiryOf
+	}
+	return &TokenCache{
+		auth:       auth,
+		store:      config.Store,
+		ttl:        config.TTL,
+		negTTL:     config.NegativeTTL,
+		refresh:    config.RefreshAhead,
+		credential: config.Credential,
+		expiry:     config.Expiry,
+		now:        nowFunc(config.Clock),
+	}
+}
+
+// Authenticate is an AuthFunc that returns cached authentication information
+// if possible, and otherwise calls the wrapped AuthFunc.
+func (c *TokenCache) Authenticate(ctx context.Context, req *Request) (any, error) {
+	credential := c.credential(req)
+	if credential == "" {
+		return c.auth(ctx, req)
+	}
+	key := cacheKey(credential)
+	if entry, ok := c.store.Get(ctx, key); ok {
+		if now := c.now(); now.Before(entry.Expires) {
+			c.hits.Add(1)
+			if c.shouldRefresh(entry, now) {
+				// The result channel is buffered, so there's no need to read it.
+				c.group.DoChan(key, c.validator(ctx, key, req, make(http.Header)))
+			}
+			return entry.Info, entry.Err
+		}
+		c.store.Delete(ctx, key)
+	}
+	c.misses.Add(1)
+	return c.wait(ctx, key, req)
+}
+
+// Revalidate is an AuthFunc that always calls the wrapped AuthFunc, bypassing
+// any cached result, and then updates the cache. It's designed for
+// [StreamRevalidationConfig].
+func (c *TokenCache) Revalidate(ctx context.Context, req *Request) (any, error) {
+	credential := c.credential(req)
+	if credential == "" {
+		return c.auth(ctx, req)
+	}
+	return c.wait(ctx, cacheKey(credential), req)
+}
+
+// Invalidate removes a credential from the cache. Call it when a credential
+// is revoked.
+func (c *TokenCache) Invalidate(ctx context.Context, credential string) {
+	c.store.Delete(ctx, cacheKey(credential))
+}
+
+// Stats reports the number of cache hits and misses since the cache was
+// constructed, and the number of entries if the store has a Len method, like
+// [ShardedCache]. Requests without a credential aren't counted.
+func (c *TokenCache) Stats() TokenCacheStats {
+	stats := TokenCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: -1,
+	}
+	if counter, ok := c.store.(interface{ Len() int }); ok {
+		stats.Entries = counter.Len()
+	}
+	return stats
+}
+
+// Purge removes every entry from the cache, forcing all credentials to be
+// revalidated. It returns an error if the store doesn't have a Purge method,
+// like [ShardedCache]'s.
+func (c *TokenCache) Purge() error {
+	purger, ok := c.store.(interface{ Purge() })
+	if !ok {
+		return fmt.Errorf("%T can't be purged", c.store)
+	}
+	purger.Purge()
+	return nil
+}
+
+// wait validates the request's credential, sharing the validation with
+// concurrent callers, and waits for the result. If this caller's validation is
+// the one that runs, the response headers it sets are copied to the request.
+func (c *TokenCache) wait(ctx context.Context, key string, req *Request) (any, error) {
+	header := make(http.Header)
+	validate := c.validator(ctx, key, req, header)
+	var ran bool
+	results := c.group.DoChan(key, func() (any, error) {
+		ran = true
+		return validate()
+	})
+	select {
+	case res := <-results:
+		// The validation has finished, so its header is safe to read.
+		if ran && req.ResponseHeader != nil {
+			for k, vals := range header {
+				req.ResponseHeader[k] = append(req.ResponseHeader[k], vals...)
+			}
+		}
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// validator returns a function that validates the request's credential and
+// updates the cache. The function may outlive the request, so it validates a
+// copy that writes response headers to header.
+func (c *TokenCache) validator(ctx context.Context, key string, req *Request, header http.Header) func() (any, error) {
+	// The shared validation must outlive any single caller.
+	ctx = context.WithoutCancel(ctx)
+	shared := *req
+	shared.Header = req.Header.Clone()
+	shared.Body = nil
+	shared.Flags = slices.Clone(req.Flags)
+	shared.ResponseHeader = header
+	return func() (any, error) {
+		info, err := c.auth(ctx, &shared)
+		if err != nil {
+			if isDefinitiveFailure(err) {
+				if c.negTTL > 0 {
+					c.store.Set(ctx, key, &CacheEntry{Err: err, Expires: c.now().Add(c.negTTL)})
+				} else {
+					c.store.Delete(ctx, key) // the credential may have been revoked
+				}
+			}
+			return nil, err
+		}
+		c.set(ctx, key, info)
+		return info, nil
+	}
+}
+
+func (c *TokenCache) shouldRefresh(entry *CacheEntry, now time.Time) bool {
+	if c.refresh <= 0 || entry.Err != nil || now.Before(entry.Expires.Add(-c.refresh)) {
+		return false
+	}
+	// Revalidating can't extend entries limited by the credential's expiry.
+	exp := c.expiry(entry.Info)
+	return exp.IsZero() || exp.After(entry.Expires)
+}
+
+func (c *TokenCache) set(ctx context.Context, key string, info any) {
+	now := c.now()
+	expires := now.Add(c.ttl)
+	if exp := c.expiry(info); !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	if !now.Before(expires) {
+		return
+	}
+	c.store.Set(ctx, key, &CacheEntry{Info: info, Expires: expires})
+}
+
+func cacheKey(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// isDefinitiveFailure reports whether an error means that the credential is
+// invalid, rather than that validation failed.
+func isDefinitiveFailure(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnauthenticated, connect.CodePermissionDenied:
+		return true
+	default:
+		return false
+	}
+}
+
+func expiryOf(info any) time.Time {
+	switch i := info.(type) {
+	case *Identity:
+		if i != nil {
+			return i.Expiry
+		}
+	case Identity:
+		return i.Expiry
+	case interface{ Expiry() time.Time }:
+		return i.Expiry()
+	}
+	return time.Time{}
+}
@@ -0,0 +1,325 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+type expiringInfo struct {
+	name    string
+	expires time.Time
+}
+
+func (i expiringInfo) Expiry() time.Time { return i.expires }
+
+// testClock is a manually advanced clock, safe for concurrent use.
+type testClock struct {
+	nanos atomic.Int64
+}
+
+func newTestClock() *testClock {
+	var c testClock
+	c.nanos.Store(time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC).UnixNano())
+	return &c
+}
+
+func (c *testClock) Now() time.Time          { return time.Unix(0, c.nanos.Load()).UTC() }
+func (c *testClock) Advance(d time.Duration) { c.nanos.Add(int64(d)) }
+
+func newTestCache(config TokenCacheConfig, auth AuthFunc) (*TokenCache, *testClock, *atomic.Int64) {
+	var calls atomic.Int64
+	clock := newTestClock()
+	config.Clock = clock
+	cache := NewTokenCache(func(ctx context.Context, req *Request) (any, error) {
+		calls.Add(1)
+		return auth(ctx, req)
+	}, config)
+	return cache, clock, &calls
+}
+
+func bearer(token string) *Request {
+	return &Request{Header: http.Header{"Authorization": []string{"Bearer " + token}}}
+}
+
+func TestTokenCache(t *testing.T) {
+	ctx := context.Background()
+	cache, now, calls := newTestCache(TokenCacheConfig{TTL: time.Minute}, authenticate)
+
+	for i := 0; i < 3; i++ {
+		info, err := cache.Authenticate(ctx, bearer(passphrase))
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(hero))
+	}
+	attest.Equal(t, calls.Load(), 1)
+
+	// Failures aren't cached.
+	for i := 0; i < 2; i++ {
+		_, err := cache.Authenticate(ctx, bearer("wrong"))
+		attest.Error(t, err)
+	}
+	attest.Equal(t, calls.Load(), 3)
+
+	// Entries expire after the TTL.
+	now.Advance(time.Minute)
+	_, err := cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 4)
+
+	// Revoked credentials can be invalidated.
+	cache.Invalidate(ctx, "Bearer "+passphrase)
+	_, err = cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 5)
+
+	// Requests without credentials bypass the cache.
+	_, _ = cache.Authenticate(ctx, &Request{Header: http.Header{}})
+	attest.Equal(t, cache.store.(*ShardedCache).Len(), 1)
+}
+
+func TestTokenCacheRevalidate(t *testing.T) {
+	ctx := context.Background()
+	revoked := false
+	cache, _, calls := newTestCache(TokenCacheConfig{TTL: time.Minute}, func(ctx context.Context, req *Request) (any, error) {
+		if revoked {
+			return nil, ReasonErrorf(ReasonRevoked, "credential revoked")
+		}
+		return authenticate(ctx, req)
+	})
+	_, err := cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	info, err := cache.Revalidate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	attest.Equal(t, calls.Load(), 2)
+
+	// Revalidation bypasses and updates the cache.
+	revoked = true
+	_, err = cache.Revalidate(ctx, bearer(passphrase))
+	attest.Equal(t, ReasonOf(err), ReasonRevoked)
+	_, err = cache.Authenticate(ctx, bearer(passphrase))
+	attest.Equal(t, ReasonOf(err), ReasonRevoked)
+	attest.Equal(t, calls.Load(), 4)
+}
+
+func TestTokenCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	var expires time.Time
+	cache, now, calls := newTestCache(TokenCacheConfig{TTL: time.Hour}, func(context.Context, *Request) (any, error) {
+		return expiringInfo{name: hero, expires: expires}, nil
+	})
+	expires = now.Now().Add(time.Second)
+	_, err := cache.Authenticate(ctx, bearer("a"))
+	attest.Ok(t, err)
+	_, err = cache.Authenticate(ctx, bearer("a"))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 1)
+	now.Advance(time.Second) // token expired, despite the long TTL
+	_, err = cache.Authenticate(ctx, bearer("a"))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 2)
+
+	// Already-expired information isn't cached at all.
+	attest.Equal(t, cache.store.(*ShardedCache).Len(), 0)
+}
+
+func TestTokenCacheNegative(t *testing.T) {
+	ctx := context.Background()
+	unavailable := false
+	cache, now, calls := newTestCache(
+		TokenCacheConfig{NegativeTTL: time.Second},
+		func(ctx context.Context, req *Request) (any, error) {
+			if unavailable {
+				return nil, connect.NewError(connect.CodeUnavailable, errors.New("introspection endpoint down"))
+			}
+			return authenticate(ctx, req)
+		},
+	)
+	for i := 0; i < 3; i++ {
+		_, err := cache.Authenticate(ctx, bearer("wrong"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	}
+	attest.Equal(t, calls.Load(), 1)
+	now.Advance(time.Second)
+	_, err := cache.Authenticate(ctx, bearer("wrong"))
+	attest.Error(t, err)
+	attest.Equal(t, calls.Load(), 2)
+
+	// Transient failures aren't cached.
+	unavailable = true
+	for i := 0; i < 2; i++ {
+		_, err := cache.Authenticate(ctx, bearer("other"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	}
+	attest.Equal(t, calls.Load(), 4)
+}
+
+func TestTokenCacheSingleflight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	cache, _, calls := newTestCache(TokenCacheConfig{}, func(ctx context.Context, req *Request) (any, error) {
+		started <- struct{}{}
+		<-release
+		return authenticate(ctx, req)
+	})
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			_, err := cache.Authenticate(context.Background(), bearer(passphrase))
+			errs <- err
+		}()
+	}
+	<-started
+	// A caller that gives up doesn't cancel the shared validation.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.Authenticate(ctx, bearer(passphrase))
+	attest.ErrorIs(t, err, context.Canceled)
+	time.Sleep(10 * time.Millisecond) // let the other callers join the flight
+	close(release)
+	for i := 0; i < n; i++ {
+		attest.Ok(t, <-errs)
+	}
+	attest.True(t, calls.Load() < n, attest.Sprintf("expected shared validation, got %d calls", calls.Load()))
+}
+
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+}
+
+func (c *mapCache) Get(_ context.Context, key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	return e, ok
+}
+
+func (c *mapCache) Set(_ context.Context, key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+func (c *mapCache) Delete(_ context.Context, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+func TestTokenCacheStore(t *testing.T) {
+	store := &mapCache{entries: make(map[string]*CacheEntry)}
+	cache, _, calls := newTestCache(TokenCacheConfig{Store: store}, authenticate)
+	for i := 0; i < 2; i++ {
+		_, err := cache.Authenticate(context.Background(), bearer(passphrase))
+		attest.Ok(t, err)
+	}
+	attest.Equal(t, calls.Load(), 1)
+	attest.Equal(t, len(store.entries), 1)
+	for key := range store.entries {
+		attest.Equal(t, key, cacheKey("Bearer "+passphrase))
+		attest.Equal(t, len(key), 64) // hex-encoded SHA-256
+	}
+}
+
+func TestTokenCacheRefreshAhead(t *testing.T) {
+	ctx := context.Background()
+	var revoked atomic.Bool
+	cache, now, calls := newTestCache(
+		TokenCacheConfig{TTL: time.Minute, RefreshAhead: 10 * time.Second},
+		func(ctx context.Context, req *Request) (any, error) {
+			if revoked.Load() {
+				return nil, Errorf("revoked")
+			}
+			return authenticate(ctx, req)
+		},
+	)
+	waitForCalls := func(n int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for calls.Load() < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		attest.Equal(t, calls.Load(), n)
+	}
+	_, err := cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	now.Advance(49 * time.Second) // outside the refresh window
+	_, err = cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 1)
+
+	now.Advance(time.Second) // inside the window: served from cache, refreshed in background
+	info, err := cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	waitForCalls(2)
+
+	// A failed refresh evicts the entry.
+	now.Advance(55 * time.Second)
+	revoked.Store(true)
+	_, err = cache.Authenticate(ctx, bearer(passphrase))
+	attest.Ok(t, err) // still cached
+	waitForCalls(3)
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.store.(*ShardedCache).Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	_, err = cache.Authenticate(ctx, bearer(passphrase))
+	attest.Error(t, err)
+}
+
+func TestTokenCacheResponseHeader(t *testing.T) {
+	ctx := context.Background()
+	cache, now, calls := newTestCache(
+		TokenCacheConfig{TTL: time.Minute, RefreshAhead: 10 * time.Second},
+		func(ctx context.Context, req *Request) (any, error) {
+			attest.Zero(t, req.Body)
+			req.ResponseHeader.Add("Set-Cookie", "session=renewed")
+			return authenticate(ctx, req)
+		},
+	)
+	request := func() *Request {
+		req := bearer(passphrase)
+		req.Body = []byte("{}")
+		req.ResponseHeader = make(http.Header)
+		return req
+	}
+
+	// Callers that wait for the validation get its response headers.
+	req := request()
+	_, err := cache.Authenticate(ctx, req)
+	attest.Ok(t, err)
+	attest.Equal(t, req.ResponseHeader.Values("Set-Cookie"), []string{"session=renewed"})
+
+	// Background refreshes don't touch the caller's Request.
+	now.Advance(55 * time.Second)
+	req = request()
+	_, err = cache.Authenticate(ctx, req)
+	attest.Ok(t, err)
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	attest.Equal(t, calls.Load(), 2)
+	attest.Equal(t, len(req.ResponseHeader), 0)
+}
+
+func TestTokenCachePurge(t *testing.T) {
+	cache := NewTokenCache(authenticate, TokenCacheConfig{Store: noPurgeCache{NewShardedCache(10)}})
+	attest.Error(t, cache.Purge())
+	attest.Equal(t, cache.Stats().Entries, -1)
+}
+
+// noPurgeCache hides ShardedCache's Len and Purge methods.
+type noPurgeCache struct {
+	Cache
+}
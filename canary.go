@@ -0,0 +1,61 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// A CanaryResult records the outcomes of the primary and candidate AuthFuncs
+// for a single request.
+type CanaryResult struct {
+	Request       *Request
+	PrimaryInfo   any
+	PrimaryErr    error
+	CandidateInfo any
+	CandidateErr  error
+}
+
+// DecisionsDiffer reports whether exactly one of the AuthFuncs rejected the
+// request.
+func (r *CanaryResult) DecisionsDiffer() bool {
+	return (r.PrimaryErr == nil) != (r.CandidateErr == nil)
+}
+
+// Canary returns an AuthFunc that runs both a primary and a candidate AuthFunc
+// for every request, but only enforces the primary's decision. It's useful for
+// validating a new verifier before cutting over to it.
+//
+// If the two AuthFuncs disagree, Canary calls onDisagree. The AuthFuncs
+// disagree if exactly one of them rejects the request, or if both accept the
+// request but return different authentication information (as determined by
+// [reflect.DeepEqual]). A nil onDisagree ignores disagreements.
+//
+// The candidate runs after the primary, on the request path, so it adds to
+// the latency of every request. It receives a copy of the request with its own
+// Flags and ResponseHeader, so it can't affect the response or the flags seen
+// by later checks.
+func Canary(primary, candidate AuthFunc, onDisagree func(context.Context, *CanaryResult)) AuthFunc {
+	return func(ctx context.Context, req *Request) (any, error) {
+		info, err := primary(ctx, req)
+		shadow := *req
+		shadow.Flags = slices.Clone(req.Flags)
+		shadow.ResponseHeader = make(http.Header)
+		candidateInfo, candidateErr := candidate(ctx, &shadow)
+		res := &CanaryResult{
+			Request:       req,
+			PrimaryInfo:   info,
+			PrimaryErr:    err,
+			CandidateInfo: candidateInfo,
+			CandidateErr:  candidateErr,
+		}
+		if onDisagree == nil {
+			return info, err
+		}
+		if res.DecisionsDiffer() || (err == nil && !reflect.DeepEqual(info, candidateInfo)) {
+			onDisagree(ctx, res)
+		}
+		return info, err
+	}
+}
@@ -0,0 +1,71 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestCanary(t *testing.T) {
+	var disagreements []*CanaryResult
+	record := func(_ context.Context, res *CanaryResult) {
+		disagreements = append(disagreements, res)
+	}
+	candidate := func(ctx context.Context, req *Request) (any, error) {
+		if req.Header.Get("Authorization") == "Bearer "+passphrase {
+			return "someone else", nil
+		}
+		return nil, Errorf("candidate says no")
+	}
+	auth := Canary(authenticate, candidate, record)
+	call := func(authorization string) (any, error) {
+		header := http.Header{}
+		header.Set("Authorization", authorization)
+		return auth(context.Background(), &Request{Header: header})
+	}
+
+	// Both reject: no disagreement, primary error returned.
+	_, err := call("")
+	attest.Error(t, err)
+	attest.Subsequence(t, err.Error(), "expected Bearer")
+	attest.Equal(t, len(disagreements), 0)
+
+	// Both accept, but with different identities.
+	info, err := call("Bearer " + passphrase)
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	attest.Equal(t, len(disagreements), 1)
+	attest.False(t, disagreements[0].DecisionsDiffer())
+	attest.Equal(t, disagreements[0].CandidateInfo, any("someone else"))
+
+	// Only the primary accepts.
+	auth = Canary(authenticate, func(context.Context, *Request) (any, error) {
+		return nil, Errorf("no")
+	}, record)
+	info, err = call("Bearer " + passphrase)
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	attest.Equal(t, len(disagreements), 2)
+	attest.True(t, disagreements[1].DecisionsDiffer())
+
+	// The candidate can't change the request's flags or response headers, and
+	// disagreements may be ignored.
+	auth = Canary(authenticate, func(_ context.Context, req *Request) (any, error) {
+		req.Flags = append(req.Flags, "candidate")
+		req.ResponseHeader.Set("Set-Cookie", "session=candidate")
+		return nil, Errorf("no")
+	}, nil)
+	req := &Request{
+		Header:         http.Header{"Authorization": []string{"Bearer " + passphrase}},
+		Flags:          make([]string, 0, 1),
+		ResponseHeader: http.Header{},
+	}
+	info, err = auth(context.Background(), req)
+	attest.Ok(t, err)
+	attest.Equal(t, info, any(hero))
+	attest.Zero(t, req.Flags[:1][0]) // the backing array is untouched
+	attest.Equal(t, len(req.Flags), 0)
+	attest.Equal(t, len(req.ResponseHeader), 0)
+}
@@ -0,0 +1,35 @@
+package connectauth
+
+import "time"
+
+// A Clock tells the current time. Time-sensitive components, like
+// [TokenCache], [FailureLimiter], [Lockout], and [Freshness], read the time
+// from a Clock so that tests can control it: rather than sleeping until a
+// token or lockout expires, a test can supply a fake Clock and advance it
+// explicitly. The connectauthtest package includes a suitable fake.
+//
+// Clocks only affect expiry and rate calculations. Background goroutines,
+// delays, and latency measurements always use real time.
+//
+// Implementations must be safe to call concurrently.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock returns a Clock that uses [time.Now]. Components use it when
+// they aren't configured with a Clock.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// nowFunc returns the clock's Now method, defaulting to [time.Now].
+func nowFunc(clock Clock) func() time.Time {
+	if clock == nil {
+		return time.Now
+	}
+	return clock.Now
+}
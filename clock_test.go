@@ -0,0 +1,19 @@
+package connectauth
+
+import (
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestClock(t *testing.T) {
+	before := time.Now()
+	now := SystemClock().Now()
+	attest.False(t, now.Before(before))
+	attest.True(t, time.Since(now) < time.Minute)
+
+	clock := newTestClock()
+	attest.Equal(t, nowFunc(clock)(), clock.Now())
+	attest.NotZero(t, nowFunc(nil)())
+}
@@ -0,0 +1,54 @@
+package connectauth
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+)
+
+// All combines AuthFuncs, all of which must succeed. It's typically used to
+// put guards, like an [IPFilter], in front of an AuthFunc that validates
+// credentials:
+//
+//	filter := connectauth.NewIPFilter(connectauth.IPFilterConfig{
+//		Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+//	})
+//	auth := connectauth.New(connectauth.All(filter.Authenticate, authenticateJWT))
+//
+// The AuthFuncs are called in order, and the first error is returned without
+// calling the remaining functions. On success, All returns the first non-nil
+// authentication information.
+func All(funcs ...AuthFunc) AuthFunc {
+	return func(ctx context.Context, req *Request) (any, error) {
+		var info any
+		for _, f := range funcs {
+			i, err := f(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			if info == nil {
+				info = i
+			}
+		}
+		return info, nil
+	}
+}
+
+// ByStreamType calls one AuthFunc for unary RPCs and non-RPC requests, and
+// another for streaming RPCs. Long-lived streams often warrant stronger
+// credentials or additional requirements:
+//
+//	auth := connectauth.New(connectauth.ByStreamType(
+//		authenticateJWT,
+//		connectauth.All(requireClientCert, authenticateJWT),
+//	))
+//
+// See [Request].StreamType for how streaming RPCs are identified.
+func ByStreamType(unary, streaming AuthFunc) AuthFunc {
+	return func(ctx context.Context, req *Request) (any, error) {
+		if req.StreamType == connect.StreamTypeUnary {
+			return unary(ctx, req)
+		}
+		return streaming(ctx, req)
+	}
+}
@@ -0,0 +1,52 @@
+package connectauth
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestAll(t *testing.T) {
+	var calls []string
+	record := func(name string, info any, err error) AuthFunc {
+		return func(context.Context, *Request) (any, error) {
+			calls = append(calls, name)
+			return info, err
+		}
+	}
+	ctx := context.Background()
+
+	info, err := All(record("guard", nil, nil), record("auth", "alice", nil), record("other", "bob", nil))(ctx, &Request{})
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("alice"))
+	attest.Equal(t, calls, []string{"guard", "auth", "other"})
+
+	calls = nil
+	_, err = All(record("guard", nil, Errorf("denied")), record("auth", "alice", nil))(ctx, &Request{})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, calls, []string{"guard"})
+
+	info, err = All()(ctx, &Request{})
+	attest.Ok(t, err)
+	attest.Zero(t, info)
+}
+
+func TestByStreamType(t *testing.T) {
+	auth := ByStreamType(
+		func(context.Context, *Request) (any, error) { return "unary", nil },
+		func(context.Context, *Request) (any, error) { return "streaming", nil },
+	)
+	ctx := context.Background()
+	for st, want := range map[connect.StreamType]string{
+		connect.StreamTypeUnary:  "unary",
+		connect.StreamTypeClient: "streaming",
+		connect.StreamTypeServer: "streaming",
+		connect.StreamTypeBidi:   "streaming",
+	} {
+		info, err := auth(ctx, &Request{StreamType: st})
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(want))
+	}
+}
@@ -0,0 +1,157 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+type connCacheKey struct{}
+
+// ConnCacheConfig configures a [ConnCache].
+type ConnCacheConfig struct {
+	// TTL is the maximum time a successful validation is cached. The default
+	// is one minute.
+	TTL time.Duration
+	// Credential extracts the credential from a request. Requests with an
+	// empty credential bypass the cache. By default, the credential is the
+	// value of the Authorization header.
+	Credential func(*Request) string
+	// Expiry returns the time at which the authentication information for a
+	// credential expires, as in [TokenCacheConfig].
+	Expiry func(info any) time.Time
+	// Clock determines when cached entries expire. The default is
+	// [SystemClock].
+	Clock Clock
+}
+
+// A ConnCache wraps an AuthFunc and caches its result for the lifetime of
+// each underlying network connection. HTTP/2 and gRPC clients multiplex many
+// streams over a single connection, so caching per connection lets hundreds
+// of concurrent streams from an authenticated client skip revalidating the
+// same credential. Unlike [TokenCache], ConnCache needs no size limit: each
+// connection holds at most one entry, which is discarded when the connection
+// closes.
+//
+// The cached entry is replaced whenever a request on the connection presents
+// a different credential, and it's never used after the TTL or the
+// credential's expiry. As with TokenCache, cached information must not be
+// modified and mustn't depend on properties of the request other than the
+// credential.
+//
+// ConnCache only works if its ConnContext method is installed on the
+// [http.Server]:
+//
+//	cache := connectauth.NewConnCache(authenticate, connectauth.ConnCacheConfig{})
+//	srv := &http.Server{
+//		Handler:     connectauth.NewMiddleware(cache.Authenticate).Wrap(mux),
+//		ConnContext: cache.ConnContext,
+//	}
+//
+// Otherwise, every request calls the wrapped AuthFunc.
+type ConnCache struct {
+	auth       AuthFunc
+	ttl        time.Duration
+	credential func(*Request) string
+	expiry     func(any) time.Time
+	now        func() time.Time
+}
+
+// connEntry is the cache for a single connection.
+type connEntry struct {
+	group singleflight.Group
+
+	mu      sync.Mutex
+	key     [sha256.Size]byte
+	info    any
+	expires time.Time
+}
+
+// NewConnCache constructs a ConnCache.
+func NewConnCache(auth AuthFunc, config ConnCacheConfig) *ConnCache {
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			return headerValue(req.Header, "Authorization")
+		}
+	}
+	if config.Expiry == nil {
+		config.Expiry = expiryOf
+	}
+	return &ConnCache{
+		auth:       auth,
+		ttl:        config.TTL,
+		credential: config.Credential,
+		expiry:     config.Expiry,
+		now:        nowFunc(config.Clock),
+	}
+}
+
+// ConnContext attaches an empty cache to a new connection's context. Install
+// it as the ConnContext field of an [http.Server]. If the server already has
+// a ConnContext function, call both.
+func (c *ConnCache) ConnContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connCacheKey{}, &connEntry{})
+}
+
+// Authenticate is an AuthFunc that returns the connection's cached
+// authentication information if possible, and otherwise calls the wrapped
+// AuthFunc.
+func (c *ConnCache) Authenticate(ctx context.Context, req *Request) (any, error) {
+	entry, ok := ctx.Value(connCacheKey{}).(*connEntry)
+	if !ok {
+		return c.auth(ctx, req)
+	}
+	credential := c.credential(req)
+	if credential == "" {
+		return c.auth(ctx, req)
+	}
+	key := sha256.Sum256([]byte(credential))
+	if info, ok := entry.get(key, c.now()); ok {
+		return info, nil
+	}
+	// As in TokenCache, the shared validation must outlive any single caller.
+	sharedCtx := context.WithoutCancel(ctx)
+	results := entry.group.DoChan(string(key[:]), func() (any, error) {
+		info, err := c.auth(sharedCtx, req)
+		if err != nil {
+			return nil, err
+		}
+		now := c.now()
+		expires := now.Add(c.ttl)
+		if exp := c.expiry(info); !exp.IsZero() && exp.Before(expires) {
+			expires = exp
+		}
+		if now.Before(expires) {
+			entry.set(key, info, expires)
+		}
+		return info, nil
+	})
+	select {
+	case res := <-results:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (e *connEntry) get(key [sha256.Size]byte, now time.Time) (any, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.key != key || !now.Before(e.expires) {
+		return nil, false
+	}
+	return e.info, true
+}
+
+func (e *connEntry) set(key [sha256.Size]byte, info any, expires time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.key, e.info, e.expires = key, info, expires
+}
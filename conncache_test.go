@@ -0,0 +1,79 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestConnCache(t *testing.T) {
+	var calls atomic.Int64
+	cache := NewConnCache(func(ctx context.Context, req *Request) (any, error) {
+		calls.Add(1)
+		return authenticate(ctx, req)
+	}, ConnCacheConfig{TTL: time.Minute})
+	now := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	// Without ConnContext, every request is validated.
+	_, _ = cache.Authenticate(context.Background(), bearer(passphrase))
+	_, _ = cache.Authenticate(context.Background(), bearer(passphrase))
+	attest.Equal(t, calls.Load(), 2)
+
+	conn := cache.ConnContext(context.Background(), nil)
+	other := cache.ConnContext(context.Background(), nil)
+	calls.Store(0)
+	for i := 0; i < 3; i++ {
+		info, err := cache.Authenticate(conn, bearer(passphrase))
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(hero))
+	}
+	attest.Equal(t, calls.Load(), 1)
+	// Entries are scoped to a connection.
+	_, err := cache.Authenticate(other, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 2)
+	// A new credential replaces the entry, and failures aren't cached.
+	_, err = cache.Authenticate(conn, bearer("wrong"))
+	attest.Error(t, err)
+	_, err = cache.Authenticate(conn, bearer("wrong"))
+	attest.Error(t, err)
+	attest.Equal(t, calls.Load(), 4)
+	// Entries expire.
+	now = now.Add(time.Minute)
+	_, err = cache.Authenticate(other, bearer(passphrase))
+	attest.Ok(t, err)
+	attest.Equal(t, calls.Load(), 5)
+}
+
+func TestConnCacheServer(t *testing.T) {
+	var calls atomic.Int64
+	cache := NewConnCache(func(ctx context.Context, req *Request) (any, error) {
+		calls.Add(1)
+		return authenticate(ctx, req)
+	}, ConnCacheConfig{})
+	srv := httptest.NewUnstartedServer(
+		NewMiddleware(cache.Authenticate).Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})),
+	)
+	srv.Config.ConnContext = cache.ConnContext
+	srv.Start()
+	defer srv.Close()
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/acme.v1.Svc/Get", strings.NewReader("{}"))
+		attest.Ok(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+passphrase)
+		res, err := srv.Client().Do(req)
+		attest.Ok(t, err)
+		res.Body.Close()
+		attest.Equal(t, res.StatusCode, http.StatusOK)
+	}
+	attest.Equal(t, calls.Load(), 1) // the client reuses its connection
+}
@@ -0,0 +1,147 @@
+// Package connectauthbufconnect adapts [connectauth] to handlers built with
+// github.com/bufbuild/connect-go, the module that preceded
+// connectrpc.com/connect. Teams partway through migrating between the two can
+// share one [connectauth.Authenticator], along with its caches, lockouts, and
+// metrics, across handlers generated for either module:
+//
+//	auth := connectauth.New(authenticate)
+//	mux.Handle(foov1connect.NewFooServiceHandler(foo, auth.HandlerOption()))
+//	mux.Handle(barv1connect.NewBarServiceHandler(bar, connectauthbufconnect.HandlerOption(auth)))
+//	http.ListenAndServe(":8080", auth.Middleware().Wrap(mux))
+//
+// Both modules speak the same wire protocols, so [connectauth.Middleware]
+// already works with bufbuild handlers. This package is only necessary for
+// applications that authenticate in interceptors.
+//
+// Both modules register the same protobuf file for gRPC status details, which
+// usually panics at startup. Importing this package suppresses that one
+// conflict, so binaries that link both modules need no special configuration.
+// It relies on the package initialization order introduced in Go 1.21.
+package connectauthbufconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+	bufconnect "github.com/bufbuild/connect-go"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/connectauth/connectauthbufconnect/internal/protoconflict"
+)
+
+func init() {
+	// Both connect modules have registered their protobuf files by now.
+	protoconflict.Restore()
+}
+
+// Interceptor is a bufbuild/connect-go interceptor that authenticates
+// requests. It behaves like [connectauth.Interceptor], including the
+// Authenticator's stream and profiling options.
+type Interceptor struct {
+	auth *connectauth.Authenticator
+}
+
+// NewInterceptor constructs a bufbuild/connect-go interceptor that
+// authenticates requests with the supplied Authenticator. Authentication
+// errors are converted to bufbuild errors with the same code, message,
+// metadata, and details.
+func NewInterceptor(auth *connectauth.Authenticator) *Interceptor {
+	return &Interceptor{auth: auth}
+}
+
+// HandlerOption returns a bufbuild/connect-go handler option that applies the
+// supplied options and an [Interceptor]. Like
+// [connectauth.Authenticator.HandlerOption], the interceptor doesn't
+// re-authenticate requests that the Authenticator's middleware has already
+// handled, and HandlerOption should be called before the Authenticator begins
+// serving requests.
+func HandlerOption(auth *connectauth.Authenticator, opts ...bufconnect.HandlerOption) bufconnect.HandlerOption {
+	auth.MarkAuthenticated()
+	return bufconnect.WithHandlerOptions(
+		bufconnect.WithInterceptors(NewInterceptor(auth)),
+		bufconnect.WithHandlerOptions(opts...),
+	)
+}
+
+// WrapUnary implements bufconnect.Interceptor.
+func (i *Interceptor) WrapUnary(next bufconnect.UnaryFunc) bufconnect.UnaryFunc {
+	return func(ctx context.Context, req bufconnect.AnyRequest) (bufconnect.AnyResponse, error) {
+		spec := req.Spec()
+		peer := req.Peer()
+		header := make(http.Header)
+		var res bufconnect.AnyResponse
+		err := i.auth.HandleUnary(ctx, &connectauth.Request{
+			Procedure:      spec.Procedure,
+			ClientAddr:     peer.Addr,
+			Protocol:       peer.Protocol,
+			StreamType:     connect.StreamType(spec.StreamType),
+			Header:         req.Header(),
+			ResponseHeader: header,
+		}, func(ctx context.Context) error {
+			var err error
+			res, err = next(ctx, req)
+			return err
+		})
+		if res != nil {
+			for k, vals := range header {
+				res.Header()[k] = append(res.Header()[k], vals...)
+			}
+		}
+		return res, toBufError(err)
+	}
+}
+
+// WrapStreamingClient implements bufconnect.Interceptor with a no-op.
+func (i *Interceptor) WrapStreamingClient(next bufconnect.StreamingClientFunc) bufconnect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements bufconnect.Interceptor.
+func (i *Interceptor) WrapStreamingHandler(next bufconnect.StreamingHandlerFunc) bufconnect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn bufconnect.StreamingHandlerConn) error {
+		spec := conn.Spec()
+		peer := conn.Peer()
+		err := i.auth.HandleStream(ctx, &connectauth.Request{
+			Procedure:      spec.Procedure,
+			ClientAddr:     peer.Addr,
+			Protocol:       peer.Protocol,
+			StreamType:     connect.StreamType(spec.StreamType),
+			Header:         conn.RequestHeader(),
+			ResponseHeader: conn.ResponseHeader(),
+		}, func(ctx context.Context) error {
+			return next(ctx, conn)
+		})
+		return toBufError(err)
+	}
+}
+
+// toBufError converts a connectrpc.com/connect error, like an authentication
+// failure or a stream ended by the Authenticator, to the equivalent
+// bufbuild/connect-go error. Details whose types aren't linked into the
+// binary can't be converted, so they're dropped. Other errors are returned
+// unchanged.
+func toBufError(err error) error {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return err
+	}
+	var underlying error
+	if msg := connectErr.Message(); msg != "" {
+		underlying = errors.New(msg)
+	}
+	bufErr := bufconnect.NewError(bufconnect.Code(connectErr.Code()), underlying)
+	for k, vals := range connectErr.Meta() {
+		bufErr.Meta()[k] = append(bufErr.Meta()[k], vals...)
+	}
+	for _, detail := range connectErr.Details() {
+		msg, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if bufDetail, err := bufconnect.NewErrorDetail(msg); err == nil {
+			bufErr.AddDetail(bufDetail)
+		}
+	}
+	return bufErr
+}
@@ -0,0 +1,176 @@
+package connectauthbufconnect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	bufconnect "github.com/bufbuild/connect-go"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	whoAmI = "/acme.user.v1.UserService/WhoAmI"
+	watch  = "/acme.user.v1.UserService/Watch"
+)
+
+func TestInterceptor(t *testing.T) {
+	var calls int
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		calls++
+		if token, _ := connectauth.BearerToken(req.Header); token != "alice-token" {
+			err := connectauth.Errorf("invalid token")
+			err.Meta().Set("WWW-Authenticate", "Bearer")
+			if detail, detailErr := connect.NewErrorDetail(wrapperspb.String("expired")); detailErr == nil {
+				err.AddDetail(detail)
+			}
+			return nil, err
+		}
+		req.ResponseHeader.Set("X-Session", "renewed")
+		return "alice", nil
+	})
+	opt := HandlerOption(auth)
+	mux := http.NewServeMux()
+	mux.Handle(whoAmI, bufconnect.NewUnaryHandler(
+		whoAmI,
+		func(ctx context.Context, _ *bufconnect.Request[emptypb.Empty]) (*bufconnect.Response[wrapperspb.StringValue], error) {
+			name, _ := connectauth.GetInfo(ctx).(string)
+			return bufconnect.NewResponse(wrapperspb.String(name)), nil
+		},
+		opt,
+	))
+	mux.Handle(watch, bufconnect.NewServerStreamHandler(
+		watch,
+		func(ctx context.Context, _ *bufconnect.Request[emptypb.Empty], stream *bufconnect.ServerStream[wrapperspb.StringValue]) error {
+			name, _ := connectauth.GetInfo(ctx).(string)
+			return stream.Send(wrapperspb.String(name))
+		},
+		opt,
+	))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	unary := bufconnect.NewClient[emptypb.Empty, wrapperspb.StringValue](srv.Client(), srv.URL+whoAmI)
+	stream := bufconnect.NewClient[emptypb.Empty, wrapperspb.StringValue](srv.Client(), srv.URL+watch)
+	request := func(token string) *bufconnect.Request[emptypb.Empty] {
+		req := bufconnect.NewRequest(&emptypb.Empty{})
+		if token != "" {
+			req.Header().Set("Authorization", "Bearer "+token)
+		}
+		return req
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		res, err := unary.CallUnary(context.Background(), request("alice-token"))
+		attest.Ok(t, err)
+		attest.Equal(t, res.Msg.GetValue(), "alice")
+		attest.Equal(t, res.Header().Get("X-Session"), "renewed")
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		res, err := stream.CallServerStream(context.Background(), request("alice-token"))
+		attest.Ok(t, err)
+		defer res.Close()
+		attest.True(t, res.Receive())
+		attest.Equal(t, res.Msg().GetValue(), "alice")
+		attest.Equal(t, res.ResponseHeader().Get("X-Session"), "renewed")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := unary.CallUnary(context.Background(), request(""))
+		var bufErr *bufconnect.Error
+		attest.True(t, errors.As(err, &bufErr))
+		attest.Equal(t, bufErr.Code(), bufconnect.CodeUnauthenticated)
+		attest.Equal(t, bufErr.Message(), "invalid token")
+		attest.Equal(t, bufErr.Meta().Get("WWW-Authenticate"), "Bearer")
+		attest.Equal(t, len(bufErr.Details()), 1)
+		msg, err := bufErr.Details()[0].Value()
+		attest.Ok(t, err)
+		attest.Equal(t, msg.(*wrapperspb.StringValue).GetValue(), "expired")
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		// Requests authenticated by the middleware aren't authenticated again.
+		srv := httptest.NewServer(auth.Middleware().Wrap(mux))
+		t.Cleanup(srv.Close)
+		client := bufconnect.NewClient[emptypb.Empty, wrapperspb.StringValue](srv.Client(), srv.URL+whoAmI)
+		calls = 0
+		res, err := client.CallUnary(context.Background(), request("alice-token"))
+		attest.Ok(t, err)
+		attest.Equal(t, res.Msg.GetValue(), "alice")
+		attest.Equal(t, calls, 1)
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		attest.ErrorIs(t, toBufError(context.Canceled), context.Canceled)
+	})
+}
+
+func TestStreamOptions(t *testing.T) {
+	revoked := make(chan struct{})
+	limiter := connectauth.NewStreamLimiter(connectauth.StreamLimiterConfig{Max: 1})
+	auth := connectauth.New(
+		func(_ context.Context, req *connectauth.Request) (any, error) {
+			if token, _ := connectauth.BearerToken(req.Header); token != "alice-token" {
+				return nil, connectauth.Errorf("invalid token")
+			}
+			return "alice", nil
+		},
+		connectauth.WithStreamLimiter(limiter),
+		connectauth.WithStreamRevalidation(connectauth.StreamRevalidationConfig{
+			Interval: 10 * time.Millisecond,
+			Revalidate: func(context.Context, *connectauth.Request) (any, error) {
+				select {
+				case <-revoked:
+					return nil, connectauth.Errorf("token revoked")
+				default:
+					return "alice", nil
+				}
+			},
+		}),
+	)
+	mux := http.NewServeMux()
+	mux.Handle(watch, bufconnect.NewServerStreamHandler(
+		watch,
+		func(ctx context.Context, _ *bufconnect.Request[emptypb.Empty], stream *bufconnect.ServerStream[wrapperspb.StringValue]) error {
+			if err := stream.Send(wrapperspb.String("alice")); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		HandlerOption(auth),
+	))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := bufconnect.NewClient[emptypb.Empty, wrapperspb.StringValue](srv.Client(), srv.URL+watch)
+	open := func() *bufconnect.ServerStreamForClient[wrapperspb.StringValue] {
+		req := bufconnect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("Authorization", "Bearer alice-token")
+		res, err := client.CallServerStream(context.Background(), req)
+		attest.Ok(t, err)
+		t.Cleanup(func() { res.Close() })
+		return res
+	}
+
+	first := open()
+	attest.True(t, first.Receive())
+	attest.Equal(t, limiter.Open("alice"), 1)
+
+	// The limiter rejects a second concurrent stream.
+	second := open()
+	attest.False(t, second.Receive())
+	attest.Equal(t, bufconnect.CodeOf(second.Err()), bufconnect.CodeResourceExhausted)
+
+	// Revalidation ends the first stream with the check's error.
+	close(revoked)
+	attest.False(t, first.Receive())
+	attest.Equal(t, bufconnect.CodeOf(first.Err()), bufconnect.CodeUnauthenticated)
+	attest.Equal(t, first.Err().(*bufconnect.Error).Message(), "token revoked")
+}
@@ -0,0 +1,32 @@
+// Package protoconflict lets binaries link both connectrpc.com/connect and
+// github.com/bufbuild/connect-go. Both modules register the same protobuf file
+// for gRPC status details, which panics at startup by default.
+//
+// Since Go 1.21, packages are initialized in import path order as soon as
+// their dependencies are ready. This package depends only on os, and its
+// import path sorts before google.golang.org/protobuf, so its init runs before
+// either module registers any protobuf files. It tells the protobuf runtime
+// to ignore conflicts, and connectauthbufconnect's init restores the original
+// policy once both modules have registered their files.
+package protoconflict
+
+import "os"
+
+const env = "GOLANG_PROTOBUF_REGISTRATION_CONFLICT"
+
+var overridden bool
+
+func init() {
+	if _, ok := os.LookupEnv(env); !ok {
+		overridden = os.Setenv(env, "ignore") == nil
+	}
+}
+
+// Restore undoes the override, if any, so that conflicts registered after
+// both connect modules are initialized are handled as usual.
+func Restore() {
+	if overridden {
+		os.Unsetenv(env)
+		overridden = false
+	}
+}
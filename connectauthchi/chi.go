@@ -0,0 +1,25 @@
+// Package connectauthchi adapts [connectauth] middleware to chi routers:
+//
+//	auth := connectauth.New(authenticate, connectauth.WithAuthenticateAll())
+//	r := chi.NewRouter()
+//	r.Use(connectauthchi.Middleware(auth.Middleware()))
+//	r.Mount("/", foov1connect.NewFooServiceHandler(foo))
+//
+// chi uses standard net/http middleware, so the adapter is only a change of
+// shape. Authentication information travels in the request's context, where
+// chi handlers can read it with [connectauth.GetInfo].
+package connectauthchi
+
+import "net/http"
+
+// A Wrapper is connectauth HTTP middleware, like a [connectauth.Middleware]
+// or [connectauth.HTTPMiddleware].
+type Wrapper interface {
+	Wrap(http.Handler) http.Handler
+}
+
+// Middleware converts connectauth middleware to chi's middleware signature,
+// suitable for chi.Router's Use and With methods.
+func Middleware(mw Wrapper) func(http.Handler) http.Handler {
+	return mw.Wrap
+}
@@ -0,0 +1,38 @@
+package connectauthchi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestMiddleware(t *testing.T) {
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		if token, _ := connectauth.BearerToken(req.Header); token == "alice-token" {
+			return "alice", nil
+		}
+		return nil, connectauth.Errorf("invalid token")
+	})
+	r := chi.NewRouter()
+	r.Use(Middleware(auth.HTTPMiddleware()))
+	r.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		name, _ := connectauth.GetInfo(r.Context()).(string)
+		w.Write([]byte(name + " " + chi.URLParam(r, "id")))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	attest.Equal(t, rec.Code, http.StatusOK)
+	attest.Equal(t, rec.Body.String(), "alice 42")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	attest.Equal(t, rec.Code, http.StatusUnauthorized)
+}
@@ -0,0 +1,53 @@
+// Package connectauthecho adapts [connectauth] middleware to Echo:
+//
+//	auth := connectauth.New(authenticate)
+//	e := echo.New()
+//	e.Use(connectauthecho.Middleware(auth.HTTPMiddleware()))
+//
+// After authentication, the echo.Context's request carries the
+// authentication information in its context, so handlers can use
+// [connectauth.GetInfo] with c.Request().Context(). The information is also
+// stored under [InfoKey]; use [GetInfo] to read it directly from an
+// echo.Context.
+package connectauthecho
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.akshayshah.org/connectauth"
+)
+
+// InfoKey is the echo.Context key that holds the authentication information.
+const InfoKey = "connectauth.info"
+
+// A Wrapper is connectauth HTTP middleware, like a [connectauth.Middleware]
+// or [connectauth.HTTPMiddleware].
+type Wrapper interface {
+	Wrap(http.Handler) http.Handler
+}
+
+// Middleware converts connectauth middleware to an echo.MiddlewareFunc.
+// Requests that fail authentication are answered by the connectauth
+// middleware, and the rest of the Echo chain doesn't run.
+func Middleware(mw Wrapper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			mw.Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				if info := connectauth.GetInfo(r.Context()); info != nil {
+					c.Set(InfoKey, info)
+				}
+				err = next(c)
+			})).ServeHTTP(c.Response(), c.Request())
+			return err
+		}
+	}
+}
+
+// GetInfo returns the authentication information stored by [Middleware], if
+// any.
+func GetInfo(c echo.Context) any {
+	return c.Get(InfoKey)
+}
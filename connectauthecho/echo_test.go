@@ -0,0 +1,48 @@
+package connectauthecho
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestMiddleware(t *testing.T) {
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		if token, _ := connectauth.BearerToken(req.Header); token == "alice-token" {
+			return "alice", nil
+		}
+		return nil, connectauth.Errorf("invalid token")
+	})
+	var calls int
+	e := echo.New()
+	e.Use(Middleware(auth.HTTPMiddleware()))
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			calls++
+			return next(c)
+		}
+	})
+	e.GET("/users/:id", func(c echo.Context) error {
+		attest.Equal(t, connectauth.GetInfo(c.Request().Context()), GetInfo(c))
+		name, _ := GetInfo(c).(string)
+		return c.String(http.StatusOK, name+" "+c.Param("id"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	attest.Equal(t, rec.Code, http.StatusOK)
+	attest.Equal(t, rec.Body.String(), "alice 42")
+	attest.Equal(t, calls, 1)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	attest.Equal(t, rec.Code, http.StatusUnauthorized)
+	attest.Equal(t, calls, 1)
+}
@@ -0,0 +1,28 @@
+module go.akshayshah.org/connectauth/connectauthecho
+
+go 1.21
+
+require (
+	github.com/labstack/echo/v4 v4.11.4
+	go.akshayshah.org/attest v1.0.2
+	go.akshayshah.org/connectauth v0.4.0
+)
+
+require (
+	connectrpc.com/connect v1.11.1 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/labstack/gommon v0.4.2 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
+	golang.org/x/net v0.19.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+replace go.akshayshah.org/connectauth => ../
@@ -0,0 +1,230 @@
+// Package connectauthgateway resolves grpc-gateway requests to the procedures
+// they invoke, so [connectauth.WithGateway] can authenticate them exactly like
+// native RPCs:
+//
+//	routes, err := connectauthgateway.NewRoutes(
+//		foov1.File_acme_foo_v1_foo_proto.Services().ByName("FooService"),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	auth := connectauth.New(authenticate, connectauth.WithGateway(routes.Resolve))
+//	mux.Handle("/v1/", auth.Middleware().Wrap(gatewayMux))
+//
+// Routes come from the google.api.http annotations that grpc-gateway itself
+// uses, so the two always agree about which procedure a REST request calls.
+// connectrpc.com/vanguard uses the same annotations, so Routes also work with
+// [connectauth.WithProcedureResolver] for Vanguard-mounted handlers.
+package connectauthgateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Routes maps HTTP methods and paths to procedures. All routes must be added
+// before Resolve is called: Routes aren't safe to modify concurrently with
+// Resolve.
+type Routes struct {
+	routes []route
+}
+
+type route struct {
+	method    string
+	segments  []string // literals, "*", and "**"
+	verb      string
+	procedure string
+}
+
+// NewRoutes constructs Routes from the google.api.http annotations on the
+// methods of the supplied services, including any additional bindings.
+// Methods without annotations aren't reachable through the gateway, so
+// they're skipped.
+func NewRoutes(services ...protoreflect.ServiceDescriptor) (*Routes, error) {
+	r := &Routes{}
+	for _, service := range services {
+		methods := service.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			method := methods.Get(i)
+			rule, ok := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if !ok || rule == nil {
+				continue
+			}
+			procedure := "/" + string(service.FullName()) + "/" + string(method.Name())
+			for _, binding := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+				verb, template := httpPattern(binding)
+				if template == "" {
+					continue
+				}
+				if err := r.Handle(verb, template, procedure); err != nil {
+					return nil, fmt.Errorf("%s: %w", procedure, err)
+				}
+			}
+		}
+	}
+	return r, nil
+}
+
+// Handle adds a route, like grpc-gateway's ServeMux.HandlePath. The template
+// uses google.api.http syntax, like "/v1/{name=shelves/*/books/*}:archive".
+// As in grpc-gateway, routes added later take precedence.
+func (r *Routes) Handle(method, template, procedure string) error {
+	segments, verb, err := parseTemplate(template)
+	if err != nil {
+		return err
+	}
+	r.routes = append(r.routes, route{
+		method:    method,
+		segments:  segments,
+		verb:      verb,
+		procedure: procedure,
+	})
+	return nil
+}
+
+// Resolve returns the procedure that the request invokes, if any. Its
+// signature matches [connectauth.WithGateway].
+func (r *Routes) Resolve(req *http.Request) (string, bool) {
+	path := strings.TrimPrefix(req.URL.Path, "/")
+	for i := len(r.routes) - 1; i >= 0; i-- {
+		if rt := r.routes[i]; rt.method == req.Method && rt.match(path) {
+			return rt.procedure, true
+		}
+	}
+	return "", false
+}
+
+// Header returns a resolver that reads the procedure from a request header,
+// for deployments where the component in front of the gateway already knows
+// which procedure a request calls. The header decides which exemptions and
+// policies apply, so it must be set by a trusted proxy that overwrites any
+// value sent by clients. Values that aren't shaped like procedures are
+// ignored.
+func Header(name string) func(*http.Request) (string, bool) {
+	return func(req *http.Request) (string, bool) {
+		procedure := req.Header.Get(name)
+		if strings.Count(procedure, "/") != 2 || !strings.HasPrefix(procedure, "/") || strings.HasSuffix(procedure, "/") {
+			return "", false
+		}
+		return procedure, true
+	}
+}
+
+func (rt *route) match(path string) bool {
+	if rt.verb != "" {
+		var ok bool
+		path, ok = strings.CutSuffix(path, ":"+rt.verb)
+		if !ok {
+			return false
+		}
+	}
+	parts := strings.Split(path, "/")
+	for i, seg := range rt.segments {
+		if seg == "**" {
+			return true
+		}
+		if i >= len(parts) || parts[i] == "" {
+			return false
+		}
+		if seg != "*" && seg != parts[i] {
+			return false
+		}
+	}
+	return len(parts) == len(rt.segments)
+}
+
+func httpPattern(rule *annotations.HttpRule) (method, template string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		return p.Custom.GetKind(), p.Custom.GetPath()
+	default:
+		return "", ""
+	}
+}
+
+// parseTemplate flattens a google.api.http path template into segments and a
+// verb. Variables match the segments of their sub-pattern, or a single
+// segment if they don't have one.
+func parseTemplate(template string) ([]string, string, error) {
+	rest, ok := strings.CutPrefix(template, "/")
+	if !ok {
+		return nil, "", fmt.Errorf("path template %q doesn't start with a slash", template)
+	}
+	var verb string
+	depth, colon := 0, -1
+	for i, c := range rest {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				colon = i
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, "", fmt.Errorf("path template %q has unbalanced braces", template)
+	}
+	if colon >= 0 {
+		rest, verb = rest[:colon], rest[colon+1:]
+	}
+	var segments []string
+	for _, part := range splitTopLevel(rest) {
+		if inner, ok := strings.CutPrefix(part, "{"); ok {
+			inner = strings.TrimSuffix(inner, "}")
+			if _, sub, ok := strings.Cut(inner, "="); ok {
+				segments = append(segments, strings.Split(sub, "/")...)
+			} else {
+				segments = append(segments, "*")
+			}
+			continue
+		}
+		segments = append(segments, part)
+	}
+	for i, seg := range segments {
+		if seg == "" || strings.ContainsAny(seg, "{}") {
+			return nil, "", fmt.Errorf("path template %q is malformed", template)
+		}
+		if seg == "**" && i != len(segments)-1 {
+			return nil, "", fmt.Errorf("path template %q has ** before its last segment", template)
+		}
+	}
+	return segments, verb, nil
+}
+
+// splitTopLevel splits a path on slashes that aren't inside variables.
+func splitTopLevel(path string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range path {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '/':
+			if depth == 0 {
+				parts = append(parts, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, path[start:])
+}
@@ -0,0 +1,119 @@
+package connectauthgateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.akshayshah.org/attest"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestNewRoutes(t *testing.T) {
+	routes, err := NewRoutes(libraryService(t))
+	attest.Ok(t, err)
+	resolve := func(method, path string) string {
+		procedure, _ := routes.Resolve(httptest.NewRequest(method, path, nil))
+		return procedure
+	}
+	const prefix = "/acme.library.v1.LibraryService/"
+	attest.Equal(t, resolve(http.MethodGet, "/v1/shelves/1/books/2"), prefix+"GetBook")
+	attest.Equal(t, resolve(http.MethodGet, "/v1/books/2"), prefix+"GetBook")
+	attest.Equal(t, resolve(http.MethodPost, "/v1/shelves/1/books/2:archive"), prefix+"ArchiveBook")
+	attest.Zero(t, resolve(http.MethodPost, "/v1/shelves/1/books/2"))
+	attest.Zero(t, resolve(http.MethodGet, "/v1/shelves/1/books"))
+	attest.Zero(t, resolve(http.MethodGet, "/v1/shelves/1/books/2/pages"))
+	attest.Zero(t, resolve(http.MethodDelete, "/v1/books/2"))
+}
+
+// libraryService builds a service with google.api.http annotations, like
+// protoc-gen-go would for library.proto.
+func libraryService(tb testing.TB) protoreflect.ServiceDescriptor {
+	tb.Helper()
+	getBook := &descriptorpb.MethodOptions{}
+	proto.SetExtension(getBook, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Get{Get: "/v1/{name=shelves/*/books/*}"},
+		AdditionalBindings: []*annotations.HttpRule{{
+			Pattern: &annotations.HttpRule_Get{Get: "/v1/books/{name}"},
+		}},
+	})
+	archiveBook := &descriptorpb.MethodOptions{}
+	proto.SetExtension(archiveBook, annotations.E_Http, &annotations.HttpRule{
+		Pattern: &annotations.HttpRule_Post{Post: "/v1/{name=shelves/*/books/*}:archive"},
+		Body:    "*",
+	})
+	method := func(name string, opts *descriptorpb.MethodOptions) *descriptorpb.MethodDescriptorProto {
+		return &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(name),
+			InputType:  proto.String(".acme.library.v1.Book"),
+			OutputType: proto.String(".acme.library.v1.Book"),
+			Options:    opts,
+		}
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/library/v1/library.proto"),
+		Package:    proto.String("acme.library.v1"),
+		Dependency: []string{"google/api/annotations.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Book"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("name"),
+				JsonName: proto.String("name"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("LibraryService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetBook", getBook),
+				method("ArchiveBook", archiveBook),
+				method("Internal", nil),
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	attest.Ok(tb, err)
+	return fd.Services().Get(0)
+}
+
+func TestHandle(t *testing.T) {
+	routes := &Routes{}
+	attest.Ok(t, routes.Handle(http.MethodGet, "/v1/{name=files/**}", "/acme.fs.v1.FileService/GetFile"))
+	attest.Ok(t, routes.Handle(http.MethodGet, "/v1/files/root", "/acme.fs.v1.FileService/GetRoot"))
+	procedure, ok := routes.Resolve(httptest.NewRequest(http.MethodGet, "/v1/files/a/b/c", nil))
+	attest.True(t, ok)
+	attest.Equal(t, procedure, "/acme.fs.v1.FileService/GetFile")
+	// Later routes take precedence.
+	procedure, ok = routes.Resolve(httptest.NewRequest(http.MethodGet, "/v1/files/root", nil))
+	attest.True(t, ok)
+	attest.Equal(t, procedure, "/acme.fs.v1.FileService/GetRoot")
+
+	for _, template := range []string{"v1/files", "/v1/{name", "/v1/**/files", "/v1//files"} {
+		attest.Error(t, routes.Handle(http.MethodGet, template, "/acme.fs.v1.FileService/GetFile"))
+	}
+}
+
+func TestHeader(t *testing.T) {
+	resolve := Header("X-Procedure")
+	for value, ok := range map[string]bool{
+		"/acme.foo.v1.FooService/Bar": true,
+		"":                            false,
+		"/acme.foo.v1.FooService/":    false,
+		"acme.foo.v1.FooService/Bar":  false,
+		"/a/b/c":                      false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Procedure", value)
+		procedure, resolved := resolve(req)
+		attest.Equal(t, resolved, ok, attest.Sprintf("value %q", value))
+		if ok {
+			attest.Equal(t, procedure, value)
+		}
+	}
+}
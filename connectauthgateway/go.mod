@@ -0,0 +1,21 @@
+module go.akshayshah.org/connectauth/connectauthgateway
+
+go 1.21
+
+require (
+	connectrpc.com/vanguard v0.1.0
+	go.akshayshah.org/attest v1.0.2
+	go.akshayshah.org/connectauth v0.4.0
+	google.golang.org/genproto/googleapis/api v0.0.0-20230822172742-b8732ec3820d
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	connectrpc.com/connect v1.11.1 // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	google.golang.org/genproto v0.0.0-20230807174057-1744710a1577 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+)
+
+replace go.akshayshah.org/connectauth => ../
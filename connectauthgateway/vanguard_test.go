@@ -0,0 +1,93 @@
+package connectauthgateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/vanguard"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestVanguard(t *testing.T) {
+	service := libraryService(t)
+	types := &protoregistry.Types{}
+	attest.Ok(t, types.RegisterMessage(dynamicpb.NewMessageType(service.Methods().Get(0).Input())))
+	// The backend speaks the Connect protocol with JSON, so it doesn't need
+	// generated code.
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := connectauth.GetInfo(r.Context()).(string)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q}`, name)
+	})
+	transcoder, err := vanguard.NewTranscoder([]*vanguard.Service{
+		vanguard.NewServiceWithSchema(
+			service,
+			backend,
+			vanguard.WithTargetProtocols(vanguard.ProtocolConnect),
+			vanguard.WithTargetCodecs(vanguard.CodecJSON),
+			vanguard.WithTypeResolver(types),
+		),
+	})
+	attest.Ok(t, err)
+	routes, err := NewRoutes(service)
+	attest.Ok(t, err)
+
+	var requests []*connectauth.Request
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		requests = append(requests, req)
+		if token, _ := connectauth.BearerToken(req.Header); token != "alice-token" {
+			return nil, connectauth.Errorf("invalid token")
+		}
+		return "alice", nil
+	}, connectauth.WithProcedureResolver(routes.Resolve))
+	handler := auth.Middleware().Wrap(transcoder)
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader("{}"))
+		for k, vals := range header {
+			req.Header[k] = vals
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("rest", func(t *testing.T) {
+		requests = nil
+		rec := serve(http.MethodPost, "/v1/shelves/1/books/2:archive", http.Header{
+			"Content-Type":  []string{"application/json"},
+			"Authorization": []string{"Bearer alice-token"},
+		})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Subsequence(t, rec.Body.String(), `"alice"`)
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/acme.library.v1.LibraryService/ArchiveBook")
+		attest.Equal(t, requests[0].Protocol, connectauth.ProtocolREST)
+	})
+
+	t.Run("rest failure", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/v1/books/2", nil)
+		attest.Equal(t, rec.Code, http.StatusUnauthorized)
+		attest.Subsequence(t, rec.Body.String(), "invalid token")
+	})
+
+	t.Run("connect", func(t *testing.T) {
+		requests = nil
+		rec := serve(http.MethodPost, "/acme.library.v1.LibraryService/GetBook", http.Header{
+			"Content-Type":             []string{"application/json"},
+			"Connect-Protocol-Version": []string{"1"},
+			"Authorization":            []string{"Bearer alice-token"},
+		})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/acme.library.v1.LibraryService/GetBook")
+		attest.Equal(t, requests[0].Protocol, "connect")
+	})
+}
@@ -0,0 +1,174 @@
+// Package connectauthgeoip applies geographic access policies to
+// [connectauth] requests, using MaxMind GeoLite2 or GeoIP2 databases.
+package connectauthgeoip
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+)
+
+// A Location is the resolved location of a client address. Fields are empty
+// if the database doesn't have a record for the address.
+type Location struct {
+	Country      string // ISO 3166-1 alpha-2 code, like "US"
+	ASN          uint32 // autonomous system number
+	Organization string // autonomous system organization
+}
+
+// A Resolver resolves client addresses to locations. [MaxMind] is the
+// standard implementation. Implementations must be safe to call
+// concurrently.
+type Resolver interface {
+	Resolve(netip.Addr) (Location, error)
+}
+
+// PolicyConfig configures a [Policy]. Countries are ISO 3166-1 alpha-2 codes,
+// matched case-insensitively.
+type PolicyConfig struct {
+	// AllowCountries and AllowASNs, if non-empty, list the only countries
+	// and autonomous systems allowed to call the server.
+	AllowCountries []string
+	AllowASNs      []uint32
+	// DenyCountries and DenyASNs list countries and autonomous systems that
+	// may not call the server. They take precedence over the allow lists.
+	DenyCountries []string
+	DenyASNs      []uint32
+	// AllowUnknown allows clients whose country or autonomous system can't
+	// be resolved, even if allow lists are configured. Private and loopback
+	// addresses are never in GeoIP databases, so set AllowUnknown when
+	// clients may connect from internal networks.
+	AllowUnknown bool
+}
+
+// A Policy allows or denies requests based on the client's location. Use its
+// Authenticate method with [connectauth.All] to enforce the policy before
+// validating credentials, and its Enricher to make the location available
+// to handlers:
+//
+//	policy := connectauthgeoip.NewPolicy(db, connectauthgeoip.PolicyConfig{
+//		DenyCountries: []string{"AQ"},
+//	})
+//	auth := connectauth.New(
+//		connectauth.All(policy.Authenticate, authenticateJWT),
+//		connectauth.WithEnricher(policy.Enricher()),
+//	)
+//
+// When the server is behind a proxy or load balancer, also use
+// [connectauth.WithTrustedProxies].
+type Policy struct {
+	resolver       Resolver
+	allowCountries map[string]struct{}
+	denyCountries  map[string]struct{}
+	allowASNs      map[uint32]struct{}
+	denyASNs       map[uint32]struct{}
+	allowUnknown   bool
+}
+
+// NewPolicy constructs a Policy.
+func NewPolicy(resolver Resolver, config PolicyConfig) *Policy {
+	return &Policy{
+		resolver:       resolver,
+		allowCountries: countrySet(config.AllowCountries),
+		denyCountries:  countrySet(config.DenyCountries),
+		allowASNs:      asnSet(config.AllowASNs),
+		denyASNs:       asnSet(config.DenyASNs),
+		allowUnknown:   config.AllowUnknown,
+	}
+}
+
+// Authenticate is a [connectauth.AuthFunc] that rejects requests from
+// denied locations with [connect.CodePermissionDenied] and
+// [connectauth.ReasonPolicy]. Allowed requests return nil authentication
+// information. Addresses that can't be resolved are treated as unknown
+// locations.
+func (p *Policy) Authenticate(_ context.Context, req *connectauth.Request) (any, error) {
+	loc, _ := p.resolve(req)
+	if err := p.check(loc); err != nil {
+		return nil, connectauth.NewReasonError(connect.CodePermissionDenied, connectauth.ReasonPolicy, err)
+	}
+	return nil, nil
+}
+
+// Enricher returns a [connectauth.Enricher] that attaches the client's
+// location to the context. Retrieve it with [FromContext].
+func (p *Policy) Enricher() connectauth.Enricher {
+	return connectauth.Enricher{
+		Enrich: func(ctx context.Context, req *connectauth.Request, _ any) (context.Context, error) {
+			loc, ok := p.resolve(req)
+			if !ok {
+				return ctx, nil
+			}
+			return context.WithValue(ctx, locationKey{}, loc), nil
+		},
+	}
+}
+
+// FromContext returns the client location attached by a Policy's Enricher.
+func FromContext(ctx context.Context) (Location, bool) {
+	loc, ok := ctx.Value(locationKey{}).(Location)
+	return loc, ok
+}
+
+func (p *Policy) resolve(req *connectauth.Request) (Location, bool) {
+	addr, err := netip.ParseAddrPort(req.ClientAddr)
+	if err != nil {
+		return Location{}, false
+	}
+	loc, err := p.resolver.Resolve(addr.Addr().Unmap())
+	if err != nil {
+		return Location{}, false
+	}
+	return loc, true
+}
+
+func (p *Policy) check(loc Location) error {
+	country := strings.ToUpper(loc.Country)
+	if _, ok := p.denyCountries[country]; ok && country != "" {
+		return fmt.Errorf("country %s not allowed", country)
+	}
+	if _, ok := p.denyASNs[loc.ASN]; ok && loc.ASN != 0 {
+		return fmt.Errorf("autonomous system %d not allowed", loc.ASN)
+	}
+	if len(p.allowCountries) > 0 {
+		if country == "" {
+			if !p.allowUnknown {
+				return fmt.Errorf("unknown country not allowed")
+			}
+		} else if _, ok := p.allowCountries[country]; !ok {
+			return fmt.Errorf("country %s not allowed", country)
+		}
+	}
+	if len(p.allowASNs) > 0 {
+		if loc.ASN == 0 {
+			if !p.allowUnknown {
+				return fmt.Errorf("unknown autonomous system not allowed")
+			}
+		} else if _, ok := p.allowASNs[loc.ASN]; !ok {
+			return fmt.Errorf("autonomous system %d not allowed", loc.ASN)
+		}
+	}
+	return nil
+}
+
+type locationKey struct{}
+
+func countrySet(countries []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(countries))
+	for _, c := range countries {
+		set[strings.ToUpper(c)] = struct{}{}
+	}
+	return set
+}
+
+func asnSet(asns []uint32) map[uint32]struct{} {
+	set := make(map[uint32]struct{}, len(asns))
+	for _, asn := range asns {
+		set[asn] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,88 @@
+package connectauthgeoip
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+type mapResolver map[netip.Addr]Location
+
+func (m mapResolver) Resolve(addr netip.Addr) (Location, error) {
+	if loc, ok := m[addr]; ok {
+		return loc, nil
+	}
+	if addr.IsLoopback() {
+		return Location{}, errors.New("oh no")
+	}
+	return Location{}, nil
+}
+
+func TestPolicy(t *testing.T) {
+	resolver := mapResolver{
+		netip.MustParseAddr("192.0.2.1"): {Country: "US", ASN: 64500},
+		netip.MustParseAddr("192.0.2.2"): {Country: "CA", ASN: 64501},
+		netip.MustParseAddr("192.0.2.3"): {Country: "US", ASN: 64666},
+		netip.MustParseAddr("192.0.2.4"): {Country: "FR", ASN: 64500},
+	}
+	tests := []struct {
+		name   string
+		config PolicyConfig
+		allow  []string
+		deny   []string
+	}{
+		{
+			name:   "deny countries",
+			config: PolicyConfig{DenyCountries: []string{"fr"}},
+			allow:  []string{"192.0.2.1", "192.0.2.2", "198.51.100.1"},
+			deny:   []string{"192.0.2.4"},
+		},
+		{
+			name:   "allow countries",
+			config: PolicyConfig{AllowCountries: []string{"US", "CA"}, DenyASNs: []uint32{64666}},
+			allow:  []string{"192.0.2.1", "192.0.2.2"},
+			deny:   []string{"192.0.2.3", "192.0.2.4", "198.51.100.1", "127.0.0.1"},
+		},
+		{
+			name:   "allow unknown",
+			config: PolicyConfig{AllowASNs: []uint32{64500}, AllowUnknown: true},
+			allow:  []string{"192.0.2.1", "192.0.2.4", "198.51.100.1", "127.0.0.1"},
+			deny:   []string{"192.0.2.2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewPolicy(resolver, tt.config)
+			for _, addr := range tt.allow {
+				_, err := policy.Authenticate(context.Background(), &connectauth.Request{ClientAddr: addr + ":443"})
+				attest.Ok(t, err, attest.Sprintf("%s", addr))
+			}
+			for _, addr := range tt.deny {
+				_, err := policy.Authenticate(context.Background(), &connectauth.Request{ClientAddr: addr + ":443"})
+				attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied, attest.Sprintf("%s", addr))
+				attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonPolicy)
+			}
+		})
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	resolver := mapResolver{netip.MustParseAddr("192.0.2.1"): {Country: "US", ASN: 64500}}
+	enricher := NewPolicy(resolver, PolicyConfig{}).Enricher()
+
+	ctx, err := enricher.Enrich(context.Background(), &connectauth.Request{ClientAddr: "[::ffff:192.0.2.1]:443"}, nil)
+	attest.Ok(t, err)
+	loc, ok := FromContext(ctx)
+	attest.True(t, ok)
+	attest.Equal(t, loc, Location{Country: "US", ASN: 64500})
+
+	ctx, err = enricher.Enrich(context.Background(), &connectauth.Request{ClientAddr: "127.0.0.1:443"}, nil)
+	attest.Ok(t, err)
+	_, ok = FromContext(ctx)
+	attest.False(t, ok)
+}
@@ -0,0 +1,83 @@
+package connectauthgeoip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MaxMind is a [Resolver] backed by MaxMind databases in MMDB format. It
+// works with both the free GeoLite2 databases and the commercial GeoIP2
+// databases.
+type MaxMind struct {
+	country *maxminddb.Reader
+	asn     *maxminddb.Reader
+}
+
+var _ Resolver = (*MaxMind)(nil)
+
+// OpenMaxMind opens a country database (like GeoLite2-Country.mmdb or
+// GeoLite2-City.mmdb) and an ASN database (like GeoLite2-ASN.mmdb). Either
+// path may be empty, in which case the corresponding fields of resolved
+// Locations are always empty.
+func OpenMaxMind(countryPath, asnPath string) (*MaxMind, error) {
+	m := &MaxMind{}
+	if countryPath != "" {
+		r, err := maxminddb.Open(countryPath)
+		if err != nil {
+			return nil, err
+		}
+		m.country = r
+	}
+	if asnPath != "" {
+		r, err := maxminddb.Open(asnPath)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.asn = r
+	}
+	return m, nil
+}
+
+// Resolve implements [Resolver].
+func (m *MaxMind) Resolve(addr netip.Addr) (Location, error) {
+	var loc Location
+	ip := net.IP(addr.AsSlice())
+	if m.country != nil {
+		var record struct {
+			Country struct {
+				ISOCode string `maxminddb:"iso_code"`
+			} `maxminddb:"country"`
+		}
+		if err := m.country.Lookup(ip, &record); err != nil {
+			return Location{}, err
+		}
+		loc.Country = record.Country.ISOCode
+	}
+	if m.asn != nil {
+		var record struct {
+			ASN          uint32 `maxminddb:"autonomous_system_number"`
+			Organization string `maxminddb:"autonomous_system_organization"`
+		}
+		if err := m.asn.Lookup(ip, &record); err != nil {
+			return Location{}, err
+		}
+		loc.ASN = record.ASN
+		loc.Organization = record.Organization
+	}
+	return loc, nil
+}
+
+// Close closes the databases.
+func (m *MaxMind) Close() error {
+	var errs []error
+	for _, r := range []*maxminddb.Reader{m.country, m.asn} {
+		if r != nil {
+			errs = append(errs, r.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
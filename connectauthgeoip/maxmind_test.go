@@ -0,0 +1,60 @@
+package connectauthgeoip
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"go.akshayshah.org/attest"
+)
+
+func TestMaxMind(t *testing.T) {
+	dir := t.TempDir()
+	countryPath := writeDB(t, dir, "GeoLite2-Country", "81.2.69.0/24", mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+	})
+	asnPath := writeDB(t, dir, "GeoLite2-ASN", "81.2.69.0/24", mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(20712),
+		"autonomous_system_organization": mmdbtype.String("Andrews & Arnold Ltd"),
+	})
+
+	db, err := OpenMaxMind(countryPath, asnPath)
+	attest.Ok(t, err)
+	t.Cleanup(func() { attest.Ok(t, db.Close()) })
+	loc, err := db.Resolve(netip.MustParseAddr("81.2.69.142"))
+	attest.Ok(t, err)
+	attest.Equal(t, loc, Location{Country: "GB", ASN: 20712, Organization: "Andrews & Arnold Ltd"})
+	loc, err = db.Resolve(netip.MustParseAddr("2001:db8::1"))
+	attest.Ok(t, err)
+	attest.Zero(t, loc)
+
+	countryOnly, err := OpenMaxMind(countryPath, "")
+	attest.Ok(t, err)
+	t.Cleanup(func() { attest.Ok(t, countryOnly.Close()) })
+	loc, err = countryOnly.Resolve(netip.MustParseAddr("81.2.69.142"))
+	attest.Ok(t, err)
+	attest.Equal(t, loc, Location{Country: "GB"})
+
+	_, err = OpenMaxMind(countryPath, filepath.Join(dir, "missing.mmdb"))
+	attest.Error(t, err)
+}
+
+func writeDB(t *testing.T, dir, dbType, network string, record mmdbtype.Map) string {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: dbType, RecordSize: 24})
+	attest.Ok(t, err)
+	_, ipnet, err := net.ParseCIDR(network)
+	attest.Ok(t, err)
+	attest.Ok(t, tree.Insert(ipnet, record))
+	path := filepath.Join(dir, dbType+".mmdb")
+	f, err := os.Create(path)
+	attest.Ok(t, err)
+	_, err = tree.WriteTo(f)
+	attest.Ok(t, err)
+	attest.Ok(t, f.Close())
+	return path
+}
@@ -0,0 +1,54 @@
+// Package connectauthgin adapts [connectauth] middleware to Gin:
+//
+//	auth := connectauth.New(authenticate)
+//	r := gin.New()
+//	r.Use(connectauthgin.Middleware(auth.HTTPMiddleware()))
+//
+// After authentication, the authentication information is stored in the
+// gin.Context under [InfoKey] and attached to the context of c.Request, so
+// handlers can use c.Get or [connectauth.GetInfo] with c.Request.Context().
+// Use [GetInfo] to read it directly from a gin.Context.
+package connectauthgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.akshayshah.org/connectauth"
+)
+
+// InfoKey is the gin.Context key that holds the authentication information.
+const InfoKey = "connectauth.info"
+
+// A Wrapper is connectauth HTTP middleware, like a [connectauth.Middleware]
+// or [connectauth.HTTPMiddleware].
+type Wrapper interface {
+	Wrap(http.Handler) http.Handler
+}
+
+// Middleware converts connectauth middleware to a gin.HandlerFunc. Requests
+// that fail authentication are answered by the connectauth middleware, and
+// the rest of the Gin chain is aborted.
+func Middleware(mw Wrapper) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authenticated := false
+		mw.Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			authenticated = true
+			c.Request = r
+			if info := connectauth.GetInfo(r.Context()); info != nil {
+				c.Set(InfoKey, info)
+			}
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !authenticated {
+			c.Abort()
+		}
+	}
+}
+
+// GetInfo returns the authentication information stored by [Middleware], if
+// any.
+func GetInfo(c *gin.Context) any {
+	info, _ := c.Get(InfoKey)
+	return info
+}
@@ -0,0 +1,44 @@
+package connectauthgin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		if token, _ := connectauth.BearerToken(req.Header); token == "alice-token" {
+			return "alice", nil
+		}
+		return nil, connectauth.Errorf("invalid token")
+	})
+	var calls int
+	r := gin.New()
+	r.Use(Middleware(auth.HTTPMiddleware()))
+	r.Use(func(c *gin.Context) { calls++ })
+	r.GET("/users/:id", func(c *gin.Context) {
+		attest.Equal(t, connectauth.GetInfo(c.Request.Context()), GetInfo(c))
+		name, _ := GetInfo(c).(string)
+		c.String(http.StatusOK, name+" "+c.Param("id"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	attest.Equal(t, rec.Code, http.StatusOK)
+	attest.Equal(t, rec.Body.String(), "alice 42")
+	attest.Equal(t, calls, 1)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	attest.Equal(t, rec.Code, http.StatusUnauthorized)
+	attest.Equal(t, calls, 1)
+}
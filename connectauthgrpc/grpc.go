@@ -0,0 +1,173 @@
+// Package connectauthgrpc adapts [connectauth] authentication to servers built
+// with google.golang.org/grpc, so applications serving both Connect and stock
+// gRPC handlers can share one [connectauth.Authenticator], along with its
+// caches, lockouts, and metrics:
+//
+//	auth := connectauth.New(authenticate)
+//	mux.Handle(foov1connect.NewFooServiceHandler(foo, auth.HandlerOption()))
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(connectauthgrpc.UnaryServerInterceptor(auth)),
+//		grpc.StreamInterceptor(connectauthgrpc.StreamServerInterceptor(auth)),
+//	)
+//
+// AuthFuncs see gRPC requests as [connect.ProtocolGRPC] requests. Metadata
+// becomes the request's Header, and the peer address and TLS connection
+// state come from the gRPC peer.
+package connectauthgrpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that authenticates unary
+// RPCs. If authentication succeeds, the authentication information is
+// attached to the context, and any headers the AuthFunc added to
+// [connectauth.Request].ResponseHeader are sent to the client. Failures are
+// converted to gRPC status errors with the same code, message, and details.
+func UnaryServerInterceptor(auth *connectauth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		header := make(http.Header)
+		authCtx, err := authenticate(ctx, auth, info.FullMethod, connect.StreamTypeUnary, header)
+		if err != nil {
+			return nil, toStatus(ctx, err)
+		}
+		if len(header) > 0 {
+			if err := grpc.SetHeader(authCtx, toMetadata(header)); err != nil {
+				return nil, err
+			}
+		}
+		return handler(authCtx, req)
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that authenticates
+// streaming RPCs. It behaves like [UnaryServerInterceptor].
+func StreamServerInterceptor(auth *connectauth.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		var streamType connect.StreamType
+		if info.IsClientStream {
+			streamType |= connect.StreamTypeClient
+		}
+		if info.IsServerStream {
+			streamType |= connect.StreamTypeServer
+		}
+		header := make(http.Header)
+		ctx, err := authenticate(ss.Context(), auth, info.FullMethod, streamType, header)
+		if err != nil {
+			return toStatus(ss.Context(), err)
+		}
+		if len(header) > 0 {
+			if err := ss.SetHeader(toMetadata(header)); err != nil {
+				return err
+			}
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, auth *connectauth.Authenticator, procedure string, streamType connect.StreamType, header http.Header) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	req := &connectauth.Request{
+		Procedure:      procedure,
+		Protocol:       connect.ProtocolGRPC,
+		StreamType:     streamType,
+		Header:         toHeader(md),
+		ResponseHeader: header,
+	}
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		req.Host = authority[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			req.ClientAddr = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			req.TLS = &info.State
+		}
+	}
+	return auth.AuthenticateRequest(ctx, req)
+}
+
+// toHeader converts gRPC metadata to HTTP headers, as they'd appear to a
+// Connect handler. Pseudo-headers are dropped, and binary values are
+// base64-encoded.
+func toHeader(md metadata.MD) http.Header {
+	header := make(http.Header, len(md))
+	for key, vals := range md {
+		if strings.HasPrefix(key, ":") {
+			continue
+		}
+		key := http.CanonicalHeaderKey(key)
+		for _, val := range vals {
+			if strings.HasSuffix(key, "-Bin") {
+				val = base64.RawStdEncoding.EncodeToString([]byte(val))
+			}
+			header[key] = append(header[key], val)
+		}
+	}
+	return header
+}
+
+// toMetadata converts HTTP headers to gRPC metadata, reversing toHeader.
+func toMetadata(header http.Header) metadata.MD {
+	md := make(metadata.MD, len(header))
+	for key, vals := range header {
+		key := strings.ToLower(key)
+		for _, val := range vals {
+			if strings.HasSuffix(key, "-bin") {
+				if decoded, err := connect.DecodeBinaryHeader(val); err == nil {
+					val = string(decoded)
+				}
+			}
+			md[key] = append(md[key], val)
+		}
+	}
+	return md
+}
+
+// toStatus converts an authentication error to a gRPC status error. Error
+// metadata, like WWW-Authenticate challenges, is sent as trailers.
+func toStatus(ctx context.Context, err error) error {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return status.FromContextError(err).Err()
+	}
+	if meta := connectErr.Meta(); len(meta) > 0 {
+		_ = grpc.SetTrailer(ctx, toMetadata(meta))
+	}
+	proto := &spb.Status{
+		Code:    int32(connectErr.Code()),
+		Message: connectErr.Message(),
+	}
+	for _, detail := range connectErr.Details() {
+		proto.Details = append(proto.Details, &anypb.Any{
+			TypeUrl: "type.googleapis.com/" + detail.Type(),
+			Value:   detail.Bytes(),
+		})
+	}
+	return status.FromProto(proto).Err()
+}
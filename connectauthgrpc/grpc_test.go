@@ -0,0 +1,142 @@
+package connectauthgrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestInterceptors(t *testing.T) {
+	var requests []*connectauth.Request
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		requests = append(requests, req)
+		switch token, _ := connectauth.BearerToken(req.Header); token {
+		case "alice-token":
+			req.ResponseHeader.Set("X-Session", "renewed")
+			return "alice", nil
+		case "slow-down":
+			return nil, connectauth.RetryErrorf(connect.CodeResourceExhausted, 2*time.Second, "too many attempts")
+		default:
+			return nil, connectauth.Errorf("invalid token")
+		}
+	})
+	var infos []any
+	capture := func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		infos = append(infos, connectauth.GetInfo(ctx))
+		return handler(ctx, req)
+	}
+	captureStream := func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		infos = append(infos, connectauth.GetInfo(ss.Context()))
+		return handler(srv, ss)
+	}
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(auth), capture),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(auth), captureStream),
+	)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	lis := bufconn.Listen(1024 * 1024)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	attest.Ok(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := healthpb.NewHealthClient(conn)
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		requests, infos = nil, nil
+		var header metadata.MD
+		_, err := client.Check(withToken("alice-token"), &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+		attest.Ok(t, err)
+		attest.Equal(t, header.Get("x-session"), []string{"renewed"})
+		attest.Equal(t, infos, []any{"alice"})
+		attest.Equal(t, len(requests), 1)
+		req := requests[0]
+		attest.Equal(t, req.Procedure, "/grpc.health.v1.Health/Check")
+		attest.Equal(t, req.Protocol, connect.ProtocolGRPC)
+		attest.Equal(t, req.StreamType, connect.StreamTypeUnary)
+		attest.Equal(t, req.Header.Get("Authorization"), "Bearer alice-token")
+		attest.Zero(t, req.Header.Get(":authority"))
+		attest.NotZero(t, req.ClientAddr)
+		attest.NotZero(t, req.Host)
+	})
+
+	t.Run("unary failure", func(t *testing.T) {
+		infos = nil
+		_, err := client.Check(withToken("bob-token"), &healthpb.HealthCheckRequest{})
+		attest.Equal(t, status.Code(err), codes.Unauthenticated)
+		attest.Equal(t, status.Convert(err).Message(), "invalid token")
+		attest.Zero(t, infos)
+
+		var trailer metadata.MD
+		_, err = client.Check(withToken("slow-down"), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		st := status.Convert(err)
+		attest.Equal(t, st.Code(), codes.ResourceExhausted)
+		attest.Equal(t, trailer.Get("retry-after"), []string{"2"})
+		attest.Equal(t, len(st.Details()), 1)
+		info, ok := st.Details()[0].(*errdetails.RetryInfo)
+		attest.True(t, ok)
+		attest.Equal(t, info.GetRetryDelay().AsDuration(), 2*time.Second)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		requests, infos = nil, nil
+		ctx, cancel := context.WithCancel(withToken("alice-token"))
+		defer cancel()
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		attest.Ok(t, err)
+		_, err = stream.Recv()
+		attest.Ok(t, err)
+		header, err := stream.Header()
+		attest.Ok(t, err)
+		attest.Equal(t, header.Get("x-session"), []string{"renewed"})
+		attest.Equal(t, infos, []any{"alice"})
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].StreamType, connect.StreamTypeServer)
+
+		stream, err = client.Watch(withToken("bob-token"), &healthpb.HealthCheckRequest{})
+		attest.Ok(t, err)
+		_, err = stream.Recv()
+		attest.Equal(t, status.Code(err), codes.Unauthenticated)
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	md := metadata.Pairs(
+		":authority", "example.com",
+		"authorization", "Bearer token",
+		"trace-bin", "\x00\x01binary",
+	)
+	header := toHeader(md)
+	attest.Equal(t, header, http.Header{
+		"Authorization": []string{"Bearer token"},
+		"Trace-Bin":     []string{"AAFiaW5hcnk"},
+	})
+	attest.Equal(t, toMetadata(header), metadata.Pairs(
+		"authorization", "Bearer token",
+		"trace-bin", "\x00\x01binary",
+	))
+}
@@ -0,0 +1,101 @@
+package connectauthocsf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// WithHTTPClient configures the client used by an [HTTPSink]. By default,
+// HTTPSink uses a client with a 10 second timeout, so an unresponsive
+// collector can't hold requests open indefinitely. Other sinks ignore this
+// option.
+func WithHTTPClient(client *http.Client) Option {
+	return optionFunc(func(c *config) {
+		c.HTTPClient = client
+	})
+}
+
+// WithHeader adds a header to every request sent by an [HTTPSink], which is
+// typically used to authenticate to the collector. Other sinks ignore this
+// option.
+func WithHeader(key, value string) Option {
+	return optionFunc(func(c *config) {
+		if c.Header == nil {
+			c.Header = make(map[string][]string)
+		}
+		key = http.CanonicalHeaderKey(key)
+		c.Header[key] = append(c.Header[key], value)
+	})
+}
+
+// An HTTPSink is a [connectauth.AuditSink] that POSTs OCSF events to an HTTP
+// collector as a JSON array. Any response other than 2xx is an error.
+//
+// Each call to Audit makes a network request on the request path, so most
+// applications should wrap HTTPSink with [connectauth.NewAsyncAuditSink],
+// which also sends events in batches.
+type HTTPSink struct {
+	endpoint string
+	product  Product
+	client   *http.Client
+	header   http.Header
+}
+
+var _ connectauth.BatchAuditSink = (*HTTPSink)(nil)
+
+// NewHTTPSink constructs an HTTPSink that sends events to endpoint.
+func NewHTTPSink(endpoint string, opts ...Option) *HTTPSink {
+	c := newConfig(opts)
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &HTTPSink{
+		endpoint: endpoint,
+		product:  c.Product,
+		client:   client,
+		header:   c.Header,
+	}
+}
+
+// Audit implements [connectauth.AuditSink].
+func (s *HTTPSink) Audit(ctx context.Context, ev *connectauth.AuditEvent) error {
+	return s.AuditBatch(ctx, []*connectauth.AuditEvent{ev})
+}
+
+// AuditBatch implements [connectauth.BatchAuditSink].
+func (s *HTTPSink) AuditBatch(ctx context.Context, events []*connectauth.AuditEvent) error {
+	out := make([]*Event, len(events))
+	for i, ev := range events {
+		out[i] = NewEvent(ev, s.product)
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vals := range s.header {
+		req.Header[k] = vals
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("ocsf collector returned %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package connectauthocsf
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/memhttp/memhttptest"
+)
+
+func TestHTTPSink(t *testing.T) {
+	received := make(chan []Event, 1)
+	srv := memhttptest.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Splunk token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		attest.Equal(t, r.Header.Get("Content-Type"), "application/json")
+		var events []Event
+		attest.Ok(t, json.NewDecoder(r.Body).Decode(&events))
+		received <- events
+	}))
+
+	sink := NewHTTPSink(
+		srv.URL()+"/collect",
+		WithHTTPClient(srv.Client()),
+		WithHeader("Authorization", "Splunk token"),
+	)
+	attest.Ok(t, sink.AuditBatch(context.Background(), []*connectauth.AuditEvent{
+		{Decision: connectauth.DecisionAllow, Subject: "Ali Baba"},
+		{Decision: connectauth.DecisionDeny},
+	}))
+	events := <-received
+	attest.Equal(t, len(events), 2)
+	attest.Equal(t, events[0].User.Name, "Ali Baba")
+	attest.Equal(t, events[1].StatusID, statusFailure)
+
+	unauthorized := NewHTTPSink(srv.URL()+"/collect", WithHTTPClient(srv.Client()))
+	err := unauthorized.Audit(context.Background(), &connectauth.AuditEvent{})
+	attest.Error(t, err)
+	attest.Subsequence(t, err.Error(), "403")
+}
@@ -0,0 +1,241 @@
+// Package connectauthocsf exports [connectauth] audit events in the Open
+// Cybersecurity Schema Framework (OCSF) format, so security teams can ingest
+// authentication decisions into a SIEM without a bespoke adapter.
+//
+// Audit events map to the OCSF Authentication class (class_uid 3002) with a
+// Logon activity. Fields without an OCSF equivalent, like the Connect
+// protocol and failure reason, are stored in the unmapped object.
+package connectauthocsf
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// SchemaVersion is the version of the OCSF schema used by this package.
+const SchemaVersion = "1.1.0"
+
+// OCSF Authentication class constants.
+const (
+	classUID    = 3002
+	categoryUID = 3
+	activityUID = 1 // Logon
+)
+
+// OCSF status and severity identifiers.
+const (
+	statusSuccess = 1
+	statusFailure = 2
+	statusOther   = 99
+
+	severityInformational = 1
+	severityLow           = 2
+)
+
+// An Event is an OCSF Authentication event.
+type Event struct {
+	Time         int64          `json:"time"` // milliseconds since the Unix epoch
+	ActivityID   int            `json:"activity_id"`
+	ActivityName string         `json:"activity_name"`
+	CategoryUID  int            `json:"category_uid"`
+	CategoryName string         `json:"category_name"`
+	ClassUID     int            `json:"class_uid"`
+	ClassName    string         `json:"class_name"`
+	TypeUID      int            `json:"type_uid"`
+	TypeName     string         `json:"type_name"`
+	SeverityID   int            `json:"severity_id"`
+	Severity     string         `json:"severity"`
+	StatusID     int            `json:"status_id"`
+	Status       string         `json:"status"`
+	StatusCode   string         `json:"status_code,omitempty"`
+	StatusDetail string         `json:"status_detail,omitempty"`
+	Message      string         `json:"message"`
+	Metadata     Metadata       `json:"metadata"`
+	User         *User          `json:"user,omitempty"`
+	SrcEndpoint  *Endpoint      `json:"src_endpoint,omitempty"`
+	Service      *Service       `json:"service,omitempty"`
+	Unmapped     map[string]any `json:"unmapped,omitempty"`
+}
+
+// Metadata is the OCSF metadata object.
+type Metadata struct {
+	Version        string  `json:"version"`
+	Product        Product `json:"product"`
+	CorrelationUID string  `json:"correlation_uid,omitempty"` // the request ID
+}
+
+// Product is the OCSF product object. It describes the application that
+// produced the event.
+type Product struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+// User is the OCSF user object.
+type User struct {
+	Name string `json:"name"`
+}
+
+// Endpoint is the OCSF network endpoint object.
+type Endpoint struct {
+	IP   string `json:"ip,omitempty"`
+	Port int    `json:"port,omitempty"`
+}
+
+// Service is the OCSF service object. Its name is the fully-qualified
+// Protobuf service name.
+type Service struct {
+	Name string `json:"name"`
+}
+
+// An Option configures an OCSF sink.
+type Option interface {
+	apply(*config)
+}
+
+// WithProduct sets the product recorded in each event's metadata. By
+// default, the product is "connectauth" from vendor "connectauth".
+func WithProduct(name, vendor string) Option {
+	return optionFunc(func(c *config) {
+		c.Product = Product{Name: name, VendorName: vendor}
+	})
+}
+
+// NewEvent converts an audit event to OCSF.
+func NewEvent(ev *connectauth.AuditEvent, product Product) *Event {
+	out := &Event{
+		Time:         ev.Time.UnixMilli(),
+		ActivityID:   activityUID,
+		ActivityName: "Logon",
+		CategoryUID:  categoryUID,
+		CategoryName: "Identity & Access Management",
+		ClassUID:     classUID,
+		ClassName:    "Authentication",
+		TypeUID:      classUID*100 + activityUID,
+		TypeName:     "Authentication: Logon",
+		SeverityID:   severityInformational,
+		Severity:     "Informational",
+		StatusID:     statusSuccess,
+		Status:       "Success",
+		Message:      "authentication succeeded",
+		Metadata: Metadata{
+			Version:        SchemaVersion,
+			Product:        product,
+			CorrelationUID: ev.RequestID,
+		},
+		Unmapped: map[string]any{
+			"procedure": ev.Procedure,
+		},
+	}
+	switch ev.Decision {
+	case connectauth.DecisionDeny:
+		out.SeverityID, out.Severity = severityLow, "Low"
+		out.StatusID, out.Status = statusFailure, "Failure"
+		out.StatusCode = ev.Code
+		out.StatusDetail = ev.Error
+		out.Message = "authentication failed"
+		if ev.Reason != "" {
+			out.Unmapped["reason"] = string(ev.Reason)
+		}
+	case connectauth.DecisionExempt:
+		out.StatusID, out.Status = statusOther, "Exempt"
+		out.Message = "authentication skipped for exempt procedure"
+	}
+	if ev.Protocol != "" {
+		out.Unmapped["protocol"] = ev.Protocol
+	}
+	if ev.Subject != "" {
+		out.User = &User{Name: ev.Subject}
+	}
+	if ev.ClientAddr != "" {
+		out.SrcEndpoint = parseEndpoint(ev.ClientAddr)
+	}
+	if service := serviceName(ev.Procedure); service != "" {
+		out.Service = &Service{Name: service}
+	}
+	return out
+}
+
+// A JSONSink is a [connectauth.AuditSink] that writes OCSF events to an
+// [io.Writer] as newline-delimited JSON. Most SIEM agents can tail a file in
+// this format.
+type JSONSink struct {
+	product Product
+	mu      sync.Mutex
+	w       io.Writer
+}
+
+var _ connectauth.BatchAuditSink = (*JSONSink)(nil)
+
+// NewJSONSink constructs a JSONSink. Writes to w are serialized.
+func NewJSONSink(w io.Writer, opts ...Option) *JSONSink {
+	c := newConfig(opts)
+	return &JSONSink{product: c.Product, w: w}
+}
+
+// Audit implements [connectauth.AuditSink].
+func (s *JSONSink) Audit(ctx context.Context, ev *connectauth.AuditEvent) error {
+	return s.AuditBatch(ctx, []*connectauth.AuditEvent{ev})
+}
+
+// AuditBatch implements [connectauth.BatchAuditSink].
+func (s *JSONSink) AuditBatch(_ context.Context, events []*connectauth.AuditEvent) error {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	for _, ev := range events {
+		if err := enc.Encode(NewEvent(ev, s.product)); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, b.String())
+	return err
+}
+
+func parseEndpoint(addr string) *Endpoint {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return &Endpoint{IP: addr}
+	}
+	p, _ := strconv.Atoi(port)
+	return &Endpoint{IP: host, Port: p}
+}
+
+// serviceName extracts "acme.foo.v1.FooService" from
+// "/acme.foo.v1.FooService/Bar".
+func serviceName(procedure string) string {
+	procedure = strings.TrimPrefix(procedure, "/")
+	if i := strings.LastIndexByte(procedure, '/'); i > 0 {
+		return procedure[:i]
+	}
+	return ""
+}
+
+type config struct {
+	Product    Product
+	HTTPClient *http.Client
+	Header     map[string][]string
+}
+
+func newConfig(opts []Option) *config {
+	c := config{
+		Product: Product{Name: "connectauth", VendorName: "connectauth"},
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	return &c
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
@@ -0,0 +1,74 @@
+package connectauthocsf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestNewEvent(t *testing.T) {
+	now := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	product := Product{Name: "acme-api", VendorName: "Acme"}
+
+	allow := NewEvent(&connectauth.AuditEvent{
+		Time:       now,
+		Decision:   connectauth.DecisionAllow,
+		Subject:    "Ali Baba",
+		Procedure:  "/acme.foo.v1.FooService/Bar",
+		Protocol:   "grpc",
+		ClientAddr: "192.0.2.1:1234",
+		RequestID:  "abc123",
+	}, product)
+	attest.Equal(t, allow.Time, now.UnixMilli())
+	attest.Equal(t, allow.ClassUID, 3002)
+	attest.Equal(t, allow.TypeUID, 300201)
+	attest.Equal(t, allow.StatusID, statusSuccess)
+	attest.Equal(t, allow.User, &User{Name: "Ali Baba"})
+	attest.Equal(t, allow.SrcEndpoint, &Endpoint{IP: "192.0.2.1", Port: 1234})
+	attest.Equal(t, allow.Service, &Service{Name: "acme.foo.v1.FooService"})
+	attest.Equal(t, allow.Metadata, Metadata{Version: SchemaVersion, Product: product, CorrelationUID: "abc123"})
+	attest.Equal(t, allow.Unmapped, map[string]any{"procedure": "/acme.foo.v1.FooService/Bar", "protocol": "grpc"})
+
+	deny := NewEvent(&connectauth.AuditEvent{
+		Time:      now,
+		Decision:  connectauth.DecisionDeny,
+		Procedure: "/acme.foo.v1.FooService/Bar",
+		Code:      "unauthenticated",
+		Reason:    connectauth.ReasonExpired,
+		Error:     "unauthenticated: token expired",
+	}, product)
+	attest.Equal(t, deny.StatusID, statusFailure)
+	attest.Equal(t, deny.Status, "Failure")
+	attest.Equal(t, deny.StatusCode, "unauthenticated")
+	attest.Equal(t, deny.StatusDetail, "unauthenticated: token expired")
+	attest.Equal(t, deny.Unmapped["reason"], any("expired"))
+	attest.Zero(t, deny.User)
+
+	exempt := NewEvent(&connectauth.AuditEvent{Decision: connectauth.DecisionExempt}, product)
+	attest.Equal(t, exempt.StatusID, statusOther)
+	attest.Zero(t, exempt.Service)
+}
+
+func TestJSONSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewJSONSink(&out, WithProduct("acme-api", "Acme"))
+	attest.Ok(t, sink.Audit(context.Background(), &connectauth.AuditEvent{Decision: connectauth.DecisionAllow}))
+	attest.Ok(t, sink.AuditBatch(context.Background(), []*connectauth.AuditEvent{
+		{Decision: connectauth.DecisionDeny},
+		{Decision: connectauth.DecisionExempt},
+	}))
+	dec := json.NewDecoder(&out)
+	var statuses []string
+	for dec.More() {
+		var ev Event
+		attest.Ok(t, dec.Decode(&ev))
+		attest.Equal(t, ev.Metadata.Product.Name, "acme-api")
+		statuses = append(statuses, ev.Status)
+	}
+	attest.Equal(t, statuses, []string{"Success", "Failure", "Exempt"})
+}
@@ -0,0 +1,232 @@
+// Package connectauthotel instruments [connectauth] authentication with
+// OpenTelemetry.
+package connectauthotel
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const instrumentationName = "go.akshayshah.org/connectauth/connectauthotel"
+
+// Attribute keys used on spans.
+const (
+	ProcedureKey    = attribute.Key("connectauth.procedure")
+	ProtocolKey     = attribute.Key("connectauth.protocol")
+	OutcomeKey      = attribute.Key("connectauth.outcome")
+	FailureClassKey = attribute.Key("connectauth.failure_class")
+	ReasonKey       = attribute.Key("connectauth.reason")
+	SubjectHashKey  = attribute.Key("connectauth.subject_hash")
+)
+
+// UnknownProcedure is the procedure attribute of metrics for procedures that
+// Instrumentation doesn't recognize (see [WithProcedures]).
+const UnknownProcedure = "unknown"
+
+// An Option configures [Instrumentation].
+type Option interface {
+	apply(*config)
+}
+
+// WithTracerProvider configures the TracerProvider used to create spans. By
+// default, Instrumentation uses the global TracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return optionFunc(func(c *config) {
+		c.TracerProvider = provider
+	})
+}
+
+// WithMeterProvider configures the MeterProvider used to record metrics. By
+// default, Instrumentation uses the global MeterProvider.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return optionFunc(func(c *config) {
+		c.MeterProvider = provider
+	})
+}
+
+// WithSubject configures the function used to identify the authenticated
+// subject. By default, Instrumentation uses [connectauth.SubjectOf].
+func WithSubject(subject func(info any) string) Option {
+	return optionFunc(func(c *config) {
+		c.Subject = subject
+	})
+}
+
+// WithProcedures lists the procedures that metrics are attributed to by name.
+// Metrics for any other procedure have the procedure attribute
+// [UnknownProcedure]. By default, Instrumentation recognizes procedures whose
+// descriptors are registered in [protoregistry.GlobalFiles], as generated
+// code does. Spans always record the requested procedure.
+//
+// The middleware derives procedures from URL paths, so unauthenticated
+// clients choose them. Recording arbitrary procedures would let clients
+// create unlimited metric streams.
+func WithProcedures(procedures ...string) Option {
+	return optionFunc(func(c *config) {
+		if c.Procedures == nil {
+			c.Procedures = make(map[string]struct{}, len(procedures))
+		}
+		for _, p := range procedures {
+			c.Procedures[p] = struct{}{}
+		}
+	})
+}
+
+// Instrumentation wraps AuthFuncs with OpenTelemetry instrumentation. It
+// records two metrics:
+//   - connectauth.attempts, a counter of authentication attempts.
+//   - connectauth.duration, a histogram of AuthFunc latency in seconds.
+//
+// Both metrics have procedure, protocol, outcome, failure class, and reason
+// attributes. Unrecognized procedures are recorded as [UnknownProcedure]. The
+// outcome is "success", "failure", or "degraded" (see
+// [connectauth.FlagDegraded]), and the reason attribute holds the
+// [connectauth.Reason] for failures.
+type Instrumentation struct {
+	tracer     trace.Tracer
+	attempts   metric.Int64Counter
+	duration   metric.Float64Histogram
+	subject    func(any) string
+	procedures map[string]struct{} // nil uses the global registry
+}
+
+// New constructs Instrumentation. It returns an error if the metric
+// instruments can't be created.
+func New(opts ...Option) (*Instrumentation, error) {
+	c := config{
+		TracerProvider: otel.GetTracerProvider(),
+		MeterProvider:  otel.GetMeterProvider(),
+		Subject:        connectauth.SubjectOf,
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	meter := c.MeterProvider.Meter(instrumentationName)
+	attempts, err := meter.Int64Counter(
+		"connectauth.attempts",
+		metric.WithDescription("Authentication attempts."),
+		metric.WithUnit("{attempt}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram(
+		"connectauth.duration",
+		metric.WithDescription("Time spent authenticating requests."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Instrumentation{
+		tracer:     c.TracerProvider.Tracer(instrumentationName),
+		attempts:   attempts,
+		duration:   duration,
+		subject:    c.Subject,
+		procedures: c.Procedures,
+	}, nil
+}
+
+// Wrap decorates an AuthFunc with instrumentation. Each call to the AuthFunc
+// is counted, timed, and wrapped in a span, which is a child of the incoming
+// request's span (if any). The span records the procedure, protocol,
+// outcome, failure class, reason, and a SHA-256 hash of the authenticated
+// subject.
+func (i *Instrumentation) Wrap(auth connectauth.AuthFunc) connectauth.AuthFunc {
+	return func(ctx context.Context, req *connectauth.Request) (any, error) {
+		ctx, span := i.tracer.Start(
+			ctx,
+			"connectauth.Authenticate",
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(
+				ProcedureKey.String(req.Procedure),
+				ProtocolKey.String(req.Protocol),
+			),
+		)
+		defer span.End()
+		start := time.Now()
+		info, err := auth(ctx, req)
+		elapsed := time.Since(start)
+		attrs := []attribute.KeyValue{
+			ProcedureKey.String(i.procedure(req.Procedure)),
+			ProtocolKey.String(req.Protocol),
+		}
+		if err != nil {
+			attrs = append(attrs,
+				OutcomeKey.String("failure"),
+				FailureClassKey.String(failureClass(err)),
+				ReasonKey.String(string(connectauth.ReasonOf(err))),
+			)
+			span.SetAttributes(attrs[2:]...)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			outcome := "success"
+			if req.HasFlag(connectauth.FlagDegraded) {
+				outcome = "degraded"
+			}
+			attrs = append(attrs, OutcomeKey.String(outcome))
+			span.SetAttributes(attrs[2:]...)
+			if subject := i.subject(info); subject != "" {
+				span.SetAttributes(SubjectHashKey.String(hashSubject(subject)))
+			}
+		}
+		set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+		i.attempts.Add(ctx, 1, set)
+		i.duration.Record(ctx, elapsed.Seconds(), set)
+		return info, err
+	}
+}
+
+// procedure returns the procedure attribute for metrics, which must have
+// bounded cardinality. Requests that aren't RPCs have an empty procedure.
+func (i *Instrumentation) procedure(procedure string) string {
+	if procedure == "" {
+		return ""
+	}
+	if i.procedures != nil {
+		if _, ok := i.procedures[procedure]; ok {
+			return procedure
+		}
+		return UnknownProcedure
+	}
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(procedure, "/"), "/", "."))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		if _, ok := desc.(protoreflect.MethodDescriptor); ok {
+			return procedure
+		}
+	}
+	return UnknownProcedure
+}
+
+func failureClass(err error) string {
+	return connect.CodeOf(err).String()
+}
+
+func hashSubject(subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(sum[:])
+}
+
+type config struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Subject        func(any) string
+	Procedures     map[string]struct{}
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
@@ -0,0 +1,164 @@
+package connectauthotel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func authenticate(_ context.Context, req *connectauth.Request) (any, error) {
+	if req.Header.Get("Authorization") != "Bearer opensesame" {
+		return nil, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "wrong passphrase")
+	}
+	return "Ali Baba", nil
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	instrumentation, err := New(WithTracerProvider(provider))
+	attest.Ok(t, err)
+	auth := instrumentation.Wrap(authenticate)
+
+	parentCtx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	_, err = auth(parentCtx, &connectauth.Request{
+		Procedure: "/acme.v1.Svc/Get",
+		Protocol:  "connect",
+		Header:    http.Header{"Authorization": []string{"Bearer opensesame"}},
+	})
+	attest.Ok(t, err)
+	_, err = auth(parentCtx, &connectauth.Request{
+		Procedure: "/acme.v1.Svc/Get",
+		Protocol:  "grpc",
+		Header:    http.Header{},
+	})
+	attest.Error(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	attest.Equal(t, len(spans), 3)
+	success, failure := spans[0], spans[1]
+	attest.Equal(t, success.Name(), "connectauth.Authenticate")
+	attest.Equal(t, success.Parent().SpanID(), parent.SpanContext().SpanID())
+	attrs := attribute.NewSet(success.Attributes()...)
+	outcome, _ := attrs.Value(OutcomeKey)
+	attest.Equal(t, outcome.AsString(), "success")
+	subject, _ := attrs.Value(SubjectHashKey)
+	attest.Equal(t, subject.AsString(), hashSubject("Ali Baba"))
+	attest.NotEqual(t, subject.AsString(), "Ali Baba")
+
+	attrs = attribute.NewSet(failure.Attributes()...)
+	outcome, _ = attrs.Value(OutcomeKey)
+	attest.Equal(t, outcome.AsString(), "failure")
+	class, _ := attrs.Value(FailureClassKey)
+	attest.Equal(t, class.AsString(), "unauthenticated")
+	reason, _ := attrs.Value(ReasonKey)
+	attest.Equal(t, reason.AsString(), string(connectauth.ReasonInvalidCredentials))
+	protocol, _ := attrs.Value(ProtocolKey)
+	attest.Equal(t, protocol.AsString(), "grpc")
+	attest.Equal(t, failure.Status().Code, codes.Error)
+	_, hasSubject := attrs.Value(SubjectHashKey)
+	attest.False(t, hasSubject)
+}
+
+func TestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	instrumentation, err := New(WithMeterProvider(provider))
+	attest.Ok(t, err)
+	auth := instrumentation.Wrap(authenticate)
+	for _, header := range []string{"Bearer opensesame", "Bearer opensesame", "Bearer wrong"} {
+		auth(context.Background(), &connectauth.Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  "connect",
+			Header:    http.Header{"Authorization": []string{header}},
+		})
+	}
+	// Clients choose the procedures of unauthenticated requests.
+	for _, procedure := range []string{"/attacker.v1.Svc/A", "/attacker.v1.Svc/B"} {
+		auth(context.Background(), &connectauth.Request{
+			Procedure: procedure,
+			Protocol:  "connect",
+			Header:    http.Header{},
+		})
+	}
+
+	var rm metricdata.ResourceMetrics
+	attest.Ok(t, reader.Collect(context.Background(), &rm))
+	attest.Equal(t, len(rm.ScopeMetrics), 1)
+	metrics := make(map[string]metricdata.Metrics)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	attempts, ok := metrics["connectauth.attempts"].Data.(metricdata.Sum[int64])
+	attest.True(t, ok)
+	counts := make(map[string]int64)
+	procedures := make(map[string]int64)
+	for _, dp := range attempts.DataPoints {
+		outcome, _ := dp.Attributes.Value(OutcomeKey)
+		counts[outcome.AsString()] += dp.Value
+		procedure, _ := dp.Attributes.Value(ProcedureKey)
+		procedures[procedure.AsString()] += dp.Value
+	}
+	attest.Equal(t, counts, map[string]int64{"success": 2, "failure": 3})
+	attest.Equal(t, procedures, map[string]int64{"/acme.v1.Svc/Get": 3, UnknownProcedure: 2})
+
+	duration, ok := metrics["connectauth.duration"].Data.(metricdata.Histogram[float64])
+	attest.True(t, ok)
+	var total uint64
+	for _, dp := range duration.DataPoints {
+		total += dp.Count
+	}
+	attest.Equal(t, total, 5)
+}
+
+func TestProcedures(t *testing.T) {
+	registered, err := New()
+	attest.Ok(t, err)
+	attest.Equal(t, registered.procedure("/acme.v1.Svc/Get"), "/acme.v1.Svc/Get")
+	attest.Equal(t, registered.procedure("/acme.v1.Svc/Nope"), UnknownProcedure)
+	attest.Equal(t, registered.procedure("/wp-admin/setup.php"), UnknownProcedure)
+	attest.Equal(t, registered.procedure(""), "")
+
+	listed, err := New(WithProcedures("/acme.v1.Other/List"))
+	attest.Ok(t, err)
+	attest.Equal(t, listed.procedure("/acme.v1.Other/List"), "/acme.v1.Other/List")
+	attest.Equal(t, listed.procedure("/acme.v1.Svc/Get"), UnknownProcedure)
+}
+
+func init() {
+	// Register a service, as generated code would.
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("connectauthotel/acme.proto"),
+		Package:     proto.String("acme.v1"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Svc"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".acme.v1.Empty"),
+				OutputType: proto.String(".acme.v1.Empty"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
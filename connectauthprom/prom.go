@@ -0,0 +1,193 @@
+// Package connectauthprom exposes [connectauth] authentication metrics to
+// Prometheus.
+package connectauthprom
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var labelNames = []string{"procedure", "protocol", "outcome", "failure_class", "reason"}
+
+// UnknownProcedure is the procedure label of requests for procedures that
+// the Collector doesn't recognize (see [WithProcedures]).
+const UnknownProcedure = "unknown"
+
+// An Option configures a [Collector].
+type Option interface {
+	apply(*config)
+}
+
+// WithNamespace sets the namespace of the Collector's metrics. The default
+// namespace is "connectauth", so metrics are named
+// connectauth_attempts_total and connectauth_duration_seconds.
+func WithNamespace(namespace string) Option {
+	return optionFunc(func(c *config) {
+		c.Namespace = namespace
+	})
+}
+
+// WithConstLabels adds constant labels to all the Collector's metrics.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return optionFunc(func(c *config) {
+		c.ConstLabels = labels
+	})
+}
+
+// WithBuckets sets the buckets of the latency histogram. By default, the
+// Collector uses [prometheus.DefBuckets].
+func WithBuckets(buckets []float64) Option {
+	return optionFunc(func(c *config) {
+		c.Buckets = buckets
+	})
+}
+
+// WithProcedures lists the procedures that the Collector labels by name.
+// Requests for any other procedure are labeled [UnknownProcedure]. By
+// default, the Collector recognizes procedures whose descriptors are in
+// [protoregistry.GlobalFiles], where generated code registers them.
+//
+// [connectauth.Middleware] takes procedures from URL paths, which
+// unauthenticated clients control, so labeling every procedure would let
+// them create unlimited time series.
+func WithProcedures(procedures ...string) Option {
+	return optionFunc(func(c *config) {
+		if c.Procedures == nil {
+			c.Procedures = make(map[string]struct{}, len(procedures))
+		}
+		for _, p := range procedures {
+			c.Procedures[p] = struct{}{}
+		}
+	})
+}
+
+// WithPrometheusRegistry registers the Collector when it's constructed.
+func WithPrometheusRegistry(registry prometheus.Registerer) Option {
+	return optionFunc(func(c *config) {
+		c.Registry = registry
+	})
+}
+
+// A Collector is a [prometheus.Collector] that records the same metrics as
+// the connectauthotel package: a counter of authentication attempts and a
+// histogram of AuthFunc latency. Both have procedure, protocol, outcome,
+// failure_class, and reason labels. The outcome is "success", "failure", or
+// "degraded" (see [connectauth.FlagDegraded]), and the reason label holds the
+// [connectauth.Reason] for failures.
+type Collector struct {
+	attempts   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	procedures map[string]struct{} // nil uses the global registry
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New constructs a Collector. If configured with [WithPrometheusRegistry], it
+// also registers the Collector and returns any registration errors.
+func New(opts ...Option) (*Collector, error) {
+	c := config{
+		Namespace: "connectauth",
+		Buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	collector := &Collector{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   c.Namespace,
+			Name:        "attempts_total",
+			Help:        "Authentication attempts.",
+			ConstLabels: c.ConstLabels,
+		}, labelNames),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   c.Namespace,
+			Name:        "duration_seconds",
+			Help:        "Time spent authenticating requests.",
+			ConstLabels: c.ConstLabels,
+			Buckets:     c.Buckets,
+		}, labelNames),
+		procedures: c.Procedures,
+	}
+	if c.Registry != nil {
+		if err := c.Registry.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return collector, nil
+}
+
+// Describe implements [prometheus.Collector].
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.attempts.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+// Collect implements [prometheus.Collector].
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.attempts.Collect(ch)
+	c.duration.Collect(ch)
+}
+
+// Wrap decorates an AuthFunc so that each call is counted and timed.
+func (c *Collector) Wrap(auth connectauth.AuthFunc) connectauth.AuthFunc {
+	return func(ctx context.Context, req *connectauth.Request) (any, error) {
+		start := time.Now()
+		info, err := auth(ctx, req)
+		elapsed := time.Since(start)
+		outcome, class := "success", ""
+		if err != nil {
+			outcome, class = "failure", failureClass(err)
+		} else if req.HasFlag(connectauth.FlagDegraded) {
+			outcome = "degraded"
+		}
+		reason := string(connectauth.ReasonOf(err))
+		labels := []string{c.procedure(req.Procedure), req.Protocol, outcome, class, reason}
+		c.attempts.WithLabelValues(labels...).Inc()
+		c.duration.WithLabelValues(labels...).Observe(elapsed.Seconds())
+		return info, err
+	}
+}
+
+// procedure returns the procedure label, which must have bounded
+// cardinality. Requests that aren't RPCs have an empty procedure.
+func (c *Collector) procedure(procedure string) string {
+	if procedure == "" {
+		return ""
+	}
+	if c.procedures != nil {
+		if _, ok := c.procedures[procedure]; ok {
+			return procedure
+		}
+		return UnknownProcedure
+	}
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(procedure, "/"), "/", "."))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		if _, ok := desc.(protoreflect.MethodDescriptor); ok {
+			return procedure
+		}
+	}
+	return UnknownProcedure
+}
+
+func failureClass(err error) string {
+	return connect.CodeOf(err).String()
+}
+
+type config struct {
+	Namespace   string
+	ConstLabels prometheus.Labels
+	Buckets     []float64
+	Registry    prometheus.Registerer
+	Procedures  map[string]struct{}
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
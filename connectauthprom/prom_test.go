@@ -0,0 +1,107 @@
+package connectauthprom
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func authenticate(_ context.Context, req *connectauth.Request) (any, error) {
+	if req.Header.Get("Authorization") == "Bearer degraded" {
+		req.Flags = append(req.Flags, connectauth.FlagDegraded)
+		return "Ali Baba", nil
+	}
+	if req.Header.Get("Authorization") != "Bearer opensesame" {
+		return nil, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "wrong passphrase")
+	}
+	return "Ali Baba", nil
+}
+
+func TestCollector(t *testing.T) {
+	registry := prometheus.NewPedanticRegistry()
+	collector, err := New(
+		WithNamespace("acme"),
+		WithConstLabels(prometheus.Labels{"service": "foo"}),
+		WithPrometheusRegistry(registry),
+	)
+	attest.Ok(t, err)
+	auth := collector.Wrap(authenticate)
+	for _, header := range []string{"Bearer opensesame", "Bearer opensesame", "Bearer wrong", "Bearer degraded"} {
+		auth(context.Background(), &connectauth.Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  "connect",
+			Header:    http.Header{"Authorization": []string{header}},
+		})
+	}
+	// Clients choose the procedures of unauthenticated requests.
+	for _, procedure := range []string{"/attacker.v1.Svc/A", "/attacker.v1.Svc/B"} {
+		auth(context.Background(), &connectauth.Request{
+			Procedure: procedure,
+			Protocol:  "connect",
+			Header:    http.Header{"Authorization": []string{"Bearer wrong"}},
+		})
+	}
+
+	expected := `
+# HELP acme_attempts_total Authentication attempts.
+# TYPE acme_attempts_total counter
+acme_attempts_total{failure_class="",outcome="degraded",procedure="/acme.v1.Svc/Get",protocol="connect",reason="",service="foo"} 1
+acme_attempts_total{failure_class="",outcome="success",procedure="/acme.v1.Svc/Get",protocol="connect",reason="",service="foo"} 2
+acme_attempts_total{failure_class="unauthenticated",outcome="failure",procedure="/acme.v1.Svc/Get",protocol="connect",reason="invalid_credentials",service="foo"} 1
+acme_attempts_total{failure_class="unauthenticated",outcome="failure",procedure="unknown",protocol="connect",reason="invalid_credentials",service="foo"} 2
+`
+	attest.Ok(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "acme_attempts_total"))
+	attest.Equal(t, testutil.CollectAndCount(collector, "acme_duration_seconds"), 4)
+
+	// Registering twice fails.
+	_, err = New(WithNamespace("acme"), WithConstLabels(prometheus.Labels{"service": "foo"}), WithPrometheusRegistry(registry))
+	attest.Error(t, err)
+}
+
+func TestCollectorProcedures(t *testing.T) {
+	registered, err := New()
+	attest.Ok(t, err)
+	attest.Equal(t, registered.procedure("/acme.v1.Svc/Get"), "/acme.v1.Svc/Get")
+	attest.Equal(t, registered.procedure("/acme.v1.Svc/Nope"), UnknownProcedure)
+	attest.Equal(t, registered.procedure("/acme.v1.Svc"), UnknownProcedure)
+	attest.Equal(t, registered.procedure("/wp-admin/setup.php"), UnknownProcedure)
+	attest.Equal(t, registered.procedure(""), "")
+
+	listed, err := New(WithProcedures("/acme.v1.Other/List"))
+	attest.Ok(t, err)
+	attest.Equal(t, listed.procedure("/acme.v1.Other/List"), "/acme.v1.Other/List")
+	attest.Equal(t, listed.procedure("/acme.v1.Svc/Get"), UnknownProcedure)
+}
+
+func init() {
+	// Register a service, as generated code would.
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("connectauthprom/acme.proto"),
+		Package:     proto.String("acme.v1"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Svc"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".acme.v1.Empty"),
+				OutputType: proto.String(".acme.v1.Empty"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
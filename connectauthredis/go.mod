@@ -0,0 +1,30 @@
+module go.akshayshah.org/connectauth/connectauthredis
+
+go 1.21
+
+require (
+	github.com/alicebob/miniredis/v2 v2.31.1
+	github.com/redis/go-redis/v9 v9.5.1
+	go.akshayshah.org/attest v1.0.2
+	go.akshayshah.org/connectauth v0.4.0
+	go.akshayshah.org/connectauth/connectauthsession v0.4.0
+	go.akshayshah.org/connectauth/connectauthtest v0.4.0
+)
+
+require (
+	connectrpc.com/connect v1.11.1 // indirect
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/google/go-cmp v0.6.0 // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+replace (
+	go.akshayshah.org/connectauth => ../
+	go.akshayshah.org/connectauth/connectauthsession => ../connectauthsession
+	go.akshayshah.org/connectauth/connectauthtest => ../connectauthtest
+)
@@ -0,0 +1,101 @@
+package connectauthredis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/connectauth"
+)
+
+// A LockoutStore is a [connectauth.LockoutStore] backed by Redis. Each
+// principal's history is a hash, updated atomically with Lua scripts.
+type LockoutStore struct {
+	client    redis.Cmdable
+	prefix    string
+	retention time.Duration
+	now       func() time.Time
+}
+
+var _ connectauth.LockoutStore = (*LockoutStore)(nil)
+
+// NewLockoutStore constructs a LockoutStore. The client may be a
+// *redis.Client, *redis.ClusterClient, or any other [redis.Cmdable].
+func NewLockoutStore(client redis.Cmdable, opts ...Option) *LockoutStore {
+	cfg := newConfig("connectauth:lockout:", opts)
+	return &LockoutStore{
+		client:    client,
+		prefix:    cfg.Prefix,
+		retention: cfg.Retention,
+		now:       cfg.Clock.Now,
+	}
+}
+
+// Lockout times are stored as Unix milliseconds. The hash's TTL is only ever
+// extended, so a late failure can't expire an active lockout.
+var (
+	addFailureScript = redis.NewScript(`
+local n = redis.call('HINCRBY', KEYS[1], 'failures', 1)
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+	lockScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], 'failures', 0, 'until', ARGV[1])
+local n = redis.call('HINCRBY', KEYS[1], 'lockouts', 1)
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+)
+
+// State implements [connectauth.LockoutStore].
+func (s *LockoutStore) State(ctx context.Context, principal string) (connectauth.LockoutState, error) {
+	vals, err := s.client.HMGet(ctx, s.prefix+principal, "failures", "lockouts", "until").Result()
+	if err != nil {
+		return connectauth.LockoutState{}, err
+	}
+	var state connectauth.LockoutState
+	state.Failures = parseInt(vals[0])
+	state.Lockouts = parseInt(vals[1])
+	if ms := parseInt(vals[2]); ms > 0 {
+		state.LockedUntil = time.UnixMilli(int64(ms))
+	}
+	return state, nil
+}
+
+// AddFailure implements [connectauth.LockoutStore].
+func (s *LockoutStore) AddFailure(ctx context.Context, principal string) (int, error) {
+	return addFailureScript.Run(
+		ctx, s.client,
+		[]string{s.prefix + principal},
+		s.retention.Milliseconds(),
+	).Int()
+}
+
+// Lock implements [connectauth.LockoutStore].
+func (s *LockoutStore) Lock(ctx context.Context, principal string, until time.Time) (int, error) {
+	return lockScript.Run(
+		ctx, s.client,
+		[]string{s.prefix + principal},
+		until.UnixMilli(),
+		(until.Sub(s.now()) + s.retention).Milliseconds(),
+	).Int()
+}
+
+// Reset implements [connectauth.LockoutStore].
+func (s *LockoutStore) Reset(ctx context.Context, principal string) error {
+	return s.client.Del(ctx, s.prefix+principal).Err()
+}
+
+func parseInt(v any) int {
+	str, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(str)
+	return n
+}
@@ -0,0 +1,54 @@
+package connectauthredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/attest"
+)
+
+func TestLockoutStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	now := time.UnixMilli(1_700_000_000_000)
+	store := NewLockoutStore(client, WithPrefix("test:"), WithRetention(time.Hour))
+	store.now = func() time.Time { return now }
+
+	state, err := store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Zero(t, state)
+
+	n, err := store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, n, 1)
+	n, err = store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, n, 2)
+	attest.Equal(t, srv.TTL("test:alice"), time.Hour)
+
+	until := now.Add(2 * time.Hour)
+	n, err = store.Lock(ctx, "alice", until)
+	attest.Ok(t, err)
+	attest.Equal(t, n, 1)
+	attest.Equal(t, srv.TTL("test:alice"), 3*time.Hour)
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, state.Failures, 0)
+	attest.Equal(t, state.Lockouts, 1)
+	attest.True(t, state.LockedUntil.Equal(until))
+
+	// Failures don't shorten the TTL of an active lockout.
+	_, err = store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, srv.TTL("test:alice"), 3*time.Hour)
+
+	attest.Ok(t, store.Reset(ctx, "alice"))
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Zero(t, state)
+}
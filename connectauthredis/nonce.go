@@ -0,0 +1,40 @@
+package connectauthredis
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/connectauth"
+)
+
+// A NonceStore is a [connectauth.NonceStore] backed by Redis. Each nonce is
+// a key, set only if it doesn't already exist and expired by Redis.
+type NonceStore struct {
+	client redis.Cmdable
+	prefix string
+	now    func() time.Time
+}
+
+var _ connectauth.NonceStore = (*NonceStore)(nil)
+
+// NewNonceStore constructs a NonceStore. The client may be a *redis.Client,
+// *redis.ClusterClient, or any other [redis.Cmdable].
+func NewNonceStore(client redis.Cmdable, opts ...Option) *NonceStore {
+	cfg := newConfig("connectauth:nonce:", opts)
+	return &NonceStore{
+		client: client,
+		prefix: cfg.Prefix,
+		now:    cfg.Clock.Now,
+	}
+}
+
+// Use implements [connectauth.NonceStore].
+func (s *NonceStore) Use(ctx context.Context, nonce string, expires time.Time) (bool, error) {
+	ttl := expires.Sub(s.now())
+	if ttl < time.Millisecond {
+		// Redis can't store the nonce, but it's too old to replay anyway.
+		return true, nil
+	}
+	return s.client.SetNX(ctx, s.prefix+nonce, 1, ttl).Result()
+}
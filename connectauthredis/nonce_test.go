@@ -0,0 +1,40 @@
+package connectauthredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/attest"
+)
+
+func TestNonceStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	now := time.UnixMilli(1_700_000_000_000)
+	store := NewNonceStore(client)
+	store.now = func() time.Time { return now }
+
+	fresh, err := store.Use(ctx, "abc", now.Add(time.Minute))
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+	attest.Equal(t, srv.TTL("connectauth:nonce:abc"), time.Minute)
+	fresh, err = store.Use(ctx, "abc", now.Add(time.Minute))
+	attest.Ok(t, err)
+	attest.False(t, fresh)
+
+	srv.FastForward(time.Minute)
+	fresh, err = store.Use(ctx, "abc", now.Add(time.Minute))
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+
+	// Expired nonces aren't stored.
+	fresh, err = store.Use(ctx, "def", now)
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+	attest.False(t, srv.Exists("connectauth:nonce:def"))
+}
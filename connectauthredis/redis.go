@@ -0,0 +1,74 @@
+// Package connectauthredis stores [connectauth] state in Redis, so that all
+// instances of a service share it.
+package connectauthredis
+
+import (
+	"time"
+
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/connectauth/connectauthsession"
+)
+
+// An Option configures a [LockoutStore], [NonceStore], or [SessionStore].
+type Option interface {
+	apply(*config)
+}
+
+// WithPrefix sets the prefix of the store's Redis keys. The defaults are
+// "connectauth:lockout:", "connectauth:nonce:", and "connectauth:session:".
+func WithPrefix(prefix string) Option {
+	return optionFunc(func(c *config) {
+		c.Prefix = prefix
+	})
+}
+
+// WithRetention sets how long principals are remembered after their last
+// failure or lockout ends. The default is 24 hours. It only applies to
+// LockoutStores.
+func WithRetention(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.Retention = d
+	})
+}
+
+// WithCodec sets the codec used to serialize sessions. The default is
+// [connectauthsession.JSONCodec]. It only applies to SessionStores.
+func WithCodec(codec connectauthsession.Codec) Option {
+	return optionFunc(func(c *config) {
+		c.Codec = codec
+	})
+}
+
+// WithClock sets the clock used to compute TTLs and check session expiry.
+// The default is [connectauth.SystemClock]. Redis always expires keys in
+// real time.
+func WithClock(clock connectauth.Clock) Option {
+	return optionFunc(func(c *config) {
+		c.Clock = clock
+	})
+}
+
+type config struct {
+	Prefix    string
+	Retention time.Duration
+	Codec     connectauthsession.Codec
+	Clock     connectauth.Clock
+}
+
+func newConfig(prefix string, opts []Option) config {
+	cfg := config{
+		Prefix:    prefix,
+		Retention: 24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = connectauth.SystemClock()
+	}
+	return cfg
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
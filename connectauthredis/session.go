@@ -0,0 +1,113 @@
+package connectauthredis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/connectauth/connectauthsession"
+)
+
+// A SessionStore is a [connectauthsession.Store] backed by Redis. Each
+// session is a key, serialized with the configured codec and expired by
+// Redis. To support [connectauthsession.Manager.RevokeAllForSubject], each
+// identity also has a set of its session keys, which expires with the
+// identity's last session.
+type SessionStore struct {
+	client redis.Cmdable
+	prefix string
+	codec  connectauthsession.Codec
+	now    func() time.Time
+}
+
+var (
+	_ connectauthsession.Store           = (*SessionStore)(nil)
+	_ connectauthsession.IdentityRevoker = (*SessionStore)(nil)
+)
+
+// indexScript adds a session key to an identity's set, only ever extending
+// the set's TTL.
+var indexScript = redis.NewScript(`
+redis.call('SADD', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// NewSessionStore constructs a SessionStore. The client may be a
+// *redis.Client, *redis.ClusterClient, or any other [redis.Cmdable].
+func NewSessionStore(client redis.Cmdable, opts ...Option) *SessionStore {
+	cfg := newConfig("connectauth:session:", opts)
+	if cfg.Codec == nil {
+		cfg.Codec = connectauthsession.JSONCodec{}
+	}
+	return &SessionStore{
+		client: client,
+		prefix: cfg.Prefix,
+		codec:  cfg.Codec,
+		now:    cfg.Clock.Now,
+	}
+}
+
+// Get implements [connectauthsession.Store].
+func (s *SessionStore) Get(ctx context.Context, key string) (*connectauthsession.Session, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.codec.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if !s.now().Before(session.Expires) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Set implements [connectauthsession.Store].
+func (s *SessionStore) Set(ctx context.Context, key string, session *connectauthsession.Session, ttl time.Duration) error {
+	data, err := s.codec.Marshal(session)
+	if err != nil {
+		return err
+	}
+	// The session and index keys may be in different cluster slots, so they
+	// can't be updated atomically.
+	pipe := s.client.Pipeline()
+	pipe.Set(ctx, s.prefix+key, data, ttl)
+	indexScript.Eval(ctx, pipe, []string{s.indexKey(session.Identity)}, s.prefix+key, ttl.Milliseconds())
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Delete implements [connectauthsession.Store]. It leaves the key in the
+// identity's set, since it doesn't know the identity; the set expires on its
+// own.
+func (s *SessionStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
+
+// RevokeIdentity implements [connectauthsession.IdentityRevoker].
+func (s *SessionStore) RevokeIdentity(ctx context.Context, identity string) error {
+	index := s.indexKey(identity)
+	keys, err := s.client.SMembers(ctx, index).Result()
+	if err != nil {
+		return err
+	}
+	pipe := s.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key) // one key per command, for cluster compatibility
+	}
+	pipe.Del(ctx, index)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *SessionStore) indexKey(identity string) string {
+	return s.prefix + "identity:" + identity
+}
@@ -0,0 +1,75 @@
+package connectauthredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth/connectauthsession"
+	"go.akshayshah.org/connectauth/connectauthtest"
+)
+
+func TestSessionStore(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { client.Close() })
+	clock := connectauthtest.NewClock(time.UnixMilli(1_700_000_000_000))
+	now := clock.Now()
+	store := NewSessionStore(client, WithPrefix("s:"), WithClock(clock))
+
+	session := &connectauthsession.Session{
+		Identity: "alice",
+		Values:   map[string]string{"theme": "dark"},
+		Created:  now,
+		Expires:  now.Add(time.Hour),
+	}
+	attest.Ok(t, store.Set(ctx, "key", session, time.Hour))
+	attest.Equal(t, srv.TTL("s:key"), time.Hour)
+	got, err := store.Get(ctx, "key")
+	attest.Ok(t, err)
+	attest.Equal(t, got.Identity, "alice")
+	attest.Equal(t, got.Values, session.Values)
+	attest.True(t, got.Expires.Equal(session.Expires))
+
+	missing, err := store.Get(ctx, "other")
+	attest.Ok(t, err)
+	attest.Zero(t, missing)
+
+	clock.Advance(time.Hour) // expired, even if Redis hasn't evicted it yet
+	expired, err := store.Get(ctx, "key")
+	attest.Ok(t, err)
+	attest.Zero(t, expired)
+	clock.Set(now)
+
+	attest.Ok(t, store.Delete(ctx, "key"))
+	deleted, err := store.Get(ctx, "key")
+	attest.Ok(t, err)
+	attest.Zero(t, deleted)
+
+	// Revoking an identity deletes all its sessions, but no others.
+	for _, key := range []string{"a1", "a2"} {
+		attest.Ok(t, store.Set(ctx, key, session, time.Hour))
+	}
+	bob := &connectauthsession.Session{Identity: "bob", Expires: now.Add(time.Hour)}
+	attest.Ok(t, store.Set(ctx, "b1", bob, 2*time.Hour))
+	attest.Equal(t, srv.TTL("s:identity:alice"), time.Hour)
+	attest.Equal(t, srv.TTL("s:identity:bob"), 2*time.Hour)
+	attest.Ok(t, store.RevokeIdentity(ctx, "alice"))
+	for _, key := range []string{"a1", "a2"} {
+		got, err := store.Get(ctx, key)
+		attest.Ok(t, err)
+		attest.Zero(t, got)
+	}
+	attest.False(t, srv.Exists("s:identity:alice"))
+	got, err = store.Get(ctx, "b1")
+	attest.Ok(t, err)
+	attest.Equal(t, got.Identity, "bob")
+
+	srv.SetError("oh no")
+	_, err = store.Get(ctx, "key")
+	attest.Error(t, err)
+}
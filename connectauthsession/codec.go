@@ -0,0 +1,28 @@
+package connectauthsession
+
+import "encoding/json"
+
+// A Codec serializes sessions for stores that persist them outside the
+// process, like [SQLStore] and Redis. Custom codecs can use a more compact
+// encoding, or encrypt sessions at rest.
+type Codec interface {
+	Marshal(*Session) ([]byte, error)
+	Unmarshal([]byte) (*Session, error)
+}
+
+// JSONCodec encodes sessions as JSON. It's the default Codec.
+type JSONCodec struct{}
+
+// Marshal implements [Codec].
+func (JSONCodec) Marshal(session *Session) ([]byte, error) {
+	return json.Marshal(session)
+}
+
+// Unmarshal implements [Codec].
+func (JSONCodec) Unmarshal(data []byte) (*Session, error) {
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,173 @@
+package connectauthsession
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// Cookie body formats. The first byte of each body identifies its format, so
+// encryption can be enabled without invalidating existing cookies.
+const (
+	formatSigned    byte = 1
+	formatEncrypted byte = 2
+)
+
+var errInvalidCookie = errors.New("invalid cookie")
+
+// CookieCodecConfig configures a [CookieCodec].
+type CookieCodecConfig struct {
+	// Keyring holds the secrets used to sign and encrypt cookies. Required.
+	Keyring *connectauth.Keyring
+	// Encrypt hides cookie values from clients with AES-256-GCM, using a key
+	// derived from the signing secret.
+	Encrypt bool
+	// MaxAge, if positive, rejects cookies encoded longer ago than MaxAge,
+	// regardless of the expiry the client was told.
+	MaxAge time.Duration
+	// Clock timestamps cookies and enforces MaxAge. The default is
+	// [connectauth.SystemClock].
+	Clock connectauth.Clock
+}
+
+// A CookieCodec makes cookie values tamper-proof and, optionally,
+// confidential. The [Manager] uses it to sign session IDs, and it's useful on
+// its own for stateless deployments that keep the whole session in a cookie.
+//
+// Encoded values have the form "keyID.body.mac": the body is base64url, and
+// the HMAC-SHA256 covers the cookie name, key ID, and body, so values can't
+// be moved between cookies. Because the key ID is embedded, cookies encoded
+// with previous keys in the [connectauth.Keyring] remain valid after
+// rotation.
+//
+// CookieCodecs are safe to use concurrently.
+type CookieCodec struct {
+	keyring *connectauth.Keyring
+	encrypt bool
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+// NewCookieCodec constructs a CookieCodec. It panics if the Keyring is nil.
+func NewCookieCodec(config CookieCodecConfig) *CookieCodec {
+	if config.Keyring == nil {
+		panic("connectauthsession: nil Keyring")
+	}
+	if config.Clock == nil {
+		config.Clock = connectauth.SystemClock()
+	}
+	return &CookieCodec{
+		keyring: config.Keyring,
+		encrypt: config.Encrypt,
+		maxAge:  config.MaxAge,
+		now:     config.Clock.Now,
+	}
+}
+
+// Encode encodes the value of the named cookie.
+func (c *CookieCodec) Encode(name string, value []byte) (string, error) {
+	key := c.keyring.Current()
+	plain := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(plain, uint64(c.now().Unix()))
+	plain = append(plain, value...)
+	var body []byte
+	if c.encrypt {
+		aead, err := newAEAD(key.Secret)
+		if err != nil {
+			return "", err
+		}
+		body = make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plain)+aead.Overhead())
+		body[0] = formatEncrypted
+		nonce := body[1:]
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		body = aead.Seal(body, nonce, plain, []byte(name))
+	} else {
+		body = append([]byte{formatSigned}, plain...)
+	}
+	payload := key.ID + "." + base64.RawURLEncoding.EncodeToString(body)
+	mac := cookieMAC(key.Secret, name, payload)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac), nil
+}
+
+// Decode verifies and decodes the value of the named cookie.
+func (c *CookieCodec) Decode(name, encoded string) ([]byte, error) {
+	i := strings.LastIndexByte(encoded, '.')
+	if i < 0 {
+		return nil, errInvalidCookie
+	}
+	payload, encodedMAC := encoded[:i], encoded[i+1:]
+	keyID, encodedBody, ok := strings.Cut(payload, ".")
+	if !ok {
+		return nil, errInvalidCookie
+	}
+	key, ok := c.keyring.Key(keyID)
+	if !ok {
+		return nil, errInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(cookieMAC(key.Secret, name, payload), mac) {
+		return nil, errInvalidCookie
+	}
+	body, err := base64.RawURLEncoding.DecodeString(encodedBody)
+	if err != nil || len(body) == 0 {
+		return nil, errInvalidCookie
+	}
+	var plain []byte
+	switch body[0] {
+	case formatSigned:
+		plain = body[1:]
+	case formatEncrypted:
+		aead, err := newAEAD(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		if len(body) < 1+aead.NonceSize() {
+			return nil, errInvalidCookie
+		}
+		nonce, sealed := body[1:1+aead.NonceSize()], body[1+aead.NonceSize():]
+		if plain, err = aead.Open(nil, nonce, sealed, []byte(name)); err != nil {
+			return nil, errInvalidCookie
+		}
+	default:
+		return nil, errInvalidCookie
+	}
+	if len(plain) < 8 {
+		return nil, errInvalidCookie
+	}
+	issued := time.Unix(int64(binary.BigEndian.Uint64(plain)), 0)
+	if c.maxAge > 0 && c.now().Sub(issued) > c.maxAge {
+		return nil, errors.New("expired cookie")
+	}
+	return plain[8:], nil
+}
+
+func cookieMAC(secret []byte, name, payload string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+// newAEAD derives an AES-256-GCM cipher from a signing secret, so that the
+// encryption and signing keys differ.
+func newAEAD(secret []byte) (cipher.AEAD, error) {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("connectauthsession cookie encryption"))
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,116 @@
+package connectauthsession
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func newTestKeyring(t testing.TB, current string, previous ...string) *connectauth.Keyring {
+	t.Helper()
+	key := func(id string) connectauth.Key {
+		return connectauth.Key{ID: id, Secret: bytes.Repeat([]byte(id), 32)}
+	}
+	var prev []connectauth.Key
+	for _, id := range previous {
+		prev = append(prev, key(id))
+	}
+	keyring, err := connectauth.NewKeyring(key(current), prev...)
+	attest.Ok(t, err)
+	return keyring
+}
+
+func TestCookieCodec(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		encrypt := encrypt
+		name := "signed"
+		if encrypt {
+			name = "encrypted"
+		}
+		t.Run(name, func(t *testing.T) {
+			codec := NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k1"), Encrypt: encrypt})
+			encoded, err := codec.Encode("session", []byte("secret value"))
+			attest.Ok(t, err)
+			attest.Equal(t, strings.Count(encoded, "."), 2)
+			attest.True(t, strings.HasPrefix(encoded, "k1."))
+			attest.Equal(t, strings.Contains(encoded, "c2VjcmV0IHZhbHVl"), !encrypt)
+			attest.True(t, (&http.Cookie{Name: "session", Value: encoded}).Valid() == nil)
+
+			decoded, err := codec.Decode("session", encoded)
+			attest.Ok(t, err)
+			attest.Equal(t, string(decoded), "secret value")
+
+			_, err = codec.Decode("other", encoded) // can't move values between cookies
+			attest.Error(t, err)
+			tampered := []byte(encoded)
+			tampered[5] ^= 1
+			_, err = codec.Decode("session", string(tampered))
+			attest.Error(t, err)
+			for _, malformed := range []string{"", "k1", "k1.abc", "k2.abc.def", "k1.!!.abc"} {
+				_, err = codec.Decode("session", malformed)
+				attest.Error(t, err, attest.Sprintf("%q", malformed))
+			}
+		})
+	}
+}
+
+func TestCookieCodecRotation(t *testing.T) {
+	old := NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k1")})
+	encoded, err := old.Encode("session", []byte("value"))
+	attest.Ok(t, err)
+
+	// Enabling encryption and rotating keys doesn't invalidate existing
+	// cookies.
+	rotated := NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k2", "k1"), Encrypt: true})
+	decoded, err := rotated.Decode("session", encoded)
+	attest.Ok(t, err)
+	attest.Equal(t, string(decoded), "value")
+
+	retired := NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k2")})
+	_, err = retired.Decode("session", encoded)
+	attest.Error(t, err)
+}
+
+func TestCookieCodecMaxAge(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	codec := NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k1"), MaxAge: time.Hour})
+	codec.now = func() time.Time { return now }
+	encoded, err := codec.Encode("session", []byte("value"))
+	attest.Ok(t, err)
+	now = now.Add(time.Hour)
+	_, err = codec.Decode("session", encoded)
+	attest.Ok(t, err)
+	now = now.Add(time.Second)
+	_, err = codec.Decode("session", encoded)
+	attest.Error(t, err)
+}
+
+func TestManagerCookieCodec(t *testing.T) {
+	ctx := context.Background()
+	sessions := New(Config{
+		Store:   &mapStore{},
+		Cookies: NewCookieCodec(CookieCodecConfig{Keyring: newTestKeyring(t, "k1")}),
+	})
+	session, id, err := sessions.Create(ctx, "alice", nil)
+	attest.Ok(t, err)
+	cookie, err := sessions.Cookie(id, session)
+	attest.Ok(t, err)
+	attest.NotEqual(t, cookie.Value, id)
+
+	req := &connectauth.Request{Header: http.Header{"Cookie": []string{cookie.String()}}}
+	attest.Equal(t, sessions.SessionID(req), id)
+	info, err := sessions.Authenticate(ctx, req)
+	attest.Ok(t, err)
+	attest.Equal(t, connectauth.SubjectOf(info), "alice")
+
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + id}}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonInvalidCredentials)
+}
@@ -0,0 +1,191 @@
+package connectauthsession
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// A MemoryStore is a [Store] and [RememberStore] that keeps sessions and
+// remember-me tokens in memory. It's suitable for tests and single-instance
+// deployments; sessions are lost when the process exits.
+//
+// Expired sessions are never returned, but they occupy memory until they're
+// removed by the janitor (see [NewMemoryStore]) or overwritten.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	remember map[string]rememberEntry
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+type memoryEntry struct {
+	session *Session
+	expires time.Time
+}
+
+type rememberEntry struct {
+	token   *RememberToken
+	expires time.Time
+}
+
+// NewMemoryStore constructs a MemoryStore. If the cleanup interval is
+// positive, a background janitor removes expired sessions at that interval
+// until the store is closed.
+func NewMemoryStore(cleanup time.Duration) *MemoryStore {
+	s := &MemoryStore{
+		now:      time.Now,
+		sessions: make(map[string]memoryEntry),
+		remember: make(map[string]rememberEntry),
+		stop:     make(chan struct{}),
+	}
+	if cleanup > 0 {
+		go s.janitor(cleanup)
+	}
+	return s
+}
+
+// SetClock replaces the clock used to expire entries. It's intended for
+// tests, and must be called before the store is used. A nil Clock restores
+// [connectauth.SystemClock]. The janitor always runs in real time.
+func (s *MemoryStore) SetClock(clock connectauth.Clock) {
+	if clock == nil {
+		clock = connectauth.SystemClock()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = clock.Now
+}
+
+// Get implements [Store].
+func (s *MemoryStore) Get(_ context.Context, key string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.sessions[key]
+	if !ok || !s.now().Before(entry.expires) {
+		return nil, nil
+	}
+	return clone(entry.session), nil
+}
+
+// Set implements [Store].
+func (s *MemoryStore) Set(_ context.Context, key string, session *Session, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[key] = memoryEntry{session: clone(session), expires: s.now().Add(ttl)}
+	return nil
+}
+
+// Delete implements [Store].
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// GetRemember implements [RememberStore].
+func (s *MemoryStore) GetRemember(_ context.Context, selector string) (*RememberToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.remember[selector]
+	if !ok || !s.now().Before(entry.expires) {
+		return nil, nil
+	}
+	token := *entry.token
+	token.Values = maps.Clone(token.Values)
+	return &token, nil
+}
+
+// SetRemember implements [RememberStore].
+func (s *MemoryStore) SetRemember(_ context.Context, selector string, token *RememberToken, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *token
+	stored.Values = maps.Clone(token.Values)
+	s.remember[selector] = rememberEntry{token: &stored, expires: s.now().Add(ttl)}
+	return nil
+}
+
+// DeleteRemember implements [RememberStore].
+func (s *MemoryStore) DeleteRemember(_ context.Context, selector string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.remember, selector)
+	return nil
+}
+
+// RevokeIdentity implements [IdentityRevoker], deleting both sessions and
+// remember-me tokens.
+func (s *MemoryStore) RevokeIdentity(_ context.Context, identity string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.sessions {
+		if entry.session.Identity == identity {
+			delete(s.sessions, key)
+		}
+	}
+	for selector, entry := range s.remember {
+		if entry.token.Identity == identity {
+			delete(s.remember, selector)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored sessions, including expired sessions that
+// haven't been removed yet.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Close stops the janitor, if any. The store remains usable.
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, entry := range s.sessions {
+		if !now.Before(entry.expires) {
+			delete(s.sessions, key)
+		}
+	}
+	for selector, entry := range s.remember {
+		if !now.Before(entry.expires) {
+			delete(s.remember, selector)
+		}
+	}
+}
+
+// clone copies a session, so callers can't modify stored sessions.
+func clone(session *Session) *Session {
+	c := *session
+	c.Values = maps.Clone(session.Values)
+	return &c
+}
@@ -0,0 +1,49 @@
+package connectauthsession
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore(0)
+	store.now = func() time.Time { return now }
+
+	session := &Session{Identity: "alice", Values: map[string]string{"theme": "dark"}}
+	attest.Ok(t, store.Set(ctx, "a", session, time.Minute))
+	attest.Ok(t, store.Set(ctx, "b", session, time.Hour))
+	session.Values["theme"] = "light" // stored sessions are copies
+	got, err := store.Get(ctx, "a")
+	attest.Ok(t, err)
+	attest.Equal(t, got.Values["theme"], "dark")
+
+	now = now.Add(time.Minute)
+	got, err = store.Get(ctx, "a")
+	attest.Ok(t, err)
+	attest.Zero(t, got)
+	attest.Equal(t, store.Len(), 2)
+	store.sweep()
+	attest.Equal(t, store.Len(), 1)
+
+	attest.Ok(t, store.Delete(ctx, "b"))
+	attest.Equal(t, store.Len(), 0)
+}
+
+func TestMemoryStoreJanitor(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(time.Millisecond)
+	t.Cleanup(func() { store.Close() })
+	attest.Ok(t, store.Set(ctx, "a", &Session{}, time.Millisecond))
+	deadline := time.Now().Add(5 * time.Second)
+	for store.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	attest.Equal(t, store.Len(), 0)
+	attest.Ok(t, store.Close())
+	attest.Ok(t, store.Close()) // idempotent
+}
@@ -0,0 +1,269 @@
+package connectauthsession
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+)
+
+// RememberConfig configures remember-me tokens: long-lived cookies that mint
+// a new session when the previous one has expired.
+//
+// Each token has a selector, which identifies it in the [RememberStore], and
+// a secret verifier, which is stored only as a SHA-256 hash. The verifier
+// rotates every time the token mints a session, so each cookie value works
+// once. If a verifier is presented after it's been rotated away, either the
+// legitimate client or an attacker is replaying a stolen cookie; the Manager
+// can't tell which, so it deletes the token and calls OnTheft. Rotation
+// isn't transactional, so if two requests rotate the same token at once, the
+// losing client's next use also looks like theft; the Grace period makes this
+// rare.
+type RememberConfig struct {
+	// Store persists remember-me tokens. Required. [MemoryStore] implements
+	// RememberStore.
+	Store RememberStore
+	// CookieName is the name of the remember-me cookie. The default is
+	// "remember".
+	CookieName string
+	// TTL is how long tokens remain valid without use. Each use extends it.
+	// The default is 30 days.
+	TTL time.Duration
+	// Grace is how long a rotated verifier remains acceptable, so that
+	// browsers sending several requests at once with the same cookie aren't
+	// mistaken for thieves. Requests within the grace period get a session,
+	// but don't rotate the token again. The default is 30 seconds.
+	Grace time.Duration
+	// OnTheft, if set, is called with the identity of a token that was
+	// invalidated because an old verifier was reused. Applications typically
+	// revoke the identity's sessions (see [Manager.RevokeAllForSubject]) and
+	// alert the user.
+	OnTheft func(ctx context.Context, identity string)
+}
+
+// A RememberToken is the server-side state of a remember-me token.
+type RememberToken struct {
+	Identity string
+	Values   map[string]string // copied to minted sessions
+	Verifier []byte            // SHA-256 hash of the current verifier
+	Previous []byte            // SHA-256 hash of the verifier before the last rotation
+	Rotated  time.Time
+	Expires  time.Time
+}
+
+// A RememberStore persists remember-me tokens, keyed by their selectors.
+// GetRemember returns nil and no error if the token doesn't exist or has
+// expired, and SetRemember must expire the token after the supplied TTL.
+// Implementations must be safe to call concurrently.
+type RememberStore interface {
+	GetRemember(ctx context.Context, selector string) (*RememberToken, error)
+	SetRemember(ctx context.Context, selector string, token *RememberToken, ttl time.Duration) error
+	DeleteRemember(ctx context.Context, selector string) error
+}
+
+var errRememberDisabled = errors.New("remember-me tokens aren't configured")
+
+func newRememberConfig(rc *RememberConfig) *RememberConfig {
+	if rc == nil {
+		return nil
+	}
+	if rc.Store == nil {
+		panic("connectauthsession: nil RememberStore")
+	}
+	c := *rc
+	if c.CookieName == "" {
+		c.CookieName = "remember"
+	}
+	if c.TTL <= 0 {
+		c.TTL = 30 * 24 * time.Hour
+	}
+	if c.Grace <= 0 {
+		c.Grace = 30 * time.Second
+	}
+	return &c
+}
+
+// Remember issues a remember-me token for the session's identity and values,
+// typically when a user logs in with "remember me" checked. It returns the
+// token's cookie, which has the same attributes as the session cookie.
+func (m *Manager) Remember(ctx context.Context, session *Session) (*http.Cookie, error) {
+	if m.remember == nil {
+		return nil, errRememberDisabled
+	}
+	selector, err := randomID(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomID(32)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	token := &RememberToken{
+		Identity: session.Identity,
+		Values:   maps.Clone(session.Values),
+		Verifier: hashVerifier(verifier),
+		Rotated:  now,
+		Expires:  now.Add(m.remember.TTL),
+	}
+	if err := m.remember.Store.SetRemember(ctx, selector, token, m.remember.TTL); err != nil {
+		return nil, err
+	}
+	return m.rememberCookie(selector, verifier, token.Expires)
+}
+
+// Forget deletes the remember-me token in the cookie value, typically when a
+// user logs out. Pair it with [Manager.ExpiredRememberCookie].
+func (m *Manager) Forget(ctx context.Context, value string) error {
+	if m.remember == nil {
+		return errRememberDisabled
+	}
+	selector, _, err := m.parseRemember(value)
+	if err != nil {
+		return err
+	}
+	return m.remember.Store.DeleteRemember(ctx, selector)
+}
+
+// ExpiredRememberCookie returns a cookie that deletes the remember-me cookie
+// from the client.
+func (m *Manager) ExpiredRememberCookie() *http.Cookie {
+	c := m.ExpiredCookie()
+	if m.remember != nil {
+		c.Name = m.remember.CookieName
+	}
+	return c
+}
+
+// recall mints a session from the request's remember-me cookie. It reports
+// whether the request had one.
+func (m *Manager) recall(ctx context.Context, req *connectauth.Request) (*Session, bool, error) {
+	if req.ResponseHeader == nil {
+		// Without a way to send the rotated token, using it would look like
+		// theft next time.
+		return nil, false, nil
+	}
+	c, err := (&http.Request{Header: req.Header}).Cookie(m.remember.CookieName)
+	if err != nil || c.Value == "" {
+		return nil, false, nil
+	}
+	selector, verifier, err := m.parseRemember(c.Value)
+	if err != nil {
+		return nil, true, connectauth.NewReasonError(connect.CodeUnauthenticated, connectauth.ReasonInvalidCredentials, err)
+	}
+	store := m.remember.Store
+	token, err := store.GetRemember(ctx, selector)
+	if err != nil {
+		return nil, true, connectauth.NewReasonError(
+			connect.CodeUnavailable,
+			connectauth.ReasonUpstreamUnavailable,
+			fmt.Errorf("load remember-me token: %w", err),
+		)
+	}
+	now := m.now()
+	if token == nil || !now.Before(token.Expires) {
+		return nil, true, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "invalid or expired remember-me token")
+	}
+	sum := hashVerifier(verifier)
+	switch {
+	case subtle.ConstantTimeCompare(sum, token.Verifier) == 1:
+		next, err := randomID(32)
+		if err != nil {
+			return nil, true, err
+		}
+		rotated := *token
+		rotated.Previous = token.Verifier
+		rotated.Verifier = hashVerifier(next)
+		rotated.Rotated = now
+		rotated.Expires = now.Add(m.remember.TTL)
+		if err := store.SetRemember(ctx, selector, &rotated, m.remember.TTL); err != nil {
+			return nil, true, connectauth.NewReasonError(
+				connect.CodeUnavailable,
+				connectauth.ReasonUpstreamUnavailable,
+				fmt.Errorf("rotate remember-me token: %w", err),
+			)
+		}
+		cookie, err := m.rememberCookie(selector, next, rotated.Expires)
+		if err != nil {
+			return nil, true, err
+		}
+		req.ResponseHeader.Add("Set-Cookie", cookie.String())
+	case token.Previous != nil &&
+		subtle.ConstantTimeCompare(sum, token.Previous) == 1 &&
+		now.Before(token.Rotated.Add(m.remember.Grace)):
+		// A concurrent request already rotated the token.
+	default:
+		_ = store.DeleteRemember(ctx, selector)
+		if m.remember.OnTheft != nil {
+			m.remember.OnTheft(ctx, token.Identity)
+		}
+		return nil, true, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "remember-me token reused")
+	}
+	session, id, err := m.Create(ctx, token.Identity, maps.Clone(token.Values))
+	if err != nil {
+		return nil, true, connectauth.NewReasonError(
+			connect.CodeUnavailable,
+			connectauth.ReasonUpstreamUnavailable,
+			fmt.Errorf("create session: %w", err),
+		)
+	}
+	cookie, err := m.Cookie(id, session)
+	if err != nil {
+		return nil, true, err
+	}
+	req.ResponseHeader.Add("Set-Cookie", cookie.String())
+	req.Flags = append(req.Flags, connectauth.FlagCookie)
+	return session, true, nil
+}
+
+func (m *Manager) rememberCookie(selector, verifier string, expires time.Time) (*http.Cookie, error) {
+	value := selector + "." + verifier
+	if m.cookies != nil {
+		var err error
+		if value, err = m.cookies.Encode(m.remember.CookieName, []byte(value)); err != nil {
+			return nil, err
+		}
+	}
+	c := m.newCookie(m.remember.CookieName, value)
+	c.Expires = expires
+	return c, nil
+}
+
+func (m *Manager) parseRemember(value string) (selector, verifier string, err error) {
+	if m.cookies != nil {
+		raw, err := m.cookies.Decode(m.remember.CookieName, value)
+		if err != nil {
+			return "", "", err
+		}
+		value = string(raw)
+	}
+	selector, verifier, ok := strings.Cut(value, ".")
+	if !ok || selector == "" || verifier == "" {
+		return "", "", errors.New("malformed remember-me token")
+	}
+	return selector, verifier, nil
+}
+
+func hashVerifier(verifier string) []byte {
+	sum := sha256.Sum256([]byte(verifier))
+	return sum[:]
+}
+
+// randomID returns n random bytes, encoded as unpadded base64url.
+func randomID(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,116 @@
+package connectauthsession
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestRemember(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1_700_000_000, 0)
+	store := NewMemoryStore(0)
+	store.now = func() time.Time { return now }
+	var thefts []string
+	sessions := New(Config{
+		Store: store,
+		TTL:   time.Hour,
+		Remember: &RememberConfig{
+			Store: store,
+			Grace: time.Second,
+			OnTheft: func(_ context.Context, identity string) {
+				thefts = append(thefts, identity)
+			},
+		},
+	})
+	sessions.now = store.now
+
+	session, _, err := sessions.Create(ctx, "alice", map[string]string{"theme": "dark"})
+	attest.Ok(t, err)
+	remember, err := sessions.Remember(ctx, session)
+	attest.Ok(t, err)
+	attest.Equal(t, remember.Name, "remember")
+	attest.True(t, remember.HttpOnly)
+
+	// recall authenticates with only the remember-me cookie, and returns the
+	// minted session and the cookies set in the response.
+	recall := func(value string) (*Session, map[string]string, error) {
+		req := &connectauth.Request{
+			Header:         http.Header{"Cookie": []string{"remember=" + value}},
+			ResponseHeader: http.Header{},
+		}
+		info, err := sessions.Authenticate(ctx, req)
+		cookies := make(map[string]string)
+		for _, c := range (&http.Response{Header: req.ResponseHeader}).Cookies() {
+			cookies[c.Name] = c.Value
+		}
+		if err != nil {
+			return nil, cookies, err
+		}
+		attest.True(t, req.HasFlag(connectauth.FlagCookie))
+		return info.(*Session), cookies, nil
+	}
+
+	now = now.Add(2 * time.Hour) // the original session has expired
+	minted, cookies, err := recall(remember.Value)
+	attest.Ok(t, err)
+	attest.Equal(t, minted.Identity, "alice")
+	attest.Equal(t, minted.Values["theme"], "dark")
+	attest.NotZero(t, cookies["session"])
+	rotated := cookies["remember"]
+	attest.NotZero(t, rotated)
+	attest.NotEqual(t, rotated, remember.Value)
+	selector, _, _ := strings.Cut(rotated, ".")
+	attest.True(t, strings.HasPrefix(remember.Value, selector+"."))
+
+	// The new session cookie works on its own.
+	req := &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + cookies["session"]}}}
+	_, err = sessions.Authenticate(ctx, req)
+	attest.Ok(t, err)
+
+	// Within the grace period, the old verifier still mints a session but
+	// doesn't rotate the token.
+	_, cookies, err = recall(remember.Value)
+	attest.Ok(t, err)
+	attest.NotZero(t, cookies["session"])
+	attest.Zero(t, cookies["remember"])
+
+	// After the grace period, reusing the old verifier is theft, and the
+	// whole token is invalidated.
+	now = now.Add(time.Second)
+	_, _, err = recall(remember.Value)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, thefts, []string{"alice"})
+	_, _, err = recall(rotated)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+
+	_, _, err = recall("malformed")
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonInvalidCredentials)
+}
+
+func TestForget(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(0)
+	sessions := New(Config{Store: store, Remember: &RememberConfig{Store: store}})
+	session, _, err := sessions.Create(ctx, "alice", nil)
+	attest.Ok(t, err)
+	remember, err := sessions.Remember(ctx, session)
+	attest.Ok(t, err)
+	attest.Ok(t, sessions.Forget(ctx, remember.Value))
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{
+		Header:         http.Header{"Cookie": []string{remember.String()}},
+		ResponseHeader: http.Header{},
+	})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, sessions.ExpiredRememberCookie().Name, "remember")
+
+	disabled := New(Config{Store: store})
+	_, err = disabled.Remember(ctx, session)
+	attest.Error(t, err)
+}
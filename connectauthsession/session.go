@@ -0,0 +1,439 @@
+// Package connectauthsession authenticates [connectauth] requests with
+// server-side sessions: the standard pattern for first-party web
+// applications calling Connect backends. Clients hold only an opaque, random
+// session ID, sent in a cookie or header, and the server resolves it to an
+// identity using a [Store].
+package connectauthsession
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+)
+
+// A Session is the server-side state of a logged-in client. Expires is the
+// time the session ends unless it's renewed (see [Config].IdleTimeout).
+type Session struct {
+	Identity string            // the authenticated principal, usually a user ID
+	Values   map[string]string // application data
+	Created  time.Time
+	Expires  time.Time
+}
+
+// Subject returns the session's identity, so that [connectauth.SubjectOf]
+// works with sessions.
+func (s *Session) Subject() string {
+	return s.Identity
+}
+
+// A Store persists sessions. Keys are hex-encoded SHA-256 hashes of session
+// IDs, so a leaked store doesn't leak usable sessions.
+//
+// Get returns nil and no error if the session doesn't exist or has expired.
+// Set must expire the session after the supplied TTL. Implementations must be
+// safe to call concurrently.
+//
+// This package provides [MemoryStore] and [SQLStore], and the
+// connectauthredis package provides a Redis-backed Store.
+type Store interface {
+	Get(ctx context.Context, key string) (*Session, error)
+	Set(ctx context.Context, key string, session *Session, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// An IdentityRevoker is a [Store] or [RememberStore] that can delete all of
+// an identity's sessions or remember-me tokens at once. All the stores in
+// this package and connectauthredis implement it.
+type IdentityRevoker interface {
+	RevokeIdentity(ctx context.Context, identity string) error
+}
+
+// Config configures a [Manager].
+type Config struct {
+	// Store persists sessions. Required.
+	Store Store
+	// CookieName is the name of the session cookie. The default is
+	// "session". Names with the "__Host-" prefix can't have a CookieDomain.
+	CookieName string
+	// CookieDomain and CookiePath scope the session cookie. By default, the
+	// cookie is host-only and its path is "/".
+	CookieDomain string
+	CookiePath   string
+	// SameSite is the session cookie's SameSite attribute. The default is
+	// [http.SameSiteLaxMode]. Session cookies are always Secure and HttpOnly.
+	SameSite http.SameSite
+	// RequireTLS rejects cookie-authenticated requests that arrived without
+	// TLS with [connect.CodePermissionDenied], since the cookie was exposed
+	// on the network and any cookie the Manager issues in response would be
+	// too. Requests count as encrypted if [connectauth.Request].TLS is set,
+	// or if one of the TrustedProxies set their X-Forwarded-Proto header to
+	// "https" (see [connectauth.Encrypted]).
+	RequireTLS bool
+	// TrustedProxies are the networks of TLS-terminating proxies whose
+	// X-Forwarded-Proto header RequireTLS and OnInsecure trust. Use the same
+	// networks as [connectauth.WithTrustedProxies]. By default, the header is
+	// ignored.
+	TrustedProxies []netip.Prefix
+	// OnInsecure, if set, is called for each cookie-authenticated request
+	// that arrived without TLS, whether or not RequireTLS is set. Use it to
+	// detect misconfigured deployments, like a TLS-terminating proxy that
+	// doesn't set X-Forwarded-Proto or is missing from TrustedProxies.
+	OnInsecure func(ctx context.Context, req *connectauth.Request)
+	// Header, if set, is a request header that may carry the session ID
+	// instead of a cookie, for clients that aren't browsers. The cookie
+	// takes precedence.
+	Header string
+	// TTL is the absolute lifetime of sessions: they're never renewed beyond
+	// it. The default is 24 hours.
+	TTL time.Duration
+	// IdleTimeout, if positive, ends sessions that go unused for this long.
+	// Sessions used within the RenewWindow of their expiry are renewed
+	// transparently: the Manager extends the session in the Store and, for
+	// cookie sessions, re-issues the cookie in [connectauth.Request].ResponseHeader.
+	IdleTimeout time.Duration
+	// RenewWindow controls how close to expiry a session must be to be
+	// renewed. Renewing only near expiry avoids writing to the Store on every
+	// request. The default is half the IdleTimeout.
+	RenewWindow time.Duration
+	// Cookies, if set, signs (and optionally encrypts) session cookies, so
+	// forged cookies are rejected without a Store lookup. Session IDs sent
+	// in the Header aren't encoded.
+	Cookies *CookieCodec
+	// Remember, if set, enables long-lived remember-me tokens.
+	Remember *RememberConfig
+	// Clock determines when sessions and remember-me tokens expire. The
+	// default is [connectauth.SystemClock].
+	Clock connectauth.Clock
+}
+
+// A Manager creates, resolves, and destroys sessions. Its Authenticate method
+// is a [connectauth.AuthFunc]:
+//
+//	sessions := connectauthsession.New(connectauthsession.Config{Store: store})
+//	auth := connectauth.New(sessions.Authenticate)
+//
+// After a user logs in, create a session and set its cookie:
+//
+//	session, id, err := sessions.Create(ctx, userID, nil)
+//	// handle err
+//	cookie, err := sessions.Cookie(id, session)
+//	// handle err
+//	http.SetCookie(w, cookie)
+type Manager struct {
+	store     Store
+	cookie    string
+	domain    string
+	path      string
+	sameSite  http.SameSite
+	tls       bool
+	encrypted func(*connectauth.Request) bool
+	insecure  func(context.Context, *connectauth.Request)
+	header    string
+	ttl       time.Duration
+	idle      time.Duration
+	renew     time.Duration
+	cookies   *CookieCodec
+	remember  *RememberConfig
+	now       func() time.Time
+}
+
+// New constructs a Manager. It panics if the Store is nil, or if the cookie
+// configuration is invalid.
+func New(config Config) *Manager {
+	if config.Store == nil {
+		panic("connectauthsession: nil Store")
+	}
+	if config.CookieName == "" {
+		config.CookieName = "session"
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	if err := checkCookieConfig(config); err != nil {
+		panic("connectauthsession: " + err.Error())
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.IdleTimeout > 0 && config.RenewWindow <= 0 {
+		config.RenewWindow = config.IdleTimeout / 2
+	}
+	if config.Clock == nil {
+		config.Clock = connectauth.SystemClock()
+	}
+	return &Manager{
+		store:     config.Store,
+		cookie:    config.CookieName,
+		domain:    config.CookieDomain,
+		path:      config.CookiePath,
+		sameSite:  config.SameSite,
+		tls:       config.RequireTLS,
+		encrypted: connectauth.Encrypted(config.TrustedProxies...),
+		insecure:  config.OnInsecure,
+		header:    config.Header,
+		ttl:       config.TTL,
+		idle:      config.IdleTimeout,
+		renew:     config.RenewWindow,
+		cookies:   config.Cookies,
+		remember:  newRememberConfig(config.Remember),
+		now:       config.Clock.Now,
+	}
+}
+
+// Create starts a new session for the identity. It returns the session and
+// its ID, which is 256 random bits encoded as unpadded base64url. The ID is
+// a bearer credential: send it to the client, but don't log or store it.
+func (m *Manager) Create(ctx context.Context, identity string, values map[string]string) (*Session, string, error) {
+	id, err := randomID(32)
+	if err != nil {
+		return nil, "", err
+	}
+	now := m.now()
+	lifetime := m.ttl
+	if m.idle > 0 {
+		lifetime = min(lifetime, m.idle)
+	}
+	session := &Session{
+		Identity: identity,
+		Values:   values,
+		Created:  now,
+		Expires:  now.Add(lifetime),
+	}
+	if err := m.store.Set(ctx, Key(id), session, lifetime); err != nil {
+		return nil, "", err
+	}
+	return session, id, nil
+}
+
+// RevokeSession ends a session, typically when the user logs out. Pair it
+// with [Manager.ExpiredCookie].
+func (m *Manager) RevokeSession(ctx context.Context, id string) error {
+	return m.store.Delete(ctx, Key(id))
+}
+
+// RevokeAllForSubject ends all of an identity's sessions and deletes its
+// remember-me tokens, which implements "sign out everywhere" and responds
+// to compromised accounts. The Store (and the RememberStore, if any) must
+// implement [IdentityRevoker]; otherwise, RevokeAllForSubject returns an
+// error wrapping [errors.ErrUnsupported].
+func (m *Manager) RevokeAllForSubject(ctx context.Context, identity string) error {
+	stores := []any{m.store}
+	if m.remember != nil {
+		stores = append(stores, m.remember.Store)
+	}
+	for _, store := range stores {
+		revoker, ok := store.(IdentityRevoker)
+		if !ok {
+			return fmt.Errorf("%T can't revoke by identity: %w", store, errors.ErrUnsupported)
+		}
+		if err := revoker.RevokeIdentity(ctx, identity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Authenticate is a [connectauth.AuthFunc] that resolves the request's
+// session ID to a [*Session]. Requests without a session ID, or with an
+// unknown or expired one, are rejected with [connect.CodeUnauthenticated].
+// Requests authenticated with the cookie are flagged with
+// [connectauth.FlagCookie], so that [connectauth.WithCSRF] protects them.
+//
+// If remember-me tokens are enabled (see [Config].Remember), requests without
+// a valid session but with a valid remember-me cookie get a new session.
+func (m *Manager) Authenticate(ctx context.Context, req *connectauth.Request) (any, error) {
+	if err := m.checkTransport(ctx, req); err != nil {
+		return nil, err
+	}
+	session, err := m.authenticate(ctx, req)
+	if err != nil && m.remember != nil && connect.CodeOf(err) == connect.CodeUnauthenticated {
+		if recalled, ok, recallErr := m.recall(ctx, req); ok {
+			return recalled, recallErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (m *Manager) authenticate(ctx context.Context, req *connectauth.Request) (*Session, error) {
+	id, fromCookie, err := m.sessionID(req)
+	if err != nil {
+		return nil, connectauth.NewReasonError(connect.CodeUnauthenticated, connectauth.ReasonInvalidCredentials, err)
+	}
+	if id == "" {
+		return nil, connectauth.ReasonErrorf(connectauth.ReasonMissingCredentials, "missing session")
+	}
+	key := Key(id)
+	session, err := m.store.Get(ctx, key)
+	if err != nil {
+		return nil, connectauth.NewReasonError(
+			connect.CodeUnavailable,
+			connectauth.ReasonUpstreamUnavailable,
+			fmt.Errorf("load session: %w", err),
+		)
+	}
+	now := m.now()
+	if session == nil || !now.Before(session.Expires) {
+		return nil, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "invalid or expired session")
+	}
+	if m.idle > 0 && session.Expires.Sub(now) < m.renew {
+		m.renewSession(ctx, req, id, key, session, now, fromCookie)
+	}
+	if fromCookie {
+		req.Flags = append(req.Flags, connectauth.FlagCookie)
+	}
+	return session, nil
+}
+
+// renewSession extends an idle session, up to its absolute lifetime.
+// Renewal is best-effort: if it fails, the session remains valid until its
+// current expiry.
+func (m *Manager) renewSession(ctx context.Context, req *connectauth.Request, id, key string, session *Session, now time.Time, fromCookie bool) {
+	expires := now.Add(min(m.idle, session.Created.Add(m.ttl).Sub(now)))
+	if !expires.After(session.Expires) {
+		return // at the absolute limit
+	}
+	renewed := *session
+	renewed.Expires = expires
+	if err := m.store.Set(ctx, key, &renewed, expires.Sub(now)); err != nil {
+		return
+	}
+	*session = renewed
+	if !fromCookie || req.ResponseHeader == nil {
+		return
+	}
+	if cookie, err := m.Cookie(id, session); err == nil {
+		req.ResponseHeader.Add("Set-Cookie", cookie.String())
+	}
+}
+
+// SessionID extracts the session ID from the request's cookie or header. It
+// returns an empty string if there's no ID or the cookie is invalid.
+func (m *Manager) SessionID(req *connectauth.Request) string {
+	id, _, _ := m.sessionID(req)
+	return id
+}
+
+func (m *Manager) sessionID(req *connectauth.Request) (id string, fromCookie bool, err error) {
+	if c, err := (&http.Request{Header: req.Header}).Cookie(m.cookie); err == nil && c.Value != "" {
+		if m.cookies == nil {
+			return c.Value, true, nil
+		}
+		raw, err := m.cookies.Decode(m.cookie, c.Value)
+		if err != nil {
+			return "", true, err
+		}
+		return string(raw), true, nil
+	}
+	if m.header != "" {
+		return req.Header.Get(m.header), false, nil
+	}
+	return "", false, nil
+}
+
+// Cookie returns a cookie carrying the session ID, encoded with the
+// configured [CookieCodec] (if any). It's always HttpOnly and Secure, and
+// expires with the session.
+func (m *Manager) Cookie(id string, session *Session) (*http.Cookie, error) {
+	value := id
+	if m.cookies != nil {
+		var err error
+		if value, err = m.cookies.Encode(m.cookie, []byte(id)); err != nil {
+			return nil, err
+		}
+	}
+	c := m.newCookie(m.cookie, value)
+	c.Expires = session.Expires
+	return c, nil
+}
+
+// ExpiredCookie returns a cookie that deletes the session cookie from the
+// client, for use when logging out.
+func (m *Manager) ExpiredCookie() *http.Cookie {
+	c := m.newCookie(m.cookie, "")
+	c.MaxAge = -1
+	return c
+}
+
+// newCookie returns a cookie with the configured attributes. Secure and
+// HttpOnly can't be disabled.
+func (m *Manager) newCookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   m.domain,
+		Path:     m.path,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: m.sameSite,
+	}
+}
+
+// checkTransport enforces the TLS policy for requests with session or
+// remember-me cookies.
+func (m *Manager) checkTransport(ctx context.Context, req *connectauth.Request) error {
+	if (!m.tls && m.insecure == nil) || !m.hasCookie(req) || m.encrypted(req) {
+		return nil
+	}
+	if m.insecure != nil {
+		m.insecure(ctx, req)
+	}
+	if m.tls {
+		return connectauth.NewReasonError(
+			connect.CodePermissionDenied,
+			connectauth.ReasonPolicy,
+			errors.New("session cookie sent without TLS"),
+		)
+	}
+	return nil
+}
+
+func (m *Manager) hasCookie(req *connectauth.Request) bool {
+	r := &http.Request{Header: req.Header}
+	if _, err := r.Cookie(m.cookie); err == nil {
+		return true
+	}
+	if m.remember == nil {
+		return false
+	}
+	_, err := r.Cookie(m.remember.CookieName)
+	return err == nil
+}
+
+func checkCookieConfig(config Config) error {
+	switch config.SameSite {
+	case http.SameSiteLaxMode, http.SameSiteStrictMode, http.SameSiteNoneMode:
+	default:
+		return fmt.Errorf("invalid SameSite mode %d", config.SameSite)
+	}
+	names := []string{config.CookieName}
+	if config.Remember != nil && config.Remember.CookieName != "" {
+		names = append(names, config.Remember.CookieName)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "__Host-") && (config.CookieDomain != "" || config.CookiePath != "/") {
+			return fmt.Errorf("cookie %q requires an empty domain and a path of \"/\"", name)
+		}
+	}
+	return nil
+}
+
+// Key returns the Store key for a session ID: its hex-encoded SHA-256 hash.
+func Key(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
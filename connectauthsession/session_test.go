@@ -0,0 +1,287 @@
+package connectauthsession
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+// mapStore is a minimal Store for tests.
+type mapStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	err      error
+}
+
+func (s *mapStore) Get(_ context.Context, key string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[key], s.err
+}
+
+func (s *mapStore) Set(_ context.Context, key string, session *Session, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*Session)
+	}
+	s.sessions[key] = session
+	return s.err
+}
+
+func (s *mapStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+	return s.err
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	store := &mapStore{}
+	now := time.Unix(1_700_000_000, 0)
+	sessions := New(Config{Store: store, Header: "X-Session-Id", TTL: time.Hour})
+	sessions.now = func() time.Time { return now }
+
+	session, id, err := sessions.Create(ctx, "alice", map[string]string{"theme": "dark"})
+	attest.Ok(t, err)
+	attest.Equal(t, len(id), 43)
+	attest.Equal(t, session.Expires, now.Add(time.Hour))
+	_, ok := store.sessions[id]
+	attest.False(t, ok) // only hashes are stored
+	_, ok = store.sessions[Key(id)]
+	attest.True(t, ok)
+
+	cookie, err := sessions.Cookie(id, session)
+	attest.Ok(t, err)
+	attest.True(t, cookie.HttpOnly)
+	attest.True(t, cookie.Secure)
+	withCookie := &connectauth.Request{Header: http.Header{"Cookie": []string{"other=1; " + cookie.String()}}}
+	info, err := sessions.Authenticate(ctx, withCookie)
+	attest.Ok(t, err)
+	attest.Equal(t, connectauth.SubjectOf(info), "alice")
+	withHeader := &connectauth.Request{Header: http.Header{"X-Session-Id": []string{id}}}
+	_, err = sessions.Authenticate(ctx, withHeader)
+	attest.Ok(t, err)
+
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{}})
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonMissingCredentials)
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"X-Session-Id": []string{"forged"}}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonInvalidCredentials)
+
+	now = now.Add(time.Hour)
+	_, err = sessions.Authenticate(ctx, withCookie)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	now = now.Add(-time.Hour)
+
+	attest.Ok(t, sessions.RevokeSession(ctx, id))
+	_, err = sessions.Authenticate(ctx, withCookie)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, sessions.ExpiredCookie().MaxAge, -1)
+
+	store.err = errors.New("oh no")
+	_, err = sessions.Authenticate(ctx, withHeader)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+}
+
+func TestManagerCSRF(t *testing.T) {
+	ctx := context.Background()
+	sessions := New(Config{Store: &mapStore{}, Header: "X-Session-Id"})
+	_, id, err := sessions.Create(ctx, "alice", nil)
+	attest.Ok(t, err)
+	handler := connectauth.New(
+		sessions.Authenticate,
+		connectauth.WithCSRF(connectauth.CSRFConfig{}),
+	).Middleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(header http.Header) int {
+		req := httptest.NewRequest(http.MethodPost, "/acme.v1.Svc/Get", strings.NewReader("{}"))
+		req.Header = header
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	attest.Equal(t, call(http.Header{"X-Session-Id": []string{id}}), http.StatusOK)
+	attest.Equal(t, call(http.Header{"Cookie": []string{"session=" + id}}), http.StatusForbidden)
+	attest.Equal(t, call(http.Header{
+		"Cookie":       []string{"session=" + id},
+		"X-Csrf-Token": []string{"token"},
+	}), http.StatusOK)
+}
+
+func TestManagerRenewal(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(1_700_000_000, 0)
+	now := start
+	sessions := New(Config{
+		Store:       &mapStore{},
+		TTL:         30 * time.Minute,
+		IdleTimeout: 10 * time.Minute, // renew within 5m of expiry
+	})
+	sessions.now = func() time.Time { return now }
+	session, id, err := sessions.Create(ctx, "alice", nil)
+	attest.Ok(t, err)
+	attest.Equal(t, session.Expires, start.Add(10*time.Minute))
+	cookie, err := sessions.Cookie(id, session)
+	attest.Ok(t, err)
+
+	// use authenticates a cookie request at the given offset from the start,
+	// and returns the new expiry and any re-issued cookie.
+	use := func(offset time.Duration) (time.Time, string, error) {
+		now = start.Add(offset)
+		req := &connectauth.Request{
+			Header:         http.Header{"Cookie": []string{cookie.String()}},
+			ResponseHeader: http.Header{},
+		}
+		info, err := sessions.Authenticate(ctx, req)
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		return info.(*Session).Expires, req.ResponseHeader.Get("Set-Cookie"), nil
+	}
+
+	expires, reissued, err := use(4 * time.Minute)
+	attest.Ok(t, err)
+	attest.Equal(t, expires, start.Add(10*time.Minute)) // outside the renewal window
+	attest.Zero(t, reissued)
+
+	expires, reissued, err = use(6 * time.Minute)
+	attest.Ok(t, err)
+	attest.Equal(t, expires, start.Add(16*time.Minute))
+	attest.Subsequence(t, reissued, "session="+id)
+	attest.Subsequence(t, reissued, "HttpOnly")
+
+	expires, _, err = use(15 * time.Minute)
+	attest.Ok(t, err)
+	attest.Equal(t, expires, start.Add(25*time.Minute))
+	expires, _, err = use(21 * time.Minute)
+	attest.Ok(t, err)
+	attest.Equal(t, expires, start.Add(30*time.Minute)) // capped by the TTL
+	expires, reissued, err = use(26 * time.Minute)
+	attest.Ok(t, err)
+	attest.Equal(t, expires, start.Add(30*time.Minute))
+	attest.Zero(t, reissued)
+	_, _, err = use(30 * time.Minute)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+
+	// Idle sessions expire.
+	now = start
+	_, idleID, err := sessions.Create(ctx, "bob", nil)
+	attest.Ok(t, err)
+	now = start.Add(10 * time.Minute)
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + idleID}}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+}
+
+func TestRevokeAllForSubject(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(0)
+	sessions := New(Config{Store: store, Remember: &RememberConfig{Store: store}})
+	var ids []string
+	for _, identity := range []string{"alice", "alice", "bob"} {
+		session, id, err := sessions.Create(ctx, identity, nil)
+		attest.Ok(t, err)
+		_, err = sessions.Remember(ctx, session)
+		attest.Ok(t, err)
+		ids = append(ids, id)
+	}
+	attest.Ok(t, sessions.RevokeAllForSubject(ctx, "alice"))
+	attest.Equal(t, store.Len(), 1)
+	attest.Equal(t, len(store.remember), 1)
+	_, err := sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + ids[0]}}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + ids[2]}}})
+	attest.Ok(t, err)
+
+	unsupported := New(Config{Store: &mapStore{}})
+	attest.ErrorIs(t, unsupported.RevokeAllForSubject(ctx, "alice"), errors.ErrUnsupported)
+}
+
+func TestManagerCookieAttributes(t *testing.T) {
+	sessions := New(Config{
+		Store:        &mapStore{},
+		CookieName:   "__Secure-session",
+		CookieDomain: "example.com",
+		CookiePath:   "/app",
+		SameSite:     http.SameSiteStrictMode,
+	})
+	cookie, err := sessions.Cookie("id", &Session{})
+	attest.Ok(t, err)
+	attest.Equal(t, cookie.Domain, "example.com")
+	attest.Equal(t, cookie.Path, "/app")
+	attest.Equal(t, cookie.SameSite, http.SameSiteStrictMode)
+	attest.True(t, cookie.Secure)
+	attest.True(t, cookie.HttpOnly)
+	attest.Equal(t, sessions.ExpiredCookie().Domain, "example.com")
+
+	for _, config := range []Config{
+		{CookieName: "__Host-session", CookieDomain: "example.com"},
+		{CookieName: "__Host-session", CookiePath: "/app"},
+		{Remember: &RememberConfig{Store: NewMemoryStore(0), CookieName: "__Host-remember"}, CookiePath: "/app"},
+		{SameSite: http.SameSite(42)},
+	} {
+		config.Store = &mapStore{}
+		func() {
+			defer func() { attest.NotZero(t, recover()) }()
+			New(config)
+		}()
+	}
+}
+
+func TestManagerRequireTLS(t *testing.T) {
+	ctx := context.Background()
+	var insecure int
+	sessions := New(Config{
+		Store:          &mapStore{},
+		Header:         "X-Session-Id",
+		RequireTLS:     true,
+		TrustedProxies: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")},
+		OnInsecure:     func(context.Context, *connectauth.Request) { insecure++ },
+	})
+	_, id, err := sessions.Create(ctx, "alice", nil)
+	attest.Ok(t, err)
+
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"Cookie": []string{"session=" + id}}})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonPolicy)
+	attest.Equal(t, insecure, 1)
+
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{
+		Header: http.Header{"Cookie": []string{"session=" + id}},
+		TLS:    &tls.ConnectionState{},
+	})
+	attest.Ok(t, err)
+	forwarded := func(peer string) *connectauth.Request {
+		return &connectauth.Request{
+			PeerAddr: peer,
+			Header: http.Header{
+				"Cookie":            []string{"session=" + id},
+				"X-Forwarded-Proto": []string{"http", "https"},
+			},
+		}
+	}
+	_, err = sessions.Authenticate(ctx, forwarded("10.0.0.1:1234"))
+	attest.Ok(t, err)
+	_, err = sessions.Authenticate(ctx, &connectauth.Request{Header: http.Header{"X-Session-Id": []string{id}}})
+	attest.Ok(t, err) // headers aren't cookies
+	attest.Equal(t, insecure, 1)
+
+	// Untrusted clients can't forge the header.
+	_, err = sessions.Authenticate(ctx, forwarded("192.0.2.1:1234"))
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, insecure, 2)
+}
@@ -0,0 +1,146 @@
+package connectauthsession
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// SQLConfig configures a [SQLStore].
+type SQLConfig struct {
+	// Table is the name of the sessions table. The default is "sessions".
+	Table string
+	// Placeholder formats the nth (1-based) query parameter. The default
+	// uses "?", which works with MySQL and SQLite; use [DollarPlaceholder]
+	// with PostgreSQL.
+	Placeholder func(n int) string
+	// Codec serializes sessions. The default is [JSONCodec].
+	Codec Codec
+	// Clock determines when stored sessions expire. The default is
+	// [connectauth.SystemClock].
+	Clock connectauth.Clock
+}
+
+// DollarPlaceholder formats query parameters as $1, $2, and so on, as
+// PostgreSQL requires.
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// A SQLStore is a [Store] backed by a database/sql database. It requires a
+// table like this one, adjusted for the database's dialect:
+//
+//	CREATE TABLE sessions (
+//		id       VARCHAR(64) PRIMARY KEY,
+//		identity VARCHAR(255) NOT NULL,
+//		data     BLOB NOT NULL,
+//		expires  BIGINT NOT NULL
+//	);
+//	CREATE INDEX sessions_identity ON sessions (identity);
+//	CREATE INDEX sessions_expires ON sessions (expires);
+//
+// Expiry times are stored as Unix milliseconds. Expired sessions are never
+// returned, but they remain in the table until [SQLStore.DeleteExpired]
+// removes them; call it periodically.
+type SQLStore struct {
+	db    *sql.DB
+	codec Codec
+	now   func() time.Time
+
+	get, insert, remove, expire, revoke string
+}
+
+// NewSQLStore constructs a SQLStore. It doesn't create the sessions table.
+func NewSQLStore(db *sql.DB, config SQLConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = "sessions"
+	}
+	if config.Placeholder == nil {
+		config.Placeholder = func(int) string { return "?" }
+	}
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+	if config.Clock == nil {
+		config.Clock = connectauth.SystemClock()
+	}
+	p := config.Placeholder
+	return &SQLStore{
+		db:     db,
+		codec:  config.Codec,
+		now:    config.Clock.Now,
+		get:    fmt.Sprintf("SELECT data, expires FROM %s WHERE id = %s", config.Table, p(1)),
+		insert: fmt.Sprintf("INSERT INTO %s (id, identity, data, expires) VALUES (%s, %s, %s, %s)", config.Table, p(1), p(2), p(3), p(4)),
+		remove: fmt.Sprintf("DELETE FROM %s WHERE id = %s", config.Table, p(1)),
+		expire: fmt.Sprintf("DELETE FROM %s WHERE expires <= %s", config.Table, p(1)),
+		revoke: fmt.Sprintf("DELETE FROM %s WHERE identity = %s", config.Table, p(1)),
+	}
+}
+
+// Get implements [Store].
+func (s *SQLStore) Get(ctx context.Context, key string) (*Session, error) {
+	var (
+		data    []byte
+		expires int64
+	)
+	err := s.db.QueryRowContext(ctx, s.get, key).Scan(&data, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.now().UnixMilli() >= expires {
+		return nil, nil
+	}
+	return s.codec.Unmarshal(data)
+}
+
+// Set implements [Store]. To stay portable across dialects, it replaces any
+// existing session with a delete and insert in a single transaction.
+func (s *SQLStore) Set(ctx context.Context, key string, session *Session, ttl time.Duration) error {
+	data, err := s.codec.Marshal(session)
+	if err != nil {
+		return err
+	}
+	expires := s.now().Add(ttl).UnixMilli()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if _, err := tx.ExecContext(ctx, s.remove, key); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.insert, key, session.Identity, data, expires); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete implements [Store].
+func (s *SQLStore) Delete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.remove, key)
+	return err
+}
+
+// RevokeIdentity implements [IdentityRevoker].
+func (s *SQLStore) RevokeIdentity(ctx context.Context, identity string) error {
+	_, err := s.db.ExecContext(ctx, s.revoke, identity)
+	return err
+}
+
+// DeleteExpired removes expired sessions from the table and returns the
+// number removed.
+func (s *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, s.expire, s.now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
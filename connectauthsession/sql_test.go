@@ -0,0 +1,95 @@
+package connectauthsession
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"go.akshayshah.org/attest"
+)
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	attest.Ok(t, err)
+	t.Cleanup(func() { db.Close() })
+	now := time.UnixMilli(1_700_000_000_000)
+	store := NewSQLStore(db, SQLConfig{Table: "web_sessions", Placeholder: DollarPlaceholder})
+	store.now = func() time.Time { return now }
+
+	session := &Session{Identity: "alice", Values: map[string]string{"theme": "dark"}}
+	data, err := JSONCodec{}.Marshal(session)
+	attest.Ok(t, err)
+	expires := now.Add(time.Hour).UnixMilli()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM web_sessions WHERE id = $1")).
+		WithArgs("key").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO web_sessions (id, identity, data, expires) VALUES ($1, $2, $3, $4)")).
+		WithArgs("key", "alice", data, expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	attest.Ok(t, store.Set(ctx, "key", session, time.Hour))
+
+	get := regexp.QuoteMeta("SELECT data, expires FROM web_sessions WHERE id = $1")
+	mock.ExpectQuery(get).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "expires"}).AddRow(data, expires))
+	got, err := store.Get(ctx, "key")
+	attest.Ok(t, err)
+	attest.Equal(t, got.Identity, "alice")
+	attest.Equal(t, got.Values, session.Values)
+
+	mock.ExpectQuery(get).
+		WithArgs("key").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "expires"}).AddRow(data, now.UnixMilli()))
+	got, err = store.Get(ctx, "key")
+	attest.Ok(t, err)
+	attest.Zero(t, got) // expired
+
+	mock.ExpectQuery(get).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows([]string{"data", "expires"}))
+	got, err = store.Get(ctx, "missing")
+	attest.Ok(t, err)
+	attest.Zero(t, got)
+
+	mock.ExpectQuery(get).WithArgs("key").WillReturnError(errors.New("oh no"))
+	_, err = store.Get(ctx, "key")
+	attest.Error(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM web_sessions WHERE id = $1")).
+		WithArgs("key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	attest.Ok(t, store.Delete(ctx, "key"))
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM web_sessions WHERE expires <= $1")).
+		WithArgs(now.UnixMilli()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	n, err := store.DeleteExpired(ctx)
+	attest.Ok(t, err)
+	attest.Equal(t, n, 3)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM web_sessions WHERE identity = $1")).
+		WithArgs("alice").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	attest.Ok(t, store.RevokeIdentity(ctx, "alice"))
+
+	attest.Ok(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLStoreDefaults(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	attest.Ok(t, err)
+	t.Cleanup(func() { db.Close() })
+	store := NewSQLStore(db, SQLConfig{})
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM sessions WHERE id = ?")).
+		WithArgs("key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	attest.Ok(t, store.Delete(context.Background(), "key"))
+	attest.Ok(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,18 @@
+module go.akshayshah.org/connectauth/connectauthstatsd
+
+go 1.21
+
+require (
+	connectrpc.com/connect v1.11.1
+	go.akshayshah.org/attest v1.0.2
+	go.akshayshah.org/connectauth v0.4.0
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/google/go-cmp v0.6.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+)
+
+replace go.akshayshah.org/connectauth => ../
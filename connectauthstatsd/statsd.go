@@ -0,0 +1,169 @@
+// Package connectauthstatsd exports [connectauth] authentication metrics to
+// StatsD and DogStatsD.
+package connectauthstatsd
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// UnknownProcedure is the procedure tag of metrics for procedures that the
+// Reporter doesn't recognize (see [WithProcedures]).
+const UnknownProcedure = "unknown"
+
+// A Client sends metrics to a StatsD server. Its method signatures match the
+// DogStatsD client in github.com/DataDog/datadog-go, so applications already
+// using that client can pass it directly. Tags are in "key:value" format.
+type Client interface {
+	Count(name string, value int64, tags []string, rate float64) error
+	Timing(name string, value time.Duration, tags []string, rate float64) error
+}
+
+// An Option configures a [Reporter].
+type Option interface {
+	apply(*config)
+}
+
+// WithPrefix sets the prefix of the Reporter's metric names. The default
+// prefix is "connectauth.", so metrics are named connectauth.attempts and
+// connectauth.duration.
+func WithPrefix(prefix string) Option {
+	return optionFunc(func(c *config) {
+		c.Prefix = prefix
+	})
+}
+
+// WithTags adds constant tags, in "key:value" format, to all the Reporter's
+// metrics.
+func WithTags(tags ...string) Option {
+	return optionFunc(func(c *config) {
+		c.Tags = append(c.Tags, tags...)
+	})
+}
+
+// WithSampleRate sets the sample rate passed to the Client. The default is 1.
+func WithSampleRate(rate float64) Option {
+	return optionFunc(func(c *config) {
+		c.Rate = rate
+	})
+}
+
+// WithProcedures lists the procedures that the Reporter tags by name.
+// Metrics for any other procedure are tagged with [UnknownProcedure]. By
+// default, the Reporter recognizes procedures registered in
+// [protoregistry.GlobalFiles] by generated code.
+//
+// StatsD backends store every distinct tag value, and the middleware takes
+// procedures from URL paths that unauthenticated clients choose, so tagging
+// arbitrary procedures would let clients create unlimited custom metrics.
+func WithProcedures(procedures ...string) Option {
+	return optionFunc(func(c *config) {
+		if c.Procedures == nil {
+			c.Procedures = make(map[string]struct{}, len(procedures))
+		}
+		for _, p := range procedures {
+			c.Procedures[p] = struct{}{}
+		}
+	})
+}
+
+// A Reporter records the same metrics as the connectauthotel and
+// connectauthprom packages: a counter of authentication attempts and a timer
+// of AuthFunc latency. Both are tagged with procedure, protocol, outcome,
+// failure_class, and reason. The outcome is "success", "failure", or
+// "degraded" (see [connectauth.FlagDegraded]). Unrecognized procedures are
+// tagged with [UnknownProcedure].
+//
+// Errors from the Client are ignored, since StatsD is best-effort.
+type Reporter struct {
+	client     Client
+	attempts   string
+	duration   string
+	tags       []string
+	rate       float64
+	procedures map[string]struct{} // nil uses the global registry
+}
+
+// New constructs a Reporter.
+func New(client Client, opts ...Option) *Reporter {
+	c := config{
+		Prefix: "connectauth.",
+		Rate:   1,
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	return &Reporter{
+		client:     client,
+		attempts:   c.Prefix + "attempts",
+		duration:   c.Prefix + "duration",
+		tags:       c.Tags,
+		rate:       c.Rate,
+		procedures: c.Procedures,
+	}
+}
+
+// Wrap decorates an AuthFunc so that each call is counted and timed.
+func (r *Reporter) Wrap(auth connectauth.AuthFunc) connectauth.AuthFunc {
+	return func(ctx context.Context, req *connectauth.Request) (any, error) {
+		start := time.Now()
+		info, err := auth(ctx, req)
+		elapsed := time.Since(start)
+		outcome, class := "success", ""
+		if err != nil {
+			outcome, class = "failure", connect.CodeOf(err).String()
+		} else if req.HasFlag(connectauth.FlagDegraded) {
+			outcome = "degraded"
+		}
+		tags := make([]string, 0, len(r.tags)+5)
+		tags = append(tags, r.tags...)
+		tags = append(tags,
+			"procedure:"+r.procedure(req.Procedure),
+			"protocol:"+req.Protocol,
+			"outcome:"+outcome,
+			"failure_class:"+class,
+			"reason:"+string(connectauth.ReasonOf(err)),
+		)
+		_ = r.client.Count(r.attempts, 1, tags, r.rate)
+		_ = r.client.Timing(r.duration, elapsed, tags, r.rate)
+		return info, err
+	}
+}
+
+// procedure returns the procedure tag, which must have bounded cardinality.
+// Requests that aren't RPCs have an empty procedure.
+func (r *Reporter) procedure(procedure string) string {
+	if procedure == "" {
+		return ""
+	}
+	if r.procedures != nil {
+		if _, ok := r.procedures[procedure]; ok {
+			return procedure
+		}
+		return UnknownProcedure
+	}
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(procedure, "/"), "/", "."))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name); err == nil {
+		if _, ok := desc.(protoreflect.MethodDescriptor); ok {
+			return procedure
+		}
+	}
+	return UnknownProcedure
+}
+
+type config struct {
+	Prefix     string
+	Tags       []string
+	Rate       float64
+	Procedures map[string]struct{}
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
@@ -0,0 +1,128 @@
+package connectauthstatsd
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func authenticate(_ context.Context, req *connectauth.Request) (any, error) {
+	if req.Header.Get("Authorization") != "Bearer opensesame" {
+		return nil, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "wrong passphrase")
+	}
+	return "Ali Baba", nil
+}
+
+type metric struct {
+	Name string
+	Tags []string
+	Rate float64
+}
+
+type fakeClient struct {
+	mu      sync.Mutex
+	counts  []metric
+	timings []metric
+}
+
+func (c *fakeClient) Count(name string, _ int64, tags []string, rate float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = append(c.counts, metric{name, tags, rate})
+	return nil
+}
+
+func (c *fakeClient) Timing(name string, _ time.Duration, tags []string, rate float64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timings = append(c.timings, metric{name, tags, rate})
+	return nil
+}
+
+func TestReporter(t *testing.T) {
+	client := &fakeClient{}
+	auth := New(client, WithPrefix("acme.auth."), WithTags("service:foo"), WithProcedures("/acme.v1.Svc/Get")).Wrap(authenticate)
+	for _, header := range []string{"Bearer opensesame", "Bearer wrong"} {
+		auth(context.Background(), &connectauth.Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  "connect",
+			Header:    http.Header{"Authorization": []string{header}},
+		})
+	}
+	attest.Equal(t, len(client.counts), 2)
+	attest.Equal(t, len(client.timings), 2)
+	attest.Equal(t, client.counts[0], metric{
+		Name: "acme.auth.attempts",
+		Tags: []string{
+			"service:foo",
+			"procedure:/acme.v1.Svc/Get",
+			"protocol:connect",
+			"outcome:success",
+			"failure_class:",
+			"reason:",
+		},
+		Rate: 1,
+	})
+	attest.Equal(t, client.timings[1], metric{
+		Name: "acme.auth.duration",
+		Tags: []string{
+			"service:foo",
+			"procedure:/acme.v1.Svc/Get",
+			"protocol:connect",
+			"outcome:failure",
+			"failure_class:unauthenticated",
+			"reason:invalid_credentials",
+		},
+		Rate: 1,
+	})
+}
+
+func TestReporterProcedures(t *testing.T) {
+	client := &fakeClient{}
+	auth := New(client).Wrap(authenticate)
+	// Clients choose the procedures of unauthenticated requests.
+	for _, procedure := range []string{"/acme.v1.Svc/Get", "/attacker.v1.Svc/A", ""} {
+		auth(context.Background(), &connectauth.Request{Procedure: procedure, Header: http.Header{}})
+	}
+	attest.Equal(t, len(client.counts), 3)
+	attest.Equal(t, client.counts[0].Tags[0], "procedure:/acme.v1.Svc/Get")
+	attest.Equal(t, client.counts[1].Tags[0], "procedure:"+UnknownProcedure)
+	attest.Equal(t, client.counts[2].Tags[0], "procedure:")
+
+	client = &fakeClient{}
+	auth = New(client, WithProcedures("/acme.v1.Svc/List")).Wrap(authenticate)
+	auth(context.Background(), &connectauth.Request{Procedure: "/acme.v1.Svc/Get", Header: http.Header{}})
+	attest.Equal(t, client.counts[0].Tags[0], "procedure:"+UnknownProcedure)
+}
+
+func init() {
+	// Register a service, as generated code would.
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:        proto.String("connectauthstatsd/acme.proto"),
+		Package:     proto.String("acme.v1"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Empty")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Svc"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".acme.v1.Empty"),
+				OutputType: proto.String(".acme.v1.Empty"),
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(err)
+	}
+	if err := protoregistry.GlobalFiles.RegisterFile(file); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,86 @@
+package connectauthstatsd
+
+import (
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A UDPClient is a minimal [Client] that sends each metric to a StatsD server
+// in a single UDP packet. Metrics use the DogStatsD format, which adds tags
+// to the plain StatsD line protocol; the Datadog agent, Telegraf, and the
+// Prometheus statsd_exporter all understand it.
+//
+// Applications sending large volumes of metrics should use a client that
+// buffers and aggregates, like the one in github.com/DataDog/datadog-go.
+type UDPClient struct {
+	conn net.Conn
+}
+
+var _ Client = (*UDPClient)(nil)
+
+// NewUDPClient constructs a UDPClient that sends metrics to addr, which is in
+// host:port format.
+func NewUDPClient(addr string) (*UDPClient, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPClient{conn: conn}, nil
+}
+
+// Count implements [Client].
+func (c *UDPClient) Count(name string, value int64, tags []string, rate float64) error {
+	return c.send(name, strconv.FormatInt(value, 10), "c", tags, rate)
+}
+
+// Timing implements [Client]. Durations are sent in milliseconds.
+func (c *UDPClient) Timing(name string, value time.Duration, tags []string, rate float64) error {
+	ms := strconv.FormatFloat(float64(value)/float64(time.Millisecond), 'f', -1, 64)
+	return c.send(name, ms, "ms", tags, rate)
+}
+
+// Close closes the underlying connection.
+func (c *UDPClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *UDPClient) send(name, value, kind string, tags []string, rate float64) error {
+	if rate < 1 && rand.Float64() >= rate {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+	if rate < 1 {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(rate, 'f', -1, 64))
+	}
+	if len(tags) > 0 {
+		b.WriteString("|#")
+		for i, tag := range tags {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitize(tag))
+		}
+	}
+	_, err := c.conn.Write([]byte(b.String()))
+	return err
+}
+
+// sanitize replaces characters that are reserved by the DogStatsD protocol.
+func sanitize(tag string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '|', ',', '#', '\n':
+			return '_'
+		}
+		return r
+	}, tag)
+}
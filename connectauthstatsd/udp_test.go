@@ -0,0 +1,32 @@
+package connectauthstatsd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestUDPClient(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	attest.Ok(t, err)
+	defer server.Close()
+	client, err := NewUDPClient(server.LocalAddr().String())
+	attest.Ok(t, err)
+	defer client.Close()
+
+	read := func() string {
+		t.Helper()
+		buf := make([]byte, 1024)
+		attest.Ok(t, server.SetReadDeadline(time.Now().Add(5*time.Second)))
+		n, _, err := server.ReadFrom(buf)
+		attest.Ok(t, err)
+		return string(buf[:n])
+	}
+
+	attest.Ok(t, client.Count("connectauth.attempts", 1, []string{"outcome:success", "procedure:/a|b"}, 1))
+	attest.Equal(t, read(), "connectauth.attempts:1|c|#outcome:success,procedure:/a_b")
+	attest.Ok(t, client.Timing("connectauth.duration", 1500*time.Microsecond, nil, 1))
+	attest.Equal(t, read(), "connectauth.duration:1.5|ms")
+}
@@ -0,0 +1,96 @@
+package connectauthtest
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+)
+
+// IdentityHeader is the request header read by [FromHeader] and set by
+// [ClientInterceptor] for contexts from [WithIdentity].
+const IdentityHeader = "Connectauthtest-Identity"
+
+type identityKey struct{}
+
+type tokenKey struct{}
+
+// FromHeader returns an AuthFunc that trusts the identity in the request's
+// [IdentityHeader], attaching it to the context as a string. Requests
+// without the header are rejected with [connect.CodeUnauthenticated].
+//
+// FromHeader lets clients claim any identity, so it must only be used in
+// tests.
+func FromHeader() connectauth.AuthFunc {
+	return func(_ context.Context, req *connectauth.Request) (any, error) {
+		identity := req.Header.Get(IdentityHeader)
+		if identity == "" {
+			return nil, connectauth.ReasonErrorf(connectauth.ReasonMissingCredentials, "connectauthtest: missing %s header", IdentityHeader)
+		}
+		return identity, nil
+	}
+}
+
+// WithIdentity returns a client context that makes RPCs arrive
+// authenticated as the identity, when sent with a [ClientInterceptor] to a
+// server using [FromHeader]. It's the client-side counterpart of
+// [connectauth.SetInfo], but works end-to-end over the wire.
+func WithIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// WithToken returns a client context that makes RPCs carry the bearer token,
+// when sent with a [ClientInterceptor]. Use it with servers using [Static].
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// StampHeader sets the headers for the identity and token from the context,
+// if any. It's useful for tests that send plain HTTP requests.
+func StampHeader(ctx context.Context, h http.Header) {
+	if identity, ok := ctx.Value(identityKey{}).(string); ok {
+		h.Set(IdentityHeader, identity)
+	}
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		h.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// ClientInterceptor is a Connect client interceptor that sends the identity
+// and token from [WithIdentity] and [WithToken] with each RPC:
+//
+//	client := pingv1connect.NewPingServiceClient(
+//		httpClient,
+//		url,
+//		connect.WithInterceptors(connectauthtest.ClientInterceptor{}),
+//	)
+//	ctx := connectauthtest.WithIdentity(context.Background(), "alice")
+//	res, err := client.Ping(ctx, req)
+type ClientInterceptor struct{}
+
+var _ connect.Interceptor = ClientInterceptor{}
+
+// WrapUnary implements connect.Interceptor.
+func (ClientInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			StampHeader(ctx, req.Header())
+		}
+		return next(ctx, req)
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor.
+func (ClientInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		StampHeader(ctx, conn.RequestHeader())
+		return conn
+	}
+}
+
+// WrapStreamingHandler implements connect.Interceptor with a no-op.
+func (ClientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
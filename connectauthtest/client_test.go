@@ -0,0 +1,84 @@
+package connectauthtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestClientInterceptor(t *testing.T) {
+	identify := func(ctx context.Context) string {
+		return connectauth.GetInfo(ctx).(string)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/test.v1/Header", connect.NewUnaryHandler(
+		"/test.v1/Header",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			res := connect.NewResponse(&emptypb.Empty{})
+			res.Header().Set("Identity", identify(ctx))
+			return res, nil
+		},
+	))
+	mux.Handle("/test.v1/Stream", connect.NewClientStreamHandler(
+		"/test.v1/Stream",
+		func(ctx context.Context, stream *connect.ClientStream[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			for stream.Receive() {
+			}
+			res := connect.NewResponse(&emptypb.Empty{})
+			res.Header().Set("Identity", identify(ctx))
+			return res, nil
+		},
+	))
+	mux.Handle("/test.v1/Token", connect.NewUnaryHandler(
+		"/test.v1/Token",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			res := connect.NewResponse(&emptypb.Empty{})
+			res.Header().Set("Identity", identify(ctx))
+			return res, nil
+		},
+		connect.WithInterceptors(StaticInterceptor(map[string]any{"bob-token": "bob"})),
+	))
+	srv := memhttptest.New(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/test.v1/Token" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		connectauth.NewMiddleware(FromHeader()).Wrap(mux).ServeHTTP(w, r)
+	}))
+	opt := connect.WithInterceptors(ClientInterceptor{})
+
+	ctx := WithIdentity(context.Background(), "alice")
+	unary := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/test.v1/Header", opt)
+	_, err := unary.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	res, err := unary.CallUnary(ctx, connect.NewRequest(&emptypb.Empty{}))
+	attest.Ok(t, err)
+	attest.Equal(t, res.Header().Get("Identity"), "alice")
+
+	streaming := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/test.v1/Stream", opt)
+	stream := streaming.CallClientStream(ctx)
+	attest.Ok(t, stream.Send(&emptypb.Empty{}))
+	res, err = stream.CloseAndReceive()
+	attest.Ok(t, err)
+	attest.Equal(t, res.Header().Get("Identity"), "alice")
+
+	token := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/test.v1/Token", opt)
+	res, err = token.CallUnary(WithToken(context.Background(), "bob-token"), connect.NewRequest(&emptypb.Empty{}))
+	attest.Ok(t, err)
+	attest.Equal(t, res.Header().Get("Identity"), "bob")
+}
+
+func TestStampHeader(t *testing.T) {
+	h := http.Header{}
+	StampHeader(context.Background(), h)
+	attest.Equal(t, len(h), 0)
+	StampHeader(WithToken(WithIdentity(context.Background(), "alice"), "token"), h)
+	attest.Equal(t, h.Get(IdentityHeader), "alice")
+	attest.Equal(t, h.Get("Authorization"), "Bearer token")
+}
@@ -0,0 +1,60 @@
+package connectauthtest
+
+import (
+	"sync"
+	"time"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// A Clock is a [connectauth.Clock] that only moves when told to. Supply it
+// to time-sensitive components, then advance it to expire tokens, sessions,
+// and lockouts without sleeping:
+//
+//	clock := connectauthtest.NewClock(time.Time{})
+//	cache := connectauth.NewTokenCache(auth, connectauth.TokenCacheConfig{
+//		TTL:   time.Minute,
+//		Clock: clock,
+//	})
+//	// ...populate the cache...
+//	clock.Advance(time.Minute) // cached entries are now expired
+//
+// Clocks are safe to use concurrently.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ connectauth.Clock = (*Clock)(nil)
+
+// NewClock constructs a Clock set to the supplied time. If the time is zero,
+// the clock starts at midnight UTC on January 1, 2024.
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return &Clock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward and returns the new time. Negative
+// durations move it backward, which is useful for simulating clock skew.
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// Set moves the clock to the supplied time.
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
@@ -0,0 +1,37 @@
+package connectauthtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewClock(time.Time{})
+	start := clock.Now()
+	attest.Equal(t, start, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	attest.Equal(t, clock.Advance(time.Minute), start.Add(time.Minute))
+	attest.Equal(t, clock.Now(), start.Add(time.Minute))
+	clock.Set(start)
+	attest.Equal(t, clock.Now(), start)
+
+	var calls int
+	cache := connectauth.NewTokenCache(func(context.Context, *connectauth.Request) (any, error) {
+		calls++
+		return "alice", nil
+	}, connectauth.TokenCacheConfig{TTL: time.Minute, Clock: clock})
+	req := &connectauth.Request{Header: http.Header{"Authorization": []string{"Bearer alice-token"}}}
+	for i := 0; i < 2; i++ {
+		_, err := cache.Authenticate(context.Background(), req)
+		attest.Ok(t, err)
+	}
+	attest.Equal(t, calls, 1)
+	clock.Advance(time.Minute)
+	_, err := cache.Authenticate(context.Background(), req)
+	attest.Ok(t, err)
+	attest.Equal(t, calls, 2)
+}
@@ -0,0 +1,77 @@
+// Package connectauthtest provides fake authentication for tests of Connect
+// services that use [connectauth]. Instead of standing up real token
+// verifiers, tests can accept every request, reject every request, map a
+// fixed set of bearer tokens to identities, or trust an identity header set
+// by a [ClientInterceptor].
+//
+// The package also helps test authentication itself. A [Recorder] captures
+// the requests an AuthFunc sees, a [Signer] and [IdentityProvider] issue real
+// tokens from deterministic keys, a [Clock] lets tests advance time, a
+// [FaultInjector] simulates outages in authentication dependencies, and
+// [CheckCoverage] finds procedures left unauthenticated by mistake.
+package connectauthtest
+
+import (
+	"context"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// AllowAll returns an AuthFunc that authenticates every request, attaching
+// the supplied information to its context.
+func AllowAll(info any) connectauth.AuthFunc {
+	return func(context.Context, *connectauth.Request) (any, error) {
+		return info, nil
+	}
+}
+
+// DenyAll returns an AuthFunc that rejects every request with the supplied
+// error. If the error is nil, requests are rejected with
+// [connect.CodeUnauthenticated].
+func DenyAll(err error) connectauth.AuthFunc {
+	if err == nil {
+		err = connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "connectauthtest: request denied")
+	}
+	return func(context.Context, *connectauth.Request) (any, error) {
+		return nil, err
+	}
+}
+
+// Static returns an AuthFunc that authenticates requests whose bearer token
+// is a key in the map, attaching the corresponding identity to their
+// context. Requests without a bearer token, or with an unknown token, are
+// rejected with [connect.CodeUnauthenticated]. The map must not be modified
+// after calling Static.
+func Static(tokens map[string]any) connectauth.AuthFunc {
+	return func(_ context.Context, req *connectauth.Request) (any, error) {
+		token, ok := connectauth.BearerToken(req.Header)
+		if !ok {
+			return nil, connectauth.ReasonErrorf(connectauth.ReasonMissingCredentials, "connectauthtest: missing bearer token")
+		}
+		identity, ok := tokens[token]
+		if !ok {
+			return nil, connectauth.ReasonErrorf(connectauth.ReasonInvalidCredentials, "connectauthtest: unknown bearer token")
+		}
+		return identity, nil
+	}
+}
+
+// AllowAllMiddleware returns [connectauth.Middleware] using [AllowAll].
+func AllowAllMiddleware(info any, opts ...connectauth.Option) *connectauth.Middleware {
+	return connectauth.New(AllowAll(info), opts...).Middleware()
+}
+
+// AllowAllInterceptor returns a [connectauth.Interceptor] using [AllowAll].
+func AllowAllInterceptor(info any, opts ...connectauth.Option) *connectauth.Interceptor {
+	return connectauth.NewInterceptor(AllowAll(info), opts...)
+}
+
+// StaticMiddleware returns [connectauth.Middleware] using [Static].
+func StaticMiddleware(tokens map[string]any, opts ...connectauth.Option) *connectauth.Middleware {
+	return connectauth.New(Static(tokens), opts...).Middleware()
+}
+
+// StaticInterceptor returns a [connectauth.Interceptor] using [Static].
+func StaticInterceptor(tokens map[string]any, opts ...connectauth.Option) *connectauth.Interceptor {
+	return connectauth.NewInterceptor(Static(tokens), opts...)
+}
@@ -0,0 +1,90 @@
+package connectauthtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestAuthFuncs(t *testing.T) {
+	ctx := context.Background()
+	req := &connectauth.Request{Header: http.Header{}}
+
+	info, err := AllowAll("alice")(ctx, req)
+	attest.Ok(t, err)
+	attest.Equal(t, info, "alice")
+
+	_, err = DenyAll(nil)(ctx, req)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	denied := connect.NewError(connect.CodePermissionDenied, errors.New("nope"))
+	_, err = DenyAll(denied)(ctx, req)
+	attest.ErrorIs(t, err, denied)
+
+	static := Static(map[string]any{"alice-token": "alice"})
+	_, err = static(ctx, req)
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonMissingCredentials)
+	req.Header.Set("Authorization", "Bearer bob-token")
+	_, err = static(ctx, req)
+	attest.Equal(t, connectauth.ReasonOf(err), connectauth.ReasonInvalidCredentials)
+	req.Header.Set("Authorization", "Bearer alice-token")
+	info, err = static(ctx, req)
+	attest.Ok(t, err)
+	attest.Equal(t, info, "alice")
+}
+
+func TestMiddlewareAndInterceptor(t *testing.T) {
+	tokens := map[string]any{"alice-token": "alice"}
+	handler := func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+		res := connect.NewResponse(&emptypb.Empty{})
+		res.Header().Set("Identity", connectauth.GetInfo(ctx).(string))
+		return res, nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/test.v1/Interceptor", connect.NewUnaryHandler(
+		"/test.v1/Interceptor",
+		handler,
+		connect.WithInterceptors(StaticInterceptor(tokens)),
+	))
+	mux.Handle("/test.v1/AllowAll", connect.NewUnaryHandler(
+		"/test.v1/AllowAll",
+		handler,
+		connect.WithInterceptors(AllowAllInterceptor("anyone")),
+	))
+	mux.Handle("/test.v1/Middleware", StaticMiddleware(tokens).Wrap(connect.NewUnaryHandler("/test.v1/Middleware", handler)))
+	mux.Handle("/test.v1/AllowAllMiddleware", AllowAllMiddleware("anyone").Wrap(
+		connect.NewUnaryHandler("/test.v1/AllowAllMiddleware", handler),
+	))
+	srv := memhttptest.New(t, mux)
+
+	call := func(procedure, token string) (string, error) {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+		req := connect.NewRequest(&emptypb.Empty{})
+		if token != "" {
+			req.Header().Set("Authorization", "Bearer "+token)
+		}
+		res, err := client.CallUnary(context.Background(), req)
+		if err != nil {
+			return "", err
+		}
+		return res.Header().Get("Identity"), nil
+	}
+	for _, procedure := range []string{"/test.v1/Interceptor", "/test.v1/Middleware"} {
+		_, err := call(procedure, "")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		identity, err := call(procedure, "alice-token")
+		attest.Ok(t, err)
+		attest.Equal(t, identity, "alice")
+	}
+	for _, procedure := range []string{"/test.v1/AllowAll", "/test.v1/AllowAllMiddleware"} {
+		identity, err := call(procedure, "")
+		attest.Ok(t, err)
+		attest.Equal(t, identity, "anyone")
+	}
+}
@@ -0,0 +1,151 @@
+package connectauthtest
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Procedures lists the procedures of every service in the registry, sorted
+// and formatted like "/acme.foo.v1.FooService/Bar". A nil registry uses
+// [protoregistry.GlobalFiles], which includes every service compiled into
+// the binary.
+func Procedures(files *protoregistry.Files) []string {
+	if files == nil {
+		files = protoregistry.GlobalFiles
+	}
+	var procedures []string
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			procedures = append(procedures, serviceProcedures(services.Get(i))...)
+		}
+		return true
+	})
+	slices.Sort(procedures)
+	return procedures
+}
+
+// ServiceProcedures lists the procedures of the supplied services, sorted
+// and formatted like "/acme.foo.v1.FooService/Bar". Services usually come
+// from generated code:
+//
+//	foov1.File_acme_foo_v1_foo_proto.Services().ByName("FooService")
+func ServiceProcedures(services ...protoreflect.ServiceDescriptor) []string {
+	var procedures []string
+	for _, service := range services {
+		procedures = append(procedures, serviceProcedures(service)...)
+	}
+	slices.Sort(procedures)
+	return slices.Compact(procedures)
+}
+
+func serviceProcedures(service protoreflect.ServiceDescriptor) []string {
+	methods := service.Methods()
+	procedures := make([]string, 0, methods.Len())
+	for i := 0; i < methods.Len(); i++ {
+		procedures = append(procedures, "/"+string(service.FullName())+"/"+string(methods.Get(i).Name()))
+	}
+	return procedures
+}
+
+// CoverageConfig describes the procedures an application serves and which of
+// them are meant to be callable without credentials.
+type CoverageConfig struct {
+	// Procedures are the procedures registered on the server, typically from
+	// [Procedures] or [ServiceProcedures].
+	Procedures []string
+	// Exempt lists the procedures that are intentionally unauthenticated.
+	// Entries may be exact procedures or globs compatible with [path.Match],
+	// like "/grpc.health.v1.Health/*".
+	Exempt []string
+	// Router, if set, is the Router used to authenticate the remaining
+	// procedures. Procedures it doesn't handle are reported as unrouted.
+	Router *connectauth.Router
+}
+
+// A CoverageReport lists procedures whose authentication doesn't match the
+// application's intent.
+type CoverageReport struct {
+	// Unexpected procedures are exempt from authentication, but aren't
+	// listed in [CoverageConfig].Exempt. They're callable without
+	// credentials, which is almost always a mistake.
+	Unexpected []string
+	// Unrouted procedures require authentication, but don't match any of the
+	// [CoverageConfig].Router's patterns, so every request for them is
+	// rejected.
+	Unrouted []string
+}
+
+// OK reports whether every procedure is covered as intended.
+func (r CoverageReport) OK() bool {
+	return len(r.Unexpected) == 0 && len(r.Unrouted) == 0
+}
+
+// String describes the problems in the report, one per line.
+func (r CoverageReport) String() string {
+	var b strings.Builder
+	for _, procedure := range r.Unexpected {
+		fmt.Fprintf(&b, "%s is unintentionally exempt from authentication\n", procedure)
+	}
+	for _, procedure := range r.Unrouted {
+		fmt.Fprintf(&b, "%s doesn't match any route\n", procedure)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// CheckCoverage reports procedures that the Authenticator would leave
+// unauthenticated without the application intending it, along with
+// procedures that the Router would reject outright. It's designed to catch
+// new RPCs that were added to a broadly-exempt service or that were never
+// given an authentication route.
+func CheckCoverage(auth *connectauth.Authenticator, config CoverageConfig) CoverageReport {
+	var report CoverageReport
+	for _, procedure := range config.Procedures {
+		if auth.IsExempt(procedure) {
+			if !intendedExempt(config.Exempt, procedure) {
+				report.Unexpected = append(report.Unexpected, procedure)
+			}
+			continue
+		}
+		if config.Router != nil && !config.Router.Handles(procedure) {
+			report.Unrouted = append(report.Unrouted, procedure)
+		}
+	}
+	return report
+}
+
+// AssertCoverage calls [CheckCoverage] and fails the test if the report
+// isn't OK:
+//
+//	func TestAuthCoverage(t *testing.T) {
+//		connectauthtest.AssertCoverage(t, authenticator, connectauthtest.CoverageConfig{
+//			Procedures: connectauthtest.Procedures(nil),
+//			Exempt:     []string{"/acme.auth.v1.AuthService/Login"},
+//			Router:     router,
+//		})
+//	}
+func AssertCoverage(tb testing.TB, auth *connectauth.Authenticator, config CoverageConfig) {
+	tb.Helper()
+	if report := CheckCoverage(auth, config); !report.OK() {
+		tb.Errorf("authentication coverage:\n%s", report)
+	}
+}
+
+func intendedExempt(patterns []string, procedure string) bool {
+	for _, pattern := range patterns {
+		if pattern == procedure {
+			return true
+		}
+		if ok, _ := path.Match(pattern, procedure); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package connectauthtest
+
+import (
+	"testing"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestCoverage(t *testing.T) {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/v1/acme.proto"),
+		Package:    proto.String("acme.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{
+			{
+				Name: proto.String("AuthService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("Login"),
+					method("Logout"),
+				},
+			},
+			{
+				Name: proto.String("UserService"),
+				Method: []*descriptorpb.MethodDescriptorProto{
+					method("GetUser"),
+					method("DeleteUser"),
+				},
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	attest.Ok(t, err)
+	files := new(protoregistry.Files)
+	attest.Ok(t, files.RegisterFile(file))
+
+	procedures := Procedures(files)
+	attest.Equal(t, procedures, []string{
+		"/acme.v1.AuthService/Login",
+		"/acme.v1.AuthService/Logout",
+		"/acme.v1.UserService/DeleteUser",
+		"/acme.v1.UserService/GetUser",
+	})
+	attest.Equal(t, ServiceProcedures(file.Services().Get(0), file.Services().Get(0)), procedures[:2])
+
+	router := connectauth.NewRouter()
+	router.Handle("/acme.v1.UserService/GetUser", AllowAll("alice"))
+	auth := connectauth.New(router.Authenticate, connectauth.WithExemptProcedures("/acme.v1.AuthService/*"))
+
+	t.Run("ok", func(t *testing.T) {
+		router := connectauth.NewRouter()
+		router.Handle("/acme.v1.UserService/*", AllowAll("alice"))
+		report := CheckCoverage(auth, CoverageConfig{
+			Procedures: procedures,
+			Exempt:     []string{"/acme.v1.AuthService/*"},
+			Router:     router,
+		})
+		attest.True(t, report.OK())
+		attest.Equal(t, report.String(), "")
+		AssertCoverage(t, auth, CoverageConfig{
+			Procedures: procedures,
+			Exempt:     []string{"/acme.v1.AuthService/Login", "/acme.v1.AuthService/Logout"},
+		})
+	})
+
+	t.Run("problems", func(t *testing.T) {
+		report := CheckCoverage(auth, CoverageConfig{
+			Procedures: procedures,
+			Exempt:     []string{"/acme.v1.AuthService/Login"},
+			Router:     router,
+		})
+		attest.False(t, report.OK())
+		attest.Equal(t, report.Unexpected, []string{"/acme.v1.AuthService/Logout"})
+		attest.Equal(t, report.Unrouted, []string{"/acme.v1.UserService/DeleteUser"})
+		attest.Equal(t, report.String(), "/acme.v1.AuthService/Logout is unintentionally exempt from authentication\n"+
+			"/acme.v1.UserService/DeleteUser doesn't match any route")
+	})
+}
+
+func method(name string) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       proto.String(name),
+		InputType:  proto.String(".google.protobuf.Empty"),
+		OutputType: proto.String(".google.protobuf.Empty"),
+	}
+}
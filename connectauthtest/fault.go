@@ -0,0 +1,231 @@
+package connectauthtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/connectauth/connectauthsession"
+)
+
+// ErrInjected is the default error returned by a [FaultInjector]. It's
+// coded [connect.CodeUnavailable], so connectauth treats it as an outage.
+var ErrInjected = connect.NewError(connect.CodeUnavailable, errors.New("connectauthtest: injected fault"))
+
+// A FaultInjector wraps authentication dependencies (remote validators, key
+// stores, caches, and session stores) and makes them slow, broken, or
+// flaky on demand. It's designed to test outage handling, like
+// [connectauth.OutagePolicy] and [connectauth.CircuitBreaker]:
+//
+//	faults := connectauthtest.NewFaultInjector()
+//	breaker := connectauth.NewCircuitBreaker(connectauth.CircuitBreakerConfig{})
+//	auth := breaker.Wrap(faults.Wrap(validateToken))
+//	faults.Fail(nil)
+//	// ...make requests and assert that the circuit opens...
+//	faults.Heal()
+//
+// Faults are deterministic: failures follow the configured pattern exactly,
+// rather than occurring at random. A single FaultInjector may wrap many
+// dependencies, in which case they share a call count and fail together.
+// FaultInjectors are safe to use concurrently.
+type FaultInjector struct {
+	mu       sync.Mutex
+	latency  time.Duration
+	err      error
+	up, down int // zero up means every call fails
+	calls    int
+	failures int
+}
+
+// NewFaultInjector constructs a healthy FaultInjector.
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{}
+}
+
+// SetLatency delays every subsequent call by d. Delays use real time and
+// end early if the call's context is canceled, in which case the call
+// returns the context's error.
+func (f *FaultInjector) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = d
+}
+
+// Fail makes every subsequent call fail with err. A nil error uses
+// [ErrInjected].
+func (f *FaultInjector) Fail(err error) {
+	f.Flap(0, 1, err)
+}
+
+// Flap makes subsequent calls alternate between up successes and down
+// failures, starting with the successes. For example, Flap(2, 1, nil) fails
+// every third call. A nil error uses [ErrInjected]. Flap panics if down
+// isn't positive or up is negative.
+func (f *FaultInjector) Flap(up, down int, err error) {
+	if up < 0 || down <= 0 {
+		panic(fmt.Sprintf("connectauthtest: invalid flap pattern %d/%d", up, down))
+	}
+	if err == nil {
+		err = ErrInjected
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.up, f.down, f.err = up, down, err
+	f.calls = 0
+}
+
+// Heal removes all injected errors and latency.
+func (f *FaultInjector) Heal() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency, f.err = 0, nil
+	f.up, f.down, f.calls = 0, 0, 0
+}
+
+// Failures returns the number of calls that have failed because of an
+// injected error.
+func (f *FaultInjector) Failures() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.failures
+}
+
+// inject applies the configured faults to a single call.
+func (f *FaultInjector) inject(ctx context.Context) error {
+	f.mu.Lock()
+	latency, err := f.latency, f.next()
+	f.mu.Unlock()
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// next advances the flapping pattern and returns the error for the current
+// call, if any. It must be called with the lock held.
+func (f *FaultInjector) next() error {
+	if f.err == nil {
+		return nil
+	}
+	pos := f.calls % (f.up + f.down)
+	f.calls++
+	if pos < f.up {
+		return nil
+	}
+	f.failures++
+	return f.err
+}
+
+// Wrap returns an AuthFunc that injects faults before calling auth. It's
+// typically used to simulate a remote validator, like a token
+// introspection endpoint.
+func (f *FaultInjector) Wrap(auth connectauth.AuthFunc) connectauth.AuthFunc {
+	return func(ctx context.Context, req *connectauth.Request) (any, error) {
+		if err := f.inject(ctx); err != nil {
+			return nil, err
+		}
+		return auth(ctx, req)
+	}
+}
+
+// WrapKeyStore returns a KeyStore that injects faults before each lookup.
+func (f *FaultInjector) WrapKeyStore(store connectauth.KeyStore) connectauth.KeyStore {
+	return &faultyKeyStore{faults: f, store: store}
+}
+
+// WrapCache returns a Cache that injects faults before each operation.
+// Caches are best-effort, so failed reads are misses and failed writes and
+// deletes are dropped.
+func (f *FaultInjector) WrapCache(cache connectauth.Cache) connectauth.Cache {
+	return &faultyCache{faults: f, cache: cache}
+}
+
+// WrapSessionStore returns a session Store that injects faults before each
+// operation. The returned Store is an [connectauthsession.IdentityRevoker]
+// if the wrapped Store is.
+func (f *FaultInjector) WrapSessionStore(store connectauthsession.Store) connectauthsession.Store {
+	return &faultySessionStore{faults: f, store: store}
+}
+
+type faultyKeyStore struct {
+	faults *FaultInjector
+	store  connectauth.KeyStore
+}
+
+func (s *faultyKeyStore) LookupAPIKey(ctx context.Context, id string) (*connectauth.APIKeyRecord, error) {
+	if err := s.faults.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.LookupAPIKey(ctx, id)
+}
+
+type faultyCache struct {
+	faults *FaultInjector
+	cache  connectauth.Cache
+}
+
+func (c *faultyCache) Get(ctx context.Context, key string) (*connectauth.CacheEntry, bool) {
+	if err := c.faults.inject(ctx); err != nil {
+		return nil, false
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c *faultyCache) Set(ctx context.Context, key string, entry *connectauth.CacheEntry) {
+	if err := c.faults.inject(ctx); err == nil {
+		c.cache.Set(ctx, key, entry)
+	}
+}
+
+func (c *faultyCache) Delete(ctx context.Context, key string) {
+	if err := c.faults.inject(ctx); err == nil {
+		c.cache.Delete(ctx, key)
+	}
+}
+
+type faultySessionStore struct {
+	faults *FaultInjector
+	store  connectauthsession.Store
+}
+
+func (s *faultySessionStore) Get(ctx context.Context, key string) (*connectauthsession.Session, error) {
+	if err := s.faults.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.Get(ctx, key)
+}
+
+func (s *faultySessionStore) Set(ctx context.Context, key string, session *connectauthsession.Session, ttl time.Duration) error {
+	if err := s.faults.inject(ctx); err != nil {
+		return err
+	}
+	return s.store.Set(ctx, key, session, ttl)
+}
+
+func (s *faultySessionStore) Delete(ctx context.Context, key string) error {
+	if err := s.faults.inject(ctx); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, key)
+}
+
+func (s *faultySessionStore) RevokeIdentity(ctx context.Context, identity string) error {
+	revoker, ok := s.store.(connectauthsession.IdentityRevoker)
+	if !ok {
+		return fmt.Errorf("%T can't revoke by identity: %w", s.store, errors.ErrUnsupported)
+	}
+	if err := s.faults.inject(ctx); err != nil {
+		return err
+	}
+	return revoker.RevokeIdentity(ctx, identity)
+}
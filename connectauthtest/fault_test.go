@@ -0,0 +1,127 @@
+package connectauthtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/connectauth/connectauthsession"
+)
+
+func TestFaultInjector(t *testing.T) {
+	ctx := context.Background()
+	req := &connectauth.Request{Procedure: "/test.v1/Get"}
+
+	t.Run("flap", func(t *testing.T) {
+		faults := NewFaultInjector()
+		auth := faults.Wrap(AllowAll("alice"))
+		call := func() error {
+			_, err := auth(ctx, req)
+			return err
+		}
+		attest.Ok(t, call())
+
+		faults.Flap(2, 1, nil)
+		var failed []bool
+		for i := 0; i < 6; i++ {
+			err := call()
+			failed = append(failed, err != nil)
+			if err != nil {
+				attest.ErrorIs(t, err, ErrInjected)
+				attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+			}
+		}
+		attest.Equal(t, failed, []bool{false, false, true, false, false, true})
+		attest.Equal(t, faults.Failures(), 2)
+
+		custom := errors.New("boom")
+		faults.Fail(custom)
+		attest.ErrorIs(t, call(), custom)
+		faults.Heal()
+		attest.Ok(t, call())
+		attest.Equal(t, faults.Failures(), 3)
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		faults := NewFaultInjector()
+		auth := faults.Wrap(AllowAll("alice"))
+		faults.SetLatency(10 * time.Millisecond)
+		start := time.Now()
+		_, err := auth(ctx, req)
+		attest.Ok(t, err)
+		attest.True(t, time.Since(start) >= 10*time.Millisecond)
+
+		faults.SetLatency(time.Hour)
+		timeout, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		_, err = auth(timeout, req)
+		attest.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("circuit breaker", func(t *testing.T) {
+		faults := NewFaultInjector()
+		breaker := connectauth.NewCircuitBreaker(connectauth.CircuitBreakerConfig{Threshold: 2})
+		auth := breaker.Wrap(faults.Wrap(AllowAll("alice")))
+		faults.Fail(nil)
+		for i := 0; i < 3; i++ {
+			_, err := auth(ctx, req)
+			attest.Error(t, err)
+		}
+		attest.Equal(t, breaker.State(), connectauth.CircuitOpen)
+		attest.Equal(t, faults.Failures(), 2) // the open circuit stopped calling
+	})
+
+	t.Run("stores", func(t *testing.T) {
+		faults := NewFaultInjector()
+		keys := faults.WrapKeyStore(connectauth.NewMemoryKeyStore(&connectauth.APIKeyRecord{ID: "k1"}))
+		cache := faults.WrapCache(connectauth.NewShardedCache(10))
+		sessions := faults.WrapSessionStore(connectauthsession.NewMemoryStore(0))
+		session := &connectauthsession.Session{Identity: "alice", Expires: time.Now().Add(time.Hour)}
+
+		cache.Set(ctx, "k", &connectauth.CacheEntry{Info: "alice"})
+		attest.Ok(t, sessions.Set(ctx, "s", session, time.Hour))
+
+		faults.Fail(nil)
+		_, err := keys.LookupAPIKey(ctx, "k1")
+		attest.ErrorIs(t, err, ErrInjected)
+		_, ok := cache.Get(ctx, "k")
+		attest.False(t, ok)
+		cache.Delete(ctx, "k") // dropped
+		_, err = sessions.Get(ctx, "s")
+		attest.ErrorIs(t, err, ErrInjected)
+		attest.ErrorIs(t, sessions.(connectauthsession.IdentityRevoker).RevokeIdentity(ctx, "alice"), ErrInjected)
+
+		faults.Heal()
+		record, err := keys.LookupAPIKey(ctx, "k1")
+		attest.Ok(t, err)
+		attest.Equal(t, record.ID, "k1")
+		entry, ok := cache.Get(ctx, "k")
+		attest.True(t, ok)
+		attest.Equal[any](t, entry.Info, "alice")
+		got, err := sessions.Get(ctx, "s")
+		attest.Ok(t, err)
+		attest.Equal(t, got.Identity, "alice")
+	})
+
+	t.Run("session manager", func(t *testing.T) {
+		faults := NewFaultInjector()
+		manager := connectauthsession.New(connectauthsession.Config{
+			Store:  faults.WrapSessionStore(connectauthsession.NewMemoryStore(0)),
+			Header: "Session-Id",
+		})
+		_, id, err := manager.Create(ctx, "alice", nil)
+		attest.Ok(t, err)
+		req := &connectauth.Request{Header: http.Header{"Session-Id": []string{id}}}
+		faults.Fail(nil)
+		_, err = manager.Authenticate(ctx, req)
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		faults.Heal()
+		_, err = manager.Authenticate(ctx, req)
+		attest.Ok(t, err)
+	})
+}
@@ -0,0 +1,149 @@
+package connectauthtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// An IdentityProvider is a fake OpenID Connect provider for tests. It serves
+// a discovery document at /.well-known/openid-configuration and a JSON Web
+// Key Set at /jwks.json from an [httptest.Server], so JWT and OIDC verifiers
+// can point at it without network access.
+//
+// Keys can be rotated and retired while the server is running, which makes
+// verifiers' key refresh behavior testable. IdentityProviders are safe to use
+// concurrently.
+type IdentityProvider struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	signers []*Signer // the first signs new tokens
+	maxAge  time.Duration
+
+	jwksRequests      atomic.Int64
+	discoveryRequests atomic.Int64
+}
+
+// NewIdentityProvider starts an IdentityProvider with a single signing key,
+// and stops it when the test ends.
+func NewIdentityProvider(tb testing.TB) *IdentityProvider {
+	tb.Helper()
+	p := &IdentityProvider{signers: []*Signer{NewSigner("key-1")}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.serveDiscovery)
+	mux.HandleFunc("/jwks.json", p.serveJWKS)
+	p.server = httptest.NewServer(mux)
+	tb.Cleanup(p.server.Close)
+	return p
+}
+
+// Issuer returns the provider's issuer URL.
+func (p *IdentityProvider) Issuer() string {
+	return p.server.URL
+}
+
+// JWKSURL returns the URL of the provider's JSON Web Key Set.
+func (p *IdentityProvider) JWKSURL() string {
+	return p.server.URL + "/jwks.json"
+}
+
+// Client returns an HTTP client configured to call the provider.
+func (p *IdentityProvider) Client() *http.Client {
+	return p.server.Client()
+}
+
+// Signer returns the provider's current signing key.
+func (p *IdentityProvider) Signer() *Signer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.signers[0]
+}
+
+// Token starts building a token signed with the current key, with the
+// provider as its issuer.
+func (p *IdentityProvider) Token() *TokenBuilder {
+	return p.Signer().Token().Issuer(p.Issuer())
+}
+
+// Rotate publishes a new key with the supplied ID and makes it the current
+// signing key. Previous keys remain in the key set until they're retired, so
+// tokens they signed stay valid.
+func (p *IdentityProvider) Rotate(kid string) *Signer {
+	signer := NewSigner(kid)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signers = append([]*Signer{signer}, p.signers...)
+	return signer
+}
+
+// Retire removes a key from the key set. Verifiers that refresh the key set
+// afterward reject tokens it signed. Retiring the current key leaves the
+// next most recent key as current; the last key can't be retired.
+func (p *IdentityProvider) Retire(kid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, s := range p.signers {
+		if s.KeyID() == kid && len(p.signers) > 1 {
+			p.signers = append(p.signers[:i:i], p.signers[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetMaxAge sets the max-age of the key set's Cache-Control header. By
+// default, the key set isn't cacheable.
+func (p *IdentityProvider) SetMaxAge(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxAge = d
+}
+
+// JWKSRequests returns the number of times the key set has been fetched.
+func (p *IdentityProvider) JWKSRequests() int {
+	return int(p.jwksRequests.Load())
+}
+
+// DiscoveryRequests returns the number of times the discovery document has
+// been fetched.
+func (p *IdentityProvider) DiscoveryRequests() int {
+	return int(p.discoveryRequests.Load())
+}
+
+func (p *IdentityProvider) serveDiscovery(w http.ResponseWriter, _ *http.Request) {
+	p.discoveryRequests.Add(1)
+	writeJSON(w, map[string]any{
+		"issuer":                                p.Issuer(),
+		"jwks_uri":                              p.JWKSURL(),
+		"id_token_signing_alg_values_supported": []string{"EdDSA"},
+		"response_types_supported":              []string{"code", "id_token"},
+		"subject_types_supported":               []string{"public"},
+	})
+}
+
+func (p *IdentityProvider) serveJWKS(w http.ResponseWriter, _ *http.Request) {
+	p.jwksRequests.Add(1)
+	p.mu.Lock()
+	keys := make([]JWK, len(p.signers))
+	for i, s := range p.signers {
+		keys[i] = s.JWK()
+	}
+	maxAge := p.maxAge
+	p.mu.Unlock()
+	if maxAge > 0 {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge/time.Second)))
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	writeJSON(w, map[string]any{"keys": keys})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
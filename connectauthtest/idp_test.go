@@ -0,0 +1,63 @@
+package connectauthtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestIdentityProvider(t *testing.T) {
+	idp := NewIdentityProvider(t)
+	get := func(url string, v any) http.Header {
+		t.Helper()
+		res, err := idp.Client().Get(url)
+		attest.Ok(t, err)
+		defer res.Body.Close()
+		attest.Equal(t, res.StatusCode, http.StatusOK)
+		attest.Ok(t, json.NewDecoder(res.Body).Decode(v))
+		return res.Header
+	}
+	keyIDs := func() []string {
+		var jwks struct{ Keys []JWK }
+		get(idp.JWKSURL(), &jwks)
+		ids := make([]string, len(jwks.Keys))
+		for i, k := range jwks.Keys {
+			ids[i] = k.KeyID
+		}
+		return ids
+	}
+
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	get(idp.Issuer()+"/.well-known/openid-configuration", &discovery)
+	attest.Equal(t, discovery.Issuer, idp.Issuer())
+	attest.Equal(t, discovery.JWKSURI, idp.JWKSURL())
+	attest.Equal(t, idp.DiscoveryRequests(), 1)
+
+	attest.Equal(t, keyIDs(), []string{"key-1"})
+	token := idp.Token().Subject("alice").JWT()
+	var claims map[string]any
+	decodeJSON(t, strings.Split(token, ".")[1], &claims)
+	attest.Equal[any](t, claims["iss"], idp.Issuer())
+	attest.True(t, verifyJWT(idp.Signer().PublicKey(), token))
+
+	idp.Rotate("key-2")
+	attest.Equal(t, idp.Signer().KeyID(), "key-2")
+	attest.Equal(t, keyIDs(), []string{"key-2", "key-1"})
+	idp.Retire("key-1")
+	attest.Equal(t, keyIDs(), []string{"key-2"})
+	idp.Retire("key-2") // the last key can't be retired
+	attest.Equal(t, keyIDs(), []string{"key-2"})
+	attest.Equal(t, idp.JWKSRequests(), 4)
+
+	var jwks struct{ Keys []JWK }
+	attest.Equal(t, get(idp.JWKSURL(), &jwks).Get("Cache-Control"), "no-store")
+	idp.SetMaxAge(5 * time.Minute)
+	attest.Equal(t, get(idp.JWKSURL(), &jwks).Get("Cache-Control"), "public, max-age=300")
+}
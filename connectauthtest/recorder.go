@@ -0,0 +1,108 @@
+package connectauthtest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.akshayshah.org/connectauth"
+)
+
+// A Record is a single authentication observed by a [Recorder].
+type Record struct {
+	Procedure string
+	Protocol  string
+	Header    http.Header // a copy of the request headers
+	Info      any
+	Err       error
+}
+
+// Allowed reports whether the request was authenticated.
+func (r Record) Allowed() bool {
+	return r.Err == nil
+}
+
+// A Recorder wraps an AuthFunc and records every request it sees, so tests
+// can assert exactly which RPCs were authenticated and with what
+// credentials:
+//
+//	rec := connectauthtest.NewRecorder(connectauthtest.AllowAll("alice"))
+//	handler := connectauth.NewMiddleware(rec.Authenticate).Wrap(mux)
+//	// ...call the handler...
+//	if procs := rec.Procedures(); !slices.Equal(procs, want) {
+//		t.Errorf("authenticated %v, want %v", procs, want)
+//	}
+//
+// Requests that connectauth exempts from authentication never reach the
+// AuthFunc, so they aren't recorded. Recorders are safe to use concurrently.
+type Recorder struct {
+	auth connectauth.AuthFunc
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewRecorder constructs a Recorder.
+func NewRecorder(auth connectauth.AuthFunc) *Recorder {
+	return &Recorder{auth: auth}
+}
+
+// Authenticate is an AuthFunc that calls the wrapped AuthFunc and records the
+// request and result.
+func (r *Recorder) Authenticate(ctx context.Context, req *connectauth.Request) (any, error) {
+	info, err := r.auth(ctx, req)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, Record{
+		Procedure: req.Procedure,
+		Protocol:  req.Protocol,
+		Header:    req.Header.Clone(),
+		Info:      info,
+		Err:       err,
+	})
+	return info, err
+}
+
+// Records returns a copy of the recorded authentications, in the order they
+// completed.
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Record(nil), r.records...)
+}
+
+// Procedures returns the procedures of the recorded authentications, in the
+// order they completed.
+func (r *Recorder) Procedures() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	procs := make([]string, len(r.records))
+	for i, rec := range r.records {
+		procs[i] = rec.Procedure
+	}
+	return procs
+}
+
+// Last returns the most recent authentication, if any.
+func (r *Recorder) Last() (Record, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) == 0 {
+		return Record{}, false
+	}
+	return r.records[len(r.records)-1], true
+}
+
+// Len returns the number of recorded authentications.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records)
+}
+
+// Reset discards all recorded authentications.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = nil
+}
@@ -0,0 +1,61 @@
+package connectauthtest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(Static(map[string]any{"alice-token": "alice"}))
+	_, ok := rec.Last()
+	attest.False(t, ok)
+
+	mux := http.NewServeMux()
+	for _, procedure := range []string{"/test.v1/Get", "/test.v1/Health"} {
+		mux.Handle(procedure, connect.NewUnaryHandler(
+			procedure,
+			func(context.Context, *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			},
+		))
+	}
+	srv := memhttptest.New(t, connectauth.New(
+		rec.Authenticate,
+		connectauth.WithExemptProcedures("/test.v1/Health"),
+	).Middleware().Wrap(mux))
+	call := func(procedure, token string) {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](
+			srv.Client(),
+			srv.URL()+procedure,
+			connect.WithInterceptors(ClientInterceptor{}),
+		)
+		_, _ = client.CallUnary(WithToken(context.Background(), token), connect.NewRequest(&emptypb.Empty{}))
+	}
+	call("/test.v1/Get", "alice-token")
+	call("/test.v1/Health", "")
+	call("/test.v1/Get", "bob-token")
+
+	attest.Equal(t, rec.Len(), 2)
+	attest.Equal(t, rec.Procedures(), []string{"/test.v1/Get", "/test.v1/Get"})
+	records := rec.Records()
+	attest.True(t, records[0].Allowed())
+	attest.Equal(t, records[0].Info, "alice")
+	attest.Equal(t, records[0].Protocol, connect.ProtocolConnect)
+	attest.Equal(t, records[0].Header.Get("Authorization"), "Bearer alice-token")
+	last, ok := rec.Last()
+	attest.True(t, ok)
+	attest.False(t, last.Allowed())
+	attest.Equal(t, connect.CodeOf(last.Err), connect.CodeUnauthenticated)
+	attest.Equal(t, last.Header.Get("Authorization"), "Bearer bob-token")
+
+	rec.Reset()
+	attest.Equal(t, rec.Len(), 0)
+	attest.Equal(t, len(records), 2) // copies are unaffected
+}
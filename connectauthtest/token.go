@@ -0,0 +1,234 @@
+package connectauthtest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// A Signer mints JWTs and PASETOs for tests. Its Ed25519 key pair is derived
+// from its key ID, so tokens from Signers with the same key ID verify with the
+// same public key in every test run, and different key IDs simulate key
+// rotation. The keys are public knowledge: never trust them outside tests.
+//
+// JWTs use the EdDSA algorithm and PASETOs use v4.public, which most
+// verification libraries support.
+type Signer struct {
+	kid string
+	key ed25519.PrivateKey
+}
+
+// NewSigner constructs a Signer. If the key ID is empty, it's
+// "connectauthtest".
+func NewSigner(kid string) *Signer {
+	if kid == "" {
+		kid = "connectauthtest"
+	}
+	seed := sha256.Sum256([]byte("connectauthtest signer " + kid))
+	return &Signer{kid: kid, key: ed25519.NewKeyFromSeed(seed[:])}
+}
+
+// KeyID returns the Signer's key ID, which is set as the "kid" header of
+// JWTs.
+func (s *Signer) KeyID() string {
+	return s.kid
+}
+
+// PublicKey returns the Signer's public key.
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// JWK returns the Signer's public key as a JSON Web Key.
+func (s *Signer) JWK() JWK {
+	return JWK{
+		KeyType:   "OKP",
+		Curve:     "Ed25519",
+		X:         base64.RawURLEncoding.EncodeToString(s.PublicKey()),
+		KeyID:     s.kid,
+		Algorithm: "EdDSA",
+		Use:       "sig",
+	}
+}
+
+// Token starts building a token signed by s. By default, the token was issued
+// now and expires in an hour.
+func (s *Signer) Token() *TokenBuilder {
+	return &TokenBuilder{signer: s, expiresIn: time.Hour, claims: make(map[string]any)}
+}
+
+// A JWK is a JSON Web Key, as described in RFC 7517 and RFC 8037.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// A TokenBuilder builds the claims of a token, which it then signs as a JWT
+// or PASETO. Its methods return the builder, so calls can be chained:
+//
+//	token := signer.Token().Subject("alice").Scopes("read").ExpiresIn(time.Minute).JWT()
+//
+// Times are relative to the time [TokenBuilder.JWT] or [TokenBuilder.PASETO]
+// is called, unless [TokenBuilder.At] sets a fixed issue time.
+type TokenBuilder struct {
+	signer    *Signer
+	at        time.Time
+	expiresIn time.Duration
+	notBefore time.Duration
+	claims    map[string]any
+}
+
+// Issuer sets the "iss" claim.
+func (b *TokenBuilder) Issuer(iss string) *TokenBuilder {
+	return b.Claim("iss", iss)
+}
+
+// Subject sets the "sub" claim.
+func (b *TokenBuilder) Subject(sub string) *TokenBuilder {
+	return b.Claim("sub", sub)
+}
+
+// Audience sets the "aud" claim: a string for a single audience, and an
+// array otherwise.
+func (b *TokenBuilder) Audience(aud ...string) *TokenBuilder {
+	if len(aud) == 1 {
+		return b.Claim("aud", aud[0])
+	}
+	return b.Claim("aud", aud)
+}
+
+// Scopes sets the "scope" claim to a space-separated list, as described in
+// RFC 8693.
+func (b *TokenBuilder) Scopes(scopes ...string) *TokenBuilder {
+	return b.Claim("scope", strings.Join(scopes, " "))
+}
+
+// ID sets the "jti" claim.
+func (b *TokenBuilder) ID(jti string) *TokenBuilder {
+	return b.Claim("jti", jti)
+}
+
+// Claim sets an arbitrary claim. Setting "exp", "nbf", or "iat" overrides the
+// computed times.
+func (b *TokenBuilder) Claim(name string, value any) *TokenBuilder {
+	b.claims[name] = value
+	return b
+}
+
+// At fixes the token's issue time.
+func (b *TokenBuilder) At(t time.Time) *TokenBuilder {
+	b.at = t
+	return b
+}
+
+// ExpiresIn sets the token's expiry relative to its issue time. Negative
+// durations produce expired tokens.
+func (b *TokenBuilder) ExpiresIn(d time.Duration) *TokenBuilder {
+	b.expiresIn = d
+	return b
+}
+
+// Expired makes the token expire a minute before its issue time.
+func (b *TokenBuilder) Expired() *TokenBuilder {
+	return b.ExpiresIn(-time.Minute)
+}
+
+// NotBefore sets the "nbf" claim relative to the token's issue time. Positive
+// durations produce tokens that aren't valid yet.
+func (b *TokenBuilder) NotBefore(d time.Duration) *TokenBuilder {
+	b.notBefore = d
+	return b
+}
+
+// JWT signs the claims as a compact JWS with the EdDSA algorithm.
+func (b *TokenBuilder) JWT() string {
+	now := b.issued()
+	claims := b.build(func(d time.Duration) any { return now.Add(d).Unix() })
+	header := mustJSON(map[string]string{"alg": "EdDSA", "kid": b.signer.kid, "typ": "JWT"})
+	input := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(mustJSON(claims))
+	sig := ed25519.Sign(b.signer.key, []byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// PASETO signs the claims as a v4.public PASETO, with the key ID in the
+// footer.
+func (b *TokenBuilder) PASETO() string {
+	const header = "v4.public."
+	now := b.issued()
+	claims := b.build(func(d time.Duration) any { return now.Add(d).UTC().Format(time.RFC3339) })
+	msg := mustJSON(claims)
+	footer := mustJSON(map[string]string{"kid": b.signer.kid})
+	sig := ed25519.Sign(b.signer.key, pae([]byte(header), msg, footer, nil))
+	return header + base64.RawURLEncoding.EncodeToString(append(msg, sig...)) +
+		"." + base64.RawURLEncoding.EncodeToString(footer)
+}
+
+func (b *TokenBuilder) issued() time.Time {
+	if b.at.IsZero() {
+		return time.Now()
+	}
+	return b.at
+}
+
+// build returns the claims, adding times formatted by the supplied function.
+func (b *TokenBuilder) build(format func(time.Duration) any) map[string]any {
+	claims := make(map[string]any, len(b.claims)+3)
+	claims["iat"] = format(0)
+	claims["exp"] = format(b.expiresIn)
+	if b.notBefore != 0 {
+		claims["nbf"] = format(b.notBefore)
+	}
+	for k, v := range b.claims {
+		claims[k] = v
+	}
+	return claims
+}
+
+// Corrupt returns a copy of the token with a corrupted signature, for testing
+// that verifiers reject tampered tokens. It works with JWTs and PASETOs.
+func Corrupt(token string) string {
+	// The signature ends the JWT, and ends the PASETO payload before the
+	// footer.
+	end := len(token)
+	if strings.HasPrefix(token, "v4.") && strings.Count(token, ".") == 3 {
+		end = strings.LastIndexByte(token, '.')
+	}
+	b := []byte(token)
+	// Flip a character well inside the last base64url block, so the change
+	// survives decoding.
+	i := end - 3
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return string(b)
+}
+
+// pae is PASETO's pre-authentication encoding.
+func pae(pieces ...[]byte) []byte {
+	var out []byte
+	out = binary.LittleEndian.AppendUint64(out, uint64(len(pieces)))
+	for _, p := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(p)))
+		out = append(out, p...)
+	}
+	return out
+}
+
+func mustJSON(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic("connectauthtest: " + err.Error())
+	}
+	return b
+}
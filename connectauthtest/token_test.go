@@ -0,0 +1,108 @@
+package connectauthtest
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestSignerDeterministic(t *testing.T) {
+	attest.Equal(t, NewSigner("").KeyID(), "connectauthtest")
+	attest.True(t, NewSigner("k1").PublicKey().Equal(NewSigner("k1").PublicKey()))
+	attest.False(t, NewSigner("k1").PublicKey().Equal(NewSigner("k2").PublicKey()))
+	jwk := NewSigner("k1").JWK()
+	attest.Equal(t, jwk.KeyID, "k1")
+	attest.Equal(t, jwk.Algorithm, "EdDSA")
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	attest.Ok(t, err)
+	attest.True(t, NewSigner("k1").PublicKey().Equal(ed25519.PublicKey(x)))
+}
+
+func TestJWT(t *testing.T) {
+	signer := NewSigner("k1")
+	at := time.Unix(1_700_000_000, 0)
+	token := signer.Token().
+		At(at).
+		Issuer("https://issuer.example").
+		Subject("alice").
+		Audience("api").
+		Scopes("read", "write").
+		NotBefore(time.Second).
+		ExpiresIn(time.Minute).
+		JWT()
+	parts := strings.Split(token, ".")
+	attest.Equal(t, len(parts), 3)
+	var header map[string]string
+	decodeJSON(t, parts[0], &header)
+	attest.Equal(t, header, map[string]string{"alg": "EdDSA", "kid": "k1", "typ": "JWT"})
+	var claims map[string]any
+	decodeJSON(t, parts[1], &claims)
+	attest.Equal(t, claims, map[string]any{
+		"iss":   "https://issuer.example",
+		"sub":   "alice",
+		"aud":   "api",
+		"scope": "read write",
+		"iat":   float64(at.Unix()),
+		"nbf":   float64(at.Unix() + 1),
+		"exp":   float64(at.Unix() + 60),
+	})
+	attest.True(t, verifyJWT(signer.PublicKey(), token))
+	attest.False(t, verifyJWT(NewSigner("k2").PublicKey(), token))
+	attest.False(t, verifyJWT(signer.PublicKey(), Corrupt(token)))
+
+	// Tokens are deterministic.
+	attest.Equal(t, signer.Token().At(at).Subject("alice").JWT(), signer.Token().At(at).Subject("alice").JWT())
+
+	expired := signer.Token().At(at).Expired().Audience("a", "b").JWT()
+	decodeJSON(t, strings.Split(expired, ".")[1], &claims)
+	attest.Equal[any](t, claims["exp"], float64(at.Unix()-60))
+	attest.Equal[any](t, claims["aud"], []any{"a", "b"})
+}
+
+func TestPASETO(t *testing.T) {
+	signer := NewSigner("k1")
+	at := time.Unix(1_700_000_000, 0)
+	token := signer.Token().At(at).Subject("alice").PASETO()
+	attest.True(t, strings.HasPrefix(token, "v4.public."))
+	parts := strings.Split(token, ".")
+	attest.Equal(t, len(parts), 4)
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	attest.Ok(t, err)
+	footer, err := base64.RawURLEncoding.DecodeString(parts[3])
+	attest.Ok(t, err)
+	attest.Equal(t, string(footer), `{"kid":"k1"}`)
+	msg, sig := payload[:len(payload)-ed25519.SignatureSize], payload[len(payload)-ed25519.SignatureSize:]
+	var claims map[string]any
+	attest.Ok(t, json.Unmarshal(msg, &claims))
+	attest.Equal[any](t, claims["sub"], "alice")
+	attest.Equal[any](t, claims["exp"], "2023-11-14T23:13:20Z")
+	attest.True(t, ed25519.Verify(signer.PublicKey(), pae([]byte("v4.public."), msg, footer, nil), sig))
+
+	corrupted := strings.Split(Corrupt(token), ".")
+	attest.Equal(t, corrupted[3], parts[3]) // the footer is intact
+	payload, err = base64.RawURLEncoding.DecodeString(corrupted[2])
+	attest.Ok(t, err)
+	msg, sig = payload[:len(payload)-ed25519.SignatureSize], payload[len(payload)-ed25519.SignatureSize:]
+	attest.False(t, ed25519.Verify(signer.PublicKey(), pae([]byte("v4.public."), msg, footer, nil), sig))
+}
+
+func verifyJWT(key ed25519.PublicKey, token string) bool {
+	i := strings.LastIndexByte(token, '.')
+	sig, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key, []byte(token[:i]), sig)
+}
+
+func decodeJSON(tb testing.TB, segment string, v any) {
+	tb.Helper()
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	attest.Ok(tb, err)
+	attest.Ok(tb, json.Unmarshal(raw, v))
+}
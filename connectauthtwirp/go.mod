@@ -0,0 +1,19 @@
+module go.akshayshah.org/connectauth/connectauthtwirp
+
+go 1.21
+
+require (
+	connectrpc.com/connect v1.11.1
+	github.com/twitchtv/twirp v8.1.3+incompatible
+	go.akshayshah.org/attest v1.0.2
+	go.akshayshah.org/connectauth v0.4.0
+)
+
+require (
+	github.com/google/go-cmp v0.6.0 // indirect
+	golang.org/x/sync v0.3.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+)
+
+replace go.akshayshah.org/connectauth => ../
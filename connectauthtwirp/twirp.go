@@ -0,0 +1,101 @@
+// Package connectauthtwirp adapts [connectauth] authentication to Twirp
+// servers, so teams migrating from Twirp to Connect can apply identical
+// authentication to both during the transition:
+//
+//	auth := connectauth.New(authenticate)
+//	mux.Handle(foov1connect.NewFooServiceHandler(foo, auth.HandlerOption()))
+//	twirpServer := foov1.NewFooServiceServer(foo)
+//	mux.Handle(twirpServer.PathPrefix(), connectauthtwirp.Wrap(auth, twirpServer))
+//
+// AuthFuncs see Twirp requests with the [Protocol] "twirp", and with the
+// same procedure names as the equivalent Connect requests.
+package connectauthtwirp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/twitchtv/twirp"
+	"go.akshayshah.org/connectauth"
+)
+
+// Protocol identifies Twirp requests in [connectauth.Request]. Applications
+// restricting protocols with [connectauth.WithProtocols] must allow it.
+const Protocol = "twirp"
+
+// Wrap returns an HTTP handler that authenticates Twirp requests before
+// passing them to the Twirp server. If authentication succeeds, the
+// authentication information is attached to the request context, where
+// Twirp methods may access it with [connectauth.GetInfo]. Failures are
+// written as Twirp errors with the equivalent error code, and any metadata
+// attached to a [connect.Error] (for example, a WWW-Authenticate challenge)
+// is copied to the response headers.
+//
+// Twirp only routes POST requests with paths ending in
+// "<package>.<Service>/<Method>". Wrap rejects other requests with a
+// bad_route error, without calling the AuthFunc.
+func Wrap(auth *connectauth.Authenticator, server http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		procedure, ok := procedureFromPath(r)
+		if !ok {
+			writeError(w, twirp.NewError(twirp.BadRoute, "no such Twirp route").
+				WithMeta("twirp_invalid_route", r.Method+" "+r.URL.Path))
+			return
+		}
+		ctx, err := auth.AuthenticateRequest(r.Context(), &connectauth.Request{
+			Procedure:      procedure,
+			ClientAddr:     r.RemoteAddr,
+			Host:           r.Host,
+			Protocol:       Protocol,
+			Header:         r.Header,
+			TLS:            r.TLS,
+			ResponseHeader: w.Header(),
+		})
+		if err != nil {
+			writeError(w, toTwirpError(w.Header(), err))
+			return
+		}
+		server.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// procedureFromPath extracts the procedure from a Twirp request's path,
+// ignoring the server's path prefix.
+func procedureFromPath(r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		return "", false
+	}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 3 {
+		return "", false
+	}
+	service, method := parts[len(parts)-2], parts[len(parts)-1]
+	if service == "" || method == "" {
+		return "", false
+	}
+	return "/" + service + "/" + method, true
+}
+
+// toTwirpError converts an authentication error to a Twirp error, copying
+// any error metadata to the response headers.
+func toTwirpError(header http.Header, err error) twirp.Error {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return twirp.InternalErrorWith(err)
+	}
+	for key, vals := range connectErr.Meta() {
+		header[key] = append(header[key], vals...)
+	}
+	code := twirp.ErrorCode(connectErr.Code().String())
+	if !twirp.IsValidErrorCode(code) {
+		code = twirp.Internal
+	}
+	return twirp.NewError(code, connectErr.Message())
+}
+
+func writeError(w http.ResponseWriter, err twirp.Error) {
+	// The only possible error is a failed write to the client.
+	_ = twirp.WriteError(w, err)
+}
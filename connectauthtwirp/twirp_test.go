@@ -0,0 +1,89 @@
+package connectauthtwirp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/twitchtv/twirp"
+	"github.com/twitchtv/twirp/example"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/connectauth"
+)
+
+type haberdasher struct{}
+
+func (haberdasher) MakeHat(ctx context.Context, size *example.Size) (*example.Hat, error) {
+	name, _ := connectauth.GetInfo(ctx).(string)
+	return &example.Hat{Size: size.GetInches(), Name: name}, nil
+}
+
+func TestWrap(t *testing.T) {
+	var requests []*connectauth.Request
+	auth := connectauth.New(func(_ context.Context, req *connectauth.Request) (any, error) {
+		requests = append(requests, req)
+		if token, _ := connectauth.BearerToken(req.Header); token != "alice-token" {
+			err := connectauth.Errorf("invalid token")
+			err.Meta().Set("WWW-Authenticate", "Bearer")
+			return nil, err
+		}
+		req.ResponseHeader.Set("X-Session", "renewed")
+		return "alice", nil
+	})
+	server := example.NewHaberdasherServer(haberdasher{})
+	mux := http.NewServeMux()
+	mux.Handle(server.PathPrefix(), Wrap(auth, server))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	client := example.NewHaberdasherProtobufClient(srv.URL, srv.Client())
+
+	t.Run("success", func(t *testing.T) {
+		requests = nil
+		header := http.Header{"Authorization": []string{"Bearer alice-token"}}
+		ctx, err := twirp.WithHTTPRequestHeaders(context.Background(), header)
+		attest.Ok(t, err)
+		hat, err := client.MakeHat(ctx, &example.Size{Inches: 7})
+		attest.Ok(t, err)
+		attest.Equal(t, hat.GetName(), "alice")
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/twitch.twirp.example.Haberdasher/MakeHat")
+		attest.Equal(t, requests[0].Protocol, Protocol)
+		attest.NotZero(t, requests[0].ClientAddr)
+		attest.NotZero(t, requests[0].Host)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := client.MakeHat(context.Background(), &example.Size{Inches: 7})
+		var twerr twirp.Error
+		attest.True(t, errors.As(err, &twerr))
+		attest.Equal(t, twerr.Code(), twirp.Unauthenticated)
+		attest.Equal(t, twerr.Msg(), "invalid token")
+
+		res, err := srv.Client().Post(srv.URL+"/twirp/twitch.twirp.example.Haberdasher/MakeHat", "application/json", strings.NewReader("{}"))
+		attest.Ok(t, err)
+		res.Body.Close()
+		attest.Equal(t, res.StatusCode, http.StatusUnauthorized)
+		attest.Equal(t, res.Header.Get("WWW-Authenticate"), "Bearer")
+	})
+
+	t.Run("bad route", func(t *testing.T) {
+		requests = nil
+		res, err := srv.Client().Get(srv.URL + "/twirp/twitch.twirp.example.Haberdasher/MakeHat")
+		attest.Ok(t, err)
+		res.Body.Close()
+		attest.Equal(t, res.StatusCode, http.StatusNotFound)
+		attest.Zero(t, requests)
+	})
+
+	t.Run("error codes", func(t *testing.T) {
+		header := make(http.Header)
+		err := toTwirpError(header, connect.NewError(connect.CodeResourceExhausted, nil))
+		attest.Equal(t, err.Code(), twirp.ResourceExhausted)
+		err = toTwirpError(header, context.Canceled)
+		attest.Equal(t, err.Code(), twirp.Internal)
+	})
+}
@@ -0,0 +1,67 @@
+package connectauth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// BearerToken extracts an OAuth 2 bearer token (RFC 6750) from the
+// Authorization header. The scheme is matched case-insensitively. It doesn't
+// allocate.
+func BearerToken(h http.Header) (string, bool) {
+	scheme, credentials := parseAuthorization(h)
+	if !strings.EqualFold(scheme, "Bearer") || credentials == "" {
+		return "", false
+	}
+	return credentials, true
+}
+
+// BasicAuth extracts HTTP Basic credentials (RFC 7617) from the
+// Authorization header. The scheme is matched case-insensitively. Unlike
+// [http.Request.BasicAuth], it allocates only once, to hold the decoded
+// credentials.
+func BasicAuth(h http.Header) (username, password string, ok bool) {
+	scheme, credentials := parseAuthorization(h)
+	if !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	var buf [128]byte
+	dst := buf[:]
+	if n := base64.StdEncoding.DecodedLen(len(credentials)); n > len(buf) {
+		dst = make([]byte, n)
+	}
+	n, err := base64.StdEncoding.Decode(dst, []byte(credentials))
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok = strings.Cut(string(dst[:n]), ":")
+	if !ok {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// APIKey extracts an API key from the named header, ignoring surrounding
+// whitespace. It doesn't allocate. Names in canonical form (see
+// [http.CanonicalHeaderKey]), like "X-Api-Key", are looked up directly;
+// others are canonicalized only if the direct lookup fails.
+func APIKey(h http.Header, name string) (string, bool) {
+	vals, ok := h[name]
+	if !ok {
+		vals = h[http.CanonicalHeaderKey(name)]
+	}
+	var key string
+	if len(vals) > 0 {
+		key = strings.TrimSpace(vals[0])
+	}
+	return key, key != ""
+}
+
+// parseAuthorization splits the Authorization header into a scheme and
+// credentials.
+func parseAuthorization(h http.Header) (scheme, credentials string) {
+	auth := headerValue(h, "Authorization")
+	scheme, credentials, _ = strings.Cut(auth, " ")
+	return scheme, strings.TrimLeft(credentials, " ")
+}
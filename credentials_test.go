@@ -0,0 +1,98 @@
+package connectauth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer   abc", "abc", true},
+		{"Basic abc", "", false},
+		{"Bearer", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		token, ok := BearerToken(http.Header{"Authorization": []string{tt.header}})
+		attest.Equal(t, token, tt.token, attest.Sprintf("header %q", tt.header))
+		attest.Equal(t, ok, tt.ok, attest.Sprintf("header %q", tt.header))
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		header   string
+		user     string
+		password string
+		ok       bool
+	}{
+		{"Basic " + encode("ali:open:sesame"), "ali", "open:sesame", true},
+		{"basic " + encode("ali:"), "ali", "", true},
+		{"Basic " + encode("ali"), "", "", false},
+		{"Basic not-base64!", "", "", false},
+		{"Bearer " + encode("ali:sesame"), "", "", false},
+	}
+	for _, tt := range tests {
+		user, password, ok := BasicAuth(http.Header{"Authorization": []string{tt.header}})
+		attest.Equal(t, user, tt.user, attest.Sprintf("header %q", tt.header))
+		attest.Equal(t, password, tt.password, attest.Sprintf("header %q", tt.header))
+		attest.Equal(t, ok, tt.ok, attest.Sprintf("header %q", tt.header))
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	h := http.Header{"X-Api-Key": []string{" abc123 "}}
+	key, ok := APIKey(h, "x-api-key")
+	attest.True(t, ok)
+	attest.Equal(t, key, "abc123")
+	_, ok = APIKey(h, "X-Other")
+	attest.False(t, ok)
+}
+
+func TestCredentialAllocations(t *testing.T) {
+	h := http.Header{
+		"Authorization": []string{"Bearer " + passphrase},
+		"X-Api-Key":     []string{"abc123"},
+	}
+	attest.Equal(t, testing.AllocsPerRun(100, func() { BearerToken(h) }), 0.0)
+	attest.Equal(t, testing.AllocsPerRun(100, func() { APIKey(h, "X-Api-Key") }), 0.0)
+	basic := http.Header{"Authorization": []string{
+		"Basic " + base64.StdEncoding.EncodeToString([]byte("ali-baba:open-sesame-0123456789")),
+	}}
+	attest.Equal(t, testing.AllocsPerRun(100, func() { BasicAuth(basic) }), 1.0)
+}
+
+func BenchmarkBearerToken(b *testing.B) {
+	h := http.Header{"Authorization": []string{"Bearer " + passphrase}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BearerToken(h)
+	}
+}
+
+func BenchmarkBasicAuth(b *testing.B) {
+	h := http.Header{"Authorization": []string{
+		"Basic " + base64.StdEncoding.EncodeToString([]byte("ali-baba:open-sesame")),
+	}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BasicAuth(h)
+	}
+}
+
+func BenchmarkAPIKey(b *testing.B) {
+	h := http.Header{"X-Api-Key": []string{"abc123"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		APIKey(h, "X-Api-Key")
+	}
+}
@@ -0,0 +1,78 @@
+package connectauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// FlagCookie is added to [Request].Flags by AuthFuncs that authenticated the
+// request with a cookie, like connectauthsession's. [WithCSRF] only protects
+// requests with this flag, so AuthFuncs that read cookies should add it.
+const FlagCookie = "cookie"
+
+// CSRFConfig configures [WithCSRF].
+type CSRFConfig struct {
+	// Header is the request header carrying the CSRF token. The default is
+	// "X-CSRF-Token".
+	Header string
+	// Cookie, if set, enables the double-submit pattern: the header must
+	// match the value of this cookie. If it's empty, any non-empty header
+	// value is accepted, which relies on browsers refusing to send custom
+	// headers cross-origin without a successful CORS preflight.
+	Cookie string
+}
+
+// WithCSRF protects cookie-authenticated requests from cross-site request
+// forgery. Requests that the AuthFunc flagged with [FlagCookie] must carry a
+// CSRF token header, and are otherwise rejected with
+// [connect.CodePermissionDenied] and [ReasonPolicy]. Clients authenticating
+// with headers, like most gRPC and Connect clients, never carry the flag and
+// are unaffected.
+//
+// With double-submit protection, the server sets a random token (see
+// [NewCSRFToken]) in a cookie that the web application can read, and the
+// application copies it into the header of each request. The check runs after
+// the AuthFunc, for both RPCs and non-RPC requests (see
+// [WithAuthenticateAll]). It complements [WithAllowedOrigins].
+func WithCSRF(cc CSRFConfig) Option {
+	if cc.Header == "" {
+		cc.Header = "X-CSRF-Token"
+	}
+	cc.Header = http.CanonicalHeaderKey(cc.Header)
+	return optionFunc(func(c *config) {
+		c.CSRF = &cc
+	})
+}
+
+// NewCSRFToken returns a random token for double-submit CSRF protection:
+// 256 bits, encoded as unpadded base64url.
+func NewCSRFToken() (string, error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw[:]), nil
+}
+
+func (cc *CSRFConfig) check(req *Request) error {
+	if !req.HasFlag(FlagCookie) {
+		return nil
+	}
+	token := headerValue(req.Header, cc.Header)
+	if token == "" {
+		return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("missing CSRF token"))
+	}
+	if cc.Cookie == "" {
+		return nil
+	}
+	cookie, err := (&http.Request{Header: req.Header}).Cookie(cc.Cookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+		return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("invalid CSRF token"))
+	}
+	return nil
+}
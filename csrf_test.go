@@ -0,0 +1,59 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestCSRF(t *testing.T) {
+	byCookie := func(_ context.Context, req *Request) (any, error) {
+		if headerValue(req.Header, "Cookie") != "" {
+			req.Flags = append(req.Flags, FlagCookie)
+		}
+		return "alice", nil
+	}
+	call := func(auth *Authenticator, header http.Header) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			Header:    header,
+		})
+		return err
+	}
+
+	t.Run("custom_header", func(t *testing.T) {
+		auth := New(byCookie, WithCSRF(CSRFConfig{Header: "x-requested-with"}))
+		attest.Ok(t, call(auth, http.Header{"Authorization": []string{"Bearer token"}}))
+		err := call(auth, http.Header{"Cookie": []string{"session=abc"}})
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		attest.Equal(t, ReasonOf(err), ReasonPolicy)
+		attest.Ok(t, call(auth, http.Header{
+			"Cookie":           []string{"session=abc"},
+			"X-Requested-With": []string{"XMLHttpRequest"},
+		}))
+	})
+	t.Run("double_submit", func(t *testing.T) {
+		token, err := NewCSRFToken()
+		attest.Ok(t, err)
+		attest.Equal(t, len(token), 43)
+		auth := New(byCookie, WithCSRF(CSRFConfig{Cookie: "csrf"}))
+		attest.Ok(t, call(auth, http.Header{
+			"Cookie":       []string{"session=abc; csrf=" + token},
+			"X-Csrf-Token": []string{token},
+		}))
+		err = call(auth, http.Header{
+			"Cookie":       []string{"session=abc; csrf=" + token},
+			"X-Csrf-Token": []string{"forged"},
+		})
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		err = call(auth, http.Header{
+			"Cookie":       []string{"session=abc"},
+			"X-Csrf-Token": []string{token},
+		})
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	})
+}
@@ -0,0 +1,145 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	connectauthv1 "go.akshayshah.org/connectauth/internal/gen/connectauth/v1"
+)
+
+// DelegatorConfig configures a [Delegator].
+type DelegatorConfig struct {
+	// TTL is how long minted tokens are valid. Tokens are bearer
+	// credentials, so it should be no longer than the work they're minted
+	// for. Tokens never outlive the delegated identity's Expiry. The default
+	// is 15 minutes.
+	TTL time.Duration
+	// Audience, if set, is the name of the service or job runner verifying
+	// tokens. Tokens minted for other audiences are rejected.
+	Audience string
+	// Credential extracts the token from a request. By default, it's the
+	// bearer token (see [BearerToken]).
+	Credential func(*Request) string
+	// Identity converts authentication information to the Identity being
+	// delegated. It returns nil if the information can't be delegated. The
+	// default is [IdentityOf].
+	Identity func(info any) *Identity
+	// Clock is used to stamp and check expiry. The default is [SystemClock].
+	Clock Clock
+}
+
+// A Delegator mints delegation tokens: short-lived, audience-scoped
+// credentials that let background jobs and downstream services act as the
+// caller after the request that authenticated it has finished. A handler
+// mints a token for the work it schedules:
+//
+//	token, err := delegator.Mint(ctx, "report-worker", "reports:write")
+//	if err != nil {
+//		return err
+//	}
+//	queue.Enqueue(job{Report: id, Token: token})
+//
+// and the worker verifies it before acting:
+//
+//	identity, err := delegator.Verify(job.Token)
+//
+// Services receiving delegation tokens in requests may use Authenticate as
+// an [AuthFunc].
+//
+// Tokens are connectauth.v1.IdentityToken protobuf messages signed with the
+// [Keyring], like propagated identities (see [IdentityPropagator]), but the
+// two aren't interchangeable. Like propagated identities, delegation tokens
+// expire no later than the identity they carry, but they may outlive a
+// credential that's revoked early, so it's wise to narrow their scopes.
+type Delegator struct {
+	keyring    *Keyring
+	ttl        time.Duration
+	audience   string
+	credential func(*Request) string
+	identity   func(any) *Identity
+	now        func() time.Time
+}
+
+// NewDelegator constructs a Delegator. It panics if the keyring is nil.
+func NewDelegator(keyring *Keyring, config DelegatorConfig) *Delegator {
+	if keyring == nil {
+		panic("connectauth: delegation requires a Keyring")
+	}
+	if config.TTL <= 0 {
+		config.TTL = 15 * time.Minute
+	}
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			token, _ := BearerToken(req.Header)
+			return token
+		}
+	}
+	if config.Identity == nil {
+		config.Identity = IdentityOf
+	}
+	return &Delegator{
+		keyring:    keyring,
+		ttl:        config.TTL,
+		audience:   config.Audience,
+		credential: config.Credential,
+		identity:   config.Identity,
+		now:        nowFunc(config.Clock),
+	}
+}
+
+// Mint returns a delegation token for the identity attached to the context
+// (see [GetInfo]), addressed to the audience. If scopes are supplied, the
+// token carries only those scopes, each of which the identity must have;
+// otherwise, it carries all the identity's scopes.
+func (d *Delegator) Mint(ctx context.Context, audience string, scopes ...string) (string, error) {
+	identity := d.identity(GetInfo(ctx))
+	if identity == nil {
+		return "", errors.New("no identity to delegate")
+	}
+	delegated := *identity
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			if !identity.HasScope(scope) {
+				return "", fmt.Errorf("can't delegate scope %q: %q doesn't have it", scope, identity.Subject)
+			}
+		}
+		delegated.Scopes = scopes
+	}
+	expires := d.now().Add(d.ttl)
+	if !identity.Expiry.IsZero() && identity.Expiry.Before(expires) {
+		expires = identity.Expiry
+	}
+	return signIdentityToken(
+		d.keyring,
+		&delegated,
+		audience,
+		connectauthv1.TokenUse_TOKEN_USE_DELEGATION,
+		expires,
+	)
+}
+
+// Verify verifies a delegation token and returns the delegated identity.
+// Errors carry a [Reason].
+func (d *Delegator) Verify(token string) (*Identity, error) {
+	if token == "" {
+		return nil, ReasonErrorf(ReasonMissingCredentials, "missing delegation token")
+	}
+	return verifyIdentityToken(d.keyring, token, tokenCheck{
+		noun:     "delegation token",
+		use:      connectauthv1.TokenUse_TOKEN_USE_DELEGATION,
+		audience: d.audience,
+		now:      d.now(),
+	})
+}
+
+// Authenticate is an [AuthFunc] that verifies delegation tokens. It returns
+// an *Identity.
+func (d *Delegator) Authenticate(_ context.Context, req *Request) (any, error) {
+	identity, err := d.Verify(d.credential(req))
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
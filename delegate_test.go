@@ -0,0 +1,90 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestDelegator(t *testing.T) {
+	keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("d"), 32)})
+	attest.Ok(t, err)
+	clock := newTestClock()
+	minter := NewDelegator(keyring, DelegatorConfig{Clock: clock})
+	worker := NewDelegator(keyring, DelegatorConfig{Audience: "report-worker", Clock: clock})
+	ctx := SetInfo(context.Background(), &Identity{Subject: hero, Scopes: []string{"reports:read", "reports:write"}})
+
+	t.Run("mint and verify", func(t *testing.T) {
+		token, err := minter.Mint(ctx, "report-worker", "reports:write")
+		attest.Ok(t, err)
+		identity, err := worker.Verify(token)
+		attest.Ok(t, err)
+		attest.Equal(t, identity, &Identity{Subject: hero, Scopes: []string{"reports:write"}})
+
+		info, err := worker.Authenticate(context.Background(), &Request{
+			Header: http.Header{"Authorization": []string{"Bearer " + token}},
+		})
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(identity))
+
+		token, err = minter.Mint(ctx, "report-worker")
+		attest.Ok(t, err)
+		identity, err = worker.Verify(token)
+		attest.Ok(t, err)
+		attest.Equal(t, identity.Scopes, []string{"reports:read", "reports:write"})
+	})
+
+	t.Run("mint errors", func(t *testing.T) {
+		_, err := minter.Mint(context.Background(), "report-worker")
+		attest.Error(t, err)
+		_, err = minter.Mint(ctx, "report-worker", "admin")
+		attest.Error(t, err)
+	})
+
+	t.Run("identity expiry", func(t *testing.T) {
+		// Tokens don't outlive the identity they delegate.
+		expiring := SetInfo(context.Background(), &Identity{Subject: hero, Expiry: clock.Now().Add(time.Minute)})
+		token, err := minter.Mint(expiring, "report-worker")
+		attest.Ok(t, err)
+		_, err = worker.Verify(token)
+		attest.Ok(t, err)
+		clock.Advance(2 * time.Minute)
+		_, err = worker.Verify(token)
+		attest.Equal(t, ReasonOf(err), ReasonExpired)
+	})
+
+	t.Run("verify errors", func(t *testing.T) {
+		_, err := worker.Verify("")
+		attest.Equal(t, ReasonOf(err), ReasonMissingCredentials)
+		_, err = worker.Authenticate(context.Background(), &Request{Header: http.Header{}})
+		attest.Equal(t, ReasonOf(err), ReasonMissingCredentials)
+
+		token, err := minter.Mint(ctx, "billing")
+		attest.Ok(t, err)
+		_, err = worker.Verify(token)
+		attest.Equal(t, ReasonOf(err), ReasonWrongAudience)
+
+		// Propagated identities aren't delegation tokens, and vice versa.
+		propagator := NewIdentityPropagator(keyring, IdentityPropagatorConfig{Clock: clock})
+		propagated, err := propagator.Sign(&Identity{Subject: hero}, "report-worker")
+		attest.Ok(t, err)
+		_, err = worker.Verify(propagated)
+		attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+		token, err = minter.Mint(ctx, "report-worker")
+		attest.Ok(t, err)
+		_, err = propagator.Authenticate(context.Background(), &Request{
+			Header: http.Header{"Connectauth-Identity": []string{token}},
+		})
+		attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+
+		clock.Advance(15 * time.Minute)
+		_, err = worker.Verify(token)
+		attest.Equal(t, ReasonOf(err), ReasonExpired)
+	})
+
+	attest.Panics(t, func() { NewDelegator(nil, DelegatorConfig{}) })
+}
@@ -0,0 +1,87 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// An Enricher runs after a request is successfully authenticated. It loads
+// additional data about the caller (for example, an account record, feature
+// flags, or tenant configuration) and attaches it to the context. Enrichers
+// keep AuthFuncs focused on verifying credentials.
+type Enricher struct {
+	// Enrich receives the authentication information returned by the
+	// AuthFunc, which is also available via [GetInfo]. It returns a context
+	// derived from the one it receives, typically with additional values
+	// attached. Required.
+	Enrich func(ctx context.Context, req *Request, info any) (context.Context, error)
+	// Timeout bounds the time spent in Enrich. The timeout applies only to
+	// Enrich: the context it returns isn't canceled when the timeout expires.
+	// If zero, Enrich runs without a timeout.
+	Timeout time.Duration
+	// MapError converts errors returned by Enrich into errors returned to the
+	// client. If nil, [*connect.Error]s are returned unchanged, timeouts are
+	// coded with [connect.CodeUnavailable], and all other errors are coded with
+	// [connect.CodeInternal].
+	MapError func(error) error
+}
+
+// WithEnricher appends an [Enricher] to the chain of enrichers that run after
+// successful authentication. Enrichers run in the order they're configured,
+// each receiving the context returned by the previous one. Enrichers don't run
+// for exempt procedures.
+func WithEnricher(e Enricher) Option {
+	return optionFunc(func(c *config) {
+		c.Enrichers = append(c.Enrichers, e)
+	})
+}
+
+func (e *Enricher) run(ctx context.Context, req *Request, info any) (context.Context, error) {
+	enrichCtx := ctx
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		enrichCtx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	enriched, err := e.Enrich(enrichCtx, req, info)
+	if err != nil {
+		if e.MapError != nil {
+			return nil, e.MapError(err)
+		}
+		return nil, defaultEnrichError(err)
+	}
+	if enriched == nil || enriched == enrichCtx {
+		return ctx, nil
+	}
+	if enrichCtx == ctx {
+		return enriched, nil
+	}
+	// Keep the values attached by Enrich, but not its timeout.
+	return valuesContext{Context: ctx, values: enriched}, nil
+}
+
+func defaultEnrichError(err error) error {
+	var connectErr *connect.Error
+	switch {
+	case errors.As(err, &connectErr):
+		return connectErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, err)
+	default:
+		return connect.NewError(connect.CodeInternal, err)
+	}
+}
+
+// valuesContext takes its deadline and cancelation from the embedded context,
+// but its values from another context.
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key any) any {
+	return c.values.Value(key)
+}
@@ -0,0 +1,67 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+type accountKey struct{}
+
+func TestEnricher(t *testing.T) {
+	loadAccount := Enricher{
+		Enrich: func(ctx context.Context, req *Request, info any) (context.Context, error) {
+			return context.WithValue(ctx, accountKey{}, "account for "+info.(string)), nil
+		},
+		Timeout: time.Second,
+	}
+	auth := New(authenticate, WithEnricher(loadAccount))
+	header := http.Header{"Authorization": []string{"Bearer " + passphrase}}
+	ctx, err := auth.authenticate(context.Background(), &Request{Protocol: connect.ProtocolConnect, Header: header})
+	attest.Ok(t, err)
+	attest.Equal(t, ctx.Value(accountKey{}), any("account for "+hero))
+	assertInfo(t, ctx)
+	// The enricher's timeout doesn't leak into the request context.
+	_, hasDeadline := ctx.Deadline()
+	attest.False(t, hasDeadline)
+
+	t.Run("default errors", func(t *testing.T) {
+		tests := []struct {
+			err  error
+			code connect.Code
+		}{
+			{context.DeadlineExceeded, connect.CodeUnavailable},
+			{errors.New("oops"), connect.CodeInternal},
+			{connect.NewError(connect.CodeNotFound, errors.New("no account")), connect.CodeNotFound},
+		}
+		for _, tt := range tests {
+			auth := New(authenticate, WithEnricher(Enricher{
+				Enrich: func(context.Context, *Request, any) (context.Context, error) {
+					return nil, tt.err
+				},
+			}))
+			_, err := auth.authenticate(context.Background(), &Request{Protocol: connect.ProtocolConnect, Header: header})
+			attest.Equal(t, connect.CodeOf(err), tt.code)
+		}
+	})
+
+	t.Run("timeout and custom mapping", func(t *testing.T) {
+		auth := New(authenticate, WithEnricher(Enricher{
+			Enrich: func(ctx context.Context, _ *Request, _ any) (context.Context, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			Timeout: time.Millisecond,
+			MapError: func(err error) error {
+				return connect.NewError(connect.CodePermissionDenied, err)
+			},
+		}))
+		_, err := auth.authenticate(context.Background(), &Request{Protocol: connect.ProtocolConnect, Header: header})
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	})
+}
@@ -0,0 +1,67 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// An Event describes the outcome of authenticating a single request.
+type Event struct {
+	Request  *Request
+	Info     any   // returned by the AuthFunc, if authentication succeeded
+	Err      error // the complete error, even if WithRedactedErrors is used
+	Exempt   bool  // the procedure is exempt from authentication
+	Actor    any   // if the caller tried to impersonate another subject, the caller's authentication information
+	Start    time.Time
+	Duration time.Duration
+}
+
+// WithObserver registers a function that's called after every authentication
+// attempt, including attempts to call exempt procedures. Observers are useful
+// for logging and metrics. They run synchronously on the request path, so
+// they should be fast, and they must not retain the Event after returning.
+// Observers may be called concurrently.
+//
+// Observers run in the order they're configured.
+func WithObserver(observe func(context.Context, *Event)) Option {
+	return optionFunc(func(c *config) {
+		c.Observers = append(c.Observers, observe)
+	})
+}
+
+// WithRedactedErrors replaces the message of every authentication error sent
+// to clients with a generic "authentication failed". Detailed failure reasons
+// (a token parsing failure, an issuer mismatch, and so on) can help attackers
+// probe verifiers, but they're still available to observers registered with
+// [WithObserver].
+//
+// Redacted errors keep their codes, metadata, and details, so clients can
+// still distinguish authentication from authorization failures, respond to
+// challenges, and back off when throttled.
+func WithRedactedErrors() Option {
+	return optionFunc(func(c *config) {
+		c.RedactErrors = true
+	})
+}
+
+func redact(err error) error {
+	var msg error = errors.New("authentication failed")
+	var re *reasonError
+	if errors.As(err, &re) {
+		msg = &reasonError{reason: re.reason, err: msg}
+	}
+	redacted := connect.NewError(connect.CodeOf(err), msg)
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		for k, vals := range connectErr.Meta() {
+			redacted.Meta()[k] = append(redacted.Meta()[k], vals...)
+		}
+		for _, detail := range connectErr.Details() {
+			redacted.AddDetail(detail)
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,70 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestObserver(t *testing.T) {
+	var events []Event
+	auth := New(
+		authenticate,
+		WithExemptProcedures("/acme.v1.Svc/Ping"),
+		WithObserver(func(_ context.Context, ev *Event) {
+			events = append(events, *ev)
+		}),
+	)
+	call := func(procedure, authorization string) error {
+		header := http.Header{}
+		header.Set("Authorization", authorization)
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: procedure,
+			Protocol:  connect.ProtocolConnect,
+			Header:    header,
+		})
+		return err
+	}
+
+	attest.Ok(t, call("/acme.v1.Svc/Get", "Bearer "+passphrase))
+	attest.Error(t, call("/acme.v1.Svc/Get", "Bearer wrong"))
+	attest.Ok(t, call("/acme.v1.Svc/Ping", ""))
+
+	attest.Equal(t, len(events), 3)
+	attest.Equal(t, events[0].Info, any(hero))
+	attest.Ok(t, events[0].Err)
+	attest.False(t, events[0].Exempt)
+	attest.False(t, events[0].Start.IsZero())
+	attest.Error(t, events[1].Err)
+	attest.Zero(t, events[1].Info)
+	attest.True(t, events[2].Exempt)
+}
+
+func TestRedactedErrors(t *testing.T) {
+	var observed error
+	auth := New(
+		func(context.Context, *Request) (any, error) {
+			err := RetryErrorf(connect.CodeUnauthenticated, time.Second, "issuer %q is not trusted", "evil.example.com")
+			err.Meta().Set("WWW-Authenticate", "Bearer")
+			return nil, err
+		},
+		WithRedactedErrors(),
+		WithObserver(func(_ context.Context, ev *Event) {
+			observed = ev.Err
+		}),
+	)
+	_, err := auth.authenticate(context.Background(), &Request{Protocol: connect.ProtocolConnect})
+	var connectErr *connect.Error
+	attest.True(t, errors.As(err, &connectErr))
+	attest.Equal(t, connectErr.Code(), connect.CodeUnauthenticated)
+	attest.Equal(t, connectErr.Message(), "authentication failed")
+	attest.Equal(t, connectErr.Meta().Get("WWW-Authenticate"), "Bearer")
+	_, ok := RetryDelay(err)
+	attest.True(t, ok)
+	attest.Subsequence(t, observed.Error(), "evil.example.com")
+}
@@ -0,0 +1,223 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"connectrpc.com/connect"
+)
+
+// ForwardAuthConfig configures a [ForwardAuth] handler.
+type ForwardAuthConfig struct {
+	// Headers returns the identity headers sent with successful verdicts.
+	// Proxies copy them to the upstream request when configured to (for
+	// example, with Traefik's authResponseHeaders or Caddy's copy_headers).
+	// By default, X-Forwarded-User holds the caller's subject, as reported
+	// by [SubjectOf].
+	Headers func(info any) http.Header
+}
+
+// ForwardAuth is an HTTP handler that answers the forward-auth subrequests
+// sent by reverse proxies like Traefik and Caddy, so they can delegate
+// authentication decisions to the same code protecting Connect services:
+//
+//	auth := connectauth.New(authenticate, connectauth.WithTrustedProxies(proxyNet))
+//	mux.Handle(foov1connect.NewFooServiceHandler(foo, auth.HandlerOption()))
+//	mux.Handle("/auth/verify", connectauth.NewForwardAuth(auth, connectauth.ForwardAuthConfig{}))
+//
+// Each subrequest carries the original request's headers, and its method and
+// URI in the X-Forwarded-Method and X-Forwarded-Uri headers. Subrequests for
+// RPCs are authenticated exactly like RPCs reaching [Middleware], so
+// exemptions and per-procedure policies apply; other subrequests are
+// authenticated like requests to [HTTPMiddleware]. Successful verdicts are
+// empty 200 OK responses carrying identity headers. Failures are written like
+// HTTPMiddleware's, using the HTTP status codes configured by
+// [WithHTTPStatus], and proxies relay them to the client.
+//
+// The immediate peer of a subrequest is the proxy, so applications should
+// use [WithTrustedProxies] to recover the client's address. The handler must
+// only be reachable by the proxy: callers who can reach it directly can
+// forge the X-Forwarded headers.
+type ForwardAuth struct {
+	auth      *Authenticator
+	headers   func(any) http.Header
+	methodKey string
+	uriKey    string
+	hostKey   string
+	writeErr  func(http.ResponseWriter, error)
+}
+
+// NewForwardAuth constructs a ForwardAuth handler that authenticates
+// subrequests with the supplied Authenticator.
+func NewForwardAuth(auth *Authenticator, config ForwardAuthConfig) *ForwardAuth {
+	if config.Headers == nil {
+		config.Headers = func(info any) http.Header {
+			if subject := SubjectOf(info); subject != "" {
+				return http.Header{"X-Forwarded-User": []string{subject}}
+			}
+			return nil
+		}
+	}
+	return &ForwardAuth{
+		auth:      auth,
+		headers:   config.Headers,
+		methodKey: "X-Forwarded-Method",
+		uriKey:    "X-Forwarded-Uri",
+		hostKey:   "X-Forwarded-Host",
+		writeErr: func(w http.ResponseWriter, err error) {
+			writeJSONError(w, err, auth.config.HTTPStatus)
+		},
+	}
+}
+
+// AuthRequestConfig configures a [ForwardAuth] handler for NGINX (see
+// [NewAuthRequest]).
+type AuthRequestConfig struct {
+	// Headers returns the identity headers sent with successful verdicts,
+	// which NGINX exposes as $upstream_http_ variables to auth_request_set.
+	// By default, X-Auth-Request-User holds the caller's subject, as reported
+	// by [SubjectOf].
+	Headers func(info any) http.Header
+}
+
+// NewAuthRequest constructs a ForwardAuth handler for NGINX's auth_request
+// module:
+//
+//	location = /auth {
+//		internal;
+//		proxy_pass http://backend/auth/verify;
+//		proxy_pass_request_body off;
+//		proxy_set_header Content-Length "";
+//		proxy_set_header X-Original-Method $request_method;
+//		proxy_set_header X-Original-URI $request_uri;
+//	}
+//	location / {
+//		auth_request /auth;
+//		auth_request_set $user $upstream_http_x_auth_request_user;
+//		proxy_set_header X-User $user;
+//		proxy_pass http://backend;
+//	}
+//
+// The handler behaves like one constructed with [NewForwardAuth], but reads
+// the original method and URI from X-Original-Method and X-Original-URI.
+// NGINX discards the bodies of subrequest responses and only understands
+// 401 and 403 verdicts, so failures have empty bodies, the status is 401
+// for [connect.CodeUnauthenticated] and 403 otherwise, and the error's code
+// is sent in the X-Auth-Request-Error header. Metadata attached to a
+// [connect.Error] (for example, a WWW-Authenticate challenge) is copied to
+// the response headers.
+func NewAuthRequest(auth *Authenticator, config AuthRequestConfig) *ForwardAuth {
+	if config.Headers == nil {
+		config.Headers = func(info any) http.Header {
+			if subject := SubjectOf(info); subject != "" {
+				return http.Header{"X-Auth-Request-User": []string{subject}}
+			}
+			return nil
+		}
+	}
+	return &ForwardAuth{
+		auth:      auth,
+		headers:   config.Headers,
+		methodKey: "X-Original-Method",
+		uriKey:    "X-Original-Uri",
+		hostKey:   "X-Original-Host",
+		writeErr:  writeAuthRequestError,
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (f *ForwardAuth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	original, err := f.original(r)
+	if err != nil {
+		f.writeErr(w, err)
+		return
+	}
+	if err := f.auth.config.checkLimits(original); err != nil {
+		f.writeErr(w, err)
+		return
+	}
+	procedure, protocol := f.auth.route(original)
+	ctx, err := f.auth.authenticate(r.Context(), &Request{
+		Procedure:      procedure,
+		ClientAddr:     r.RemoteAddr,
+		Host:           original.Host,
+		Protocol:       protocol,
+		StreamType:     streamTypeFromHTTP(original, procedure, protocol),
+		Header:         original.Header,
+		ResponseHeader: w.Header(),
+	})
+	if err != nil {
+		f.writeErr(w, err)
+		return
+	}
+	header := w.Header()
+	for k, vals := range f.headers(GetInfo(ctx)) {
+		header[k] = vals
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// original reconstructs the request that the proxy is asking about. It
+// shares the subrequest's headers, but never has a body.
+func (f *ForwardAuth) original(r *http.Request) (*http.Request, error) {
+	original := r.Clone(r.Context())
+	original.Body = http.NoBody
+	original.ContentLength = 0
+	if method := r.Header.Get(f.methodKey); method != "" {
+		original.Method = method
+	}
+	if uri := r.Header.Get(f.uriKey); uri != "" {
+		u, err := url.ParseRequestURI(uri)
+		if err != nil {
+			return nil, connect.NewError(
+				connect.CodeInvalidArgument,
+				fmt.Errorf("invalid %s header: %w", f.uriKey, err),
+			)
+		}
+		original.URL = u
+		original.RequestURI = uri
+	}
+	if host := r.Header.Get(f.hostKey); host != "" {
+		original.Host = host
+	}
+	return original, nil
+}
+
+// route returns the procedure and protocol that [Middleware] would use to
+// authenticate a request. Both are empty for non-RPC requests.
+func (a *Authenticator) route(r *http.Request) (procedure, protocol string) {
+	if resolve := a.config.ProcedureResolver; resolve != nil {
+		if procedure, ok := resolve(r); ok && procedure != procedureFromHTTP(r) {
+			return procedure, ProtocolREST
+		}
+	}
+	if a.errW.Load().IsSupported(r) {
+		return procedureFromHTTP(r), protocolFromHTTP(r)
+	}
+	if resolve := a.config.Gateway; resolve != nil {
+		if procedure, ok := resolve(r); ok {
+			return procedure, ProtocolGateway
+		}
+	}
+	return "", ""
+}
+
+// writeAuthRequestError writes an error as an NGINX auth_request verdict.
+func writeAuthRequestError(w http.ResponseWriter, err error) {
+	header := w.Header()
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		for k, vals := range connectErr.Meta() {
+			header[k] = append(header[k], vals...)
+		}
+	}
+	code := connect.CodeOf(err)
+	header.Set("X-Auth-Request-Error", code.String())
+	if code == connect.CodeUnauthenticated {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusForbidden)
+}
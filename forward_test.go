@@ -0,0 +1,144 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestForwardAuth(t *testing.T) {
+	var requests []*Request
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		requests = append(requests, req)
+		return authenticate(ctx, req)
+	}, WithExemptProcedures("/grpc.health.v1.Health/*"))
+	handler := NewForwardAuth(auth, ForwardAuthConfig{})
+	serve := func(method, uri string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+		for k, vals := range header {
+			req.Header[k] = vals
+		}
+		req.Header.Set("X-Forwarded-Method", method)
+		req.Header.Set("X-Forwarded-Uri", uri)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("rpc", func(t *testing.T) {
+		requests = nil
+		rec := serve(http.MethodPost, "/acme.user.v1.UserService/GetUser", http.Header{
+			"Content-Type":  []string{"application/proto"},
+			"Authorization": []string{"Bearer " + passphrase},
+		})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, rec.Header().Get("X-Forwarded-User"), hero)
+		attest.Zero(t, rec.Body.Len())
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/acme.user.v1.UserService/GetUser")
+		attest.Equal(t, requests[0].Protocol, connect.ProtocolConnect)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		requests = nil
+		rec := serve(http.MethodGet, "/dashboard?tab=1", http.Header{
+			"Authorization": []string{"Bearer " + passphrase},
+		})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, len(requests), 1)
+		attest.Zero(t, requests[0].Procedure)
+		attest.Zero(t, requests[0].Protocol)
+	})
+
+	t.Run("exempt", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/grpc.health.v1.Health/Check", http.Header{
+			"Content-Type": []string{"application/grpc"},
+		})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Zero(t, rec.Header().Get("X-Forwarded-User"))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/dashboard", nil)
+		attest.Equal(t, rec.Code, http.StatusUnauthorized)
+		attest.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+		attest.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+		attest.Zero(t, rec.Header().Get("X-Forwarded-User"))
+	})
+
+	t.Run("invalid uri", func(t *testing.T) {
+		rec := serve(http.MethodGet, "dashboard", nil)
+		attest.Equal(t, rec.Code, http.StatusBadRequest)
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		handler := NewForwardAuth(auth, ForwardAuthConfig{
+			Headers: func(info any) http.Header {
+				return http.Header{"X-Auth-Name": []string{SubjectOf(info)}}
+			},
+		})
+		req := httptest.NewRequest(http.MethodGet, "/auth/verify", nil)
+		req.Header.Set("Authorization", "Bearer "+passphrase)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, rec.Header().Get("X-Auth-Name"), hero)
+	})
+}
+
+func TestAuthRequest(t *testing.T) {
+	var requests []*Request
+	handler := NewAuthRequest(New(func(ctx context.Context, req *Request) (any, error) {
+		requests = append(requests, req)
+		if req.Procedure == "/acme.admin.v1.AdminService/Reset" {
+			return nil, connect.NewError(connect.CodePermissionDenied, nil)
+		}
+		return authenticate(ctx, req)
+	}), AuthRequestConfig{})
+	serve := func(uri string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth", nil)
+		for k, vals := range header {
+			req.Header[k] = vals
+		}
+		req.Header.Set("X-Original-Method", http.MethodPost)
+		req.Header.Set("X-Original-URI", uri)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	rpc := func(token string) http.Header {
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		return header
+	}
+
+	t.Run("success", func(t *testing.T) {
+		requests = nil
+		rec := serve("/acme.user.v1.UserService/GetUser", rpc(passphrase))
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, rec.Header().Get("X-Auth-Request-User"), hero)
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/acme.user.v1.UserService/GetUser")
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		rec := serve("/acme.user.v1.UserService/GetUser", rpc(""))
+		attest.Equal(t, rec.Code, http.StatusUnauthorized)
+		attest.Equal(t, rec.Header().Get("X-Auth-Request-Error"), connect.CodeUnauthenticated.String())
+		attest.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+		attest.Zero(t, rec.Body.Len())
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		rec := serve("/acme.admin.v1.AdminService/Reset", rpc(passphrase))
+		attest.Equal(t, rec.Code, http.StatusForbidden)
+		attest.Equal(t, rec.Header().Get("X-Auth-Request-Error"), connect.CodePermissionDenied.String())
+		attest.Zero(t, rec.Header().Get("X-Auth-Request-User"))
+	})
+}
@@ -0,0 +1,66 @@
+package connectauth
+
+import "time"
+
+// A Freshness enforces the acceptance window of signed requests. Verifiers
+// for signed-request schemes should check the request's signed timestamp
+// before doing any expensive work:
+//
+//	stale, err := freshness.Check(signedAt)
+//	if err != nil {
+//		return nil, err
+//	}
+//	// Verify the signature, then:
+//	if err := connectauth.CheckNonce(ctx, nonces, nonce, stale); err != nil {
+//		return nil, err
+//	}
+type Freshness struct {
+	maxAge  time.Duration
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+// NewFreshness constructs a Freshness. Requests are accepted if they were
+// signed within maxAge, with an additional maxSkew of tolerance in both
+// directions for differences between the client's and server's clocks. If
+// maxAge isn't positive, it defaults to five minutes; negative skews are
+// treated as zero.
+func NewFreshness(maxAge, maxSkew time.Duration) *Freshness {
+	if maxAge <= 0 {
+		maxAge = 5 * time.Minute
+	}
+	if maxSkew < 0 {
+		maxSkew = 0
+	}
+	return &Freshness{
+		maxAge:  maxAge,
+		maxSkew: maxSkew,
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the clock used to check timestamps. It's intended for
+// tests, and must be called before the Freshness is used. A nil Clock
+// restores [SystemClock].
+func (f *Freshness) SetClock(clock Clock) {
+	f.now = nowFunc(clock)
+}
+
+// Check rejects requests signed too long ago or too far in the future with
+// [connect.CodeUnauthenticated] and [ReasonStale]. For fresh requests, it
+// returns the time at which the request becomes stale, which is also how
+// long its nonce must be remembered (see [CheckNonce]).
+func (f *Freshness) Check(signed time.Time) (time.Time, error) {
+	if signed.IsZero() {
+		return time.Time{}, ReasonErrorf(ReasonMalformedCredentials, "missing signature timestamp")
+	}
+	now := f.now()
+	if signed.After(now.Add(f.maxSkew)) {
+		return time.Time{}, ReasonErrorf(ReasonStale, "request signed %v in the future", signed.Sub(now).Round(time.Second))
+	}
+	stale := signed.Add(f.maxAge + f.maxSkew)
+	if !now.Before(stale) {
+		return time.Time{}, ReasonErrorf(ReasonStale, "request signed %v ago", now.Sub(signed).Round(time.Second))
+	}
+	return stale, nil
+}
@@ -0,0 +1,35 @@
+package connectauth
+
+import (
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestFreshness(t *testing.T) {
+	clock := newTestClock()
+	fresh := NewFreshness(5*time.Minute, 30*time.Second)
+	fresh.SetClock(clock)
+	now := clock.Now()
+
+	stale, err := fresh.Check(now.Add(-time.Minute))
+	attest.Ok(t, err)
+	attest.Equal(t, stale, now.Add(4*time.Minute+30*time.Second))
+	_, err = fresh.Check(now.Add(-5*time.Minute - 29*time.Second))
+	attest.Ok(t, err)
+	_, err = fresh.Check(now.Add(30 * time.Second))
+	attest.Ok(t, err)
+
+	for _, signed := range []time.Time{
+		now.Add(-5*time.Minute - 30*time.Second),
+		now.Add(31 * time.Second),
+	} {
+		_, err := fresh.Check(signed)
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		attest.Equal(t, ReasonOf(err), ReasonStale)
+	}
+	_, err = fresh.Check(time.Time{})
+	attest.Equal(t, ReasonOf(err), ReasonMalformedCredentials)
+}
@@ -0,0 +1,127 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// ProtocolGateway is the [Request].Protocol of requests transcoded by
+// grpc-gateway (see [WithGateway]). Applications restricting protocols with
+// [WithProtocols] must allow it.
+const ProtocolGateway = "grpc-gateway"
+
+// ProtocolREST is the [Request].Protocol of REST requests transcoded into
+// RPCs by the handler [Middleware] wraps, like a Vanguard transcoder (see
+// [WithProcedureResolver]). Applications restricting protocols with
+// [WithProtocols] must allow it.
+const ProtocolREST = "rest"
+
+// WithGateway makes [Middleware] recognize grpc-gateway requests: JSON REST
+// requests, like GET /v1/users/42, that the gateway transcodes into RPCs.
+// The resolve function maps each non-RPC request to the procedure it will
+// invoke. The connectauthgateway package builds resolvers from the
+// google.api.http annotations in protobuf service definitions.
+//
+// Requests that resolve to a procedure are authenticated exactly like RPCs
+// to the same procedure, with Protocol set to [ProtocolGateway], so
+// exemptions, routers, and per-procedure policies apply consistently whether
+// a call arrives natively or through the gateway. Failures are written in
+// grpc-gateway's JSON error format, using the HTTP status codes configured by
+// [WithHTTPStatus]. Requests that don't resolve are handled as usual (see
+// [WithAuthenticateAll]).
+func WithGateway(resolve func(*http.Request) (procedure string, ok bool)) Option {
+	return optionFunc(func(c *config) {
+		c.Gateway = resolve
+	})
+}
+
+// WithProcedureResolver makes [Middleware] resolve procedures with the
+// supplied function before falling back to the request path. It's designed
+// for handlers mounted with connectrpc.com/vanguard, which serve REST routes
+// like GET /v1/users/42 alongside Connect, gRPC, and gRPC-Web:
+//
+//	routes, err := connectauthgateway.NewRoutes(services...)
+//	if err != nil {
+//		return err
+//	}
+//	auth := connectauth.New(authenticate, connectauth.WithProcedureResolver(routes.Resolve))
+//	mux.Handle("/", auth.Middleware().Wrap(transcoder))
+//
+// The resolver sees every request, whatever its content type. When it
+// returns a procedure other than the one named by the request path, the
+// request is authenticated as a call to that procedure with Protocol set to
+// [ProtocolREST], and failures are written as JSON google.rpc.Status
+// messages, like Vanguard's own REST errors. Other requests, including
+// Connect, gRPC, and gRPC-Web calls to the transcoder, are handled as usual.
+func WithProcedureResolver(resolve func(*http.Request) (procedure string, ok bool)) Option {
+	return optionFunc(func(c *config) {
+		c.ProcedureResolver = resolve
+	})
+}
+
+// serveTranscoded authenticates a request that a gateway or transcoder will
+// convert into a call to the supplied procedure.
+func (m *Middleware) serveTranscoded(w http.ResponseWriter, r *http.Request, next http.Handler, procedure, protocol string) {
+	status := m.auth.config.HTTPStatus
+	if err := m.auth.config.checkLimits(r); err != nil {
+		writeGatewayError(w, err, status)
+		return
+	}
+	ctx, err := m.auth.authenticate(r.Context(), &Request{
+		Procedure:      procedure,
+		ClientAddr:     r.RemoteAddr,
+		Host:           r.Host,
+		Protocol:       protocol,
+		StreamType:     streamTypeFromHTTP(r, procedure, protocol),
+		Header:         r.Header,
+		TLS:            r.TLS,
+		ResponseHeader: w.Header(),
+	})
+	if err != nil {
+		writeGatewayError(w, err, status)
+		return
+	}
+	m.auth.run(ctx, procedure, func(ctx context.Context) {
+		if ctx != r.Context() {
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeGatewayError writes an error as a JSON google.rpc.Status, like
+// grpc-gateway's default error handler and Vanguard's REST errors.
+func writeGatewayError(w http.ResponseWriter, err error, status func(connect.Code) int) {
+	code := connect.CodeOf(err)
+	st := &spb.Status{Code: int32(code), Message: err.Error()}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		header := w.Header()
+		for k, vals := range connectErr.Meta() {
+			header[k] = append(header[k], vals...)
+		}
+		st.Message = connectErr.Message()
+		for _, detail := range connectErr.Details() {
+			st.Details = append(st.Details, &anypb.Any{
+				TypeUrl: "type.googleapis.com/" + detail.Type(),
+				Value:   detail.Bytes(),
+			})
+		}
+	}
+	body, marshalErr := protojson.Marshal(st)
+	if marshalErr != nil {
+		// Details with unregistered types can't be marshaled to JSON.
+		st.Details = nil
+		body, _ = protojson.Marshal(st)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status(code))
+	// The only possible error is a failed write to the client.
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,122 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestGateway(t *testing.T) {
+	var requests []*Request
+	auth := func(ctx context.Context, req *Request) (any, error) {
+		requests = append(requests, req)
+		return authenticate(ctx, req)
+	}
+	resolve := func(r *http.Request) (string, bool) {
+		if r.Method == http.MethodGet && r.URL.Path == "/v1/users/me" {
+			return "/acme.user.v1.UserService/GetUser", true
+		}
+		if r.Method == http.MethodGet && r.URL.Path == "/healthz" {
+			return "/grpc.health.v1.Health/Check", true
+		}
+		return "", false
+	}
+	handler := New(
+		auth,
+		WithGateway(resolve),
+		WithExemptProcedures("/grpc.health.v1.Health/*"),
+	).Middleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _ := GetInfo(r.Context()).(string)
+		w.Write([]byte(name))
+	}))
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, vals := range header {
+			req.Header[k] = vals
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("success", func(t *testing.T) {
+		requests = nil
+		rec := serve("/v1/users/me", http.Header{"Authorization": []string{"Bearer " + passphrase}})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, rec.Body.String(), hero)
+		attest.Equal(t, len(requests), 1)
+		attest.Equal(t, requests[0].Procedure, "/acme.user.v1.UserService/GetUser")
+		attest.Equal(t, requests[0].Protocol, ProtocolGateway)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		rec := serve("/v1/users/me", nil)
+		attest.Equal(t, rec.Code, http.StatusUnauthorized)
+		attest.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+		var st spb.Status
+		attest.Ok(t, protojson.Unmarshal(rec.Body.Bytes(), &st))
+		attest.Equal(t, connect.Code(st.GetCode()), connect.CodeUnauthenticated)
+		attest.Equal(t, st.GetMessage(), "expected Bearer authentication scheme")
+	})
+
+	t.Run("exempt", func(t *testing.T) {
+		requests = nil
+		rec := serve("/healthz", nil)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Zero(t, requests)
+	})
+
+	t.Run("unresolved", func(t *testing.T) {
+		// Without WithAuthenticateAll, unresolved non-RPC requests pass through.
+		requests = nil
+		rec := serve("/static/app.js", nil)
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Zero(t, requests)
+	})
+}
+
+func TestProcedureResolver(t *testing.T) {
+	var requests []*Request
+	auth := func(ctx context.Context, req *Request) (any, error) {
+		requests = append(requests, req)
+		return authenticate(ctx, req)
+	}
+	handler := New(auth, WithProcedureResolver(func(r *http.Request) (string, bool) {
+		switch r.URL.Path {
+		case "/v1/users":
+			return "/acme.user.v1.UserService/CreateUser", true
+		case "/acme.user.v1.UserService/GetUser":
+			return "/acme.user.v1.UserService/GetUser", true
+		}
+		return "", false
+	})).Middleware().Wrap(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		// REST requests with JSON bodies look like Connect unary RPCs.
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+passphrase)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	requests = nil
+	attest.Equal(t, serve("/v1/users").Code, http.StatusOK)
+	attest.Equal(t, len(requests), 1)
+	attest.Equal(t, requests[0].Procedure, "/acme.user.v1.UserService/CreateUser")
+	attest.Equal(t, requests[0].Protocol, ProtocolREST)
+
+	// Resolving a procedure to itself doesn't change how it's handled.
+	requests = nil
+	attest.Equal(t, serve("/acme.user.v1.UserService/GetUser").Code, http.StatusOK)
+	attest.Equal(t, len(requests), 1)
+	attest.Equal(t, requests[0].Procedure, "/acme.user.v1.UserService/GetUser")
+	attest.Equal(t, requests[0].Protocol, connect.ProtocolConnect)
+}
@@ -1,12 +1,14 @@
 module go.akshayshah.org/connectauth
 
-go 1.19
+go 1.21
 
 require (
-	connectrpc.com/connect v1.11.0
+	connectrpc.com/connect v1.11.1
 	go.akshayshah.org/attest v1.0.2
 	go.akshayshah.org/memhttp v0.1.0
+	golang.org/x/sync v0.3.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230822172742-b8732ec3820d
 	google.golang.org/protobuf v1.31.0
 )
 
-require github.com/google/go-cmp v0.5.9 // indirect
+require github.com/google/go-cmp v0.6.0 // indirect
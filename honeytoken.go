@@ -0,0 +1,91 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+)
+
+// FlagHoneytoken is added to [Request].Flags when a request presents a
+// honeytoken, so observers and audit sinks can record it.
+const FlagHoneytoken = "honeytoken"
+
+// HoneytokenConfig configures [WithHoneytokens].
+type HoneytokenConfig struct {
+	// Tokens maps labels to canary credentials. Labels identify where each
+	// credential was planted (like "ci-secrets-file" or "wiki-page"), so an
+	// alert shows which secret store leaked.
+	Tokens map[string]string
+	// Credential extracts the credential from a request. By default, it's
+	// the credentials part of the Authorization header, whatever the scheme.
+	Credential func(*Request) string
+	// OnTrip is called whenever a request presents a honeytoken. It runs
+	// synchronously, so slow alerting (like paging or posting to a chat
+	// channel) should happen in another goroutine. Required.
+	OnTrip func(context.Context, *HoneytokenEvent)
+	// Clock timestamps events. The default is [SystemClock].
+	Clock Clock
+}
+
+// A HoneytokenEvent describes a request that presented a honeytoken.
+type HoneytokenEvent struct {
+	Label   string
+	Request *Request
+	Time    time.Time
+}
+
+// WithHoneytokens plants canary credentials: fake secrets placed where real
+// ones might leak from, like configuration files, CI variables, or
+// documentation. Honeytokens are never valid, so anyone presenting one has
+// found a leaked secrets file and is trying it out. Requests presenting a
+// honeytoken trigger OnTrip and are rejected with
+// [connect.CodeUnauthenticated] and [ReasonInvalidCredentials], without
+// calling the AuthFunc. Use [WithRedactedErrors] so that the rejection is
+// indistinguishable from any other invalid credential.
+//
+// Honeytokens are stored as SHA-256 hashes and checked before the AuthFunc
+// runs, but not for exempt procedures. It panics if OnTrip is nil.
+func WithHoneytokens(hc HoneytokenConfig) Option {
+	if hc.OnTrip == nil {
+		panic("connectauth: honeytokens require an OnTrip function")
+	}
+	if hc.Credential == nil {
+		hc.Credential = func(req *Request) string {
+			_, credentials := parseAuthorization(req.Header)
+			return credentials
+		}
+	}
+	h := &honeytokens{
+		labels:     make(map[[sha256.Size]byte]string, len(hc.Tokens)),
+		credential: hc.Credential,
+		onTrip:     hc.OnTrip,
+		now:        nowFunc(hc.Clock),
+	}
+	for label, token := range hc.Tokens {
+		h.labels[sha256.Sum256([]byte(token))] = label
+	}
+	return optionFunc(func(c *config) {
+		c.Honeytokens = h
+	})
+}
+
+type honeytokens struct {
+	labels     map[[sha256.Size]byte]string
+	credential func(*Request) string
+	onTrip     func(context.Context, *HoneytokenEvent)
+	now        func() time.Time
+}
+
+func (h *honeytokens) check(ctx context.Context, req *Request) error {
+	credential := h.credential(req)
+	if credential == "" {
+		return nil
+	}
+	label, ok := h.labels[sha256.Sum256([]byte(credential))]
+	if !ok {
+		return nil
+	}
+	req.Flags = append(req.Flags, FlagHoneytoken)
+	h.onTrip(ctx, &HoneytokenEvent{Label: label, Request: req, Time: h.now()})
+	return ReasonErrorf(ReasonInvalidCredentials, "invalid credentials")
+}
@@ -0,0 +1,54 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestHoneytokens(t *testing.T) {
+	var trips []string
+	var calls int
+	var flagged []string
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		calls++
+		return authenticate(ctx, req)
+	},
+		WithHoneytokens(HoneytokenConfig{
+			Tokens: map[string]string{
+				"ci-secrets": "sk_live_canary",
+				"wiki":       "hunter2",
+			},
+			OnTrip: func(_ context.Context, ev *HoneytokenEvent) {
+				trips = append(trips, ev.Label)
+				attest.Equal(t, ev.Request.ClientAddr, "192.0.2.1:1234")
+			},
+		}),
+		WithObserver(func(_ context.Context, ev *Event) {
+			flagged = ev.Request.Flags
+		}),
+	)
+	call := func(authorization string) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure:  "/acme.v1.Svc/Get",
+			Protocol:   connect.ProtocolConnect,
+			ClientAddr: "192.0.2.1:1234",
+			Header:     http.Header{"Authorization": []string{authorization}},
+		})
+		return err
+	}
+
+	attest.Ok(t, call("Bearer "+passphrase))
+	attest.Zero(t, trips)
+	err := call("Bearer sk_live_canary")
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+	attest.Equal(t, trips, []string{"ci-secrets"})
+	attest.Equal(t, flagged, []string{FlagHoneytoken})
+	attest.Equal(t, calls, 1) // the AuthFunc wasn't called
+	attest.Error(t, call("Token hunter2"))
+	attest.Equal(t, trips, []string{"ci-secrets", "wiki"})
+}
@@ -0,0 +1,83 @@
+package connectauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// HTTPMiddleware is server-side HTTP middleware that authenticates every
+// request, whatever its content type. It's designed for REST endpoints,
+// webhooks, and admin pages mounted on the same mux as Connect handlers:
+//
+//	auth := connectauth.New(authenticate)
+//	mux.Handle(foov1connect.NewFooServiceHandler(foo, auth.HandlerOption()))
+//	mux.Handle("/webhooks/", auth.HTTPMiddleware().Wrap(webhooks))
+//
+// Requests are authenticated with the same pipeline as RPCs, but their
+// [Request] has an empty Procedure and Protocol, so exemptions and protocol
+// policies don't apply. Failures are written as JSON objects with code and
+// message fields, like
+//
+//	{"code": "unauthenticated", "message": "missing bearer token"}
+//
+// using the HTTP status codes configured by [WithHTTPStatus]. Any metadata
+// attached to a [connect.Error] (for example, a WWW-Authenticate challenge)
+// is copied to the response headers.
+type HTTPMiddleware struct {
+	auth *Authenticator
+}
+
+// NewHTTPMiddleware constructs HTTP middleware for non-RPC routes using the
+// supplied authentication function. If authentication succeeds, the
+// authentication information (if any) will be attached to the context, and
+// the wrapped handler may access it with [GetInfo].
+func NewHTTPMiddleware(auth AuthFunc, opts ...Option) *HTTPMiddleware {
+	return New(auth, opts...).HTTPMiddleware()
+}
+
+// Wrap decorates an HTTP handler with authentication logic.
+func (m *HTTPMiddleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.auth.config.SkipPreflight && isPreflight(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := m.auth.config.checkLimits(r); err != nil {
+			m.writeError(w, err)
+			return
+		}
+		m.auth.serveHTTP(w, r, next, m.writeError)
+	})
+}
+
+func (m *HTTPMiddleware) writeError(w http.ResponseWriter, err error) {
+	writeJSONError(w, err, m.auth.config.HTTPStatus)
+}
+
+// writeJSONError writes an error as a JSON object with code and message
+// fields.
+func writeJSONError(w http.ResponseWriter, err error, status func(connect.Code) int) {
+	body := struct {
+		Code    string `json:"code"`
+		Message string `json:"message,omitempty"`
+	}{
+		Code:    connect.CodeOf(err).String(),
+		Message: err.Error(),
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		header := w.Header()
+		for k, vals := range connectErr.Meta() {
+			header[k] = append(header[k], vals...)
+		}
+		body.Message = connectErr.Message()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status(connect.CodeOf(err)))
+	// The only possible error is a failed write to the client.
+	_ = json.NewEncoder(w).Encode(body)
+}
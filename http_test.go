@@ -0,0 +1,60 @@
+package connectauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	handler := NewHTTPMiddleware(authenticate, WithExemptProcedures("/*/*")).Wrap(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, _ := GetInfo(r.Context()).(string)
+			w.Write([]byte(name))
+		}),
+	)
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "application/json")
+		for k, vals := range header {
+			req.Header[k] = vals
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rec := serve(http.Header{"Authorization": []string{"Bearer " + passphrase}})
+		attest.Equal(t, rec.Code, http.StatusOK)
+		attest.Equal(t, rec.Body.String(), hero)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		// Exemptions only apply to RPCs, so this request isn't exempt.
+		rec := serve(nil)
+		attest.Equal(t, rec.Code, http.StatusUnauthorized)
+		attest.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+		attest.Equal(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+		var body map[string]string
+		attest.Ok(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		attest.Equal(t, body, map[string]string{
+			"code":    connect.CodeUnauthenticated.String(),
+			"message": "expected Bearer authentication scheme",
+		})
+	})
+
+	t.Run("status", func(t *testing.T) {
+		handler := NewHTTPMiddleware(authenticate, WithHTTPStatus(func(connect.Code) int {
+			return http.StatusTeapot
+		})).Wrap(http.NotFoundHandler())
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		attest.Equal(t, rec.Code, http.StatusTeapot)
+	})
+}
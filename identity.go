@@ -0,0 +1,199 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	connectauthv1 "go.akshayshah.org/connectauth/internal/gen/connectauth/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// An Identity describes an authenticated principal in a form that can cross
+// service boundaries. AuthFuncs may return Identities as their
+// authentication information, or applications may convert their own types
+// when propagating identities (see [IdentityPropagator]).
+//
+// Identities cross service boundaries as connectauth.v1.Identity protobuf
+// messages (see EncodeIdentity and the schema in the proto directory), so
+// services in any language can decode them. Claims must be representable as
+// a google.protobuf.Struct: after decoding, numbers are float64s, lists are
+// []any, and objects are map[string]any. Expiry is truncated to microseconds
+// in JSON and to nanoseconds in binary.
+type Identity struct {
+	Subject string
+	Issuer  string
+	Scopes  []string
+	Claims  map[string]any // JSON-compatible values
+	Expiry  time.Time      // zero if the identity doesn't expire
+	Actor   *Identity      // acting on the subject's behalf, if any (see WithImpersonation)
+	Chain   []string       // services that propagated the identity, first to last (see IdentityPropagatorConfig)
+}
+
+// EncodeIdentity serializes an Identity as a binary connectauth.v1.Identity
+// protobuf message. It fails if the claims aren't JSON-compatible.
+func EncodeIdentity(identity *Identity) ([]byte, error) {
+	msg, err := identity.toProto()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// DecodeIdentity parses a binary connectauth.v1.Identity protobuf message.
+func DecodeIdentity(data []byte) (*Identity, error) {
+	var msg connectauthv1.Identity
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("decode identity: %w", err)
+	}
+	return identityFromProto(&msg), nil
+}
+
+// MarshalJSON implements json.Marshaler using the canonical JSON mapping of
+// connectauth.v1.Identity, so that audit events and other JSON records share
+// a schema with propagated identities.
+func (i *Identity) MarshalJSON() ([]byte, error) {
+	msg, err := i.toProto()
+	if err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(msg)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the output of
+// MarshalJSON.
+func (i *Identity) UnmarshalJSON(data []byte) error {
+	var msg connectauthv1.Identity
+	if err := protojson.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("decode identity: %w", err)
+	}
+	*i = *identityFromProto(&msg)
+	return nil
+}
+
+func (i *Identity) toProto() (*connectauthv1.Identity, error) {
+	msg := &connectauthv1.Identity{
+		Subject: i.Subject,
+		Issuer:  i.Issuer,
+		Scopes:  i.Scopes,
+		Chain:   i.Chain,
+	}
+	if len(i.Claims) > 0 {
+		claims, err := structpb.NewStruct(i.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("encode identity claims: %w", err)
+		}
+		msg.Claims = claims
+	}
+	if !i.Expiry.IsZero() {
+		msg.ExpireTime = timestamppb.New(i.Expiry)
+	}
+	if i.Actor != nil {
+		actor, err := i.Actor.toProto()
+		if err != nil {
+			return nil, err
+		}
+		msg.Actor = actor
+	}
+	return msg, nil
+}
+
+func identityFromProto(msg *connectauthv1.Identity) *Identity {
+	identity := &Identity{
+		Subject: msg.GetSubject(),
+		Issuer:  msg.GetIssuer(),
+		Scopes:  msg.GetScopes(),
+		Chain:   msg.GetChain(),
+	}
+	if claims := msg.GetClaims(); len(claims.GetFields()) > 0 {
+		identity.Claims = claims.AsMap()
+	}
+	if msg.ExpireTime != nil {
+		identity.Expiry = msg.GetExpireTime().AsTime()
+	}
+	if msg.Actor != nil {
+		identity.Actor = identityFromProto(msg.GetActor())
+	}
+	return identity
+}
+
+// HasScope reports whether the identity was granted the scope.
+func (i *Identity) HasScope(scope string) bool {
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityOf converts authentication information to an Identity. Identities
+// are returned directly. Other information is converted using [SubjectOf]
+// and, if it has one, its Expiry method; IdentityOf returns nil if the
+// information doesn't have a subject.
+func IdentityOf(info any) *Identity {
+	switch i := info.(type) {
+	case *Identity:
+		return i
+	case Identity:
+		return &i
+	}
+	subject := SubjectOf(info)
+	if subject == "" {
+		return nil
+	}
+	return &Identity{Subject: subject, Expiry: expiryOf(info)}
+}
+
+// signIdentityToken signs an IdentityToken with the current key (see
+// [Keyring.SignValue]).
+func signIdentityToken(keyring *Keyring, identity *Identity, audience string, use connectauthv1.TokenUse, expires time.Time) (string, error) {
+	msg, err := identity.toProto()
+	if err != nil {
+		return "", err
+	}
+	value, err := proto.Marshal(&connectauthv1.IdentityToken{
+		Identity:   msg,
+		Audience:   audience,
+		ExpireTime: timestamppb.New(expires),
+		Use:        use,
+	})
+	if err != nil {
+		return "", err
+	}
+	return keyring.SignValue(value), nil
+}
+
+// tokenCheck describes the IdentityTokens a verifier accepts.
+type tokenCheck struct {
+	noun     string // in error messages
+	use      connectauthv1.TokenUse
+	audience string // if empty, any audience is accepted
+	now      time.Time
+}
+
+func verifyIdentityToken(keyring *Keyring, signed string, check tokenCheck) (*Identity, error) {
+	value, err := keyring.VerifyValue(signed)
+	if errors.Is(err, errMalformedSignedValue) {
+		return nil, ReasonErrorf(ReasonMalformedCredentials, "malformed %s", check.noun)
+	} else if err != nil {
+		return nil, ReasonErrorf(ReasonBadSignature, "invalid %s", check.noun)
+	}
+	var token connectauthv1.IdentityToken
+	if err := proto.Unmarshal(value, &token); err != nil || token.GetIdentity().GetSubject() == "" || token.ExpireTime == nil {
+		return nil, ReasonErrorf(ReasonMalformedCredentials, "malformed %s", check.noun)
+	}
+	if token.GetUse() != check.use {
+		return nil, ReasonErrorf(ReasonInvalidCredentials, "invalid %s", check.noun)
+	}
+	if !check.now.Before(token.GetExpireTime().AsTime()) {
+		return nil, ReasonErrorf(ReasonExpired, "%s expired", check.noun)
+	}
+	if check.audience != "" && token.GetAudience() != check.audience {
+		return nil, ReasonErrorf(ReasonWrongAudience, "%s is for another service", check.noun)
+	}
+	return identityFromProto(token.GetIdentity()), nil
+}
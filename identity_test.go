@@ -0,0 +1,59 @@
+package connectauth
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestIdentityOf(t *testing.T) {
+	expires := time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC)
+	id := &Identity{Subject: hero, Scopes: []string{"read"}, Expiry: expires}
+	attest.Equal(t, IdentityOf(id), id)
+	attest.Equal(t, IdentityOf(*id), id)
+	attest.Equal(t, IdentityOf(hero), &Identity{Subject: hero})
+	attest.Zero(t, IdentityOf(expiringInfo{name: hero, expires: expires})) // no subject
+	attest.Zero(t, IdentityOf(nil))
+	attest.Zero(t, IdentityOf(42))
+
+	attest.Equal(t, SubjectOf(id), hero)
+	attest.Equal(t, SubjectOf((*Identity)(nil)), "")
+	attest.Equal(t, expiryOf(id), expires)
+	attest.True(t, id.HasScope("read"))
+	attest.False(t, id.HasScope("write"))
+}
+
+func TestIdentityCodec(t *testing.T) {
+	identity := &Identity{
+		Subject: hero,
+		Issuer:  "https://accounts.example.com",
+		Scopes:  []string{"read", "write"},
+		Claims:  map[string]any{"tenant": "acme", "level": 3.0, "groups": []any{"admins"}},
+		Expiry:  time.Date(2023, 8, 1, 12, 0, 0, 0, time.UTC),
+		Actor:   &Identity{Subject: "support", Issuer: "https://accounts.example.com"},
+		Chain:   []string{"gateway", "orders"},
+	}
+	data, err := EncodeIdentity(identity)
+	attest.Ok(t, err)
+	decoded, err := DecodeIdentity(data)
+	attest.Ok(t, err)
+	attest.Equal(t, decoded, identity)
+
+	data, err = json.Marshal(identity)
+	attest.Ok(t, err)
+	attest.Subsequence(t, string(data), `"expireTime":"2023-08-01T12:00:00Z"`)
+	var fromJSON Identity
+	attest.Ok(t, json.Unmarshal(data, &fromJSON))
+	attest.Equal(t, &fromJSON, identity)
+
+	minimal, err := DecodeIdentity(nil)
+	attest.Ok(t, err)
+	attest.Equal(t, minimal, &Identity{})
+
+	_, err = EncodeIdentity(&Identity{Subject: hero, Claims: map[string]any{"ch": make(chan int)}})
+	attest.Error(t, err)
+	_, err = DecodeIdentity([]byte("\xff"))
+	attest.Error(t, err)
+}
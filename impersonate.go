@@ -0,0 +1,95 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// ImpersonationConfig configures [WithImpersonation].
+type ImpersonationConfig struct {
+	// Header names the subject to impersonate. The default is
+	// "Impersonate-Subject".
+	Header string
+	// Allow reports whether the authenticated caller may impersonate the
+	// subject. Callers are typically granted a scope or role:
+	//
+	//	Allow: func(_ context.Context, actor *connectauth.Identity, _ string) bool {
+	//		return actor.HasScope("impersonate")
+	//	},
+	//
+	// Required.
+	Allow func(ctx context.Context, actor *Identity, subject string) bool
+	// Resolve loads the impersonated subject's Identity, for example to
+	// populate its scopes and claims. It returns nil and no error if the
+	// subject doesn't exist. The Actor of the returned Identity is always
+	// set to the caller. If Resolve returns a [*connect.Error], it's
+	// returned to the client; other errors are coded with
+	// [connect.CodeUnavailable]. The default returns an Identity with only a
+	// Subject.
+	Resolve func(ctx context.Context, subject string) (*Identity, error)
+}
+
+// WithImpersonation lets privileged callers, like support staff and
+// administrative tools, act on behalf of other subjects. Requests naming a
+// subject in the impersonation header are authenticated as usual, and then,
+// if the caller is allowed, as the subject: the authentication information
+// becomes an [*Identity] for the subject whose Actor is the caller (see
+// [IdentityOf]). Callers that aren't allowed are rejected with
+// [connect.CodePermissionDenied] and [ReasonPolicy]. Requests without the
+// header are unaffected.
+//
+// Every impersonation attempt, whether allowed or denied, is recorded by the
+// audit sinks with the caller's subject in [AuditEvent].Actor, even if audit
+// events are sampled (see [WithAuditSampler]). [New] panics if the
+// Authenticator doesn't have an audit sink (see [WithAuditSink]). It also
+// panics if Allow is nil.
+func WithImpersonation(ic ImpersonationConfig) Option {
+	if ic.Allow == nil {
+		panic("connectauth: impersonation requires an Allow function")
+	}
+	if ic.Header == "" {
+		ic.Header = "Impersonate-Subject"
+	}
+	ic.Header = http.CanonicalHeaderKey(ic.Header)
+	if ic.Resolve == nil {
+		ic.Resolve = func(_ context.Context, subject string) (*Identity, error) {
+			return &Identity{Subject: subject}, nil
+		}
+	}
+	return optionFunc(func(c *config) {
+		c.Impersonation = &ic
+	})
+}
+
+func (c *ImpersonationConfig) impersonate(ctx context.Context, info any, subject string) (*Identity, error) {
+	actor := IdentityOf(info)
+	if actor == nil || !c.Allow(ctx, actor, subject) {
+		return nil, NewReasonError(
+			connect.CodePermissionDenied,
+			ReasonPolicy,
+			fmt.Errorf("%q may not impersonate %q", SubjectOf(info), subject),
+		)
+	}
+	identity, err := c.Resolve(ctx, subject)
+	if err != nil {
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) {
+			return nil, err
+		}
+		return nil, NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, fmt.Errorf("resolve impersonated subject: %w", err))
+	}
+	if identity == nil {
+		return nil, NewReasonError(
+			connect.CodePermissionDenied,
+			ReasonPolicy,
+			fmt.Errorf("can't impersonate unknown subject %q", subject),
+		)
+	}
+	impersonated := *identity
+	impersonated.Actor = actor
+	return &impersonated, nil
+}
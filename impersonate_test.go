@@ -0,0 +1,110 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+type memoryAuditSink struct {
+	events []*AuditEvent
+}
+
+func (s *memoryAuditSink) Audit(_ context.Context, ev *AuditEvent) error {
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func TestImpersonation(t *testing.T) {
+	sink := &memoryAuditSink{}
+	auth := New(
+		func(_ context.Context, req *Request) (any, error) {
+			switch token, _ := BearerToken(req.Header); token {
+			case "admin-token":
+				return &Identity{Subject: "admin", Scopes: []string{"impersonate"}}, nil
+			case "user-token":
+				return "user", nil
+			}
+			return nil, ReasonErrorf(ReasonInvalidCredentials, "invalid token")
+		},
+		WithAuditSink(sink),
+		WithAuditSampler(func(*Event) bool { return false }),
+		WithImpersonation(ImpersonationConfig{
+			Allow: func(_ context.Context, actor *Identity, subject string) bool {
+				return actor.HasScope("impersonate")
+			},
+			Resolve: func(_ context.Context, subject string) (*Identity, error) {
+				switch subject {
+				case hero:
+					return &Identity{Subject: hero, Scopes: []string{"read"}}, nil
+				case "flaky":
+					return nil, errors.New("directory unavailable")
+				}
+				return nil, nil
+			},
+		}),
+	)
+	call := func(token, subject string) (any, error) {
+		header := http.Header{"Authorization": []string{"Bearer " + token}}
+		if subject != "" {
+			header.Set("Impersonate-Subject", subject)
+		}
+		ctx, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			Header:    header,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return GetInfo(ctx), nil
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		sink.events = nil
+		info, err := call("admin-token", hero)
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(&Identity{
+			Subject: hero,
+			Scopes:  []string{"read"},
+			Actor:   &Identity{Subject: "admin", Scopes: []string{"impersonate"}},
+		}))
+		attest.Equal(t, len(sink.events), 1) // despite sampling
+		attest.Equal(t, sink.events[0].Subject, hero)
+		attest.Equal(t, sink.events[0].Actor, "admin")
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		sink.events = nil
+		_, err := call("user-token", hero)
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		attest.Equal(t, ReasonOf(err), ReasonPolicy)
+		_, err = call("admin-token", "nobody")
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		_, err = call("admin-token", "flaky")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		attest.Equal(t, len(sink.events), 3)
+		attest.Equal(t, sink.events[0].Decision, DecisionDeny)
+		attest.Equal(t, sink.events[0].Actor, "user")
+	})
+
+	t.Run("not impersonating", func(t *testing.T) {
+		sink.events = nil
+		info, err := call("user-token", "")
+		attest.Ok(t, err)
+		attest.Equal(t, info, any("user"))
+		attest.Zero(t, sink.events) // sampled out
+	})
+}
+
+func TestImpersonationPanics(t *testing.T) {
+	allow := func(context.Context, *Identity, string) bool { return true }
+	attest.Panics(t, func() { WithImpersonation(ImpersonationConfig{}) })
+	attest.Panics(t, func() {
+		New(authenticate, WithImpersonation(ImpersonationConfig{Allow: allow}))
+	})
+}
@@ -0,0 +1,395 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        (unknown)
+// source: connectauth/v1/identity.proto
+
+package connectauthv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// TokenUse distinguishes the kinds of IdentityToken, so that tokens minted
+// for one purpose can't be replayed for another.
+type TokenUse int32
+
+const (
+	TokenUse_TOKEN_USE_UNSPECIFIED TokenUse = 0
+	// Propagated with a single request (connectauth.IdentityPropagator).
+	TokenUse_TOKEN_USE_PROPAGATION TokenUse = 1
+	// Handed to jobs or services that outlive the request
+	// (connectauth.Delegator).
+	TokenUse_TOKEN_USE_DELEGATION TokenUse = 2
+)
+
+// Enum value maps for TokenUse.
+var (
+	TokenUse_name = map[int32]string{
+		0: "TOKEN_USE_UNSPECIFIED",
+		1: "TOKEN_USE_PROPAGATION",
+		2: "TOKEN_USE_DELEGATION",
+	}
+	TokenUse_value = map[string]int32{
+		"TOKEN_USE_UNSPECIFIED": 0,
+		"TOKEN_USE_PROPAGATION": 1,
+		"TOKEN_USE_DELEGATION":  2,
+	}
+)
+
+func (x TokenUse) Enum() *TokenUse {
+	p := new(TokenUse)
+	*p = x
+	return p
+}
+
+func (x TokenUse) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TokenUse) Descriptor() protoreflect.EnumDescriptor {
+	return file_connectauth_v1_identity_proto_enumTypes[0].Descriptor()
+}
+
+func (TokenUse) Type() protoreflect.EnumType {
+	return &file_connectauth_v1_identity_proto_enumTypes[0]
+}
+
+func (x TokenUse) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TokenUse.Descriptor instead.
+func (TokenUse) EnumDescriptor() ([]byte, []int) {
+	return file_connectauth_v1_identity_proto_rawDescGZIP(), []int{0}
+}
+
+// An Identity describes an authenticated principal. It's the canonical,
+// cross-service representation of connectauth.Identity, used in propagated
+// identities and audit events.
+type Identity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Subject string           `protobuf:"bytes,1,opt,name=subject,proto3" json:"subject,omitempty"`
+	Issuer  string           `protobuf:"bytes,2,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	Scopes  []string         `protobuf:"bytes,3,rep,name=scopes,proto3" json:"scopes,omitempty"`
+	Claims  *structpb.Struct `protobuf:"bytes,4,opt,name=claims,proto3" json:"claims,omitempty"`
+	// Unset if the identity doesn't expire.
+	ExpireTime *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expire_time,json=expireTime,proto3" json:"expire_time,omitempty"`
+	// The principal acting on the subject's behalf, if any: for example, an
+	// administrator impersonating a user.
+	Actor *Identity `protobuf:"bytes,6,opt,name=actor,proto3" json:"actor,omitempty"`
+	// The services that propagated the identity, starting with the service
+	// that first authenticated it.
+	Chain []string `protobuf:"bytes,7,rep,name=chain,proto3" json:"chain,omitempty"`
+}
+
+func (x *Identity) Reset() {
+	*x = Identity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connectauth_v1_identity_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Identity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Identity) ProtoMessage() {}
+
+func (x *Identity) ProtoReflect() protoreflect.Message {
+	mi := &file_connectauth_v1_identity_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Identity.ProtoReflect.Descriptor instead.
+func (*Identity) Descriptor() ([]byte, []int) {
+	return file_connectauth_v1_identity_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Identity) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *Identity) GetIssuer() string {
+	if x != nil {
+		return x.Issuer
+	}
+	return ""
+}
+
+func (x *Identity) GetScopes() []string {
+	if x != nil {
+		return x.Scopes
+	}
+	return nil
+}
+
+func (x *Identity) GetClaims() *structpb.Struct {
+	if x != nil {
+		return x.Claims
+	}
+	return nil
+}
+
+func (x *Identity) GetExpireTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpireTime
+	}
+	return nil
+}
+
+func (x *Identity) GetActor() *Identity {
+	if x != nil {
+		return x.Actor
+	}
+	return nil
+}
+
+func (x *Identity) GetChain() []string {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+// An IdentityToken is the signed payload of a propagated identity.
+type IdentityToken struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Identity *Identity `protobuf:"bytes,1,opt,name=identity,proto3" json:"identity,omitempty"`
+	// The service the token is addressed to. Empty tokens are accepted by any
+	// service that doesn't require an audience.
+	Audience   string                 `protobuf:"bytes,2,opt,name=audience,proto3" json:"audience,omitempty"`
+	ExpireTime *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expire_time,json=expireTime,proto3" json:"expire_time,omitempty"`
+	// Tokens are only accepted for the use they were minted for.
+	Use TokenUse `protobuf:"varint,4,opt,name=use,proto3,enum=connectauth.v1.TokenUse" json:"use,omitempty"`
+}
+
+func (x *IdentityToken) Reset() {
+	*x = IdentityToken{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_connectauth_v1_identity_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IdentityToken) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdentityToken) ProtoMessage() {}
+
+func (x *IdentityToken) ProtoReflect() protoreflect.Message {
+	mi := &file_connectauth_v1_identity_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdentityToken.ProtoReflect.Descriptor instead.
+func (*IdentityToken) Descriptor() ([]byte, []int) {
+	return file_connectauth_v1_identity_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *IdentityToken) GetIdentity() *Identity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *IdentityToken) GetAudience() string {
+	if x != nil {
+		return x.Audience
+	}
+	return ""
+}
+
+func (x *IdentityToken) GetExpireTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpireTime
+	}
+	return nil
+}
+
+func (x *IdentityToken) GetUse() TokenUse {
+	if x != nil {
+		return x.Use
+	}
+	return TokenUse_TOKEN_USE_UNSPECIFIED
+}
+
+var File_connectauth_v1_identity_proto protoreflect.FileDescriptor
+
+var file_connectauth_v1_identity_proto_rawDesc = []byte{
+	0x0a, 0x1d, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68, 0x2f, 0x76, 0x31,
+	0x2f, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12,
+	0x0e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68, 0x2e, 0x76, 0x31, 0x1a,
+	0x1c, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2f, 0x73, 0x74, 0x72, 0x75, 0x63, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x88,
+	0x02, 0x0a, 0x08, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a, 0x07, 0x73,
+	0x75, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x75,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x69, 0x73, 0x73, 0x75, 0x65, 0x72, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x69, 0x73, 0x73, 0x75, 0x65, 0x72, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x63, 0x6f, 0x70, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x63, 0x6f, 0x70, 0x65, 0x73, 0x12, 0x2f, 0x0a, 0x06, 0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x52, 0x06,
+	0x63, 0x6c, 0x61, 0x69, 0x6d, 0x73, 0x12, 0x3b, 0x0a, 0x0b, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65,
+	0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x54,
+	0x69, 0x6d, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68,
+	0x2e, 0x76, 0x31, 0x2e, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x05, 0x61, 0x63,
+	0x74, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x05, 0x63, 0x68, 0x61, 0x69, 0x6e, 0x22, 0xca, 0x01, 0x0a, 0x0d, 0x49, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x34, 0x0a, 0x08, 0x69,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e,
+	0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x49,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x52, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74,
+	0x79, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x75, 0x64, 0x69, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x3b, 0x0a,
+	0x0b, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a,
+	0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x2a, 0x0a, 0x03, 0x75, 0x73,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63,
+	0x74, 0x61, 0x75, 0x74, 0x68, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x55, 0x73,
+	0x65, 0x52, 0x03, 0x75, 0x73, 0x65, 0x2a, 0x5a, 0x0a, 0x08, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x55,
+	0x73, 0x65, 0x12, 0x19, 0x0a, 0x15, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x55, 0x53, 0x45, 0x5f,
+	0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x19, 0x0a,
+	0x15, 0x54, 0x4f, 0x4b, 0x45, 0x4e, 0x5f, 0x55, 0x53, 0x45, 0x5f, 0x50, 0x52, 0x4f, 0x50, 0x41,
+	0x47, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x01, 0x12, 0x18, 0x0a, 0x14, 0x54, 0x4f, 0x4b, 0x45,
+	0x4e, 0x5f, 0x55, 0x53, 0x45, 0x5f, 0x44, 0x45, 0x4c, 0x45, 0x47, 0x41, 0x54, 0x49, 0x4f, 0x4e,
+	0x10, 0x02, 0x42, 0x49, 0x5a, 0x47, 0x67, 0x6f, 0x2e, 0x61, 0x6b, 0x73, 0x68, 0x61, 0x79, 0x73,
+	0x68, 0x61, 0x68, 0x2e, 0x6f, 0x72, 0x67, 0x2f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61,
+	0x75, 0x74, 0x68, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x65, 0x6e,
+	0x2f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68, 0x2f, 0x76, 0x31, 0x3b,
+	0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x61, 0x75, 0x74, 0x68, 0x76, 0x31, 0x62, 0x06, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_connectauth_v1_identity_proto_rawDescOnce sync.Once
+	file_connectauth_v1_identity_proto_rawDescData = file_connectauth_v1_identity_proto_rawDesc
+)
+
+func file_connectauth_v1_identity_proto_rawDescGZIP() []byte {
+	file_connectauth_v1_identity_proto_rawDescOnce.Do(func() {
+		file_connectauth_v1_identity_proto_rawDescData = protoimpl.X.CompressGZIP(file_connectauth_v1_identity_proto_rawDescData)
+	})
+	return file_connectauth_v1_identity_proto_rawDescData
+}
+
+var file_connectauth_v1_identity_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_connectauth_v1_identity_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_connectauth_v1_identity_proto_goTypes = []interface{}{
+	(TokenUse)(0),                 // 0: connectauth.v1.TokenUse
+	(*Identity)(nil),              // 1: connectauth.v1.Identity
+	(*IdentityToken)(nil),         // 2: connectauth.v1.IdentityToken
+	(*structpb.Struct)(nil),       // 3: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_connectauth_v1_identity_proto_depIdxs = []int32{
+	3, // 0: connectauth.v1.Identity.claims:type_name -> google.protobuf.Struct
+	4, // 1: connectauth.v1.Identity.expire_time:type_name -> google.protobuf.Timestamp
+	1, // 2: connectauth.v1.Identity.actor:type_name -> connectauth.v1.Identity
+	1, // 3: connectauth.v1.IdentityToken.identity:type_name -> connectauth.v1.Identity
+	4, // 4: connectauth.v1.IdentityToken.expire_time:type_name -> google.protobuf.Timestamp
+	0, // 5: connectauth.v1.IdentityToken.use:type_name -> connectauth.v1.TokenUse
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_connectauth_v1_identity_proto_init() }
+func file_connectauth_v1_identity_proto_init() {
+	if File_connectauth_v1_identity_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_connectauth_v1_identity_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Identity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_connectauth_v1_identity_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IdentityToken); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_connectauth_v1_identity_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_connectauth_v1_identity_proto_goTypes,
+		DependencyIndexes: file_connectauth_v1_identity_proto_depIdxs,
+		EnumInfos:         file_connectauth_v1_identity_proto_enumTypes,
+		MessageInfos:      file_connectauth_v1_identity_proto_msgTypes,
+	}.Build()
+	File_connectauth_v1_identity_proto = out.File
+	file_connectauth_v1_identity_proto_rawDesc = nil
+	file_connectauth_v1_identity_proto_goTypes = nil
+	file_connectauth_v1_identity_proto_depIdxs = nil
+}
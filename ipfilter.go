@@ -0,0 +1,150 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"connectrpc.com/connect"
+)
+
+// IPFilterConfig configures an [IPFilter].
+type IPFilterConfig struct {
+	// Allow lists the networks that may call the server. If it's empty, all
+	// networks not explicitly denied are allowed.
+	Allow []netip.Prefix
+	// Deny lists networks that may not call the server. Deny takes precedence
+	// over Allow, so it can carve exceptions out of allowed networks.
+	Deny []netip.Prefix
+}
+
+// An IPFilter allows or denies requests based on the client's IP address.
+// Use it with [All] to combine it with an AuthFunc that validates
+// credentials. When the server is behind a proxy or load balancer, also use
+// [WithTrustedProxies], so that the filter sees the client's address rather
+// than the proxy's.
+//
+// Networks are stored in a binary radix tree, so matching takes time
+// proportional to the address length, not the number of networks. IPv4
+// addresses mapped into IPv6 are matched as IPv4.
+type IPFilter struct {
+	allow *prefixSet // nil allows everything
+	deny  *prefixSet
+}
+
+// NewIPFilter constructs an IPFilter.
+func NewIPFilter(config IPFilterConfig) *IPFilter {
+	f := &IPFilter{deny: newPrefixSet(config.Deny)}
+	if len(config.Allow) > 0 {
+		f.allow = newPrefixSet(config.Allow)
+	}
+	return f
+}
+
+// Authenticate is an AuthFunc that rejects requests from denied addresses
+// with [connect.CodePermissionDenied] and [ReasonPolicy]. Allowed requests
+// return nil authentication information.
+func (f *IPFilter) Authenticate(_ context.Context, req *Request) (any, error) {
+	ip := clientIP(req.ClientAddr)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, NewReasonError(connect.CodePermissionDenied, ReasonPolicy, fmt.Errorf("unknown client address %q", req.ClientAddr))
+	}
+	if !f.Allows(addr) {
+		return nil, NewReasonError(connect.CodePermissionDenied, ReasonPolicy, fmt.Errorf("client address %s not allowed", ip))
+	}
+	return nil, nil
+}
+
+// Allows reports whether the filter allows an address.
+func (f *IPFilter) Allows(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if f.deny.contains(addr) {
+		return false
+	}
+	return f.allow == nil || f.allow.contains(addr)
+}
+
+// A prefixSet is a binary radix tree of network prefixes, with separate roots
+// for IPv4 and IPv6.
+type prefixSet struct {
+	v4, v6 prefixNode
+}
+
+type prefixNode struct {
+	children [2]*prefixNode
+	terminal bool // a prefix ends here
+}
+
+func newPrefixSet(prefixes []netip.Prefix) *prefixSet {
+	s := &prefixSet{}
+	for _, p := range prefixes {
+		s.add(p)
+	}
+	return s
+}
+
+func (s *prefixSet) add(p netip.Prefix) {
+	if !p.IsValid() {
+		return
+	}
+	addr, bits := p.Addr(), p.Bits()
+	if addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	node := s.root(addr)
+	raw, _ := addrBits(addr)
+	for i := 0; i < bits; i++ {
+		if node.terminal {
+			return // already covered by a shorter prefix
+		}
+		b := bit(raw, i)
+		if node.children[b] == nil {
+			node.children[b] = &prefixNode{}
+		}
+		node = node.children[b]
+	}
+	node.terminal = true
+	node.children = [2]*prefixNode{} // longer prefixes are redundant
+}
+
+func (s *prefixSet) contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	node := s.root(addr)
+	raw, n := addrBits(addr)
+	for i := 0; node != nil; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == n {
+			return false
+		}
+		node = node.children[bit(raw, i)]
+	}
+	return false
+}
+
+func (s *prefixSet) root(addr netip.Addr) *prefixNode {
+	if addr.Is4() {
+		return &s.v4
+	}
+	return &s.v6
+}
+
+// addrBits returns an address's bytes and its length in bits, without
+// allocating.
+func addrBits(addr netip.Addr) ([16]byte, int) {
+	if addr.Is4() {
+		var raw [16]byte
+		v4 := addr.As4()
+		copy(raw[:], v4[:])
+		return raw, 32
+	}
+	return addr.As16(), 128
+}
+
+func bit(raw [16]byte, i int) int {
+	return int(raw[i/8]>>(7-i%8)) & 1
+}
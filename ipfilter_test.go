@@ -0,0 +1,74 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestIPFilter(t *testing.T) {
+	filter := NewIPFilter(IPFilterConfig{
+		Allow: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("10.1.0.0/16"), // redundant
+			netip.MustParsePrefix("192.0.2.7/32"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+		Deny: []netip.Prefix{
+			netip.MustParsePrefix("10.9.0.0/16"),
+			netip.MustParsePrefix("2001:db8:bad::/48"),
+		},
+	})
+	tests := []struct {
+		addr  string
+		allow bool
+	}{
+		{"10.0.0.1", true},
+		{"10.1.2.3", true},
+		{"10.9.0.1", false},
+		{"11.0.0.1", false},
+		{"192.0.2.7", true},
+		{"192.0.2.8", false},
+		{"::ffff:10.0.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db8:bad::1", false},
+		{"2001:db9::1", false},
+		{"fe80::1%eth0", false},
+	}
+	for _, tt := range tests {
+		attest.Equal(t, filter.Allows(netip.MustParseAddr(tt.addr)), tt.allow, attest.Sprintf("%s", tt.addr))
+	}
+
+	ctx := context.Background()
+	_, err := filter.Authenticate(ctx, &Request{ClientAddr: "10.0.0.1:443"})
+	attest.Ok(t, err)
+	_, err = filter.Authenticate(ctx, &Request{ClientAddr: "10.9.0.1:443"})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, ReasonOf(err), ReasonPolicy)
+	_, err = filter.Authenticate(ctx, &Request{ClientAddr: "pipe"})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+
+	denyOnly := NewIPFilter(IPFilterConfig{Deny: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}})
+	attest.False(t, denyOnly.Allows(netip.MustParseAddr("192.0.2.1")))
+	attest.True(t, denyOnly.Allows(netip.MustParseAddr("2001:db8::1")))
+}
+
+func BenchmarkIPFilter(b *testing.B) {
+	var allow []netip.Prefix
+	for i := 0; i < 1000; i++ {
+		allow = append(allow, netip.MustParsePrefix(fmt.Sprintf("10.%d.%d.0/24", i/256, i%256)))
+	}
+	filter := NewIPFilter(IPFilterConfig{Allow: allow})
+	addr := netip.MustParseAddr("10.3.231.7")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !filter.Allows(addr) {
+			b.Fatal("address not allowed")
+		}
+	}
+}
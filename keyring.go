@@ -0,0 +1,146 @@
+package connectauth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Key is a shared secret, identified by a key ID. Key IDs are public:
+// they're embedded in signed values so verifiers know which key to use.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// A Keyring holds the shared secrets used to sign and verify values, like
+// HMAC request signatures, session cookies, or propagated identities. It
+// signs with its current key, but verifies with any of its keys, which
+// enables zero-downtime rotation:
+//
+//  1. Add a new key as a previous key everywhere, so that it's accepted.
+//  2. Once every server accepts it, promote it to the current key.
+//  3. Once values signed with the old key have expired, remove it.
+//
+// Keyrings are immutable and safe to use concurrently. To rotate keys in a
+// running server, load a new Keyring (for example, with a [Refresher]).
+type Keyring struct {
+	current Key
+	keys    map[string]Key
+}
+
+// NewKeyring constructs a Keyring that signs with the current key and
+// verifies with the current and previous keys. Key IDs must be unique and
+// non-empty, mustn't contain periods, and secrets must be at least 32 bytes.
+func NewKeyring(current Key, previous ...Key) (*Keyring, error) {
+	k := &Keyring{current: current, keys: make(map[string]Key, len(previous)+1)}
+	for _, key := range append([]Key{current}, previous...) {
+		switch {
+		case key.ID == "":
+			return nil, errors.New("key ID is empty")
+		case strings.Contains(key.ID, "."):
+			return nil, fmt.Errorf("key ID %q contains a period", key.ID)
+		case len(key.Secret) < 32:
+			return nil, fmt.Errorf("key %q is shorter than 32 bytes", key.ID)
+		}
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		k.keys[key.ID] = key
+	}
+	return k, nil
+}
+
+// ParseKeyring parses a Keyring from a comma-separated list of keys in
+// "id:secret" form, with base64-encoded secrets. The first key is current.
+// This format is convenient for environment variables and secret managers.
+func ParseKeyring(s string) (*Keyring, error) {
+	var keys []Key
+	for _, field := range strings.Split(s, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return nil, errors.New("keys must have the form id:secret")
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	return NewKeyring(keys[0], keys[1:]...)
+}
+
+// Current returns the key used for signing.
+func (k *Keyring) Current() Key {
+	return k.current
+}
+
+// Key returns the key with the supplied ID, if any.
+func (k *Keyring) Key(id string) (Key, bool) {
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// Sign computes an HMAC-SHA256 of the message using the current key, and
+// returns the key's ID and the MAC.
+func (k *Keyring) Sign(msg []byte) (keyID string, mac []byte) {
+	return k.current.ID, computeMAC(k.current.Secret, msg)
+}
+
+// Verify reports whether mac is a valid HMAC-SHA256 of the message, computed
+// with the identified key. The comparison takes constant time.
+func (k *Keyring) Verify(keyID string, msg, mac []byte) bool {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return false
+	}
+	return hmac.Equal(computeMAC(key.Secret, msg), mac)
+}
+
+// SignValue returns a tamper-proof encoding of the value, suitable for
+// cookies, headers, and URLs: the current key ID, the base64url-encoded
+// value, and the base64url-encoded MAC, separated by periods. The value
+// isn't encrypted.
+func (k *Keyring) SignValue(value []byte) string {
+	encoded := base64.RawURLEncoding.EncodeToString(value)
+	signed := k.current.ID + "." + encoded
+	_, mac := k.Sign([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac)
+}
+
+// VerifyValue verifies a string produced by SignValue with any key in the
+// Keyring, and returns the original value.
+func (k *Keyring) VerifyValue(signed string) ([]byte, error) {
+	i := strings.LastIndexByte(signed, '.')
+	if i < 0 {
+		return nil, errMalformedSignedValue
+	}
+	payload, encodedMAC := signed[:i], signed[i+1:]
+	keyID, encoded, ok := strings.Cut(payload, ".")
+	if !ok {
+		return nil, errMalformedSignedValue
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return nil, errMalformedSignedValue
+	}
+	if !k.Verify(keyID, []byte(payload), mac) {
+		return nil, errors.New("invalid signature")
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errMalformedSignedValue
+	}
+	return value, nil
+}
+
+var errMalformedSignedValue = errors.New("malformed signed value")
+
+func computeMAC(secret, msg []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write(msg)
+	return h.Sum(nil)
+}
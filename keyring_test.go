@@ -0,0 +1,78 @@
+package connectauth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestKeyring(t *testing.T) {
+	oldKey := Key{ID: "2023", Secret: bytes.Repeat([]byte("a"), 32)}
+	newKey := Key{ID: "2024", Secret: bytes.Repeat([]byte("b"), 32)}
+	before, err := NewKeyring(oldKey)
+	attest.Ok(t, err)
+	during, err := NewKeyring(oldKey, newKey) // new key accepted, not yet used
+	attest.Ok(t, err)
+	after, err := NewKeyring(newKey, oldKey)
+	attest.Ok(t, err)
+
+	signedOld := before.SignValue([]byte("alice"))
+	attest.True(t, strings.HasPrefix(signedOld, "2023."))
+	signedNew := after.SignValue([]byte("alice"))
+	attest.True(t, strings.HasPrefix(signedNew, "2024."))
+	for _, kr := range []*Keyring{during, after} {
+		for _, signed := range []string{signedOld, signedNew} {
+			value, err := kr.VerifyValue(signed)
+			attest.Ok(t, err)
+			attest.Equal(t, string(value), "alice")
+		}
+	}
+	_, err = before.VerifyValue(signedNew)
+	attest.Error(t, err)
+
+	// Tampering is detected.
+	forged := strings.Replace(signedOld, base64.RawURLEncoding.EncodeToString([]byte("alice")), base64.RawURLEncoding.EncodeToString([]byte("admin")), 1)
+	_, err = during.VerifyValue(forged)
+	attest.Error(t, err)
+	for _, malformed := range []string{"", "abc", "2023.abc", "2023.abc.!!!"} {
+		_, err = during.VerifyValue(malformed)
+		attest.Error(t, err)
+	}
+
+	id, mac := after.Sign([]byte("msg"))
+	attest.Equal(t, id, "2024")
+	attest.True(t, during.Verify(id, []byte("msg"), mac))
+	attest.False(t, during.Verify(id, []byte("other"), mac))
+	attest.False(t, during.Verify("unknown", []byte("msg"), mac))
+}
+
+func TestNewKeyringErrors(t *testing.T) {
+	secret := bytes.Repeat([]byte("a"), 32)
+	for _, keys := range [][]Key{
+		{{ID: "", Secret: secret}},
+		{{ID: "a.b", Secret: secret}},
+		{{ID: "short", Secret: []byte("short")}},
+		{{ID: "dup", Secret: secret}, {ID: "dup", Secret: secret}},
+	} {
+		_, err := NewKeyring(keys[0], keys[1:]...)
+		attest.Error(t, err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	secretA := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("a"), 32))
+	secretB := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("b"), 32))
+	kr, err := ParseKeyring("new:" + secretB + ", old:" + secretA)
+	attest.Ok(t, err)
+	attest.Equal(t, kr.Current().ID, "new")
+	_, ok := kr.Key("old")
+	attest.True(t, ok)
+
+	_, err = ParseKeyring("nocolon")
+	attest.Error(t, err)
+	_, err = ParseKeyring("id:not base64")
+	attest.Error(t, err)
+}
@@ -0,0 +1,267 @@
+package connectauth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// A Limit is a token bucket. Each authentication failure takes a token, and
+// tokens are replenished at Rate per second, up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// A LimitStore holds token buckets for a [FailureLimiter]. Use
+// [NewMemoryLimitStore] for a single server, or implement LimitStore with a
+// shared store (like Redis) so that all instances of a service enforce the
+// same limits.
+//
+// Implementations must be safe to call concurrently.
+type LimitStore interface {
+	// Take removes a token from the key's bucket. It returns the time until
+	// the bucket will next have a token, which is zero if tokens remain.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error)
+	// Delay returns the time until the key's bucket will next have a token,
+	// which is zero if the bucket isn't empty.
+	Delay(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error)
+}
+
+// FailureLimiterConfig configures a [FailureLimiter].
+type FailureLimiterConfig struct {
+	// Limit is the token bucket for each key. The default allows a burst of
+	// 10 failures, then one failure per minute.
+	Limit Limit
+	// Keys identify the buckets charged for each failure. Requests are
+	// rejected if any of their buckets is empty. The default limits each
+	// client IP, as if configured with:
+	//
+	//	Keys: []func(*connectauth.Request) string{connectauth.LimitKeyClientIP}
+	//
+	// Keys that return an empty string are skipped.
+	Keys []func(*Request) string
+	// Store holds the buckets. The default is a new in-memory store.
+	Store LimitStore
+	// Clock determines how quickly buckets refill. The default is
+	// [SystemClock].
+	Clock Clock
+}
+
+// A FailureLimiter rate limits failed authentication attempts. Once a
+// client has exhausted its budget of failures, its requests are rejected with
+// [connect.CodeResourceExhausted] and a retry delay (see [RetryErrorf])
+// without calling the AuthFunc, which cuts off online brute-force attacks
+// and keeps misbehaving clients from overloading upstream identity
+// providers.
+//
+// Only definitive failures, coded [connect.CodeUnauthenticated] or
+// [connect.CodePermissionDenied], count toward the limit, so successful
+// requests never spend the budget. Failures are charged once the AuthFunc
+// returns, so a client can have up to Burst plus its number of concurrent
+// requests fail before it's limited. If the store returns an error, the
+// limiter fails open.
+type FailureLimiter struct {
+	limit Limit
+	keys  []func(*Request) string
+	store LimitStore
+	now   func() time.Time
+}
+
+// NewFailureLimiter constructs a FailureLimiter. Use it with
+// [WithFailureLimiter].
+func NewFailureLimiter(config FailureLimiterConfig) *FailureLimiter {
+	if config.Limit.Burst <= 0 {
+		config.Limit = Limit{Rate: 1.0 / 60, Burst: 10}
+	}
+	if len(config.Keys) == 0 {
+		config.Keys = []func(*Request) string{LimitKeyClientIP}
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryLimitStore()
+	}
+	return &FailureLimiter{
+		limit: config.Limit,
+		keys:  config.Keys,
+		store: config.Store,
+		now:   nowFunc(config.Clock),
+	}
+}
+
+// WithFailureLimiter rate limits authentication failures. Exempt procedures
+// aren't limited.
+func WithFailureLimiter(limiter *FailureLimiter) Option {
+	return optionFunc(func(c *config) {
+		c.FailureLimiter = limiter
+	})
+}
+
+// LimitKeyClientIP limits failures per client IP.
+func LimitKeyClientIP(req *Request) string {
+	return clientIP(req.ClientAddr)
+}
+
+// LimitKeyCredential limits failures per presented credential, using a hash
+// of the Authorization header. It catches distributed attacks replaying a
+// single stolen or guessed credential from many addresses.
+func LimitKeyCredential(req *Request) string {
+	auth := headerValue(req.Header, "Authorization")
+	if auth == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(auth))
+	return hex.EncodeToString(sum[:16])
+}
+
+// check rejects the request if any of its buckets is empty.
+func (l *FailureLimiter) check(ctx context.Context, req *Request) error {
+	now := l.now()
+	var wait time.Duration
+	for i, key := range l.keys {
+		k := key(req)
+		if k == "" {
+			continue
+		}
+		d, err := l.store.Delay(ctx, strconv.Itoa(i)+":"+k, l.limit, now)
+		if err == nil && d > wait {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	return newRetryError(
+		connect.CodeResourceExhausted,
+		wait,
+		&reasonError{reason: ReasonRateLimited, err: errors.New("too many failed authentication attempts")},
+	)
+}
+
+// record charges a failure to each of the request's buckets.
+func (l *FailureLimiter) record(ctx context.Context, req *Request) {
+	now := l.now()
+	for i, key := range l.keys {
+		if k := key(req); k != "" {
+			_, _ = l.store.Take(ctx, strconv.Itoa(i)+":"+k, l.limit, now)
+		}
+	}
+}
+
+// A MemoryLimitStore is an in-memory [LimitStore]. Keys are chosen by
+// clients, so the store holds a limited number of buckets (see
+// [MemoryLimitStore.SetMaxKeys]) and forgets the one that was charged least
+// recently when it's full.
+type MemoryLimitStore struct {
+	mu      sync.Mutex
+	maxKeys int
+	buckets map[string]*list.Element
+	lru     list.List // of *bucket, most recently charged first
+}
+
+var _ LimitStore = (*MemoryLimitStore)(nil)
+
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryLimitStore constructs an empty MemoryLimitStore. Full buckets are
+// discarded, so memory use is proportional to the number of recently failing
+// keys, up to a default of 100,000.
+func NewMemoryLimitStore() *MemoryLimitStore {
+	return &MemoryLimitStore{
+		maxKeys: 100_000,
+		buckets: make(map[string]*list.Element),
+	}
+}
+
+// SetMaxKeys limits the number of buckets the store holds. If n isn't
+// positive, the limit is restored to the default of 100,000.
+func (s *MemoryLimitStore) SetMaxKeys(n int) {
+	if n <= 0 {
+		n = 100_000
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxKeys = n
+	for s.lru.Len() > n {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Take implements [LimitStore].
+func (s *MemoryLimitStore) Take(_ context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Buckets are ordered by their last charge, so the back is usually the
+	// fullest.
+	for el := s.lru.Back(); el != nil; el = s.lru.Back() {
+		b := el.Value.(*bucket)
+		b.refill(limit, now)
+		if b.tokens < float64(limit.Burst) {
+			break
+		}
+		s.remove(el)
+	}
+	var b *bucket
+	if el, ok := s.buckets[key]; ok {
+		s.lru.MoveToFront(el)
+		b = el.Value.(*bucket)
+	} else {
+		b = &bucket{key: key, tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = s.lru.PushFront(b)
+		for s.lru.Len() > s.maxKeys {
+			s.remove(s.lru.Back())
+		}
+	}
+	b.refill(limit, now)
+	if b.tokens >= 1 {
+		b.tokens--
+	}
+	return b.delay(limit), nil
+}
+
+// Delay implements [LimitStore].
+func (s *MemoryLimitStore) Delay(_ context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.buckets[key]
+	if !ok {
+		return 0, nil
+	}
+	b := el.Value.(*bucket)
+	b.refill(limit, now)
+	return b.delay(limit), nil
+}
+
+func (s *MemoryLimitStore) remove(el *list.Element) {
+	delete(s.buckets, s.lru.Remove(el).(*bucket).key)
+}
+
+func (b *bucket) refill(limit Limit, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * limit.Rate
+		if burst := float64(limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.updated = now
+	}
+}
+
+func (b *bucket) delay(limit Limit) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	if limit.Rate <= 0 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
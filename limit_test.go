@@ -0,0 +1,88 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	clock := newTestClock()
+	limiter := NewFailureLimiter(FailureLimiterConfig{
+		Limit: Limit{Rate: 1, Burst: 2},
+		Keys:  []func(*Request) string{LimitKeyClientIP, LimitKeyCredential},
+		Clock: clock,
+	})
+	var calls int
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		calls++
+		return authenticate(ctx, req)
+	}, WithFailureLimiter(limiter), WithExemptProcedures("/acme.v1.Svc/Login"))
+	call := func(procedure, addr, token string) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure:  procedure,
+			Protocol:   connect.ProtocolConnect,
+			ClientAddr: addr,
+			Header:     http.Header{"Authorization": []string{"Bearer " + token}},
+		})
+		return err
+	}
+
+	const attacker = "192.0.2.1:1234"
+	for i := 0; i < 2; i++ {
+		attest.Equal(t, connect.CodeOf(call("/acme.v1.Svc/Get", attacker, "wrong")), connect.CodeUnauthenticated)
+	}
+	err := call("/acme.v1.Svc/Get", attacker, passphrase)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+	attest.Equal(t, ReasonOf(err), ReasonRateLimited)
+	delay, ok := RetryDelay(err)
+	attest.True(t, ok)
+	attest.Equal(t, delay, time.Second)
+	attest.Equal(t, calls, 2) // the AuthFunc wasn't called
+
+	// Exempt procedures and other clients aren't limited.
+	attest.Ok(t, call("/acme.v1.Svc/Login", attacker, "wrong"))
+	attest.Ok(t, call("/acme.v1.Svc/Get", "192.0.2.2:1234", passphrase))
+	// The same bad credential from another address is limited.
+	err = call("/acme.v1.Svc/Get", "192.0.2.3:1234", "wrong")
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+
+	// Tokens are replenished over time.
+	clock.Advance(time.Second)
+	attest.Ok(t, call("/acme.v1.Svc/Get", attacker, passphrase))
+}
+
+func TestMemoryLimitStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLimitStore()
+	limit := Limit{Rate: 0.5, Burst: 1}
+	now := time.Now()
+	d, err := store.Delay(ctx, "k", limit, now)
+	attest.Ok(t, err)
+	attest.Zero(t, d)
+	d, err = store.Take(ctx, "k", limit, now)
+	attest.Ok(t, err)
+	attest.Equal(t, d, 2*time.Second)
+	d, _ = store.Delay(ctx, "k", limit, now.Add(time.Second))
+	attest.Equal(t, d, time.Second)
+	d, _ = store.Delay(ctx, "k", limit, now.Add(2*time.Second))
+	attest.Zero(t, d)
+
+	// Full buckets are discarded, and the store holds a limited number of
+	// keys.
+	_, _ = store.Take(ctx, "j", limit, now.Add(4*time.Second))
+	attest.Equal(t, len(store.buckets), 1)
+	store.SetMaxKeys(2)
+	for _, k := range []string{"a", "b", "c"} {
+		_, _ = store.Take(ctx, k, limit, now.Add(4*time.Second))
+	}
+	attest.Equal(t, len(store.buckets), 2)
+	d, _ = store.Delay(ctx, "a", limit, now.Add(4*time.Second))
+	attest.Zero(t, d) // forgotten
+	d, _ = store.Delay(ctx, "c", limit, now.Add(4*time.Second))
+	attest.Equal(t, d, 2*time.Second)
+}
@@ -0,0 +1,86 @@
+package connectauth
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// WithMaxHeaderBytes makes [Middleware] reject RPCs whose headers exceed the
+// supplied size before running the AuthFunc. Header size is the sum of the
+// lengths of all header keys and values. Oversized requests are rejected with
+// [connect.CodeResourceExhausted].
+func WithMaxHeaderBytes(n int) Option {
+	return optionFunc(func(c *config) {
+		c.MaxHeaderBytes = n
+	})
+}
+
+// WithMaxContentLength makes [Middleware] reject RPCs that declare a
+// Content-Length larger than the supplied limit before running the AuthFunc.
+// Oversized requests are rejected with [connect.CodeResourceExhausted].
+// Requests that don't declare a Content-Length (for example, streaming RPCs)
+// aren't affected: use [connect.WithReadMaxBytes] to limit the size of the
+// messages they contain.
+func WithMaxContentLength(n int64) Option {
+	return optionFunc(func(c *config) {
+		c.MaxContentLength = n
+	})
+}
+
+// WithContentTypes makes [Middleware] reject RPCs whose Content-Type isn't in
+// the supplied list before running the AuthFunc. Content types are compared
+// without parameters, so "application/json" also allows
+// "application/json; charset=utf-8". Requests with other content types are
+// rejected with [connect.CodeInvalidArgument].
+func WithContentTypes(types ...string) Option {
+	return optionFunc(func(c *config) {
+		if c.ContentTypes == nil {
+			c.ContentTypes = make(map[string]struct{}, len(types))
+		}
+		for _, t := range types {
+			c.ContentTypes[strings.ToLower(t)] = struct{}{}
+		}
+	})
+}
+
+// checkLimits enforces limits on the request before authentication.
+func (c *config) checkLimits(r *http.Request) error {
+	if c.MaxContentLength > 0 && r.ContentLength > c.MaxContentLength {
+		return connect.NewError(
+			connect.CodeResourceExhausted,
+			fmt.Errorf("request body exceeds %d bytes", c.MaxContentLength),
+		)
+	}
+	if c.MaxHeaderBytes > 0 {
+		var size int
+		for k, vals := range r.Header {
+			for _, v := range vals {
+				size += len(k) + len(v)
+			}
+		}
+		if size > c.MaxHeaderBytes {
+			return connect.NewError(
+				connect.CodeResourceExhausted,
+				fmt.Errorf("request headers exceed %d bytes", c.MaxHeaderBytes),
+			)
+		}
+	}
+	if c.ContentTypes != nil {
+		ct := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			mediaType = strings.ToLower(ct)
+		}
+		if _, ok := c.ContentTypes[mediaType]; !ok {
+			return connect.NewError(
+				connect.CodeInvalidArgument,
+				fmt.Errorf("content type %q isn't allowed", ct),
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package connectauth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestLimits(t *testing.T) {
+	newRequest := func(contentType string, contentLength int64, header http.Header) *http.Request {
+		r, err := http.NewRequest(http.MethodPost, "/acme.v1.Svc/Get", strings.NewReader("{}"))
+		attest.Ok(t, err)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		r.Header.Set("Content-Type", contentType)
+		r.ContentLength = contentLength
+		return r
+	}
+	config := newConfig([]Option{
+		WithMaxHeaderBytes(64),
+		WithMaxContentLength(1024),
+		WithContentTypes("application/proto", "application/grpc"),
+	})
+
+	attest.Ok(t, config.checkLimits(newRequest("application/proto", 2, nil)))
+	attest.Ok(t, config.checkLimits(newRequest("Application/GRPC", -1, nil)))
+
+	err := config.checkLimits(newRequest("application/proto", 2048, nil))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+
+	err = config.checkLimits(newRequest("application/proto", 2, http.Header{
+		"Authorization": []string{"Bearer " + strings.Repeat("x", 64)},
+	}))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+
+	err = config.checkLimits(newRequest("application/json; charset=utf-8", 2, nil))
+	attest.Equal(t, connect.CodeOf(err), connect.CodeInvalidArgument)
+
+	attest.Ok(t, newConfig(nil).checkLimits(newRequest("application/json", 1<<30, nil)))
+}
@@ -0,0 +1,107 @@
+package connectauth
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+)
+
+// A Lockdown is a runtime switch that rejects all requests, typically during
+// incident response when credentials may have been compromised. Configure it
+// with [WithLockdown], then engage and release it as needed. Lockdowns are
+// safe to use concurrently.
+type Lockdown struct {
+	policy atomic.Pointer[lockdownPolicy]
+}
+
+// A LockdownPolicy configures an engaged [Lockdown].
+type LockdownPolicy struct {
+	// Code and Message are used for the errors returned to rejected clients.
+	// If Code is zero, rejections use [connect.CodeUnavailable]. If Message is
+	// empty, rejections use "service is in lockdown".
+	Code    connect.Code
+	Message string
+	// AllowProcedures lists patterns for procedures that remain available
+	// during the lockdown, using the same syntax as [Router]. Procedures
+	// exempt from authentication are rejected unless they're listed here.
+	AllowProcedures []string
+	// AllowIdentity, if non-nil, lets some authenticated callers (for
+	// example, on-call engineers) continue to call any procedure. It receives
+	// the information returned by the AuthFunc.
+	AllowIdentity func(info any) bool
+}
+
+type lockdownPolicy struct {
+	err           *connect.Error
+	procedures    procedureMatcher[struct{}]
+	allowIdentity func(any) bool
+}
+
+// NewLockdown constructs a Lockdown. It's initially released.
+func NewLockdown() *Lockdown {
+	return &Lockdown{}
+}
+
+// Engage begins rejecting requests. If the lockdown is already engaged, Engage
+// replaces its policy. It returns an error if any of the policy's procedure
+// patterns are malformed.
+func (l *Lockdown) Engage(policy LockdownPolicy) error {
+	code := policy.Code
+	if code == 0 {
+		code = connect.CodeUnavailable
+	}
+	msg := policy.Message
+	if msg == "" {
+		msg = "service is in lockdown"
+	}
+	compiled := &lockdownPolicy{
+		err:           NewReasonError(code, ReasonPolicy, errors.New(msg)),
+		allowIdentity: policy.AllowIdentity,
+	}
+	for _, pattern := range policy.AllowProcedures {
+		if err := compiled.procedures.add(pattern, struct{}{}); err != nil && !errors.Is(err, errDuplicatePattern) {
+			return err
+		}
+	}
+	l.policy.Store(compiled)
+	return nil
+}
+
+// Release stops rejecting requests.
+func (l *Lockdown) Release() {
+	l.policy.Store(nil)
+}
+
+// Engaged reports whether the lockdown is currently engaged.
+func (l *Lockdown) Engaged() bool {
+	return l.policy.Load() != nil
+}
+
+// WithLockdown configures a [Lockdown]. While the lockdown is engaged, the
+// Authenticator rejects requests unless they're allowed by the lockdown's
+// policy.
+func WithLockdown(l *Lockdown) Option {
+	return optionFunc(func(c *config) {
+		c.Lockdown = l
+	})
+}
+
+// active returns the lockdown policy that applies to the procedure, if any.
+func (l *Lockdown) active(procedure string) *lockdownPolicy {
+	if l == nil {
+		return nil
+	}
+	policy := l.policy.Load()
+	if policy == nil {
+		return nil
+	}
+	if _, ok := policy.procedures.match(procedure); ok {
+		return nil
+	}
+	return policy
+}
+
+func (p *lockdownPolicy) allows(info any) bool {
+	return p.allowIdentity != nil && p.allowIdentity(info)
+}
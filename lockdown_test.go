@@ -0,0 +1,59 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestLockdown(t *testing.T) {
+	lockdown := NewLockdown()
+	auth := New(
+		authenticate,
+		WithLockdown(lockdown),
+		WithExemptProcedures("/grpc.health.v1.Health/*", "/acme.v1.Public/*"),
+	)
+	call := func(procedure, token string) error {
+		header := http.Header{}
+		if token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: procedure,
+			Protocol:  connect.ProtocolConnect,
+			Header:    header,
+		})
+		return err
+	}
+	attest.False(t, lockdown.Engaged())
+	attest.Ok(t, call("/acme.v1.Svc/Get", passphrase))
+	attest.Ok(t, call("/acme.v1.Public/Get", ""))
+
+	attest.Ok(t, lockdown.Engage(LockdownPolicy{
+		Code:            connect.CodePermissionDenied,
+		Message:         "credentials compromised",
+		AllowProcedures: []string{"/grpc.health.v1.Health/*"},
+	}))
+	attest.True(t, lockdown.Engaged())
+	err := call("/acme.v1.Svc/Get", passphrase)
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Subsequence(t, err.Error(), "credentials compromised")
+	attest.Error(t, call("/acme.v1.Public/Get", ""))
+	attest.Ok(t, call("/grpc.health.v1.Health/Check", ""))
+
+	attest.Ok(t, lockdown.Engage(LockdownPolicy{
+		AllowIdentity: func(info any) bool { return info == hero },
+	}))
+	attest.Ok(t, call("/acme.v1.Svc/Get", passphrase))
+	err = call("/grpc.health.v1.Health/Check", "")
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+
+	attest.Error(t, lockdown.Engage(LockdownPolicy{AllowProcedures: []string{"no-slash"}}))
+
+	lockdown.Release()
+	attest.False(t, lockdown.Engaged())
+	attest.Ok(t, call("/acme.v1.Public/Get", ""))
+}
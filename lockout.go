@@ -0,0 +1,370 @@
+package connectauth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// LockoutState is the lockout history of a single principal.
+type LockoutState struct {
+	Failures    int       // consecutive failures since the last success or lockout
+	Lockouts    int       // lockouts since the last success
+	LockedUntil time.Time // zero if never locked out
+}
+
+// A LockoutStore holds the lockout history of principals. Use
+// [NewMemoryLockoutStore] for a single server, or a shared store (like the
+// Redis implementation in connectauthredis) so that all instances of a
+// service enforce the same lockouts.
+//
+// Implementations must be safe to call concurrently.
+type LockoutStore interface {
+	// State returns the principal's history.
+	State(ctx context.Context, principal string) (LockoutState, error)
+	// AddFailure increments the principal's consecutive failures and returns
+	// the new count.
+	AddFailure(ctx context.Context, principal string) (int, error)
+	// Lock locks the principal out until the supplied time, resets its
+	// consecutive failures, and returns its number of lockouts, including
+	// this one.
+	Lock(ctx context.Context, principal string, until time.Time) (int, error)
+	// Reset clears the principal's history.
+	Reset(ctx context.Context, principal string) error
+}
+
+// A LockoutEvent describes a principal being locked out.
+type LockoutEvent struct {
+	Request   *Request
+	Principal string
+	Lockouts  int // the number of lockouts since the principal's last success
+	Until     time.Time
+}
+
+// LockoutConfig configures a [Lockout].
+type LockoutConfig struct {
+	// Principal identifies the account a request is trying to authenticate
+	// as. Requests that return an empty string aren't tracked. By default,
+	// the principal is the username from HTTP Basic credentials (see
+	// [BasicAuth]).
+	Principal func(*Request) string
+	// Threshold is the number of consecutive failures that trigger a
+	// lockout. The default is 5.
+	Threshold int
+	// Duration is the length of the first lockout. Each subsequent lockout
+	// before a successful authentication is twice as long, up to
+	// MaxDuration. The defaults are one minute and one hour.
+	Duration    time.Duration
+	MaxDuration time.Duration
+	// Store holds lockout history. The default is an in-memory store that
+	// forgets principals after a day of inactivity.
+	Store LockoutStore
+	// OnLockout, if non-nil, is called whenever a principal is locked out.
+	// It's typically used to notify security tooling.
+	OnLockout func(context.Context, *LockoutEvent)
+	// Clock determines when lockouts end. It's also used by the default
+	// store. The default is [SystemClock].
+	Clock Clock
+}
+
+// A Lockout locks principals out after repeated authentication failures,
+// for an escalating duration. Unlike a [FailureLimiter], which throttles
+// clients, a Lockout protects accounts: it stops password-guessing attacks
+// even when they're distributed across many addresses.
+//
+// While a principal is locked out, its requests are rejected with
+// [connect.CodeResourceExhausted] and a retry delay, without calling the
+// AuthFunc, so even the correct credentials are refused. A successful
+// authentication resets the principal's history. Only definitive failures,
+// coded [connect.CodeUnauthenticated] or [connect.CodePermissionDenied],
+// count toward the threshold. If the store returns an error, the lockout
+// fails open.
+//
+// Attackers can deliberately lock out other users' accounts, so lockouts
+// should be short and paired with monitoring via OnLockout.
+type Lockout struct {
+	principal func(*Request) string
+	threshold int
+	duration  time.Duration
+	max       time.Duration
+	store     LockoutStore
+	onLockout func(context.Context, *LockoutEvent)
+	now       func() time.Time
+}
+
+// NewLockout constructs a Lockout. Use it with [WithLockout].
+func NewLockout(config LockoutConfig) *Lockout {
+	if config.Principal == nil {
+		config.Principal = func(req *Request) string {
+			user, _, _ := BasicAuth(req.Header)
+			return user
+		}
+	}
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+	if config.Duration <= 0 {
+		config.Duration = time.Minute
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = max(config.Duration, time.Hour)
+	} else if config.MaxDuration < config.Duration {
+		config.MaxDuration = config.Duration
+	}
+	if config.Store == nil {
+		store := NewMemoryLockoutStore(24 * time.Hour)
+		store.SetClock(config.Clock)
+		config.Store = store
+	}
+	return &Lockout{
+		principal: config.Principal,
+		threshold: config.Threshold,
+		duration:  config.Duration,
+		max:       config.MaxDuration,
+		store:     config.Store,
+		onLockout: config.OnLockout,
+		now:       nowFunc(config.Clock),
+	}
+}
+
+// Reset clears a principal's history, ending any lockout. It's useful when
+// support staff have verified that a locked-out user is legitimate.
+func (l *Lockout) Reset(ctx context.Context, principal string) error {
+	return l.store.Reset(ctx, principal)
+}
+
+// WithLockout locks principals out after repeated authentication failures.
+// Exempt procedures aren't affected.
+func WithLockout(lockout *Lockout) Option {
+	return optionFunc(func(c *config) {
+		c.Lockout = lockout
+	})
+}
+
+// check rejects requests for locked-out principals. It returns the
+// principal and its state, for use by record.
+func (l *Lockout) check(ctx context.Context, req *Request) (string, LockoutState, error) {
+	principal := l.principal(req)
+	if principal == "" {
+		return "", LockoutState{}, nil
+	}
+	state, err := l.store.State(ctx, principal)
+	if err != nil {
+		return "", LockoutState{}, nil
+	}
+	if wait := state.LockedUntil.Sub(l.now()); wait > 0 {
+		return "", state, newRetryError(
+			connect.CodeResourceExhausted,
+			wait,
+			&reasonError{reason: ReasonLockedOut, err: errors.New("account temporarily locked")},
+		)
+	}
+	return principal, state, nil
+}
+
+// record updates the principal's history after authentication.
+func (l *Lockout) record(ctx context.Context, req *Request, principal string, state LockoutState, authErr error) {
+	if principal == "" {
+		return
+	}
+	if authErr == nil {
+		if state.Failures > 0 || state.Lockouts > 0 {
+			_ = l.store.Reset(ctx, principal)
+		}
+		return
+	}
+	if !isDefinitiveFailure(authErr) {
+		return
+	}
+	failures, err := l.store.AddFailure(ctx, principal)
+	if err != nil || failures < l.threshold {
+		return
+	}
+	d := l.duration << state.Lockouts
+	if d > l.max || d <= 0 { // also catches overflow
+		d = l.max
+	}
+	until := l.now().Add(d)
+	lockouts, err := l.store.Lock(ctx, principal, until)
+	if err != nil {
+		return
+	}
+	if l.onLockout != nil {
+		l.onLockout(ctx, &LockoutEvent{
+			Request:   req,
+			Principal: principal,
+			Lockouts:  lockouts,
+			Until:     until,
+		})
+	}
+}
+
+// A MemoryLockoutStore is an in-memory [LockoutStore]. Principals are
+// chosen by clients, so the store holds a limited number of principals that
+// have failed authentication but aren't locked out (see
+// [MemoryLockoutStore.SetMaxPrincipals]), and forgets the one that failed
+// least recently when it's full. Locked-out principals are held separately
+// and never forgotten before their lockouts end, so failing as other
+// principals can't end a lockout early. Each lockout takes a full threshold
+// of failures, so pair the Lockout with a [FailureLimiter] to bound how fast
+// clients can add them.
+type MemoryLockoutStore struct {
+	retention time.Duration
+	now       func() time.Time
+
+	mu            sync.Mutex
+	maxPrincipals int
+	states        map[string]*list.Element
+	lru           list.List // of *memoryLockout, most recently updated first
+	locked        map[string]*memoryLockout
+	calls         int
+}
+
+var _ LockoutStore = (*MemoryLockoutStore)(nil)
+
+type memoryLockout struct {
+	LockoutState
+	principal string
+	updated   time.Time
+}
+
+// NewMemoryLockoutStore constructs an empty MemoryLockoutStore. Principals
+// are forgotten once they haven't failed authentication for the retention
+// period.
+func NewMemoryLockoutStore(retention time.Duration) *MemoryLockoutStore {
+	return &MemoryLockoutStore{
+		retention:     retention,
+		now:           time.Now,
+		maxPrincipals: 100_000,
+		states:        make(map[string]*list.Element),
+		locked:        make(map[string]*memoryLockout),
+	}
+}
+
+// SetClock replaces the clock used to expire principals. It's intended for
+// tests, and must be called before the store is used. A nil Clock restores
+// [SystemClock].
+func (s *MemoryLockoutStore) SetClock(clock Clock) {
+	s.now = nowFunc(clock)
+}
+
+// SetMaxPrincipals limits the number of principals the store holds, not
+// counting those that are locked out. If n isn't positive, the limit is
+// restored to the default of 100,000.
+func (s *MemoryLockoutStore) SetMaxPrincipals(n int) {
+	if n <= 0 {
+		n = 100_000
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxPrincipals = n
+	s.evict()
+}
+
+// State implements [LockoutStore].
+func (s *MemoryLockoutStore) State(_ context.Context, principal string) (LockoutState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st := s.get(principal, s.now()); st != nil {
+		return st.LockoutState, nil
+	}
+	return LockoutState{}, nil
+}
+
+// AddFailure implements [LockoutStore].
+func (s *MemoryLockoutStore) AddFailure(_ context.Context, principal string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.getOrCreate(principal)
+	st.Failures++
+	return st.Failures, nil
+}
+
+// Lock implements [LockoutStore].
+func (s *MemoryLockoutStore) Lock(_ context.Context, principal string, until time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.getOrCreate(principal)
+	st.Failures = 0
+	st.Lockouts++
+	st.LockedUntil = until
+	if el, ok := s.states[principal]; ok {
+		// Hold the principal outside the LRU until the lockout ends.
+		s.lru.Remove(el)
+		delete(s.states, principal)
+		s.locked[principal] = st
+	}
+	return st.Lockouts, nil
+}
+
+// Reset implements [LockoutStore].
+func (s *MemoryLockoutStore) Reset(_ context.Context, principal string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.states[principal]; ok {
+		s.remove(el)
+	}
+	delete(s.locked, principal)
+	return nil
+}
+
+func (s *MemoryLockoutStore) get(principal string, now time.Time) *memoryLockout {
+	if st, ok := s.locked[principal]; ok {
+		if now.Before(st.LockedUntil) {
+			return st
+		}
+		// The lockout has ended, so the principal can be forgotten again.
+		delete(s.locked, principal)
+		s.states[principal] = s.lru.PushFront(st)
+		s.evict()
+	}
+	el, ok := s.states[principal]
+	if !ok {
+		return nil
+	}
+	st := el.Value.(*memoryLockout)
+	if now.Sub(st.updated) > s.retention && now.After(st.LockedUntil) {
+		s.remove(el)
+		return nil
+	}
+	return st
+}
+
+func (s *MemoryLockoutStore) getOrCreate(principal string) *memoryLockout {
+	now := s.now()
+	s.calls++
+	if s.calls%1024 == 0 {
+		for p := range s.states {
+			s.get(p, now)
+		}
+		for p := range s.locked {
+			s.get(p, now)
+		}
+	}
+	st := s.get(principal, now)
+	if st == nil {
+		st = &memoryLockout{principal: principal}
+		s.states[principal] = s.lru.PushFront(st)
+		s.evict()
+	} else if el, ok := s.states[principal]; ok {
+		s.lru.MoveToFront(el)
+	}
+	st.updated = now
+	return st
+}
+
+// evict forgets the least recently failing principals until the LRU is
+// within its limit.
+func (s *MemoryLockoutStore) evict() {
+	for s.lru.Len() > s.maxPrincipals {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *MemoryLockoutStore) remove(el *list.Element) {
+	delete(s.states, s.lru.Remove(el).(*memoryLockout).principal)
+}
@@ -0,0 +1,179 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestLockout(t *testing.T) {
+	clock := newTestClock()
+	var events []*LockoutEvent
+	lockout := NewLockout(LockoutConfig{
+		Threshold:   2,
+		Duration:    time.Minute,
+		MaxDuration: 3 * time.Minute,
+		OnLockout: func(_ context.Context, ev *LockoutEvent) {
+			events = append(events, ev)
+		},
+		Clock: clock,
+	})
+	var calls int
+	auth := New(func(_ context.Context, req *Request) (any, error) {
+		calls++
+		if _, pass, ok := BasicAuth(req.Header); ok && pass == "hunter2" {
+			return "alice", nil
+		}
+		return nil, Errorf("invalid credentials")
+	}, WithLockout(lockout))
+	call := func(user, pass string) error {
+		req := &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			Header:    http.Header{},
+		}
+		r := &http.Request{Header: req.Header}
+		r.SetBasicAuth(user, pass)
+		_, err := auth.authenticate(context.Background(), req)
+		return err
+	}
+	fail := func() {
+		t.Helper()
+		attest.Equal(t, connect.CodeOf(call("alice", "wrong")), connect.CodeUnauthenticated)
+	}
+	assertLocked := func(d time.Duration) {
+		t.Helper()
+		before := calls
+		err := call("alice", "hunter2")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+		attest.Equal(t, ReasonOf(err), ReasonLockedOut)
+		delay, ok := RetryDelay(err)
+		attest.True(t, ok)
+		attest.Equal(t, delay, d)
+		attest.Equal(t, calls, before) // the AuthFunc wasn't called
+	}
+
+	fail()
+	fail()
+	assertLocked(time.Minute)
+	attest.Equal(t, len(events), 1)
+	attest.Equal(t, events[0].Principal, "alice")
+	attest.Equal(t, events[0].Lockouts, 1)
+	attest.Equal(t, events[0].Until, clock.Now().Add(time.Minute))
+	// Other principals aren't affected.
+	attest.Equal(t, connect.CodeOf(call("bob", "wrong")), connect.CodeUnauthenticated)
+
+	// Lockouts escalate, up to the maximum.
+	clock.Advance(time.Minute)
+	fail()
+	fail()
+	assertLocked(2 * time.Minute)
+	clock.Advance(2 * time.Minute)
+	fail()
+	fail()
+	assertLocked(3 * time.Minute)
+	attest.Equal(t, len(events), 3)
+
+	// Success resets the principal's history.
+	clock.Advance(3 * time.Minute)
+	attest.Ok(t, call("alice", "hunter2"))
+	fail()
+	attest.Ok(t, call("alice", "hunter2"))
+	fail()
+	fail()
+	assertLocked(time.Minute)
+}
+
+func TestLockoutIgnoresTransientErrors(t *testing.T) {
+	lockout := NewLockout(LockoutConfig{
+		Threshold: 1,
+		Principal: func(*Request) string { return "alice" },
+	})
+	auth := New(func(context.Context, *Request) (any, error) {
+		return nil, connect.NewError(connect.CodeUnavailable, nil)
+	}, WithLockout(lockout))
+	for i := 0; i < 3; i++ {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+		})
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	}
+}
+
+func TestMemoryLockoutStore(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	store := NewMemoryLockoutStore(time.Hour)
+	store.SetClock(clock)
+
+	n, err := store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, n, 1)
+	n, err = store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, n, 2)
+	until := clock.Now().Add(2 * time.Hour)
+	n, err = store.Lock(ctx, "alice", until)
+	attest.Ok(t, err)
+	attest.Equal(t, n, 1)
+	state, err := store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, state, LockoutState{Lockouts: 1, LockedUntil: until})
+
+	// Principals aren't forgotten while they're locked out.
+	clock.Advance(90 * time.Minute)
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, state.Lockouts, 1)
+	clock.Advance(time.Hour)
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Zero(t, state)
+
+	_, err = store.AddFailure(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Ok(t, store.Reset(ctx, "alice"))
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Zero(t, state)
+
+	// Only the most recently failing principals are held.
+	store.SetMaxPrincipals(2)
+	for _, p := range []string{"alice", "bob", "alice", "carol"} {
+		_, err = store.AddFailure(ctx, p)
+		attest.Ok(t, err)
+	}
+	attest.Equal(t, len(store.states), 2)
+	state, err = store.State(ctx, "alice")
+	attest.Ok(t, err)
+	attest.Equal(t, state.Failures, 2)
+	state, err = store.State(ctx, "bob")
+	attest.Ok(t, err)
+	attest.Zero(t, state) // forgotten
+
+	// Failing as other principals doesn't evict locked-out principals.
+	until = clock.Now().Add(time.Minute)
+	_, err = store.Lock(ctx, "dave", until)
+	attest.Ok(t, err)
+	for _, p := range []string{"erin", "frank", "grace"} {
+		_, err = store.AddFailure(ctx, p)
+		attest.Ok(t, err)
+	}
+	attest.Equal(t, len(store.states), 2)
+	state, err = store.State(ctx, "dave")
+	attest.Ok(t, err)
+	attest.Equal(t, state.LockedUntil, until)
+
+	// Once the lockout ends, the principal is subject to the limit again.
+	clock.Advance(2 * time.Minute)
+	state, err = store.State(ctx, "dave")
+	attest.Ok(t, err)
+	attest.Equal(t, state.Lockouts, 1)
+	attest.Equal(t, len(store.locked), 0)
+	attest.Equal(t, len(store.states), 2)
+}
@@ -0,0 +1,156 @@
+package connectauth
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+
+	"connectrpc.com/connect"
+)
+
+// sensitiveHeaders are redacted from logs, even if they're allowed.
+var sensitiveHeaders = map[string]struct{}{
+	"Authorization":       {},
+	"Proxy-Authorization": {},
+	"Cookie":              {},
+	"Set-Cookie":          {},
+}
+
+// defaultLogHeaders are the protocol and tracing headers that are logged by
+// default. Applications often carry credentials in custom headers, like
+// X-Api-Key, so other headers are omitted.
+var defaultLogHeaders = map[string]struct{}{
+	"Connect-Protocol-Version": {},
+	"Connect-Timeout-Ms":       {},
+	"Content-Encoding":         {},
+	"Content-Type":             {},
+	"Grpc-Encoding":            {},
+	"Grpc-Timeout":             {},
+	"Te":                       {},
+	"Traceparent":              {},
+	"User-Agent":               {},
+	"X-Request-Id":             {},
+}
+
+// WithLogger logs the outcome of each authentication attempt. Failures are
+// logged at [slog.LevelInfo] and successes at [slog.LevelDebug]. Records
+// include the procedure, client IP, protocol, error class, failure reason
+// (see [Reason]), and some request headers: by default, only protocol and
+// tracing headers like Content-Type, User-Agent, and X-Request-Id are logged
+// (see [WithLogHeaders]). The values of headers that carry credentials
+// (Authorization, Proxy-Authorization, Cookie, and Set-Cookie) are always
+// redacted.
+//
+// Logs include the complete error, even when using [WithRedactedErrors]. Use
+// [WithLogSampler] to log only some events.
+func WithLogger(logger *slog.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.Observers = append(c.Observers, func(ctx context.Context, ev *Event) {
+			if c.LogSampler != nil && !c.LogSampler(ev) {
+				return
+			}
+			logEvent(ctx, logger, ev, c.LogHeaders)
+		})
+	})
+}
+
+// WithLogHeaders replaces the request headers logged by [WithLogger]. Other
+// headers are omitted, and calling WithLogHeaders with no arguments omits
+// them all. Don't list headers that carry credentials, like API keys.
+func WithLogHeaders(headers ...string) Option {
+	return optionFunc(func(c *config) {
+		c.LogHeaders = make(map[string]struct{}, len(headers))
+		for _, h := range headers {
+			c.LogHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	})
+}
+
+func logEvent(ctx context.Context, logger *slog.Logger, ev *Event, allow map[string]struct{}) {
+	level, msg := slog.LevelDebug, "authentication succeeded"
+	if ev.Err != nil {
+		level, msg = slog.LevelInfo, "authentication failed"
+	} else if ev.Exempt {
+		msg = "authentication skipped for exempt procedure"
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	req := ev.Request
+	attrs := []slog.Attr{
+		slog.String("procedure", req.Procedure),
+		slog.String("protocol", req.Protocol),
+		slog.String("client_ip", clientIP(req.ClientAddr)),
+		slog.Duration("duration", ev.Duration),
+	}
+	if len(req.Flags) > 0 {
+		attrs = append(attrs, slog.Any("flags", req.Flags))
+	}
+	if ev.Err != nil {
+		attrs = append(attrs,
+			slog.String("error_class", connect.CodeOf(ev.Err).String()),
+			slog.String("reason", string(ReasonOf(ev.Err))),
+			slog.String("error", ev.Err.Error()),
+		)
+	} else if subject := SubjectOf(ev.Info); subject != "" {
+		attrs = append(attrs, slog.String("subject", subject))
+	}
+	if allow == nil {
+		allow = defaultLogHeaders
+	}
+	attrs = append(attrs, slog.Any("header", redactedHeader{req.Header, allow}))
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// LogValue implements [slog.LogValuer]. Only the headers that [WithLogger]
+// logs by default are included, the values of headers that carry credentials
+// are redacted, and the body is omitted.
+func (r *Request) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("procedure", r.Procedure),
+		slog.String("protocol", r.Protocol),
+		slog.String("client_addr", r.ClientAddr),
+		slog.Any("header", redactedHeader{r.Header, defaultLogHeaders}),
+	)
+}
+
+// redactedHeader logs only the allowed headers.
+type redactedHeader struct {
+	header http.Header
+	allow  map[string]struct{}
+}
+
+func (h redactedHeader) LogValue() slog.Value {
+	keys := make([]string, 0, len(h.allow))
+	for k := range h.header {
+		if _, ok := h.allow[http.CanonicalHeaderKey(k)]; ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		vals := h.header[k]
+		if _, ok := sensitiveHeaders[http.CanonicalHeaderKey(k)]; ok {
+			attrs = append(attrs, slog.String(k, "REDACTED"))
+			continue
+		}
+		if len(vals) == 1 {
+			attrs = append(attrs, slog.String(k, vals[0]))
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, vals))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// clientIP strips the port from an address in IP:port format.
+func clientIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,93 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	auth := New(authenticate, WithLogger(logger), WithRedactedErrors())
+	call := func(authorization string) {
+		_, _ = auth.authenticate(context.Background(), &Request{
+			Procedure:  "/acme.v1.Svc/Get",
+			Protocol:   connect.ProtocolGRPC,
+			ClientAddr: "192.0.2.1:1234",
+			Header: http.Header{
+				"Authorization": []string{authorization},
+				"User-Agent":    []string{"grpc-go/1.0"},
+				"X-Api-Key":     []string{"sk-live-secret"},
+			},
+		})
+	}
+
+	call("Bearer wrong")
+	line := logs.String()
+	attest.Subsequence(t, line, `"level":"INFO"`)
+	attest.Subsequence(t, line, `"msg":"authentication failed"`)
+	attest.Subsequence(t, line, `"procedure":"/acme.v1.Svc/Get"`)
+	attest.Subsequence(t, line, `"client_ip":"192.0.2.1"`)
+	attest.Subsequence(t, line, `"protocol":"grpc"`)
+	attest.Subsequence(t, line, `"error_class":"unauthenticated"`)
+	attest.Subsequence(t, line, `is not the magic passphrase`) // unredacted
+	attest.Subsequence(t, line, `"User-Agent":"grpc-go/1.0"`)
+	attest.False(t, strings.Contains(line, "Authorization"), attest.Sprintf("header not omitted: %s", line))
+	attest.False(t, strings.Contains(line, "Bearer wrong"), attest.Sprintf("credential leaked: %s", line))
+	attest.False(t, strings.Contains(line, "sk-live-secret"), attest.Sprintf("credential leaked: %s", line))
+
+	logs.Reset()
+	call("Bearer " + passphrase)
+	line = logs.String()
+	attest.Subsequence(t, line, `"level":"DEBUG"`)
+	attest.Subsequence(t, line, `"subject":"`+hero+`"`)
+	attest.False(t, strings.Contains(line, passphrase), attest.Sprintf("credential leaked: %s", line))
+}
+
+func TestLogHeaders(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	req := &Request{
+		Procedure: "/acme.v1.Svc/Get",
+		Header: http.Header{
+			"Authorization": []string{"Bearer wrong"},
+			"User-Agent":    []string{"grpc-go/1.0"},
+			"X-Tenant":      []string{"acme"},
+		},
+	}
+
+	auth := New(authenticate, WithLogger(logger), WithLogHeaders("x-tenant", "authorization"))
+	_, _ = auth.authenticate(context.Background(), req)
+	line := logs.String()
+	attest.Subsequence(t, line, `"X-Tenant":"acme"`)
+	attest.Subsequence(t, line, `"Authorization":"REDACTED"`) // even when allowed
+	attest.False(t, strings.Contains(line, "User-Agent"), attest.Sprintf("header not omitted: %s", line))
+
+	logs.Reset()
+	auth = New(authenticate, WithLogger(logger), WithLogHeaders())
+	_, _ = auth.authenticate(context.Background(), req)
+	attest.False(t, strings.Contains(logs.String(), `"header"`), attest.Sprintf("headers not omitted: %s", logs.String()))
+}
+
+func TestRequestLogValue(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	logger.Info("request", "req", &Request{
+		Procedure: "/acme.v1.Svc/Get",
+		Header: http.Header{
+			"Cookie":     []string{"session=secret"},
+			"X-Api-Key":  []string{"secret"},
+			"User-Agent": []string{"grpc-go/1.0"},
+		},
+	})
+	attest.Subsequence(t, logs.String(), "req.procedure=/acme.v1.Svc/Get")
+	attest.Subsequence(t, logs.String(), "req.header.User-Agent=grpc-go/1.0")
+	attest.False(t, strings.Contains(logs.String(), "secret"))
+}
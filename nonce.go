@@ -0,0 +1,110 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// A NonceStore remembers the nonces of signed requests, so that verifiers
+// can reject replays. Use [NewMemoryNonceStore] for a single server, or a
+// shared store (like the Redis implementation in connectauthredis) when
+// requests may reach any instance of a service.
+//
+// Implementations must be safe to call concurrently.
+type NonceStore interface {
+	// Use records that a nonce has been used. It must be retained until
+	// expires, and never forgotten before then. Use reports whether the
+	// nonce was fresh; checking and recording must be atomic, so that
+	// concurrent replays can't both succeed.
+	Use(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+// CheckNonce rejects replayed requests. Verifiers for signed-request schemes
+// (like HMAC request signing, Hawk, or DPoP proofs) should call it after
+// verifying the signature, with a nonce covered by the signature and the
+// time at which the verifier would reject the signed request as stale (see
+// [Freshness]).
+// Nonces only need to be remembered until then, since older replays are
+// rejected anyway.
+//
+// Nonces are shared by all verifiers using a store, so verifiers should
+// prefix them with their scheme and, if nonces are only unique per key, the
+// key ID.
+//
+// Replays are rejected with [connect.CodeUnauthenticated] and
+// [ReasonReplayed]. If the store fails, CheckNonce fails closed with
+// [connect.CodeUnavailable].
+func CheckNonce(ctx context.Context, store NonceStore, nonce string, expires time.Time) error {
+	if nonce == "" {
+		return ReasonErrorf(ReasonMalformedCredentials, "missing nonce")
+	}
+	fresh, err := store.Use(ctx, nonce, expires)
+	if err != nil {
+		return NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, fmt.Errorf("check nonce: %w", err))
+	}
+	if !fresh {
+		return ReasonErrorf(ReasonReplayed, "request replayed")
+	}
+	return nil
+}
+
+// A MemoryNonceStore is an in-memory [NonceStore].
+type MemoryNonceStore struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+	calls  int
+}
+
+var _ NonceStore = (*MemoryNonceStore)(nil)
+
+// NewMemoryNonceStore constructs an empty MemoryNonceStore. Expired nonces
+// are removed periodically.
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{
+		now:    time.Now,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// SetClock replaces the clock used to expire nonces. It's intended for tests,
+// and must be called before the store is used. A nil Clock restores
+// [SystemClock].
+func (s *MemoryNonceStore) SetClock(clock Clock) {
+	s.now = nowFunc(clock)
+}
+
+// Use implements [NonceStore].
+func (s *MemoryNonceStore) Use(_ context.Context, nonce string, expires time.Time) (bool, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls%1024 == 0 {
+		for n, exp := range s.nonces {
+			if !now.Before(exp) {
+				delete(s.nonces, n)
+			}
+		}
+	}
+	if exp, ok := s.nonces[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	if now.Before(expires) {
+		s.nonces[nonce] = expires
+	}
+	return true, nil
+}
+
+// Len returns the number of nonces in the store, including any expired
+// nonces that haven't been removed yet.
+func (s *MemoryNonceStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.nonces)
+}
@@ -0,0 +1,72 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestCheckNonce(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	store := NewMemoryNonceStore()
+	store.SetClock(clock)
+	expires := clock.Now().Add(time.Minute)
+
+	attest.Ok(t, CheckNonce(ctx, store, "hmac:key1:abc", expires))
+	err := CheckNonce(ctx, store, "hmac:key1:abc", expires)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	attest.Equal(t, ReasonOf(err), ReasonReplayed)
+	attest.Ok(t, CheckNonce(ctx, store, "hmac:key2:abc", expires))
+
+	err = CheckNonce(ctx, store, "", expires)
+	attest.Equal(t, ReasonOf(err), ReasonMalformedCredentials)
+
+	err = CheckNonce(ctx, failingNonceStore{}, "abc", expires)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	attest.Equal(t, ReasonOf(err), ReasonUpstreamUnavailable)
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	ctx := context.Background()
+	clock := newTestClock()
+	store := NewMemoryNonceStore()
+	store.SetClock(clock)
+
+	fresh, err := store.Use(ctx, "abc", clock.Now().Add(time.Minute))
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+	fresh, err = store.Use(ctx, "abc", clock.Now().Add(time.Minute))
+	attest.Ok(t, err)
+	attest.False(t, fresh)
+
+	// Once a nonce expires, it may be reused.
+	clock.Advance(time.Minute)
+	fresh, err = store.Use(ctx, "abc", clock.Now().Add(time.Minute))
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+
+	// Already-expired nonces aren't stored.
+	fresh, err = store.Use(ctx, "def", clock.Now())
+	attest.Ok(t, err)
+	attest.True(t, fresh)
+	attest.Equal(t, store.Len(), 1)
+
+	// Expired nonces are swept.
+	clock.Advance(time.Hour)
+	for i := 0; i < 1024; i++ {
+		_, err := store.Use(ctx, "ghi", clock.Now())
+		attest.Ok(t, err)
+	}
+	attest.Equal(t, store.Len(), 0)
+}
+
+type failingNonceStore struct{}
+
+func (failingNonceStore) Use(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("oh no")
+}
@@ -0,0 +1,266 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// An Option configures an [Authenticator], [Middleware], [HTTPMiddleware], or
+// [Interceptor].
+type Option interface {
+	apply(*config)
+}
+
+// WithHandlerOptions configures the Connect handler options used to identify
+// RPC requests and marshal errors. Applications using [Middleware] must pass
+// the same handler options used when constructing Connect handlers.
+//
+// Interceptors ignore this option.
+func WithHandlerOptions(opts ...connect.HandlerOption) Option {
+	return optionFunc(func(c *config) {
+		c.HandlerOptions = append(c.HandlerOptions, opts...)
+	})
+}
+
+// WithExemptProcedures skips authentication for procedures matching any of
+// the supplied patterns. It's typically used for login, health check, and
+// reflection procedures, which must be callable without credentials. Requests
+// for exempt procedures don't have any authentication information attached to
+// their context.
+//
+// Patterns use the same syntax as [Router]. WithExemptProcedures panics if
+// any pattern is malformed.
+func WithExemptProcedures(patterns ...string) Option {
+	return optionFunc(func(c *config) {
+		for _, pattern := range patterns {
+			if err := c.Exempt.add(pattern, struct{}{}); err != nil && !errors.Is(err, errDuplicatePattern) {
+				panic("connectauth: " + err.Error())
+			}
+		}
+	})
+}
+
+// WithExemptHealthAndReflection skips authentication for the gRPC health and
+// server reflection services. Both connectrpc.com/grpchealth and
+// connectrpc.com/grpcreflect use these services, so this option also covers
+// Connect applications. It's equivalent to:
+//
+//	WithExemptProcedures(
+//		"/grpc.health.v1.Health/*",
+//		"/grpc.reflection.v1.ServerReflection/*",
+//		"/grpc.reflection.v1alpha.ServerReflection/*",
+//	)
+func WithExemptHealthAndReflection() Option {
+	return WithExemptProcedures(
+		"/grpc.health.v1.Health/*",
+		"/grpc.reflection.v1.ServerReflection/*",
+		"/grpc.reflection.v1alpha.ServerReflection/*",
+	)
+}
+
+// WithProtocols restricts the wire protocols that clients may use. Protocols
+// are identified by the constants in the connect package:
+// [connect.ProtocolConnect], [connect.ProtocolGRPC], and
+// [connect.ProtocolGRPCWeb]. By default, all protocols are allowed.
+//
+// Requests using other protocols are rejected with [connect.CodeUnimplemented]
+// before authentication, even if the procedure is exempt from authentication.
+// Use [WithProcedureProtocols] to override this option for particular
+// procedures.
+func WithProtocols(protocols ...string) Option {
+	return optionFunc(func(c *config) {
+		c.Protocols = protocols
+	})
+}
+
+// WithProcedureProtocols restricts the wire protocols that clients may use
+// when calling procedures that match the pattern. For example, an application
+// might require gRPC for its streaming procedures:
+//
+//	WithProcedureProtocols("/acme.stream.v1.StreamService/*", connect.ProtocolGRPC)
+//
+// Patterns use the same syntax as [Router], and procedure-specific protocols
+// take precedence over [WithProtocols]. WithProcedureProtocols panics if the
+// pattern is malformed or has already been configured.
+func WithProcedureProtocols(pattern string, protocols ...string) Option {
+	return optionFunc(func(c *config) {
+		if err := c.ProcedureProtocols.add(pattern, protocols); err != nil {
+			panic("connectauth: " + err.Error())
+		}
+	})
+}
+
+// WithDryRun evaluates authentication without ever rejecting requests.
+// Would-be failures are logged at [slog.LevelWarn], along with the procedure
+// and the reason for the failure. Requests that fail authentication proceed
+// without any authentication information attached to their context.
+//
+// Dry run mode is useful when adding authentication to an existing API: it
+// reveals which clients would be rejected without breaking them.
+func WithDryRun(logger *slog.Logger) Option {
+	return optionFunc(func(c *config) {
+		c.DryRun = logger
+	})
+}
+
+// WithAuthenticateAll makes [Middleware] authenticate non-RPC requests too,
+// rather than forwarding them directly to the wrapped handler. This is useful
+// when REST endpoints or static assets share a mux with Connect handlers.
+//
+// The [Request] for a non-RPC request has an empty Procedure and Protocol,
+// so exemptions and protocol policies don't apply. If authentication fails,
+// the middleware responds with a plain-text 401 Unauthorized; any metadata
+// attached to a [connect.Error] (for example, a WWW-Authenticate challenge)
+// is copied to the response headers.
+func WithAuthenticateAll() Option {
+	return optionFunc(func(c *config) {
+		c.AuthenticateAll = true
+	})
+}
+
+// WithCORSPreflight makes [Middleware] forward CORS preflight requests
+// directly to the wrapped handler without authentication. Browsers never send
+// credentials with preflight requests, so authenticating them breaks
+// Connect-Web and gRPC-Web clients calling cross-origin APIs. Preflights are
+// OPTIONS requests with both Origin and Access-Control-Request-Method
+// headers.
+//
+// Without [WithAuthenticateAll], preflight requests aren't recognized as RPCs
+// and are always forwarded. Even so, applications serving browsers should
+// use this option to make their intent explicit.
+func WithCORSPreflight() Option {
+	return optionFunc(func(c *config) {
+		c.SkipPreflight = true
+	})
+}
+
+// WithHTTPStatus customizes the HTTP status codes used when [Middleware]
+// rejects a non-RPC request (see [WithAuthenticateAll]) and when
+// [HTTPMiddleware] rejects any request. The function receives
+// the code of the error returned by the authentication pipeline.
+//
+// By default, [connect.CodeUnauthenticated] maps to 401 Unauthorized,
+// [connect.CodePermissionDenied] maps to 403 Forbidden, and other codes map to
+// the same HTTP status codes used by the Connect protocol. Failed RPCs always
+// use the status codes defined by their protocol.
+func WithHTTPStatus(status func(connect.Code) int) Option {
+	return optionFunc(func(c *config) {
+		c.HTTPStatus = status
+	})
+}
+
+// WithBufferedBody makes [Middleware] read the entire request body before
+// authentication, up to the supplied limit in bytes. The raw body is
+// available to the AuthFunc as [Request].Body, which lets it verify
+// signatures or digests that cover the request content. The buffered body is
+// then replayed to the wrapped handler. Requests with larger bodies are
+// rejected with [connect.CodeResourceExhausted].
+//
+// The body is buffered exactly as it was sent: the middleware doesn't
+// decompress or unmarshal it. Because the whole body must arrive before
+// authentication, this option is only appropriate for unary RPCs; client and
+// bidirectional streams would block until the client closes its side of the
+// stream. Interceptors ignore this option.
+func WithBufferedBody(limit int64) Option {
+	return optionFunc(func(c *config) {
+		c.BodyLimit = limit
+	})
+}
+
+// WithBaggage populates [Request].Baggage from the W3C baggage header. Baggage
+// is set by clients and may be large or contain sensitive data, so it's
+// omitted by default.
+func WithBaggage() Option {
+	return optionFunc(func(c *config) {
+		c.Baggage = true
+	})
+}
+
+type config struct {
+	HandlerOptions     []connect.HandlerOption
+	Exempt             procedureMatcher[struct{}]
+	Protocols          []string // nil allows all protocols
+	ProcedureProtocols procedureMatcher[[]string]
+	DryRun             *slog.Logger
+	AuthenticateAll    bool
+	SkipPreflight      bool
+	Enrichers          []Enricher
+	HTTPStatus         func(connect.Code) int
+	Observers          []func(context.Context, *Event)
+	RedactErrors       bool
+	FailureDelay       func(*Request) time.Duration
+	Lockdown           *Lockdown
+	BodyLimit          int64 // zero disables buffering
+	MaxHeaderBytes     int
+	MaxContentLength   int64
+	ContentTypes       map[string]struct{} // nil allows all
+	AuditSinks         []AuditSink
+	RequestIDHeader    string
+	LogSampler         Sampler
+	LogHeaders         map[string]struct{} // nil logs defaultLogHeaders
+	AuditSampler       Sampler
+	PprofLabels        bool
+	Baggage            bool
+	FailureLimiter     *FailureLimiter
+	Lockout            *Lockout
+	TrustedProxies     *prefixSet
+	ReputationCheckers []ReputationChecker
+	RequireTLS         bool
+	TLSPolicy          *TLSPolicy
+	Origins            *originSet
+	Honeytokens        *honeytokens
+	CSRF               *CSRFConfig
+	Impersonation      *ImpersonationConfig
+	Gateway            func(*http.Request) (string, bool)
+	ProcedureResolver  func(*http.Request) (string, bool)
+	StreamExpiry       *StreamExpiryConfig
+	StreamLimiter      *StreamLimiter
+	StreamRevalidation *StreamRevalidationConfig
+	StreamLifetime     *streamLifetime
+}
+
+func newConfig(opts []Option) *config {
+	c := config{
+		HTTPStatus:      httpStatus,
+		RequestIDHeader: "X-Request-Id",
+	}
+	for _, opt := range opts {
+		opt.apply(&c)
+	}
+	return &c
+}
+
+func (c *config) isExempt(procedure string) bool {
+	_, ok := c.Exempt.match(procedure)
+	return ok
+}
+
+func (c *config) checkProtocol(procedure, protocol string) error {
+	allowed, ok := c.ProcedureProtocols.match(procedure)
+	if !ok {
+		if c.Protocols == nil {
+			return nil
+		}
+		allowed = c.Protocols
+	}
+	for _, p := range allowed {
+		if p == protocol {
+			return nil
+		}
+	}
+	return NewReasonError(
+		connect.CodeUnimplemented,
+		ReasonPolicy,
+		fmt.Errorf("protocol %q isn't supported for procedure %q", protocol, procedure),
+	)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) { f(c) }
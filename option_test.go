@@ -0,0 +1,272 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestExemptProcedures(t *testing.T) {
+	exempt := WithExemptProcedures(
+		"/auth.v1.AuthService/Login",
+		"/health.v1.Health/*",
+		"/health.v1.Health/*", // duplicates are harmless
+	)
+
+	t.Run("authenticator", func(t *testing.T) {
+		auth := New(authenticate, exempt)
+		attest.True(t, auth.IsExempt("/auth.v1.AuthService/Login"))
+		attest.True(t, auth.IsExempt("/health.v1.Health/Check"))
+		attest.False(t, auth.IsExempt("/auth.v1.AuthService/Logout"))
+	})
+
+	t.Run("middleware", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" && GetInfo(r.Context()) != nil {
+				t.Error("exempt request has authentication info")
+			}
+			io.WriteString(w, "{}")
+		})
+		srv := memhttptest.New(t, New(authenticate, exempt).Middleware().Wrap(mux))
+		attest.Equal(t, callMiddleware(t, srv, "/auth.v1.AuthService/Login", nil), http.StatusOK)
+		attest.Equal(t, callMiddleware(t, srv, "/health.v1.Health/Check", nil), http.StatusOK)
+		attest.Equal(t, callMiddleware(t, srv, "/auth.v1.AuthService/Logout", nil), http.StatusUnauthorized)
+	})
+
+	t.Run("interceptor", func(t *testing.T) {
+		mux := http.NewServeMux()
+		for _, procedure := range []string{"/health.v1.Health/Check", "/auth.v1.AuthService/Logout"} {
+			mux.Handle(procedure, connect.NewUnaryHandler(
+				procedure,
+				func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+					return connect.NewResponse(&emptypb.Empty{}), nil
+				},
+				connect.WithInterceptors(NewInterceptor(authenticate, exempt)),
+			))
+		}
+		srv := memhttptest.New(t, mux)
+		call := func(procedure string) error {
+			client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+			_, err := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+			return err
+		}
+		attest.Ok(t, call("/health.v1.Health/Check"))
+		err := call("/auth.v1.AuthService/Logout")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	})
+}
+
+func TestExemptHealthAndReflection(t *testing.T) {
+	config := newConfig([]Option{WithExemptHealthAndReflection()})
+	for _, procedure := range []string{
+		"/grpc.health.v1.Health/Check",
+		"/grpc.health.v1.Health/Watch",
+		"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+		"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+	} {
+		attest.True(t, config.isExempt(procedure), attest.Sprintf("%s should be exempt", procedure))
+	}
+	for _, procedure := range []string{
+		"/grpc.health.v2.Health/Check",
+		"/acme.v1.Health/Check",
+		"",
+	} {
+		attest.False(t, config.isExempt(procedure), attest.Sprintf("%s shouldn't be exempt", procedure))
+	}
+}
+
+func TestProtocols(t *testing.T) {
+	config := newConfig([]Option{
+		WithProtocols(connect.ProtocolConnect, connect.ProtocolGRPC),
+		WithProcedureProtocols("/acme.stream.v1.StreamService/*", connect.ProtocolGRPC),
+	})
+	attest.Ok(t, config.checkProtocol("/acme.v1.Svc/Get", connect.ProtocolConnect))
+	attest.Ok(t, config.checkProtocol("/acme.v1.Svc/Get", connect.ProtocolGRPC))
+	err := config.checkProtocol("/acme.v1.Svc/Get", connect.ProtocolGRPCWeb)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+	attest.Ok(t, config.checkProtocol("/acme.stream.v1.StreamService/Tail", connect.ProtocolGRPC))
+	err = config.checkProtocol("/acme.stream.v1.StreamService/Tail", connect.ProtocolConnect)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeUnimplemented)
+
+	attest.Ok(t, newConfig(nil).checkProtocol("/acme.v1.Svc/Get", connect.ProtocolGRPCWeb))
+
+	// Protocol checks apply before exemptions.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "{}")
+	})
+	srv := memhttptest.New(t, New(
+		authenticate,
+		WithProtocols(connect.ProtocolGRPC),
+		WithExemptProcedures("/acme.v1.Svc/*"),
+	).Middleware().Wrap(mux))
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusNotFound)
+}
+
+func TestDryRun(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" && GetInfo(r.Context()) != nil {
+			t.Error("unauthenticated request has authentication info")
+		}
+		io.WriteString(w, "{}")
+	})
+	srv := memhttptest.New(t, New(authenticate, WithDryRun(logger)).Middleware().Wrap(mux))
+
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusOK)
+	attest.Subsequence(t, logs.String(), "would reject request")
+	attest.Subsequence(t, logs.String(), "procedure=/acme.v1.Svc/Get")
+	attest.Subsequence(t, logs.String(), "expected Bearer authentication scheme")
+
+	logs.Reset()
+	attest.Equal(
+		t,
+		callMiddleware(t, srv, "/acme.v1.Svc/Get", http.Header{"Authorization": []string{"Bearer " + passphrase}}),
+		http.StatusOK,
+	)
+	attest.Zero(t, logs.String())
+}
+
+func TestAuthenticateAll(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		assertInfo(t, r.Context())
+		io.WriteString(w, "ok")
+	})
+	srv := memhttptest.New(t, New(authenticate, WithAuthenticateAll()).Middleware().Wrap(mux))
+	get := func(authorization string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, srv.URL()+"/static/index.html", nil)
+		attest.Ok(t, err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		res, err := srv.Client().Do(req)
+		attest.Ok(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	res := get("")
+	attest.Equal(t, res.StatusCode, http.StatusUnauthorized)
+	attest.Equal(t, res.Header.Get("WWW-Authenticate"), "Bearer")
+	attest.Equal(t, get("Bearer "+passphrase).StatusCode, http.StatusOK)
+	// RPCs are still authenticated as usual.
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusUnauthorized)
+}
+
+func TestCORSPreflight(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	preflight := func(srv *memhttp.Server) int {
+		req, err := http.NewRequest(http.MethodOptions, srv.URL()+"/acme.v1.Svc/Get", nil)
+		attest.Ok(t, err)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+		res, err := srv.Client().Do(req)
+		attest.Ok(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	srv := memhttptest.New(t, New(authenticate, WithAuthenticateAll()).Middleware().Wrap(mux))
+	attest.Equal(t, preflight(srv), http.StatusUnauthorized)
+
+	srv = memhttptest.New(t, New(authenticate, WithAuthenticateAll(), WithCORSPreflight()).Middleware().Wrap(mux))
+	attest.Equal(t, preflight(srv), http.StatusNoContent)
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusUnauthorized)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	deny := func(ctx context.Context, r *Request) (any, error) {
+		if _, err := authenticate(ctx, r); err != nil {
+			return nil, err
+		}
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("admins only"))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	get := func(srv *memhttp.Server, header http.Header) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL()+"/admin", nil)
+		attest.Ok(t, err)
+		req.Header = header
+		res, err := srv.Client().Do(req)
+		attest.Ok(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	authorized := http.Header{"Authorization": []string{"Bearer " + passphrase}}
+
+	srv := memhttptest.New(t, New(deny, WithAuthenticateAll()).Middleware().Wrap(mux))
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusUnauthorized)
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", authorized), http.StatusForbidden)
+	attest.Equal(t, get(srv, http.Header{}), http.StatusUnauthorized)
+	attest.Equal(t, get(srv, authorized), http.StatusForbidden)
+
+	srv = memhttptest.New(t, New(
+		deny,
+		WithAuthenticateAll(),
+		WithHTTPStatus(func(code connect.Code) int {
+			if code == connect.CodePermissionDenied {
+				return http.StatusNotFound // hide the existence of admin pages
+			}
+			return http.StatusUnauthorized
+		}),
+	).Middleware().Wrap(mux))
+	attest.Equal(t, get(srv, authorized), http.StatusNotFound)
+}
+
+func TestBufferedBody(t *testing.T) {
+	checkDigest := func(_ context.Context, r *Request) (any, error) {
+		sum := sha256.Sum256(r.Body)
+		if r.Header.Get("Content-Digest") != hex.EncodeToString(sum[:]) {
+			return nil, Errorf("digest mismatch")
+		}
+		return hero, nil
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// The wrapped handler sees the whole body.
+		body, err := io.ReadAll(r.Body)
+		attest.Ok(t, err)
+		attest.Equal(t, string(body), "{}")
+		w.Write(body)
+	})
+	srv := memhttptest.New(t, New(checkDigest, WithBufferedBody(16)).Middleware().Wrap(mux))
+	sum := sha256.Sum256([]byte("{}"))
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusUnauthorized)
+	attest.Equal(
+		t,
+		callMiddleware(t, srv, "/acme.v1.Svc/Get", http.Header{"Content-Digest": []string{hex.EncodeToString(sum[:])}}),
+		http.StatusOK,
+	)
+
+	srv = memhttptest.New(t, New(checkDigest, WithBufferedBody(1)).Middleware().Wrap(mux))
+	attest.Equal(t, callMiddleware(t, srv, "/acme.v1.Svc/Get", nil), http.StatusTooManyRequests)
+}
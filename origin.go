@@ -0,0 +1,93 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// WithAllowedOrigins protects cookie-authenticated RPCs from cross-site
+// request forgery. Connect and gRPC-Web requests carrying cookies are
+// rejected with [connect.CodePermissionDenied] and [ReasonPolicy] unless
+// they come from one of the allowed origins. Origins have the form
+// "https://app.example.com" or, to allow all subdomains,
+// "https://*.example.com". Browsers send an Origin header on same-origin
+// requests too, so the list must include the server's own origin if it
+// serves a web application.
+//
+// The origin is taken from the Origin header or, if it's missing, the
+// Referer header. If both are missing, requests are allowed unless their
+// Sec-Fetch-Site header shows that a browser sent them from another site.
+// gRPC requests and requests without cookies aren't browser-initiated, so
+// they're always allowed. The check runs before the AuthFunc, but doesn't
+// apply to exempt procedures.
+func WithAllowedOrigins(origins ...string) Option {
+	return optionFunc(func(c *config) {
+		if c.Origins == nil {
+			c.Origins = &originSet{exact: make(map[string]struct{})}
+		}
+		for _, o := range origins {
+			c.Origins.add(o)
+		}
+	})
+}
+
+type originSet struct {
+	exact    map[string]struct{}
+	suffixes []originSuffix
+}
+
+// originSuffix matches subdomains, like "https://*.example.com".
+type originSuffix struct {
+	scheme string // "https://"
+	suffix string // ".example.com", possibly with a port
+}
+
+func (s *originSet) add(origin string) {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	if scheme, host, ok := strings.Cut(origin, "://*."); ok {
+		s.suffixes = append(s.suffixes, originSuffix{scheme: scheme + "://", suffix: "." + host})
+		return
+	}
+	s.exact[origin] = struct{}{}
+}
+
+func (s *originSet) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	if _, ok := s.exact[origin]; ok {
+		return true
+	}
+	for _, suf := range s.suffixes {
+		host, ok := strings.CutPrefix(origin, suf.scheme)
+		if ok && len(host) > len(suf.suffix) && strings.HasSuffix(host, suf.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *config) checkOrigin(req *Request) error {
+	if req.Protocol == connect.ProtocolGRPC || headerValue(req.Header, "Cookie") == "" {
+		return nil
+	}
+	origin := headerValue(req.Header, "Origin")
+	if origin == "" {
+		if ref, err := url.Parse(headerValue(req.Header, "Referer")); err == nil && ref.Host != "" {
+			origin = ref.Scheme + "://" + ref.Host
+		}
+	}
+	if origin == "" {
+		switch headerValue(req.Header, "Sec-Fetch-Site") {
+		case "cross-site", "same-site":
+			return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("cross-site request without origin"))
+		}
+		return nil
+	}
+	if !c.Origins.allows(origin) {
+		return NewReasonError(connect.CodePermissionDenied, ReasonPolicy, fmt.Errorf("origin %q not allowed", origin))
+	}
+	return nil
+}
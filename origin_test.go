@@ -0,0 +1,55 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestAllowedOrigins(t *testing.T) {
+	var calls int
+	auth := New(func(context.Context, *Request) (any, error) {
+		calls++
+		return "alice", nil
+	}, WithAllowedOrigins("https://app.example.com", "https://*.example.org/"))
+	tests := []struct {
+		name     string
+		protocol string
+		header   http.Header
+		allow    bool
+	}{
+		{"no cookie", connect.ProtocolConnect, http.Header{"Origin": {"https://evil.com"}}, true},
+		{"grpc", connect.ProtocolGRPC, http.Header{"Cookie": {"s=1"}, "Origin": {"https://evil.com"}}, true},
+		{"allowed", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Origin": {"https://APP.example.com"}}, true},
+		{"subdomain", connect.ProtocolGRPCWeb, http.Header{"Cookie": {"s=1"}, "Origin": {"https://a.b.example.org"}}, true},
+		{"bare domain", connect.ProtocolGRPCWeb, http.Header{"Cookie": {"s=1"}, "Origin": {"https://example.org"}}, false},
+		{"wrong scheme", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Origin": {"http://app.example.com"}}, false},
+		{"evil", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Origin": {"https://evil.com"}}, false},
+		{"suffix trick", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Origin": {"https://evilexample.org"}}, false},
+		{"null", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Origin": {"null"}}, false},
+		{"referer", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Referer": {"https://app.example.com/page?q=1"}}, true},
+		{"evil referer", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Referer": {"https://evil.com/"}}, false},
+		{"no origin", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}}, true},
+		{"cross-site fetch", connect.ProtocolConnect, http.Header{"Cookie": {"s=1"}, "Sec-Fetch-Site": {"cross-site"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			_, err := auth.authenticate(context.Background(), &Request{
+				Procedure: "/acme.v1.Svc/Get",
+				Protocol:  tt.protocol,
+				Header:    tt.header,
+			})
+			if tt.allow {
+				attest.Ok(t, err)
+				return
+			}
+			attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+			attest.Equal(t, ReasonOf(err), ReasonPolicy)
+			attest.Zero(t, calls)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// FlagDegraded is added to [Request].Flags when an [OutagePolicy] accepts a
+// request that it couldn't validate. The metrics packages report these
+// requests with a "degraded" outcome.
+const FlagDegraded = "degraded"
+
+// An OutageMode determines how an [OutagePolicy] handles requests that can't
+// be validated because a remote dependency is unavailable.
+type OutageMode int
+
+// Outage modes.
+const (
+	// OutageFailClosed rejects requests with [connect.CodeUnavailable].
+	OutageFailClosed OutageMode = iota
+	// OutageFailOpen accepts requests without validating their credentials.
+	// It's only appropriate when the cost of an outage exceeds the cost of
+	// serving unauthenticated requests, and handlers must check
+	// [IsDegraded] before doing anything sensitive.
+	OutageFailOpen
+	// OutageServeStale accepts requests whose credentials were successfully
+	// validated recently, reusing the earlier authentication information.
+	// Other requests are rejected, as with OutageFailClosed.
+	OutageServeStale
+)
+
+// OutagePolicyConfig configures an [OutagePolicy].
+type OutagePolicyConfig struct {
+	// Mode is the behavior during outages. The default is OutageFailClosed.
+	Mode OutageMode
+	// IsOutage reports whether an error returned by the wrapped AuthFunc
+	// means that a dependency is unavailable. By default, errors coded
+	// [connect.CodeUnavailable] or [connect.CodeDeadlineExceeded] and
+	// context deadline errors are outages.
+	IsOutage func(error) bool
+	// Degraded returns the authentication information for requests accepted
+	// by OutageFailOpen. The default returns nil.
+	Degraded func(req *Request, cause error) any
+	// StaleTTL is how long OutageServeStale remembers successful
+	// validations. The default is one hour.
+	StaleTTL time.Duration
+	// Store holds results for OutageServeStale. The default is a
+	// [ShardedCache] of Size entries (default 10,000).
+	Store Cache
+	Size  int
+	// Credential extracts the credential from a request for
+	// OutageServeStale. By default, it's the Authorization header.
+	Credential func(*Request) string
+	// OnDegraded, if non-nil, is called whenever a request is accepted or
+	// rejected because of an outage. Accepted is false when the request is
+	// rejected.
+	OnDegraded func(ctx context.Context, req *Request, mode OutageMode, accepted bool, cause error)
+	// Clock determines when remembered validations expire. The default is
+	// [SystemClock].
+	Clock Clock
+}
+
+// An OutagePolicy wraps an AuthFunc that depends on remote services, like a
+// token introspection endpoint, a JWKS host, or a policy decision point, and
+// decides what happens when they're unavailable. Requests accepted despite an
+// outage are marked with [FlagDegraded]; handlers can check [IsDegraded].
+//
+// Definitive failures aren't affected: an OutagePolicy only intervenes when
+// the wrapped AuthFunc couldn't reach a decision.
+type OutagePolicy struct {
+	auth       AuthFunc
+	mode       OutageMode
+	isOutage   func(error) bool
+	degraded   func(*Request, error) any
+	ttl        time.Duration
+	store      Cache
+	credential func(*Request) string
+	onDegraded func(context.Context, *Request, OutageMode, bool, error)
+	now        func() time.Time
+}
+
+// NewOutagePolicy constructs an OutagePolicy.
+func NewOutagePolicy(auth AuthFunc, config OutagePolicyConfig) *OutagePolicy {
+	if config.IsOutage == nil {
+		config.IsOutage = isOutage
+	}
+	if config.Degraded == nil {
+		config.Degraded = func(*Request, error) any { return nil }
+	}
+	if config.StaleTTL <= 0 {
+		config.StaleTTL = time.Hour
+	}
+	if config.Mode == OutageServeStale && config.Store == nil {
+		config.Store = NewShardedCache(config.Size)
+	}
+	if config.Credential == nil {
+		config.Credential = func(req *Request) string {
+			return headerValue(req.Header, "Authorization")
+		}
+	}
+	return &OutagePolicy{
+		auth:       auth,
+		mode:       config.Mode,
+		isOutage:   config.IsOutage,
+		degraded:   config.Degraded,
+		ttl:        config.StaleTTL,
+		store:      config.Store,
+		credential: config.Credential,
+		onDegraded: config.OnDegraded,
+		now:        nowFunc(config.Clock),
+	}
+}
+
+// Authenticate is an AuthFunc that calls the wrapped AuthFunc and applies
+// the policy if it fails because of an outage.
+func (p *OutagePolicy) Authenticate(ctx context.Context, req *Request) (any, error) {
+	var key string
+	if p.mode == OutageServeStale {
+		if credential := p.credential(req); credential != "" {
+			key = cacheKey(credential)
+		}
+	}
+	info, err := p.auth(ctx, req)
+	if err == nil {
+		if key != "" {
+			p.store.Set(ctx, key, &CacheEntry{Info: info, Expires: p.now().Add(p.ttl)})
+		}
+		return info, nil
+	}
+	if !p.isOutage(err) {
+		if key != "" && isDefinitiveFailure(err) {
+			p.store.Delete(ctx, key) // the credential may have been revoked
+		}
+		return nil, err
+	}
+	switch p.mode {
+	case OutageFailOpen:
+		p.accept(ctx, req, err)
+		return p.degraded(req, err), nil
+	case OutageServeStale:
+		if key != "" {
+			if entry, ok := p.store.Get(ctx, key); ok && entry.Err == nil && p.now().Before(entry.Expires) {
+				p.accept(ctx, req, err)
+				return entry.Info, nil
+			}
+		}
+	}
+	if p.onDegraded != nil {
+		p.onDegraded(ctx, req, p.mode, false, err)
+	}
+	if connect.CodeOf(err) == connect.CodeUnavailable {
+		return nil, err
+	}
+	return nil, NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, err)
+}
+
+func (p *OutagePolicy) accept(ctx context.Context, req *Request, cause error) {
+	req.Flags = append(req.Flags, FlagDegraded)
+	if p.onDegraded != nil {
+		p.onDegraded(ctx, req, p.mode, true, cause)
+	}
+}
+
+// IsDegraded reports whether the request was accepted despite an outage (see
+// [OutagePolicy]).
+func IsDegraded(ctx context.Context) bool {
+	return slices.Contains(GetFlags(ctx), FlagDegraded)
+}
+
+// HasFlag reports whether the request was flagged (see [Request].Flags).
+func (r *Request) HasFlag(flag string) bool {
+	return slices.Contains(r.Flags, flag)
+}
+
+func isOutage(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnavailable, connect.CodeDeadlineExceeded:
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,115 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestOutagePolicy(t *testing.T) {
+	var down bool
+	upstream := func(ctx context.Context, req *Request) (any, error) {
+		if down {
+			return nil, connect.NewError(connect.CodeUnavailable, errors.New("introspection endpoint down"))
+		}
+		return authenticate(ctx, req)
+	}
+	type degradedEvent struct {
+		Mode     OutageMode
+		Accepted bool
+	}
+
+	t.Run("fail closed", func(t *testing.T) {
+		down = true
+		policy := NewOutagePolicy(upstream, OutagePolicyConfig{})
+		_, err := policy.Authenticate(context.Background(), bearer(passphrase))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	})
+
+	t.Run("fail open", func(t *testing.T) {
+		down = true
+		var events []degradedEvent
+		policy := NewOutagePolicy(upstream, OutagePolicyConfig{
+			Mode: OutageFailOpen,
+			Degraded: func(*Request, error) any {
+				return "anonymous"
+			},
+			OnDegraded: func(_ context.Context, _ *Request, mode OutageMode, accepted bool, _ error) {
+				events = append(events, degradedEvent{mode, accepted})
+			},
+		})
+		var flagged context.Context
+		auth := New(policy.Authenticate, WithEnricher(Enricher{
+			Enrich: func(ctx context.Context, _ *Request, _ any) (context.Context, error) {
+				flagged = ctx
+				return ctx, nil
+			},
+		}))
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			Header:    http.Header{"Authorization": []string{"Bearer wrong"}},
+		})
+		attest.Ok(t, err)
+		attest.True(t, IsDegraded(flagged))
+		attest.Equal(t, GetInfo(flagged), any("anonymous"))
+		attest.Equal(t, events, []degradedEvent{{OutageFailOpen, true}})
+
+		// Definitive failures aren't affected.
+		down = false
+		_, err = policy.Authenticate(context.Background(), bearer("wrong"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	})
+
+	t.Run("serve stale", func(t *testing.T) {
+		down = false
+		clock := newTestClock()
+		var events []degradedEvent
+		policy := NewOutagePolicy(upstream, OutagePolicyConfig{
+			Mode:     OutageServeStale,
+			StaleTTL: time.Minute,
+			OnDegraded: func(_ context.Context, _ *Request, mode OutageMode, accepted bool, _ error) {
+				events = append(events, degradedEvent{mode, accepted})
+			},
+		})
+		policy.now = clock.Now
+
+		req := bearer(passphrase)
+		info, err := policy.Authenticate(context.Background(), req)
+		attest.Ok(t, err)
+		attest.False(t, req.HasFlag(FlagDegraded))
+
+		down = true
+		req = bearer(passphrase)
+		stale, err := policy.Authenticate(context.Background(), req)
+		attest.Ok(t, err)
+		attest.Equal(t, stale, info)
+		attest.True(t, req.HasFlag(FlagDegraded))
+
+		// Unknown credentials and expired entries are rejected.
+		_, err = policy.Authenticate(context.Background(), bearer("other"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		clock.Advance(time.Minute)
+		_, err = policy.Authenticate(context.Background(), bearer(passphrase))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		attest.Equal(t, events, []degradedEvent{
+			{OutageServeStale, true},
+			{OutageServeStale, false},
+			{OutageServeStale, false},
+		})
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		policy := NewOutagePolicy(func(context.Context, *Request) (any, error) {
+			return nil, context.DeadlineExceeded
+		}, OutagePolicyConfig{})
+		_, err := policy.Authenticate(context.Background(), bearer(passphrase))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+		attest.Equal(t, ReasonOf(err), ReasonUpstreamUnavailable)
+	})
+}
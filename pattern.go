@@ -0,0 +1,144 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var errDuplicatePattern = errors.New("registered twice")
+
+// procedureMatcher is a compiled set of procedure patterns. Patterns take one
+// of three forms:
+//   - An exact procedure, like "/acme.foo.v1.FooService/Bar".
+//   - All the methods of a service, like "/acme.foo.v1.FooService/*".
+//   - A glob compatible with [path.Match], like "/acme.*.v1.*/Get*".
+//
+// Exact matches take precedence over service matches, which take precedence
+// over globs. Globs are tried in the order they were added.
+//
+// Exact and service patterns are stored in maps. Globs are indexed by their
+// literal prefix (the portion before the first metacharacter) in a trie, so
+// matching a procedure only tries globs whose prefix it shares.
+type procedureMatcher[T any] struct {
+	exact    map[string]T
+	services map[string]T // keyed by "/acme.foo.v1.FooService/"
+	globs    []globEntry[T]
+	prefixes globNode
+}
+
+type globEntry[T any] struct {
+	pattern string
+	value   T
+}
+
+func (m *procedureMatcher[T]) add(pattern string, value T) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("procedure pattern %q must begin with a slash", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("procedure pattern %q is malformed: %w", pattern, err)
+	}
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		if m.exact == nil {
+			m.exact = make(map[string]T)
+		}
+		if _, ok := m.exact[pattern]; ok {
+			return fmt.Errorf("procedure pattern %q: %w", pattern, errDuplicatePattern)
+		}
+		m.exact[pattern] = value
+		return nil
+	}
+	if service := strings.TrimSuffix(pattern, "*"); isServicePrefix(service) {
+		if m.services == nil {
+			m.services = make(map[string]T)
+		}
+		if _, ok := m.services[service]; ok {
+			return fmt.Errorf("procedure pattern %q: %w", pattern, errDuplicatePattern)
+		}
+		m.services[service] = value
+		return nil
+	}
+	for _, g := range m.globs {
+		if g.pattern == pattern {
+			return fmt.Errorf("procedure pattern %q: %w", pattern, errDuplicatePattern)
+		}
+	}
+	m.prefixes.insert(literalPrefix(pattern), len(m.globs))
+	m.globs = append(m.globs, globEntry[T]{pattern: pattern, value: value})
+	return nil
+}
+
+func (m *procedureMatcher[T]) match(procedure string) (T, bool) {
+	if v, ok := m.exact[procedure]; ok {
+		return v, true
+	}
+	if len(m.services) > 0 {
+		if i := strings.LastIndexByte(procedure, '/'); i > 0 {
+			if v, ok := m.services[procedure[:i+1]]; ok {
+				return v, true
+			}
+		}
+	}
+	// Candidates are found in prefix order, not registration order, so we
+	// must consider all of them to find the first registered match.
+	best := -1
+	node := &m.prefixes
+	for i := 0; node != nil; i++ {
+		for _, idx := range node.globs {
+			if best >= 0 && idx > best {
+				continue
+			}
+			if ok, _ := path.Match(m.globs[idx].pattern, procedure); ok {
+				best = idx
+			}
+		}
+		if i == len(procedure) {
+			break
+		}
+		node = node.children[procedure[i]]
+	}
+	if best >= 0 {
+		return m.globs[best].value, true
+	}
+	var zero T
+	return zero, false
+}
+
+// globNode is a byte-wise trie of glob prefixes.
+type globNode struct {
+	children map[byte]*globNode
+	globs    []int // indexes of globs whose literal prefix ends here, ascending
+}
+
+func (n *globNode) insert(prefix string, idx int) {
+	for i := 0; i < len(prefix); i++ {
+		if n.children == nil {
+			n.children = make(map[byte]*globNode)
+		}
+		child, ok := n.children[prefix[i]]
+		if !ok {
+			child = &globNode{}
+			n.children[prefix[i]] = child
+		}
+		n = child
+	}
+	n.globs = append(n.globs, idx)
+}
+
+// literalPrefix returns the portion of a glob before its first metacharacter.
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// isServicePrefix checks whether s looks like "/acme.foo.v1.FooService/".
+func isServicePrefix(s string) bool {
+	if len(s) < 3 || s[0] != '/' || s[len(s)-1] != '/' {
+		return false
+	}
+	return !strings.ContainsAny(s[1:len(s)-1], `/*?[\`)
+}
@@ -0,0 +1,57 @@
+package connectauth
+
+import (
+	"fmt"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestProcedureMatcher(t *testing.T) {
+	var m procedureMatcher[string]
+	for _, pattern := range []string{
+		"/acme.foo.v1.FooService/Bar",
+		"/acme.foo.v1.FooService/*",
+		"/acme.*.v1.*/Get*", // registered before the broader glob, so it wins
+		"/acme.*/*",
+		"/*/Health?",
+		`/acme.bar.v1.BarService/\*`,
+	} {
+		attest.Ok(t, m.add(pattern, pattern))
+	}
+	tests := []struct {
+		procedure string
+		want      string
+	}{
+		{"/acme.foo.v1.FooService/Bar", "/acme.foo.v1.FooService/Bar"},
+		{"/acme.foo.v1.FooService/Baz", "/acme.foo.v1.FooService/*"},
+		{"/acme.bar.v1.BarService/GetBar", "/acme.*.v1.*/Get*"},
+		{"/acme.bar.v1.BarService/PutBar", "/acme.*/*"},
+		{"/acme.bar.v1.BarService/*", "/acme.*/*"},
+		{"/other.v1.Svc/Health1", "/*/Health?"},
+		{"/other.v1.Svc/Get", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := m.match(tt.procedure)
+		attest.Equal(t, got, tt.want, attest.Sprintf("procedure %q", tt.procedure))
+		attest.Equal(t, ok, tt.want != "", attest.Sprintf("procedure %q", tt.procedure))
+	}
+	attest.ErrorIs(t, m.add("/acme.*/*", ""), errDuplicatePattern)
+	attest.Error(t, m.add("no-slash", ""))
+	attest.Error(t, m.add("/acme[", ""))
+}
+
+func BenchmarkProcedureMatcher(b *testing.B) {
+	var m procedureMatcher[int]
+	for i := 0; i < 1000; i++ {
+		attest.Ok(b, m.add(fmt.Sprintf("/acme.svc%d.v1.*/Get*", i), i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := m.match("/acme.svc999.v1.Service/GetThing"); !ok {
+			b.Fatal("no match")
+		}
+	}
+}
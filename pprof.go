@@ -0,0 +1,41 @@
+package connectauth
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// WithPprofLabels attaches profiler labels to the goroutine handling each
+// authenticated request, so CPU and goroutine profiles can be segmented by
+// caller. The "procedure" label holds the procedure name and the "subject"
+// label holds the caller's identity, as reported by [SubjectOf]. The labels
+// are also attached to the request context, so goroutines started with
+// [pprof.Do] or [pprof.SetGoroutineLabels] inherit them.
+//
+// Profiles are often shared more widely than logs, so applications whose
+// subjects are sensitive (for example, email addresses) should implement
+// Subject to return an opaque identifier.
+func WithPprofLabels() Option {
+	return optionFunc(func(c *config) {
+		c.PprofLabels = true
+	})
+}
+
+// run calls f with ctx, applying profiler labels for the duration of the
+// call and bounding the context to the credential's expiry if configured.
+func (a *Authenticator) run(ctx context.Context, procedure string, f func(context.Context)) {
+	if a.config.StreamExpiry != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = a.config.StreamExpiry.bound(ctx)
+		defer cancel()
+	}
+	if !a.config.PprofLabels {
+		f(ctx)
+		return
+	}
+	labels := pprof.Labels(
+		"procedure", procedure,
+		"subject", SubjectOf(GetInfo(ctx)),
+	)
+	pprof.Do(ctx, labels, f)
+}
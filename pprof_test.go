@@ -0,0 +1,41 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"testing"
+
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp/memhttptest"
+)
+
+func TestPprofLabels(t *testing.T) {
+	labels := make(chan map[string]string, 1)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := make(map[string]string)
+		pprof.ForLabels(r.Context(), func(k, v string) bool {
+			got[k] = v
+			return true
+		})
+		labels <- got
+	})
+	srv := memhttptest.New(t, New(authenticate, WithPprofLabels()).Middleware().Wrap(handler))
+	status := callMiddleware(t, srv, "/acme.v1.Svc/Get", http.Header{
+		"Authorization": []string{"Bearer " + passphrase},
+	})
+	attest.Equal(t, status, http.StatusOK)
+	attest.Equal(t, <-labels, map[string]string{
+		"procedure": "/acme.v1.Svc/Get",
+		"subject":   hero,
+	})
+
+	// Without the option, no labels are attached.
+	var called bool
+	New(authenticate).run(context.Background(), "/acme.v1.Svc/Get", func(ctx context.Context) {
+		called = true
+		_, ok := pprof.Label(ctx, "procedure")
+		attest.False(t, ok)
+	})
+	attest.True(t, called)
+}
@@ -0,0 +1,227 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	connectauthv1 "go.akshayshah.org/connectauth/internal/gen/connectauth/v1"
+)
+
+// IdentityPropagatorConfig configures an [IdentityPropagator].
+type IdentityPropagatorConfig struct {
+	// Header carries the signed identity. The default is
+	// Connectauth-Identity.
+	Header string
+	// TTL limits how long a propagated identity is valid. It should cover
+	// the time between a client sending a request and the server
+	// authenticating it, plus any clock skew between them. Identities that
+	// expire sooner keep their own expiry. The default is one minute.
+	TTL time.Duration
+	// Audience, if set, is the name of the service authenticating
+	// propagated identities. Identities addressed to other services are
+	// rejected, so a compromised service can't replay the identities it
+	// receives against its peers.
+	Audience string
+	// Upstream, if set, authenticates the immediate peer: the service that
+	// sent the request, rather than the principal it's acting for.
+	// Propagated identities are only accepted from peers that Upstream
+	// authenticates, so clients that reach the service directly, perhaps
+	// through a misconfigured edge, can't spoof identities. Use
+	// [TrustedClientCerts] to trust services by their mTLS certificates, or
+	// an AuthFunc that verifies service tokens.
+	Upstream AuthFunc
+	// Untrusted, if set, authenticates requests from peers that Upstream
+	// rejects, ignoring any propagated identity. For example, services
+	// reachable both internally and from the public edge may validate
+	// end-user tokens directly. By default, requests from untrusted peers
+	// are rejected with [connect.CodePermissionDenied] and [ReasonPolicy].
+	Untrusted AuthFunc
+	// Service, if set, is the name of this service. It's appended to the
+	// Chain of the identities it propagates, so that each hop can see which
+	// services a request passed through: for example, a user's request
+	// reaching the billing service via the gateway and the orders service
+	// has a Chain of ["gateway", "orders"]. The chain is recorded in audit
+	// events (see [AuditEvent].Identity), but like the rest of the identity,
+	// it's asserted by services holding the keyring.
+	Service string
+	// Identity converts authentication information to the Identity sent
+	// downstream. It returns nil if the information shouldn't be
+	// propagated. The default is [IdentityOf].
+	Identity func(info any) *Identity
+	// Clock is used to stamp and check expiry. The default is [SystemClock].
+	Clock Clock
+}
+
+// An IdentityPropagator lets internal services trust authentication performed
+// upstream, without re-validating end-user credentials at every hop. Edge
+// services authenticate users as usual and propagate their identities with
+// ClientInterceptor:
+//
+//	propagator := connectauth.NewIdentityPropagator(keyring, connectauth.IdentityPropagatorConfig{})
+//	client := barv1connect.NewBarServiceClient(
+//		http.DefaultClient,
+//		"https://bar.internal",
+//		connect.WithInterceptors(propagator.ClientInterceptor("bar")),
+//	)
+//
+// Internal services authenticate the propagated identities:
+//
+//	propagator := connectauth.NewIdentityPropagator(keyring, connectauth.IdentityPropagatorConfig{
+//		Audience: "bar",
+//	})
+//	auth := connectauth.New(propagator.Authenticate)
+//
+// Propagated identities are connectauth.v1.IdentityToken protobuf messages
+// signed with the [Keyring] (see [Keyring.SignValue]). They're not
+// encrypted, so they shouldn't carry secrets. Every service sharing the
+// keyring can mint identities, so the keyring must only be shared with
+// trusted services.
+type IdentityPropagator struct {
+	keyring   *Keyring
+	header    string
+	ttl       time.Duration
+	audience  string
+	service   string
+	upstream  AuthFunc
+	untrusted AuthFunc
+	identity  func(any) *Identity
+	now       func() time.Time
+}
+
+// NewIdentityPropagator constructs an IdentityPropagator. It panics if the
+// keyring is nil.
+func NewIdentityPropagator(keyring *Keyring, config IdentityPropagatorConfig) *IdentityPropagator {
+	if keyring == nil {
+		panic("connectauth: identity propagation requires a Keyring")
+	}
+	if config.Header == "" {
+		config.Header = "Connectauth-Identity"
+	}
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+	if config.Identity == nil {
+		config.Identity = IdentityOf
+	}
+	return &IdentityPropagator{
+		keyring:   keyring,
+		header:    config.Header,
+		ttl:       config.TTL,
+		audience:  config.Audience,
+		service:   config.Service,
+		upstream:  config.Upstream,
+		untrusted: config.Untrusted,
+		identity:  config.Identity,
+		now:       nowFunc(config.Clock),
+	}
+}
+
+// ClientInterceptor returns a client interceptor that propagates the
+// identity attached to each request's context (see [GetInfo]) to the named
+// audience. Requests without an identity are sent without one, and any
+// identity header already set on them is removed.
+func (p *IdentityPropagator) ClientInterceptor(audience string) connect.Interceptor {
+	return &propagationInterceptor{propagator: p, audience: audience}
+}
+
+// Sign returns the signed header value propagating an identity to the
+// audience. Most applications should use ClientInterceptor instead.
+func (p *IdentityPropagator) Sign(identity *Identity, audience string) (string, error) {
+	expires := p.now().Add(p.ttl)
+	if !identity.Expiry.IsZero() && identity.Expiry.Before(expires) {
+		expires = identity.Expiry
+	}
+	if p.service != "" {
+		hop := *identity
+		hop.Chain = append(identity.Chain[:len(identity.Chain):len(identity.Chain)], p.service)
+		identity = &hop
+	}
+	return signIdentityToken(p.keyring, identity, audience, connectauthv1.TokenUse_TOKEN_USE_PROPAGATION, expires)
+}
+
+// Authenticate is an [AuthFunc] that verifies propagated identities. It
+// returns an *Identity, or if the peer isn't trusted and the propagator has
+// an Untrusted AuthFunc, that function's result.
+func (p *IdentityPropagator) Authenticate(ctx context.Context, req *Request) (any, error) {
+	if p.upstream != nil {
+		if _, err := p.upstream(ctx, req); err != nil {
+			if connect.CodeOf(err) == connect.CodeUnavailable {
+				return nil, err
+			}
+			if p.untrusted != nil {
+				return p.untrusted(ctx, req)
+			}
+			return nil, NewReasonError(connect.CodePermissionDenied, ReasonPolicy, fmt.Errorf("untrusted peer: %w", err))
+		}
+	}
+	signed := headerValue(req.Header, p.header)
+	if signed == "" {
+		return nil, ReasonErrorf(ReasonMissingCredentials, "missing propagated identity")
+	}
+	return verifyIdentityToken(p.keyring, signed, tokenCheck{
+		noun:     "propagated identity",
+		use:      connectauthv1.TokenUse_TOKEN_USE_PROPAGATION,
+		audience: p.audience,
+		now:      p.now(),
+	})
+}
+
+type propagationInterceptor struct {
+	propagator *IdentityPropagator
+	audience   string
+}
+
+func (i *propagationInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if err := i.propagate(ctx, req.Header()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *propagationInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		if err := i.propagate(ctx, conn.RequestHeader()); err != nil {
+			return &failedClientConn{StreamingClientConn: conn, err: err}
+		}
+		return conn
+	}
+}
+
+func (i *propagationInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func (i *propagationInterceptor) propagate(ctx context.Context, header http.Header) error {
+	header.Del(i.propagator.header)
+	identity := i.propagator.identity(GetInfo(ctx))
+	if identity == nil {
+		return nil
+	}
+	signed, err := i.propagator.Sign(identity, i.audience)
+	if err != nil {
+		return connect.NewError(connect.CodeInternal, fmt.Errorf("propagate identity: %w", err))
+	}
+	header.Set(i.propagator.header, signed)
+	return nil
+}
+
+// failedClientConn fails every send, so errors preparing a stream surface
+// from the first call like any other stream error.
+type failedClientConn struct {
+	connect.StreamingClientConn
+
+	err error
+}
+
+func (c *failedClientConn) Send(any) error      { return c.err }
+func (c *failedClientConn) Receive(any) error   { return c.err }
+func (c *failedClientConn) CloseRequest() error { return c.err }
@@ -0,0 +1,250 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func newTestPropagator(t *testing.T, config IdentityPropagatorConfig) (*IdentityPropagator, *testClock) {
+	t.Helper()
+	keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("k"), 32)})
+	attest.Ok(t, err)
+	clock := newTestClock()
+	config.Clock = clock
+	return NewIdentityPropagator(keyring, config), clock
+}
+
+func TestIdentityPropagation(t *testing.T) {
+	const procedure = "/empty.v1/Ping"
+	propagator, _ := newTestPropagator(t, IdentityPropagatorConfig{Audience: "backend"})
+	downstream := New(propagator.Authenticate)
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewUnaryHandler(
+		procedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			res := connect.NewResponse(&emptypb.Empty{})
+			id, _ := GetInfo(ctx).(*Identity)
+			res.Header().Set("Subject", id.Subject)
+			res.Header().Set("Issuer", id.Issuer)
+			return res, nil
+		},
+		downstream.HandlerOption(),
+	))
+	srv := memhttptest.New(t, downstream.Middleware().Wrap(mux))
+	call := func(ctx context.Context, audience string) (*connect.Response[emptypb.Empty], error) {
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](
+			srv.Client(),
+			srv.URL()+procedure,
+			connect.WithInterceptors(propagator.ClientInterceptor(audience)),
+		)
+		return client.CallUnary(ctx, connect.NewRequest(&emptypb.Empty{}))
+	}
+
+	t.Run("success", func(t *testing.T) {
+		ctx := SetInfo(context.Background(), &Identity{Subject: hero, Issuer: "edge"})
+		res, err := call(ctx, "backend")
+		attest.Ok(t, err)
+		attest.Equal(t, res.Header().Get("Subject"), hero)
+		attest.Equal(t, res.Header().Get("Issuer"), "edge")
+
+		res, err = call(SetInfo(context.Background(), hero), "backend")
+		attest.Ok(t, err)
+		attest.Equal(t, res.Header().Get("Subject"), hero)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := call(context.Background(), "backend")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		_, err = call(SetInfo(context.Background(), 42), "backend")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		_, err := call(SetInfo(context.Background(), hero), "billing")
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+	})
+}
+
+func TestIdentityPropagatorAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	propagator, clock := newTestPropagator(t, IdentityPropagatorConfig{TTL: time.Minute})
+	request := func(signed string) *Request {
+		return &Request{Header: http.Header{"Connectauth-Identity": []string{signed}}}
+	}
+	identity := &Identity{
+		Subject: hero,
+		Issuer:  "edge",
+		Scopes:  []string{"read"},
+		Claims:  map[string]any{"tenant": "acme"},
+		Expiry:  clock.Now().Add(time.Hour).Truncate(time.Millisecond),
+	}
+	signed, err := propagator.Sign(identity, "anywhere")
+	attest.Ok(t, err)
+
+	t.Run("roundtrip", func(t *testing.T) {
+		info, err := propagator.Authenticate(ctx, request(signed))
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(identity))
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("x"), 32)})
+		attest.Ok(t, err)
+		forged, err := NewIdentityPropagator(keyring, IdentityPropagatorConfig{Clock: clock}).Sign(identity, "")
+		attest.Ok(t, err)
+		for _, tc := range []struct {
+			header string
+			reason Reason
+		}{
+			{"", ReasonMissingCredentials},
+			{"garbage", ReasonMalformedCredentials},
+			{forged, ReasonBadSignature},
+			{signed[:len(signed)-2] + "AA", ReasonBadSignature},
+			{propagator.keyring.SignValue([]byte("\xff")), ReasonMalformedCredentials},
+			{propagator.keyring.SignValue(nil), ReasonMalformedCredentials}, // no subject
+		} {
+			_, err := propagator.Authenticate(ctx, request(tc.header))
+			attest.Equal(t, ReasonOf(err), tc.reason, attest.Sprintf("header %q", tc.header))
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		clock.Advance(time.Minute)
+		_, err := propagator.Authenticate(ctx, request(signed))
+		attest.Equal(t, ReasonOf(err), ReasonExpired)
+
+		// Identities that expire before the TTL keep their own expiry.
+		soon := &Identity{Subject: hero, Expiry: clock.Now().Add(time.Second)}
+		signed, err := propagator.Sign(soon, "")
+		attest.Ok(t, err)
+		_, err = propagator.Authenticate(ctx, request(signed))
+		attest.Ok(t, err)
+		clock.Advance(time.Second)
+		_, err = propagator.Authenticate(ctx, request(signed))
+		attest.Equal(t, ReasonOf(err), ReasonExpired)
+	})
+}
+
+func TestIdentityPropagatorUpstream(t *testing.T) {
+	ctx := context.Background()
+	serviceToken := func(_ context.Context, req *Request) (any, error) {
+		if req.Header.Get("Service-Token") != "frontend-secret" {
+			return nil, ReasonErrorf(ReasonInvalidCredentials, "invalid service token")
+		}
+		return "frontend", nil
+	}
+	signer, _ := newTestPropagator(t, IdentityPropagatorConfig{})
+	signed, err := signer.Sign(&Identity{Subject: hero}, "")
+	attest.Ok(t, err)
+	request := func(token string) *Request {
+		return &Request{Header: http.Header{
+			"Connectauth-Identity": []string{signed},
+			"Service-Token":        []string{token},
+		}}
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		propagator, _ := newTestPropagator(t, IdentityPropagatorConfig{Upstream: serviceToken})
+		info, err := propagator.Authenticate(ctx, request("frontend-secret"))
+		attest.Ok(t, err)
+		attest.Equal(t, SubjectOf(info), hero)
+		_, err = propagator.Authenticate(ctx, request("spoofed"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		attest.Equal(t, ReasonOf(err), ReasonPolicy)
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		propagator, _ := newTestPropagator(t, IdentityPropagatorConfig{
+			Upstream:  serviceToken,
+			Untrusted: authenticate,
+		})
+		req := request("spoofed")
+		_, err := propagator.Authenticate(ctx, req)
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		req.Header.Set("Authorization", "Bearer "+passphrase)
+		info, err := propagator.Authenticate(ctx, req)
+		attest.Ok(t, err)
+		attest.Equal(t, info, any(hero))
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		propagator, _ := newTestPropagator(t, IdentityPropagatorConfig{
+			Upstream: func(context.Context, *Request) (any, error) {
+				return nil, NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, errors.New("token service down"))
+			},
+			Untrusted: authenticate,
+		})
+		_, err := propagator.Authenticate(ctx, request("frontend-secret"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnavailable)
+	})
+}
+
+func TestIdentityPropagatorChain(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := NewKeyring(Key{ID: "2024", Secret: bytes.Repeat([]byte("k"), 32)})
+	attest.Ok(t, err)
+	gateway := NewIdentityPropagator(keyring, IdentityPropagatorConfig{Service: "gateway"})
+	orders := NewIdentityPropagator(keyring, IdentityPropagatorConfig{Service: "orders"})
+	billing := NewIdentityPropagator(keyring, IdentityPropagatorConfig{})
+	hop := func(from, to *IdentityPropagator, identity *Identity) *Identity {
+		t.Helper()
+		signed, err := from.Sign(identity, "")
+		attest.Ok(t, err)
+		info, err := to.Authenticate(ctx, &Request{
+			Header: http.Header{"Connectauth-Identity": []string{signed}},
+		})
+		attest.Ok(t, err)
+		return info.(*Identity)
+	}
+
+	user := &Identity{Subject: hero}
+	atOrders := hop(gateway, orders, user)
+	attest.Equal(t, atOrders.Chain, []string{"gateway"})
+	atBilling := hop(orders, billing, atOrders)
+	attest.Equal(t, atBilling.Subject, hero)
+	attest.Equal(t, atBilling.Chain, []string{"gateway", "orders"})
+	attest.Zero(t, user.Chain)
+	attest.Equal(t, atOrders.Chain, []string{"gateway"})
+}
+
+func TestIdentityPropagatorStreaming(t *testing.T) {
+	const procedure = "/empty.v1/Upload"
+	propagator, _ := newTestPropagator(t, IdentityPropagatorConfig{})
+	downstream := New(propagator.Authenticate)
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewClientStreamHandler(
+		procedure,
+		func(ctx context.Context, stream *connect.ClientStream[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			for stream.Receive() {
+			}
+			res := connect.NewResponse(&emptypb.Empty{})
+			res.Header().Set("Subject", SubjectOf(GetInfo(ctx)))
+			return res, stream.Err()
+		},
+		downstream.HandlerOption(),
+	))
+	srv := memhttptest.New(t, downstream.Middleware().Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](
+		srv.Client(),
+		srv.URL()+procedure,
+		connect.WithInterceptors(propagator.ClientInterceptor("")),
+	)
+	stream := client.CallClientStream(SetInfo(context.Background(), hero))
+	attest.Ok(t, stream.Send(&emptypb.Empty{}))
+	res, err := stream.CloseAndReceive()
+	attest.Ok(t, err)
+	attest.Equal(t, res.Header().Get("Subject"), hero)
+}
+
+func TestNewIdentityPropagatorPanics(t *testing.T) {
+	attest.Panics(t, func() { NewIdentityPropagator(nil, IdentityPropagatorConfig{}) })
+}
@@ -0,0 +1,60 @@
+package connectauth
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies resolves the client's address when the server is behind
+// proxies or load balancers. When a request's immediate peer is in one of the
+// trusted networks, [Request].ClientAddr is replaced with the address that
+// the nearest trusted proxy received the request from, as recorded in the
+// X-Forwarded-For header. Clients can't spoof their address by sending their
+// own X-Forwarded-For header: addresses added by untrusted hops are ignored.
+// Resolved addresses don't include the client's port, so it's set to zero.
+//
+// [Request].PeerAddr always holds the immediate peer's address. Logs,
+// metrics, audit events, [IPFilter], and [FailureLimiter] all use the
+// resolved address.
+func WithTrustedProxies(networks ...netip.Prefix) Option {
+	return optionFunc(func(c *config) {
+		if c.TrustedProxies == nil {
+			c.TrustedProxies = &prefixSet{}
+		}
+		for _, n := range networks {
+			c.TrustedProxies.add(n)
+		}
+	})
+}
+
+// resolveClientAddr walks the X-Forwarded-For header from right to left,
+// starting at the peer, and returns the first untrusted address.
+func resolveClientAddr(proxies *prefixSet, peer string, h http.Header) string {
+	addr, err := netip.ParseAddr(clientIP(peer))
+	if err != nil || !proxies.contains(addr.Unmap()) {
+		return peer
+	}
+	resolved := peer
+	values := h.Values("X-Forwarded-For")
+	for i := len(values) - 1; i >= 0; i-- {
+		hops := values[i]
+		for hops != "" {
+			var hop string
+			if j := strings.LastIndexByte(hops, ','); j >= 0 {
+				hops, hop = hops[:j], hops[j+1:]
+			} else {
+				hops, hop = "", hops
+			}
+			addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+			if err != nil {
+				return resolved // the proxy chain is corrupt
+			}
+			resolved = netip.AddrPortFrom(addr, 0).String()
+			if !proxies.contains(addr.Unmap()) {
+				return resolved
+			}
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,56 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestResolveClientAddr(t *testing.T) {
+	proxies := newPrefixSet([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+	tests := []struct {
+		name   string
+		peer   string
+		header []string
+		want   string
+	}{
+		{"untrusted peer", "192.0.2.1:1234", []string{"198.51.100.1"}, "192.0.2.1:1234"},
+		{"no header", "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+		{"one proxy", "10.0.0.1:1234", []string{"192.0.2.1"}, "192.0.2.1:0"},
+		{"spoofed", "10.0.0.1:1234", []string{"203.0.113.9, 192.0.2.1"}, "192.0.2.1:0"},
+		{"chain", "10.0.0.1:1234", []string{"203.0.113.9, 192.0.2.1", "10.0.0.2,10.0.0.3"}, "192.0.2.1:0"},
+		{"all trusted", "10.0.0.1:1234", []string{"10.0.0.2"}, "10.0.0.2:0"},
+		{"ipv6", "[fd00::1]:1234", []string{"2001:db8::1"}, "[2001:db8::1]:0"},
+		{"corrupt", "10.0.0.1:1234", []string{"192.0.2.1, junk, 10.0.0.2"}, "10.0.0.2:0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{"X-Forwarded-For": tt.header}
+			attest.Equal(t, resolveClientAddr(proxies, tt.peer, h), tt.want)
+		})
+	}
+}
+
+func TestTrustedProxies(t *testing.T) {
+	var got *Request
+	auth := New(func(_ context.Context, req *Request) (any, error) {
+		got = req
+		return "alice", nil
+	}, WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+	_, err := auth.authenticate(context.Background(), &Request{
+		Procedure:  "/acme.v1.Svc/Get",
+		Protocol:   connect.ProtocolConnect,
+		ClientAddr: "10.0.0.1:1234",
+		Header:     http.Header{"X-Forwarded-For": []string{"192.0.2.1"}},
+	})
+	attest.Ok(t, err)
+	attest.Equal(t, got.ClientAddr, "192.0.2.1:0")
+	attest.Equal(t, got.PeerAddr, "10.0.0.1:1234")
+}
@@ -0,0 +1,68 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+
+	"connectrpc.com/connect"
+)
+
+// A Reason classifies an authentication failure. Reasons are stable,
+// low-cardinality strings suitable for metric labels, log fields, and audit
+// records, so dashboards and alerts needn't match on error messages.
+type Reason string
+
+// Failure reasons. Applications may define their own, but should keep the
+// total number small.
+const (
+	ReasonUnknown              Reason = "unknown"
+	ReasonMissingCredentials   Reason = "missing_credentials"
+	ReasonMalformedCredentials Reason = "malformed_credentials"
+	ReasonInvalidCredentials   Reason = "invalid_credentials" // for example, an unknown API key or wrong password
+	ReasonExpired              Reason = "expired"
+	ReasonBadSignature         Reason = "bad_signature"
+	ReasonRevoked              Reason = "revoked"
+	ReasonWrongAudience        Reason = "wrong_audience"
+	ReasonWrongIssuer          Reason = "wrong_issuer"
+	ReasonUpstreamUnavailable  Reason = "upstream_unavailable" // a dependency, like a key server, is unavailable
+	ReasonPolicy               Reason = "policy"               // rejected by configuration, like a lockdown or protocol restriction
+	ReasonRateLimited          Reason = "rate_limited"         // too many recent failures
+	ReasonLockedOut            Reason = "locked_out"           // the principal is locked out after repeated failures
+	ReasonReplayed             Reason = "replayed"             // a signed request was reused
+	ReasonStale                Reason = "stale"                // a signed request is too old or dated in the future
+	ReasonReputation           Reason = "reputation"           // the client address is known to be malicious
+	ReasonTooManyStreams       Reason = "too_many_streams"     // the caller has too many open streams
+)
+
+// ReasonErrorf is like [Errorf], but also attaches a Reason to the error.
+func ReasonErrorf(reason Reason, template string, args ...any) *connect.Error {
+	return NewReasonError(connect.CodeUnauthenticated, reason, fmt.Errorf(template, args...))
+}
+
+// NewReasonError is like [connect.NewError], but also attaches a Reason to the
+// error. The reason doesn't change the error message sent to clients.
+func NewReasonError(code connect.Code, reason Reason, underlying error) *connect.Error {
+	return connect.NewError(code, &reasonError{reason: reason, err: underlying})
+}
+
+// ReasonOf returns the Reason attached to an error by [ReasonErrorf] or
+// [NewReasonError]. It returns ReasonUnknown if the error doesn't have a
+// reason, and an empty string if the error is nil.
+func ReasonOf(err error) Reason {
+	if err == nil {
+		return ""
+	}
+	var re *reasonError
+	if errors.As(err, &re) {
+		return re.reason
+	}
+	return ReasonUnknown
+}
+
+type reasonError struct {
+	reason Reason
+	err    error
+}
+
+func (e *reasonError) Error() string { return e.err.Error() }
+func (e *reasonError) Unwrap() error { return e.err }
@@ -0,0 +1,50 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestReason(t *testing.T) {
+	err := ReasonErrorf(ReasonExpired, "token expired %d seconds ago", 30)
+	attest.Equal(t, err.Code(), connect.CodeUnauthenticated)
+	attest.Equal(t, err.Message(), "token expired 30 seconds ago")
+	attest.Equal(t, ReasonOf(err), ReasonExpired)
+	attest.Equal(t, ReasonOf(fmt.Errorf("wrapped: %w", err)), ReasonExpired)
+	attest.Equal(t, ReasonOf(redact(err)), ReasonExpired)
+
+	err = NewReasonError(connect.CodeUnavailable, ReasonUpstreamUnavailable, errors.New("jwks fetch failed"))
+	attest.Equal(t, err.Code(), connect.CodeUnavailable)
+	attest.Equal(t, ReasonOf(err), ReasonUpstreamUnavailable)
+
+	attest.Equal(t, ReasonOf(Errorf("no reason")), ReasonUnknown)
+	attest.Equal(t, ReasonOf(nil), Reason(""))
+}
+
+func TestReasonPipeline(t *testing.T) {
+	var audits bytes.Buffer
+	dispatcher := NewSchemeDispatcher(map[string]AuthFunc{"Bearer": authenticate})
+	auth := New(
+		dispatcher.Authenticate,
+		WithAuditSink(NewJSONAuditSink(&audits)),
+		WithProtocols(connect.ProtocolGRPC),
+	)
+	call := func(protocol string) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  protocol,
+			Header:    http.Header{},
+		})
+		return err
+	}
+	attest.Equal(t, ReasonOf(call(connect.ProtocolGRPC)), ReasonMissingCredentials)
+	attest.Subsequence(t, audits.String(), `"reason":"missing_credentials"`)
+	attest.Equal(t, ReasonOf(call(connect.ProtocolConnect)), ReasonPolicy)
+}
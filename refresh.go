@@ -0,0 +1,99 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A Refresher holds data loaded from a remote source, like a JSON Web Key
+// Set or a certificate revocation list, and reloads it in the background
+// before it goes stale. AuthFuncs read the current data with Load, which
+// never blocks on the network.
+//
+// If a reload fails, the Refresher keeps serving the previous data and
+// tries again at the next interval.
+type Refresher[T any] struct {
+	load    func(context.Context) (T, error)
+	onError func(error)
+	value   atomic.Pointer[T]
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu sync.Mutex // serializes loads, so older data never replaces newer
+}
+
+// NewRefresher loads the initial data and starts reloading it every
+// interval, which must be positive. If the initial load fails, NewRefresher
+// returns the error. The context is passed to every load; canceling it, or
+// calling Close, stops the background reloads. Errors from background
+// reloads are passed to onError, if it's non-nil.
+func NewRefresher[T any](
+	ctx context.Context,
+	interval time.Duration,
+	load func(context.Context) (T, error),
+	onError func(error),
+) (*Refresher[T], error) {
+	if interval <= 0 {
+		return nil, errors.New("refresh interval must be positive")
+	}
+	initial, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Refresher[T]{
+		load:    load,
+		onError: onError,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	r.value.Store(&initial)
+	go r.run(ctx, interval)
+	return r, nil
+}
+
+// Load returns the most recently loaded data.
+func (r *Refresher[T]) Load() T {
+	return *r.value.Load()
+}
+
+// Refresh reloads the data immediately. It's useful when an AuthFunc sees
+// evidence that the data is stale, like a token signed by an unknown key.
+// Callers should rate limit their calls to Refresh. If a background reload
+// is in progress, Refresh waits for it to finish and then loads again.
+func (r *Refresher[T]) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	v, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	r.value.Store(&v)
+	return nil
+}
+
+// Close stops the background reloads and waits for any in-progress reload
+// to finish.
+func (r *Refresher[T]) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *Refresher[T]) run(ctx context.Context, interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil && ctx.Err() == nil && r.onError != nil {
+				r.onError(err)
+			}
+		}
+	}
+}
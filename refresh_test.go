@@ -0,0 +1,99 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestRefresher(t *testing.T) {
+	var (
+		version atomic.Int64
+		fail    atomic.Bool
+	)
+	errs := make(chan error, 10)
+	load := func(context.Context) (int64, error) {
+		if fail.Load() {
+			return 0, errors.New("key server down")
+		}
+		return version.Add(1), nil
+	}
+	r, err := NewRefresher(context.Background(), time.Millisecond, load, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	attest.Ok(t, err)
+	defer r.Close()
+	attest.True(t, r.Load() >= 1)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	attest.True(t, r.Load() >= 3, attest.Sprintf("data wasn't refreshed: %d", r.Load()))
+
+	// Failed reloads keep the old data.
+	fail.Store(true)
+	select {
+	case err := <-errs:
+		attest.Equal(t, err.Error(), "key server down")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload error")
+	}
+	attest.True(t, r.Load() >= 3)
+	attest.Error(t, r.Refresh(context.Background()))
+
+	r.Close()
+	r.Close() // idempotent
+}
+
+func TestRefresherInitialError(t *testing.T) {
+	_, err := NewRefresher(context.Background(), time.Second, func(context.Context) (string, error) {
+		return "", errors.New("key server down")
+	}, nil)
+	attest.Error(t, err)
+
+	_, err = NewRefresher(context.Background(), 0, func(context.Context) (string, error) {
+		return "", nil
+	}, nil)
+	attest.Error(t, err)
+}
+
+func TestRefresherSerializesLoads(t *testing.T) {
+	var calls atomic.Int64
+	started, release := make(chan struct{}), make(chan struct{})
+	load := func(context.Context) (int64, error) {
+		n := calls.Add(1)
+		if n == 2 {
+			// The first reload is slow, and would overwrite newer data if
+			// loads weren't serialized.
+			close(started)
+			<-release
+		}
+		return n, nil
+	}
+	r, err := NewRefresher(context.Background(), time.Hour, load, nil)
+	attest.Ok(t, err)
+	defer r.Close()
+
+	slow := make(chan error)
+	go func() { slow <- r.Refresh(context.Background()) }()
+	<-started
+	fast := make(chan error)
+	go func() { fast <- r.Refresh(context.Background()) }()
+	select {
+	case <-fast:
+		t.Fatal("Refresh didn't wait for the load in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	attest.Ok(t, <-slow)
+	attest.Ok(t, <-fast)
+	attest.Equal(t, r.Load(), int64(3))
+}
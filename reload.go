@@ -0,0 +1,181 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReloaderConfig configures a [Reloader].
+type ReloaderConfig[T any] struct {
+	// Load reads the files and builds the configuration. It should validate
+	// the configuration thoroughly: whatever it returns without an error
+	// becomes active immediately. Required.
+	Load func(context.Context) (T, error)
+	// Files lists the files Load reads, like policy files, pinned
+	// certificates, or local overrides of a JSON Web Key Set. They're
+	// checked for changes every Interval.
+	Files []string
+	// Interval is how often the files are checked for changes. The default
+	// is 5 seconds.
+	Interval time.Duration
+	// Signals also trigger reloads. Servers conventionally reload on
+	// syscall.SIGHUP.
+	Signals []os.Signal
+	// OnReload is called after every reload in the background, with a nil
+	// error if the reload succeeded. It's useful for logging and metrics.
+	OnReload func(error)
+}
+
+// A Reloader holds configuration loaded from local files, like policies,
+// pinned certificates, and key material, and reloads it when the files change
+// or the process receives a signal. Reloads are atomic: readers see either
+// the old configuration or the new one, and requests in flight finish with
+// the configuration they started with. For example, to reload an AuthFunc
+// built from a policy file:
+//
+//	policy, err := connectauth.NewReloader(ctx, connectauth.ReloaderConfig[connectauth.AuthFunc]{
+//		Load: func(context.Context) (connectauth.AuthFunc, error) {
+//			return loadPolicy("/etc/acme/policy.json")
+//		},
+//		Files:   []string{"/etc/acme/policy.json"},
+//		Signals: []os.Signal{syscall.SIGHUP},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	auth := connectauth.New(func(ctx context.Context, req *connectauth.Request) (any, error) {
+//		return policy.Load()(ctx, req)
+//	})
+//
+// Files are checked by periodically hashing their contents, which works on
+// every platform and for files replaced by renaming, like Kubernetes
+// ConfigMaps, so they should be small. If a reload fails, the Reloader keeps
+// serving the previous configuration and tries again when the files next
+// change. Use [Refresher] for data loaded from remote sources.
+type Reloader[T any] struct {
+	load     func(context.Context) (T, error)
+	files    []string
+	onReload func(error)
+	value    atomic.Pointer[T]
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu     sync.Mutex // serializes reloads
+	stamps []fileStamp
+}
+
+type fileStamp struct {
+	sum     [sha256.Size]byte
+	missing bool
+}
+
+// NewReloader loads the initial configuration and starts watching for
+// changes. If the initial load fails, NewReloader returns the error. The
+// context is passed to every load; canceling it, or calling Close, stops
+// watching. It panics if Load is nil.
+func NewReloader[T any](ctx context.Context, config ReloaderConfig[T]) (*Reloader[T], error) {
+	if config.Load == nil {
+		panic("connectauth: Reloader requires a Load function")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	r := &Reloader[T]{
+		load:     config.Load,
+		files:    config.Files,
+		onReload: config.OnReload,
+		done:     make(chan struct{}),
+	}
+	if err := r.Reload(ctx); err != nil {
+		return nil, err
+	}
+	var signals chan os.Signal
+	if len(config.Signals) > 0 {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, config.Signals...)
+	}
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.run(ctx, config.Interval, signals)
+	return r, nil
+}
+
+// Load returns the active configuration.
+func (r *Reloader[T]) Load() T {
+	return *r.value.Load()
+}
+
+// Reload loads the configuration immediately, whether or not the files have
+// changed. If it fails, the previous configuration remains active.
+func (r *Reloader[T]) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Hash before loading, so changes made during the load are noticed.
+	r.stamps = r.hash()
+	v, err := r.load(ctx)
+	if err != nil {
+		return err
+	}
+	r.value.Store(&v)
+	return nil
+}
+
+// Close stops watching for changes and waits for any in-progress reload to
+// finish.
+func (r *Reloader[T]) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *Reloader[T]) run(ctx context.Context, interval time.Duration, signals chan os.Signal) {
+	defer close(r.done)
+	if signals != nil {
+		defer signal.Stop(signals)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+		case <-signals:
+		}
+		err := r.Reload(ctx)
+		if ctx.Err() == nil && r.onReload != nil {
+			r.onReload(err)
+		}
+	}
+}
+
+func (r *Reloader[T]) changed() bool {
+	current := r.hash()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, stamp := range current {
+		if stamp != r.stamps[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Reloader[T]) hash() []fileStamp {
+	stamps := make([]fileStamp, len(r.files))
+	for i, name := range r.files {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			stamps[i].missing = true
+			continue
+		}
+		stamps[i].sum = sha256.Sum256(data)
+	}
+	return stamps
+}
@@ -0,0 +1,114 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func newTestReloader(t *testing.T, config ReloaderConfig[string]) (*Reloader[string], string, chan error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	attest.Ok(t, os.WriteFile(path, []byte("v1"), 0o600))
+	reloads := make(chan error, 10)
+	config.Load = func(context.Context) (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(string(data), "v") {
+			return "", errors.New("invalid policy")
+		}
+		return string(data), nil
+	}
+	config.Files = []string{path}
+	config.OnReload = func(err error) {
+		select {
+		case reloads <- err:
+		default:
+		}
+	}
+	r, err := NewReloader(context.Background(), config)
+	attest.Ok(t, err)
+	t.Cleanup(r.Close)
+	return r, path, reloads
+}
+
+// replaceFile replaces a file atomically, so the Reloader never sees a
+// partially written file.
+func replaceFile(t *testing.T, path, content string) {
+	t.Helper()
+	tmp := path + ".tmp"
+	attest.Ok(t, os.WriteFile(tmp, []byte(content), 0o600))
+	attest.Ok(t, os.Rename(tmp, path))
+}
+
+func awaitReload(t *testing.T, reloads chan error) error {
+	t.Helper()
+	select {
+	case err := <-reloads:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+		return nil
+	}
+}
+
+func TestReloader(t *testing.T) {
+	r, path, reloads := newTestReloader(t, ReloaderConfig[string]{Interval: time.Millisecond})
+	attest.Equal(t, r.Load(), "v1")
+
+	replaceFile(t, path, "v2")
+	attest.Ok(t, awaitReload(t, reloads))
+	attest.Equal(t, r.Load(), "v2")
+
+	// Failed reloads keep the previous configuration.
+	replaceFile(t, path, "garbage")
+	attest.Error(t, awaitReload(t, reloads))
+	attest.Equal(t, r.Load(), "v2")
+
+	// Deleted files are changes too.
+	attest.Ok(t, os.Remove(path))
+	attest.Error(t, awaitReload(t, reloads))
+	replaceFile(t, path, "v3")
+	attest.Ok(t, awaitReload(t, reloads))
+	attest.Equal(t, r.Load(), "v3")
+
+	// Unchanged files aren't reloaded.
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-reloads:
+		t.Fatalf("unexpected reload: %v", err)
+	default:
+	}
+
+	r.Close()
+	r.Close() // idempotent
+}
+
+func TestReloaderManual(t *testing.T) {
+	r, path, reloads := newTestReloader(t, ReloaderConfig[string]{Interval: time.Hour})
+	replaceFile(t, path, "v2")
+	attest.Equal(t, r.Load(), "v1")
+	attest.Ok(t, r.Reload(context.Background()))
+	attest.Equal(t, r.Load(), "v2")
+	attest.Zero(t, len(reloads)) // OnReload only reports background reloads
+}
+
+func TestReloaderErrors(t *testing.T) {
+	_, err := NewReloader(context.Background(), ReloaderConfig[string]{
+		Load: func(context.Context) (string, error) {
+			return "", errors.New("invalid policy")
+		},
+	})
+	attest.Error(t, err)
+	attest.Panics(t, func() {
+		NewReloader(context.Background(), ReloaderConfig[string]{})
+	})
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package connectauth
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestReloaderSignal(t *testing.T) {
+	r, path, reloads := newTestReloader(t, ReloaderConfig[string]{
+		Interval: time.Hour,
+		Signals:  []os.Signal{syscall.SIGHUP},
+	})
+	replaceFile(t, path, "v2")
+	attest.Ok(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	attest.Ok(t, awaitReload(t, reloads))
+	attest.Equal(t, r.Load(), "v2")
+}
@@ -0,0 +1,138 @@
+package connectauth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// A Verdict is a reputation checker's judgment of a client address.
+type Verdict int
+
+// Verdicts, from least to most severe.
+const (
+	VerdictAllow Verdict = iota // no known problems
+	VerdictFlag                 // suspicious, but not rejected
+	VerdictDeny                 // known bad, rejected
+)
+
+// A Reputation is the result of checking a client address.
+type Reputation struct {
+	Verdict Verdict
+	Label   string // identifies the feed or rule, like "spamhaus-drop"
+}
+
+// A ReputationChecker checks client addresses against threat intelligence,
+// like lists of known-bad networks or a commercial reputation service.
+// Implementations must be safe to call concurrently.
+type ReputationChecker interface {
+	CheckReputation(ctx context.Context, addr netip.Addr) (Reputation, error)
+}
+
+// WithReputationChecker checks the reputation of each client address before
+// calling the AuthFunc, so credentials from known-bad addresses are never
+// validated. Denied requests are rejected with [connect.CodePermissionDenied]
+// and [ReasonReputation]. Flagged requests proceed, but the checker's label
+// is appended to [Request].Flags, so AuthFuncs can demand stronger
+// credentials, observers can record the flag, and handlers can check
+// [GetFlags].
+//
+// Checkers run in the order they're configured, and the first denial wins.
+// If a checker returns an error, it's ignored: an unavailable threat feed
+// shouldn't take down authentication. Exempt procedures aren't checked. When
+// the server is behind a proxy or load balancer, also use
+// [WithTrustedProxies].
+func WithReputationChecker(checker ReputationChecker) Option {
+	return optionFunc(func(c *config) {
+		c.ReputationCheckers = append(c.ReputationCheckers, checker)
+	})
+}
+
+func (c *config) checkReputation(ctx context.Context, req *Request) error {
+	addr, err := netip.ParseAddr(clientIP(req.ClientAddr))
+	if err != nil {
+		return nil
+	}
+	addr = addr.Unmap()
+	for _, checker := range c.ReputationCheckers {
+		rep, err := checker.CheckReputation(ctx, addr)
+		if err != nil {
+			continue
+		}
+		switch rep.Verdict {
+		case VerdictDeny:
+			return NewReasonError(
+				connect.CodePermissionDenied,
+				ReasonReputation,
+				fmt.Errorf("client address %s denied by %s", addr, rep.Label),
+			)
+		case VerdictFlag:
+			req.Flags = append(req.Flags, rep.Label)
+		}
+	}
+	return nil
+}
+
+// A PrefixListChecker is a [ReputationChecker] that matches addresses against
+// a fixed list of networks, like the Spamhaus DROP list or a FireHOL IP set.
+// Use [ParsePrefixList] to load such feeds, and a [Refresher] to keep them
+// current.
+type PrefixListChecker struct {
+	rep      Reputation
+	prefixes *prefixSet
+}
+
+var _ ReputationChecker = (*PrefixListChecker)(nil)
+
+// NewPrefixListChecker constructs a PrefixListChecker. Addresses in any of
+// the networks receive the supplied Reputation; all other addresses are
+// allowed.
+func NewPrefixListChecker(rep Reputation, prefixes []netip.Prefix) *PrefixListChecker {
+	return &PrefixListChecker{rep: rep, prefixes: newPrefixSet(prefixes)}
+}
+
+// CheckReputation implements [ReputationChecker].
+func (c *PrefixListChecker) CheckReputation(_ context.Context, addr netip.Addr) (Reputation, error) {
+	if c.prefixes.contains(addr.Unmap()) {
+		return c.rep, nil
+	}
+	return Reputation{}, nil
+}
+
+// ParsePrefixList parses a plain-text list of networks, one per line, in the
+// format used by most threat feeds. Lines may hold a CIDR prefix or a single
+// address. Everything after a "#" or ";" is a comment, so both the Spamhaus
+// DROP format ("192.0.2.0/24 ; SBL123") and FireHOL netsets are supported.
+func ParsePrefixList(r io.Reader) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexAny(text, "#;"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if !strings.Contains(text, "/") {
+			addr, err := netip.ParseAddr(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, scanner.Err()
+}
@@ -0,0 +1,80 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestReputation(t *testing.T) {
+	drop := NewPrefixListChecker(
+		Reputation{Verdict: VerdictDeny, Label: "drop"},
+		[]netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+	)
+	tor := NewPrefixListChecker(
+		Reputation{Verdict: VerdictFlag, Label: "tor"},
+		[]netip.Prefix{netip.MustParsePrefix("198.51.100.0/24"), netip.MustParsePrefix("192.0.2.0/24")},
+	)
+	var calls int
+	var flags []string
+	auth := New(func(_ context.Context, req *Request) (any, error) {
+		calls++
+		flags = req.Flags
+		return "alice", nil
+	},
+		WithReputationChecker(failingReputationChecker{}),
+		WithReputationChecker(drop),
+		WithReputationChecker(tor),
+	)
+	call := func(addr string) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure:  "/acme.v1.Svc/Get",
+			Protocol:   connect.ProtocolConnect,
+			ClientAddr: addr,
+		})
+		return err
+	}
+
+	err := call("192.0.2.1:1234")
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, ReasonOf(err), ReasonReputation)
+	attest.Equal(t, calls, 0)
+
+	attest.Ok(t, call("[::ffff:198.51.100.1]:1234"))
+	attest.Equal(t, flags, []string{"tor"})
+	attest.Ok(t, call("203.0.113.1:1234"))
+	attest.Zero(t, flags)
+}
+
+func TestParsePrefixList(t *testing.T) {
+	const feed = `; Spamhaus DROP List
+192.0.2.0/24 ; SBL123
+# FireHOL
+198.51.100.7
+2001:db8::/32
+
+198.51.100.9/24
+`
+	prefixes, err := ParsePrefixList(strings.NewReader(feed))
+	attest.Ok(t, err)
+	got := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		got[i] = p.String()
+	}
+	attest.Equal(t, got, []string{"192.0.2.0/24", "198.51.100.7/32", "2001:db8::/32", "198.51.100.0/24"})
+
+	_, err = ParsePrefixList(strings.NewReader("192.0.2.0/24\nnonsense\n"))
+	attest.Error(t, err)
+	attest.Subsequence(t, err.Error(), "line 2")
+}
+
+type failingReputationChecker struct{}
+
+func (failingReputationChecker) CheckReputation(context.Context, netip.Addr) (Reputation, error) {
+	return Reputation{}, errors.New("oh no")
+}
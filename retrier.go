@@ -0,0 +1,166 @@
+package connectauth
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetrierConfig configures a [Retrier].
+type RetrierConfig struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// The default is 3.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff between attempts.
+	// Each delay is chosen uniformly at random between zero and
+	// min(MaxDelay, BaseDelay * 2^retry), so concurrent retries don't
+	// synchronize. The defaults are 25 milliseconds and one second.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// BudgetRatio limits retries to a fraction of calls, so retries can't
+	// multiply the load on a struggling dependency. Each call adds
+	// BudgetRatio to the budget, and each retry spends one. The budget is
+	// capped at BudgetBurst, and starts full. The defaults are 0.1 and 10.
+	BudgetRatio float64
+	BudgetBurst float64
+	// Retryable reports whether an attempt may be retried. By default, errors
+	// coded [connect.CodeUnavailable] or [connect.CodeDeadlineExceeded] and
+	// context deadline errors are retryable.
+	Retryable func(error) bool
+	// OnRetry, if non-nil, is called before each retry.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// A Retrier retries transient failures of calls to external validators, like
+// token introspection endpoints, with budgeted, jittered exponential backoff.
+// These retries happen within a single authentication attempt and are
+// invisible to clients, which apply their own retry policies to the RPC.
+//
+// Only idempotent operations may be retried: validating a token is usually
+// safe to repeat, but redeeming a one-time code isn't.
+//
+// Retries stop early if the context's deadline would expire during the
+// backoff, or if the error carries a retry delay (see [RetryDelay]) longer
+// than MaxDelay. Combine a Retrier with a [CircuitBreaker] by retrying
+// inside the breaker, so that an open circuit isn't retried.
+//
+// Retriers are safe to use concurrently.
+type Retrier struct {
+	attempts  int
+	base      time.Duration
+	max       time.Duration
+	ratio     float64
+	burst     float64
+	retryable func(error) bool
+	onRetry   func(int, time.Duration, error)
+	sleep     func(context.Context, time.Duration)
+
+	mu     sync.Mutex
+	budget float64
+}
+
+// NewRetrier constructs a Retrier.
+func NewRetrier(config RetrierConfig) *Retrier {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.BaseDelay <= 0 {
+		config.BaseDelay = 25 * time.Millisecond
+	}
+	if config.MaxDelay < config.BaseDelay {
+		config.MaxDelay = max(config.BaseDelay, time.Second)
+	}
+	if config.BudgetRatio <= 0 {
+		config.BudgetRatio = 0.1
+	}
+	if config.BudgetBurst <= 0 {
+		config.BudgetBurst = 10
+	}
+	if config.Retryable == nil {
+		config.Retryable = isOutage
+	}
+	return &Retrier{
+		attempts:  config.MaxAttempts,
+		base:      config.BaseDelay,
+		max:       config.MaxDelay,
+		ratio:     config.BudgetRatio,
+		burst:     config.BudgetBurst,
+		retryable: config.Retryable,
+		onRetry:   config.OnRetry,
+		sleep:     sleep,
+		budget:    config.BudgetBurst,
+	}
+}
+
+// Wrap retries an idempotent AuthFunc.
+func (r *Retrier) Wrap(auth AuthFunc) AuthFunc {
+	return func(ctx context.Context, req *Request) (any, error) {
+		var info any
+		err := r.Do(ctx, func(ctx context.Context) error {
+			var err error
+			info, err = auth(ctx, req)
+			return err
+		})
+		return info, err
+	}
+}
+
+// Do calls the idempotent function f, retrying transient failures. It
+// returns the last error.
+func (r *Retrier) Do(ctx context.Context, f func(context.Context) error) error {
+	r.deposit()
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = f(ctx); err == nil || !r.retryable(err) || attempt >= r.attempts {
+			return err
+		}
+		delay, ok := r.backoff(ctx, attempt, err)
+		if !ok || !r.withdraw() {
+			return err
+		}
+		if r.onRetry != nil {
+			r.onRetry(attempt, delay, err)
+		}
+		r.sleep(ctx, delay)
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, and whether there's time
+// for it.
+func (r *Retrier) backoff(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	ceiling := r.max
+	if exp := r.base << (attempt - 1); exp > 0 && exp < ceiling {
+		ceiling = exp
+	}
+	delay := time.Duration(rand.Int63n(int64(ceiling) + 1))
+	if hint, ok := RetryDelay(err); ok {
+		if hint > r.max {
+			return 0, false
+		}
+		delay = max(delay, hint)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return 0, false
+	}
+	return delay, true
+}
+
+func (r *Retrier) deposit() {
+	r.mu.Lock()
+	r.budget = min(r.budget+r.ratio, r.burst)
+	r.mu.Unlock()
+}
+
+func (r *Retrier) withdraw() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.budget < 1 {
+		return false
+	}
+	r.budget--
+	return true
+}
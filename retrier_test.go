@@ -0,0 +1,102 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestRetrier(t *testing.T) {
+	ctx := context.Background()
+	unavailable := connect.NewError(connect.CodeUnavailable, errors.New("oh no"))
+	newRetrier := func(config RetrierConfig) (*Retrier, *[]time.Duration) {
+		r := NewRetrier(config)
+		var slept []time.Duration
+		r.sleep = func(_ context.Context, d time.Duration) { slept = append(slept, d) }
+		return r, &slept
+	}
+	failing := func(n int, err error) (func(context.Context) error, *int) {
+		var calls int
+		return func(context.Context) error {
+			calls++
+			if calls <= n {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	t.Run("backoff", func(t *testing.T) {
+		r, slept := newRetrier(RetrierConfig{MaxAttempts: 4, BaseDelay: 10 * time.Millisecond, MaxDelay: 15 * time.Millisecond})
+		f, calls := failing(3, unavailable)
+		attest.Ok(t, r.Do(ctx, f))
+		attest.Equal(t, *calls, 4)
+		attest.Equal(t, len(*slept), 3)
+		for i, ceiling := range []time.Duration{10 * time.Millisecond, 15 * time.Millisecond, 15 * time.Millisecond} {
+			attest.True(t, (*slept)[i] <= ceiling)
+		}
+
+		f, calls = failing(10, unavailable)
+		attest.ErrorIs(t, r.Do(ctx, f), unavailable)
+		attest.Equal(t, *calls, 4)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		r, _ := newRetrier(RetrierConfig{})
+		f, calls := failing(1, Errorf("bad token"))
+		attest.Error(t, r.Do(ctx, f))
+		attest.Equal(t, *calls, 1)
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		r, _ := newRetrier(RetrierConfig{MaxAttempts: 2, BudgetRatio: 0.5, BudgetBurst: 1})
+		f, calls := failing(100, unavailable)
+		attest.Error(t, r.Do(ctx, f)) // spends the initial budget
+		attest.Equal(t, *calls, 2)
+		attest.Error(t, r.Do(ctx, f)) // deposits 0.5
+		attest.Equal(t, *calls, 3)
+		attest.Error(t, r.Do(ctx, f)) // deposits another 0.5, then retries
+		attest.Equal(t, *calls, 5)
+	})
+
+	t.Run("retry delay hint", func(t *testing.T) {
+		r, slept := newRetrier(RetrierConfig{MaxDelay: time.Second})
+		f, calls := failing(1, RetryErrorf(connect.CodeUnavailable, 500*time.Millisecond, "slow down"))
+		attest.Ok(t, r.Do(ctx, f))
+		attest.Equal(t, *calls, 2)
+		attest.Equal(t, *slept, []time.Duration{500 * time.Millisecond})
+
+		f, calls = failing(1, RetryErrorf(connect.CodeUnavailable, time.Minute, "go away"))
+		attest.Error(t, r.Do(ctx, f))
+		attest.Equal(t, *calls, 1)
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		r, _ := newRetrier(RetrierConfig{BaseDelay: time.Second, MaxDelay: time.Second})
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		f, calls := failing(1, RetryErrorf(connect.CodeUnavailable, 500*time.Millisecond, "slow down"))
+		attest.Error(t, r.Do(ctx, f))
+		attest.Equal(t, *calls, 1)
+	})
+
+	t.Run("wrap", func(t *testing.T) {
+		r, _ := newRetrier(RetrierConfig{})
+		var calls int
+		auth := r.Wrap(func(ctx context.Context, req *Request) (any, error) {
+			calls++
+			if calls == 1 {
+				return nil, unavailable
+			}
+			return authenticate(ctx, req)
+		})
+		info, err := auth(ctx, bearer(passphrase))
+		attest.Ok(t, err)
+		attest.Equal(t, info, hero)
+		attest.Equal(t, calls, 2)
+	})
+}
@@ -0,0 +1,59 @@
+package connectauth
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// RetryErrorf returns an error that tells clients how long to wait before
+// retrying. It's intended for throttled authentication attempts, so the code
+// is usually [connect.CodeResourceExhausted] (when the caller is being rate
+// limited) or [connect.CodeUnavailable] (when an upstream identity provider is
+// throttling the server).
+//
+// The error includes a google.rpc.RetryInfo detail, which well-behaved gRPC and
+// Connect clients use to back off, and a Retry-After metadata header for
+// clients that don't inspect error details.
+func RetryErrorf(code connect.Code, delay time.Duration, template string, args ...any) *connect.Error {
+	return newRetryError(code, delay, fmt.Errorf(template, args...))
+}
+
+func newRetryError(code connect.Code, delay time.Duration, underlying error) *connect.Error {
+	err := connect.NewError(code, underlying)
+	if delay < 0 {
+		delay = 0
+	}
+	if detail, detailErr := connect.NewErrorDetail(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(delay),
+	}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	seconds := (delay + time.Second - 1) / time.Second // round up
+	err.Meta().Set("Retry-After", strconv.FormatInt(int64(seconds), 10))
+	return err
+}
+
+// RetryDelay extracts the delay from an error's google.rpc.RetryInfo detail,
+// if any.
+func RetryDelay(err error) (time.Duration, bool) {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return 0, false
+	}
+	for _, detail := range connectErr.Details() {
+		msg, valueErr := detail.Value()
+		if valueErr != nil {
+			continue
+		}
+		if info, ok := msg.(*errdetails.RetryInfo); ok {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
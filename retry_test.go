@@ -0,0 +1,48 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestRetryErrorf(t *testing.T) {
+	err := RetryErrorf(connect.CodeResourceExhausted, 1500*time.Millisecond, "slow down, %s", "friend")
+	attest.Equal(t, err.Code(), connect.CodeResourceExhausted)
+	attest.Equal(t, err.Message(), "slow down, friend")
+	attest.Equal(t, err.Meta().Get("Retry-After"), "2")
+	delay, ok := RetryDelay(err)
+	attest.True(t, ok)
+	attest.Equal(t, delay, 1500*time.Millisecond)
+
+	_, ok = RetryDelay(errors.New("oops"))
+	attest.False(t, ok)
+	_, ok = RetryDelay(Errorf("no details"))
+	attest.False(t, ok)
+
+	// The detail survives a round trip over the network.
+	throttle := func(context.Context, *Request) (any, error) {
+		return nil, RetryErrorf(connect.CodeUnavailable, 3*time.Second, "identity provider is throttling")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/empty.v1/GetEmpty", connect.NewUnaryHandler(
+		"/empty.v1/GetEmpty",
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty]) (*connect.Response[emptypb.Empty], error) {
+			return connect.NewResponse(&emptypb.Empty{}), nil
+		},
+	))
+	srv := memhttptest.New(t, NewMiddleware(throttle).Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+"/empty.v1/GetEmpty")
+	_, callErr := client.CallUnary(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+	attest.Equal(t, connect.CodeOf(callErr), connect.CodeUnavailable)
+	delay, ok = RetryDelay(callErr)
+	attest.True(t, ok)
+	attest.Equal(t, delay, 3*time.Second)
+}
@@ -0,0 +1,85 @@
+package connectauth
+
+import (
+	"context"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// rolloutBuckets is the granularity of rollout percentages: 10,000 buckets
+// allows percentages with two decimal places.
+const rolloutBuckets = 10_000
+
+// A Rollout gradually shifts traffic from an old AuthFunc to a new one. Each
+// request is assigned to a bucket using a stable hash of a key (often the
+// client's IP address), so a given client consistently uses the same
+// AuthFunc for a particular rollout percentage.
+//
+// Rollouts start at 0%, so all requests use the old AuthFunc. Use SetPercent
+// to adjust the rollout at runtime. Rollouts are safe to use concurrently.
+type Rollout struct {
+	old, new AuthFunc
+	key      func(*Request) string
+	buckets  atomic.Uint32 // number of buckets using the new AuthFunc
+}
+
+// NewRollout constructs a Rollout. The key function extracts the value used
+// to assign requests to buckets; [RolloutKeyClientIP] and [RolloutKeyHeader]
+// cover the most common cases.
+func NewRollout(old, new AuthFunc, key func(*Request) string) *Rollout {
+	return &Rollout{old: old, new: new, key: key}
+}
+
+// SetPercent sets the percentage of requests that use the new AuthFunc.
+// Percentages are clamped to [0, 100] and rounded to two decimal places.
+func (r *Rollout) SetPercent(percent float64) {
+	switch {
+	case percent <= 0:
+		r.buckets.Store(0)
+	case percent >= 100:
+		r.buckets.Store(rolloutBuckets)
+	default:
+		r.buckets.Store(uint32(percent*rolloutBuckets/100 + 0.5))
+	}
+}
+
+// Percent returns the percentage of requests that use the new AuthFunc.
+func (r *Rollout) Percent() float64 {
+	return float64(r.buckets.Load()) * 100 / rolloutBuckets
+}
+
+// Authenticate is an AuthFunc that delegates to either the old or new
+// AuthFunc, depending on the request's bucket.
+func (r *Rollout) Authenticate(ctx context.Context, req *Request) (any, error) {
+	if r.useNew(req) {
+		return r.new(ctx, req)
+	}
+	return r.old(ctx, req)
+}
+
+func (r *Rollout) useNew(req *Request) bool {
+	buckets := r.buckets.Load()
+	switch buckets {
+	case 0:
+		return false
+	case rolloutBuckets:
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.key(req)))
+	return h.Sum32()%rolloutBuckets < buckets
+}
+
+// RolloutKeyClientIP assigns requests to rollout buckets using the client's
+// IP address.
+func RolloutKeyClientIP(req *Request) string {
+	return clientIP(req.ClientAddr)
+}
+
+// RolloutKeyHeader assigns requests to rollout buckets using the value of a
+// request header, like a tenant or user ID.
+func RolloutKeyHeader(name string) func(*Request) string {
+	return func(req *Request) string {
+		return req.Header.Get(name)
+	}
+}
@@ -0,0 +1,58 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestRollout(t *testing.T) {
+	named := func(name string) AuthFunc {
+		return func(context.Context, *Request) (any, error) {
+			return name, nil
+		}
+	}
+	rollout := NewRollout(named("old"), named("new"), RolloutKeyClientIP)
+	count := func() map[any]int {
+		counts := make(map[any]int)
+		for i := 0; i < 1000; i++ {
+			req := &Request{ClientAddr: fmt.Sprintf("10.0.%d.%d:443", i/256, i%256)}
+			info, err := rollout.Authenticate(context.Background(), req)
+			attest.Ok(t, err)
+			counts[info]++
+		}
+		return counts
+	}
+
+	attest.Equal(t, count(), map[any]int{"old": 1000})
+	rollout.SetPercent(100)
+	attest.Equal(t, rollout.Percent(), 100.0)
+	attest.Equal(t, count(), map[any]int{"new": 1000})
+
+	rollout.SetPercent(25)
+	attest.Equal(t, rollout.Percent(), 25.0)
+	counts := count()
+	attest.True(t, counts["new"] > 150 && counts["new"] < 350, attest.Sprintf("got %v", counts))
+
+	// Assignments are stable.
+	req := &Request{ClientAddr: "192.0.2.1:1234"}
+	first, _ := rollout.Authenticate(context.Background(), req)
+	for i := 0; i < 10; i++ {
+		info, _ := rollout.Authenticate(context.Background(), req)
+		attest.Equal(t, info, first)
+	}
+
+	rollout.SetPercent(-5)
+	attest.Equal(t, rollout.Percent(), 0.0)
+}
+
+func TestRolloutKeys(t *testing.T) {
+	attest.Equal(t, RolloutKeyClientIP(&Request{ClientAddr: "192.0.2.1:1234"}), "192.0.2.1")
+	attest.Equal(t, RolloutKeyClientIP(&Request{ClientAddr: "[2001:db8::1]:1234"}), "2001:db8::1")
+	attest.Equal(t, RolloutKeyClientIP(&Request{ClientAddr: "pipe"}), "pipe")
+	header := http.Header{"Tenant-Id": []string{"acme"}}
+	attest.Equal(t, RolloutKeyHeader("Tenant-ID")(&Request{Header: header}), "acme")
+}
@@ -0,0 +1,66 @@
+package connectauth
+
+import "context"
+
+// A Router selects an AuthFunc based on the procedure being called. For
+// example, an application might require mutual TLS for an internal admin
+// service while accepting JWTs for its public API:
+//
+//	router := connectauth.NewRouter()
+//	router.Handle("/acme.admin.v1.AdminService/*", authenticateMTLS)
+//	router.Handle("/acme.*.v1.*/*", authenticateJWT)
+//	middleware := connectauth.NewMiddleware(router.Authenticate)
+//
+// Patterns take one of three forms:
+//   - An exact procedure, like "/acme.foo.v1.FooService/Bar".
+//   - All the methods of a service, like "/acme.foo.v1.FooService/*".
+//   - A glob compatible with [path.Match], like "/acme.*.v1.*/Get*". Note that
+//     "*" never matches a slash, so "/*/*" matches every procedure.
+//
+// Exact patterns take precedence over service patterns, which take precedence
+// over globs. Globs are tried in the order they were registered. Patterns are
+// compiled when they're registered: exact and service lookups take constant
+// time, and globs are indexed by their literal prefix, so each request only
+// tries the globs that could plausibly match.
+//
+// Requests for procedures that don't match any pattern are rejected with
+// [connect.CodeUnauthenticated].
+type Router struct {
+	routes procedureMatcher[AuthFunc]
+}
+
+// NewRouter constructs an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers an AuthFunc for procedures matching the pattern. It panics
+// if the pattern is malformed or has already been registered.
+//
+// All routes must be registered before the Router is used: Handle isn't safe
+// to call concurrently with Authenticate.
+func (r *Router) Handle(pattern string, auth AuthFunc) {
+	if auth == nil {
+		panic("connectauth: nil AuthFunc for pattern " + pattern)
+	}
+	if err := r.routes.add(pattern, auth); err != nil {
+		panic("connectauth: " + err.Error())
+	}
+}
+
+// Handles reports whether any registered pattern matches the procedure.
+// Requests for other procedures are always rejected.
+func (r *Router) Handles(procedure string) bool {
+	_, ok := r.routes.match(procedure)
+	return ok
+}
+
+// Authenticate is an AuthFunc that delegates to the AuthFunc registered for
+// the request's procedure.
+func (r *Router) Authenticate(ctx context.Context, req *Request) (any, error) {
+	auth, ok := r.routes.match(req.Procedure)
+	if !ok {
+		return nil, ReasonErrorf(ReasonPolicy, "no authentication configured for procedure %q", req.Procedure)
+	}
+	return auth(ctx, req)
+}
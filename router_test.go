@@ -0,0 +1,70 @@
+package connectauth
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestRouter(t *testing.T) {
+	named := func(name string) AuthFunc {
+		return func(context.Context, *Request) (any, error) {
+			return name, nil
+		}
+	}
+	router := NewRouter()
+	router.Handle("/acme.admin.v1.AdminService/Reboot", named("exact"))
+	router.Handle("/acme.admin.v1.AdminService/*", named("service"))
+	router.Handle("/acme.*.v1.*/Get*", named("getters"))
+	router.Handle("/acme.*.v1.*/*", named("acme"))
+
+	tests := []struct {
+		procedure string
+		want      string
+	}{
+		{"/acme.admin.v1.AdminService/Reboot", "exact"},
+		{"/acme.admin.v1.AdminService/GetStatus", "service"},
+		{"/acme.user.v1.UserService/GetUser", "getters"},
+		{"/acme.user.v1.UserService/DeleteUser", "acme"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.procedure, func(t *testing.T) {
+			info, err := router.Authenticate(context.Background(), &Request{Procedure: tt.procedure})
+			attest.Ok(t, err)
+			attest.Equal(t, info, any(tt.want))
+		})
+	}
+
+	t.Run("unmatched", func(t *testing.T) {
+		for _, procedure := range []string{"", "/acme.user.v2.UserService/GetUser", "/other.v1.Svc/Get"} {
+			_, err := router.Authenticate(context.Background(), &Request{Procedure: procedure})
+			attest.Error(t, err)
+			attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		}
+	})
+
+	t.Run("handles", func(t *testing.T) {
+		attest.True(t, router.Handles("/acme.admin.v1.AdminService/Reboot"))
+		attest.True(t, router.Handles("/acme.user.v1.UserService/GetUser"))
+		attest.False(t, router.Handles("/other.v1.Svc/Get"))
+	})
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		for _, pattern := range []string{
+			"acme.foo.v1.FooService/Bar",         // no leading slash
+			"/acme.foo.v1.FooService/[",          // malformed glob
+			"/acme.admin.v1.AdminService/*",      // duplicate service
+			"/acme.admin.v1.AdminService/Reboot", // duplicate exact
+			"/acme.*.v1.*/*",                     // duplicate glob
+		} {
+			func() {
+				defer func() {
+					attest.NotZero(t, recover(), attest.Sprintf("expected panic for pattern %q", pattern))
+				}()
+				router.Handle(pattern, named("invalid"))
+			}()
+		}
+	})
+}
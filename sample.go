@@ -0,0 +1,79 @@
+package connectauth
+
+import (
+	"math/rand"
+)
+
+// A Sampler decides whether to record an authentication event. Samplers must
+// be safe to call concurrently.
+type Sampler func(*Event) bool
+
+// SampleRates configures a [Sampler] built by [NewSampler]. Rates are
+// fractions between 0 and 1: a rate of 0.01 records roughly one event in a
+// hundred.
+type SampleRates struct {
+	Success float64 // for allowed and exempt requests
+	Failure float64 // for rejected requests
+	// Events for procedures matching any of these patterns are always
+	// recorded. Patterns use the same syntax as [Router].
+	AlwaysProcedures []string
+}
+
+// NewSampler constructs a Sampler that records events at the supplied rates.
+// For example, a high-traffic service might record every failure, every call
+// to its admin service, and one percent of successes:
+//
+//	connectauth.NewSampler(connectauth.SampleRates{
+//		Success:          0.01,
+//		Failure:          1,
+//		AlwaysProcedures: []string{"/acme.admin.v1.AdminService/*"},
+//	})
+//
+// NewSampler panics if any pattern is malformed.
+func NewSampler(rates SampleRates) Sampler {
+	var always procedureMatcher[struct{}]
+	for _, pattern := range rates.AlwaysProcedures {
+		if err := always.add(pattern, struct{}{}); err != nil {
+			panic("connectauth: " + err.Error())
+		}
+	}
+	return func(ev *Event) bool {
+		if _, ok := always.match(ev.Request.Procedure); ok {
+			return true
+		}
+		rate := rates.Success
+		if ev.Err != nil {
+			rate = rates.Failure
+		}
+		return sample(rate)
+	}
+}
+
+// WithLogSampler limits the events logged by [WithLogger]. By default, every
+// event is logged.
+func WithLogSampler(sampler Sampler) Option {
+	return optionFunc(func(c *config) {
+		c.LogSampler = sampler
+	})
+}
+
+// WithAuditSampler limits the events sent to audit sinks (see
+// [WithAuditSink]). By default, every event is audited. Since unsampled
+// events are never seen by the sinks, sampling weakens the guarantee that the
+// audit log is authoritative; most applications should sample only
+// successes.
+func WithAuditSampler(sampler Sampler) Option {
+	return optionFunc(func(c *config) {
+		c.AuditSampler = sampler
+	})
+}
+
+func sample(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	return rand.Float64() < rate
+}
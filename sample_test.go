@@ -0,0 +1,62 @@
+package connectauth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestSampler(t *testing.T) {
+	sampler := NewSampler(SampleRates{
+		Success:          0,
+		Failure:          1,
+		AlwaysProcedures: []string{"/acme.admin.v1.Admin/*"},
+	})
+	event := func(procedure string, err error) *Event {
+		return &Event{Request: &Request{Procedure: procedure}, Err: err}
+	}
+	attest.True(t, sampler(event("/acme.v1.Svc/Get", Errorf("denied"))))
+	attest.False(t, sampler(event("/acme.v1.Svc/Get", nil)))
+	attest.True(t, sampler(event("/acme.admin.v1.Admin/Delete", nil)))
+
+	half := NewSampler(SampleRates{Success: 0.5})
+	var n int
+	for i := 0; i < 10000; i++ {
+		if half(event("/acme.v1.Svc/Get", nil)) {
+			n++
+		}
+	}
+	attest.True(t, n > 4000 && n < 6000, attest.Sprintf("sampled %d of 10000", n))
+}
+
+func TestLogAndAuditSamplers(t *testing.T) {
+	var logs, audits bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	onlyFailures := NewSampler(SampleRates{Failure: 1})
+	auth := New(
+		authenticate,
+		WithLogSampler(onlyFailures), // before WithLogger
+		WithLogger(logger),
+		WithAuditSink(NewJSONAuditSink(&audits)),
+		WithAuditSampler(onlyFailures),
+	)
+	call := func(token string) {
+		_, _ = auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			Header:    http.Header{"Authorization": []string{"Bearer " + token}},
+		})
+	}
+	call(passphrase)
+	call("wrong")
+	attest.Equal(t, strings.Count(logs.String(), "\n"), 1)
+	attest.Subsequence(t, logs.String(), "authentication failed")
+	attest.Equal(t, strings.Count(audits.String(), "\n"), 1)
+	attest.Subsequence(t, audits.String(), DecisionDeny)
+}
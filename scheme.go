@@ -0,0 +1,64 @@
+package connectauth
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// A SchemeDispatcher selects an AuthFunc based on the scheme of the request's
+// Authorization header. For example, an application might accept both bearer
+// tokens and API keys:
+//
+//	dispatcher := connectauth.NewSchemeDispatcher(map[string]connectauth.AuthFunc{
+//		"Bearer": authenticateJWT,
+//		"ApiKey": authenticateAPIKey,
+//	})
+//	middleware := connectauth.NewMiddleware(dispatcher.Authenticate)
+//
+// As required by RFC 7235, schemes are matched case-insensitively. Requests
+// without an Authorization header or with an unsupported scheme are rejected
+// with [connect.CodeUnauthenticated], and the error metadata includes a
+// WWW-Authenticate challenge for each supported scheme.
+type SchemeDispatcher struct {
+	schemes   []string // sorted, for deterministic challenges
+	authFuncs []AuthFunc
+}
+
+// NewSchemeDispatcher constructs a SchemeDispatcher. The map keys are
+// authentication schemes, like "Bearer" or "Basic".
+func NewSchemeDispatcher(schemes map[string]AuthFunc) *SchemeDispatcher {
+	d := &SchemeDispatcher{
+		schemes:   make([]string, 0, len(schemes)),
+		authFuncs: make([]AuthFunc, 0, len(schemes)),
+	}
+	for scheme := range schemes {
+		d.schemes = append(d.schemes, scheme)
+	}
+	sort.Strings(d.schemes)
+	for _, scheme := range d.schemes {
+		d.authFuncs = append(d.authFuncs, schemes[scheme])
+	}
+	return d
+}
+
+// Authenticate is an AuthFunc that delegates to the AuthFunc registered for
+// the request's authentication scheme.
+func (d *SchemeDispatcher) Authenticate(ctx context.Context, req *Request) (any, error) {
+	scheme, _ := parseAuthorization(req.Header)
+	if scheme != "" {
+		for i, s := range d.schemes {
+			if strings.EqualFold(scheme, s) {
+				return d.authFuncs[i](ctx, req)
+			}
+		}
+	}
+	err := ReasonErrorf(ReasonMalformedCredentials, "unsupported authentication scheme %q", scheme)
+	if scheme == "" {
+		err = ReasonErrorf(ReasonMissingCredentials, "missing Authorization header")
+	}
+	for _, s := range d.schemes {
+		err.Meta().Add("WWW-Authenticate", s)
+	}
+	return nil, err
+}
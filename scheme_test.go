@@ -0,0 +1,52 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestSchemeDispatcher(t *testing.T) {
+	echo := func(ctx context.Context, req *Request) (any, error) {
+		_, creds := parseAuthorization(req.Header)
+		return creds, nil
+	}
+	dispatcher := NewSchemeDispatcher(map[string]AuthFunc{
+		"Bearer": echo,
+		"ApiKey": func(ctx context.Context, req *Request) (any, error) {
+			return nil, Errorf("invalid API key")
+		},
+	})
+	authenticate := func(authorization string) (any, error) {
+		header := http.Header{}
+		if authorization != "" {
+			header.Set("Authorization", authorization)
+		}
+		return dispatcher.Authenticate(context.Background(), &Request{Header: header})
+	}
+
+	info, err := authenticate("Bearer token")
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("token"))
+
+	info, err = authenticate("bearer  other-token")
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("other-token"))
+
+	_, err = authenticate("ApiKey secret")
+	attest.Error(t, err)
+	attest.Equal(t, err.Error(), "unauthenticated: invalid API key")
+
+	for _, authorization := range []string{"", "Basic dXNlcjpwYXNz"} {
+		_, err = authenticate(authorization)
+		attest.Error(t, err)
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		var connectErr *connect.Error
+		attest.True(t, errors.As(err, &connectErr))
+		attest.Equal(t, connectErr.Meta().Values("WWW-Authenticate"), []string{"ApiKey", "Bearer"})
+	}
+}
@@ -0,0 +1,123 @@
+package connectauth
+
+import (
+	"container/list"
+	"context"
+	"hash/maphash"
+	"runtime"
+	"sync"
+)
+
+// A ShardedCache is an in-memory, size-bounded [Cache]. Entries are spread
+// across independently locked shards, so the cache scales across many cores.
+// Each shard evicts its least recently used entry when full.
+type ShardedCache struct {
+	seed   maphash.Seed
+	shards []cacheShard
+}
+
+var _ Cache = (*ShardedCache)(nil)
+
+type cacheShard struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     list.List // of *shardEntry, most recently used first
+}
+
+type shardEntry struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewShardedCache constructs a ShardedCache that holds approximately size
+// entries. If size isn't positive, it defaults to 10,000.
+func NewShardedCache(size int) *ShardedCache {
+	if size <= 0 {
+		size = 10_000
+	}
+	n := 1
+	for n < 4*runtime.GOMAXPROCS(0) && n < size {
+		n *= 2
+	}
+	c := &ShardedCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]cacheShard, n),
+	}
+	for i := range c.shards {
+		c.shards[i].size = (size + n - 1) / n
+		c.shards[i].entries = make(map[string]*list.Element)
+	}
+	return c
+}
+
+// Get implements [Cache].
+func (c *ShardedCache) Get(_ context.Context, key string) (*CacheEntry, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*shardEntry).entry, true
+}
+
+// Set implements [Cache].
+func (c *ShardedCache) Set(_ context.Context, key string, entry *CacheEntry) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*shardEntry).entry = entry
+		s.lru.MoveToFront(el)
+		return
+	}
+	s.entries[key] = s.lru.PushFront(&shardEntry{key: key, entry: entry})
+	for s.lru.Len() > s.size {
+		s.remove(s.lru.Back())
+	}
+}
+
+// Delete implements [Cache].
+func (c *ShardedCache) Delete(_ context.Context, key string) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+}
+
+// Len returns the number of cached entries, including any that have expired
+// but haven't yet been evicted.
+func (c *ShardedCache) Len() int {
+	var n int
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		n += s.lru.Len()
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Purge removes all entries from the cache.
+func (c *ShardedCache) Purge() {
+	for i := range c.shards {
+		s := &c.shards[i]
+		s.mu.Lock()
+		s.entries = make(map[string]*list.Element)
+		s.lru.Init()
+		s.mu.Unlock()
+	}
+}
+
+func (c *ShardedCache) shard(key string) *cacheShard {
+	return &c.shards[maphash.String(c.seed, key)&uint64(len(c.shards)-1)]
+}
+
+func (s *cacheShard) remove(el *list.Element) {
+	delete(s.entries, s.lru.Remove(el).(*shardEntry).key)
+}
@@ -0,0 +1,77 @@
+package connectauth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestShardedCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedCache(1000)
+	for i := 0; i < 100; i++ {
+		cache.Set(ctx, fmt.Sprint(i), &CacheEntry{Info: i})
+	}
+	attest.Equal(t, cache.Len(), 100)
+	entry, ok := cache.Get(ctx, "42")
+	attest.True(t, ok)
+	attest.Equal(t, entry.Info, any(42))
+	cache.Set(ctx, "42", &CacheEntry{Info: "replaced"})
+	entry, _ = cache.Get(ctx, "42")
+	attest.Equal(t, entry.Info, any("replaced"))
+	cache.Delete(ctx, "42")
+	_, ok = cache.Get(ctx, "42")
+	attest.False(t, ok)
+	cache.Purge()
+	attest.Equal(t, cache.Len(), 0)
+}
+
+func TestShardedCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedCache(2)
+	cache.shards = cache.shards[:1] // make eviction order deterministic
+	cache.shards[0].size = 2
+	for _, key := range []string{"a", "b", "a", "c"} { // evicts b
+		if _, ok := cache.Get(ctx, key); !ok {
+			cache.Set(ctx, key, &CacheEntry{})
+		}
+	}
+	attest.Equal(t, cache.Len(), 2)
+	_, ok := cache.Get(ctx, "a")
+	attest.True(t, ok)
+	_, ok = cache.Get(ctx, "b")
+	attest.False(t, ok)
+
+	// With many shards, the total size is still bounded.
+	cache = NewShardedCache(64)
+	for i := 0; i < 1000; i++ {
+		cache.Set(ctx, fmt.Sprint(i), &CacheEntry{})
+	}
+	attest.True(t, cache.Len() <= 64+len(cache.shards), attest.Sprintf("%d entries", cache.Len()))
+}
+
+func BenchmarkShardedCache(b *testing.B) {
+	ctx := context.Background()
+	cache := NewShardedCache(10_000)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = cacheKey(fmt.Sprint(i))
+		cache.Set(ctx, keys[i], &CacheEntry{})
+	}
+	var mu sync.Mutex
+	var next int
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		i := next
+		next++
+		mu.Unlock()
+		for pb.Next() {
+			cache.Get(ctx, keys[i%len(keys)])
+			i++
+		}
+	})
+}
@@ -0,0 +1,314 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// StreamExpiryConfig configures [WithStreamExpiry].
+type StreamExpiryConfig struct {
+	// Grace lets requests outlive their credentials by a fixed period, so
+	// clients have time to reconnect with fresh credentials. The default is
+	// zero.
+	Grace time.Duration
+	// Cancel ends expired requests with a [connect.CodeUnauthenticated] error
+	// carrying a google.rpc.ErrorInfo detail, rather than bounding the
+	// request context's deadline. Only [Interceptor] can replace the
+	// handler's error, so applications using [Middleware] must also use
+	// [Authenticator.HandlerOption]; otherwise, clients see
+	// [connect.CodeDeadlineExceeded].
+	Cancel bool
+	// Expiry returns the time at which the authentication information
+	// expires. A zero time means that the information doesn't expire. By
+	// default, Expiry uses the information's Expiry method, if it has one:
+	//
+	//	Expiry() time.Time
+	Expiry func(info any) time.Time
+}
+
+// WithStreamExpiry terminates requests, most importantly long-lived streams,
+// when the caller's credentials expire. Without it, a stream authenticated
+// with a short-lived token may stay open indefinitely.
+//
+// By default, the deadline of each authenticated request's context is
+// bounded by the credential's expiry plus the configured grace period, so
+// handlers see [context.DeadlineExceeded]. Unary RPCs and plain HTTP requests
+// are bounded too, but they rarely run long enough to notice.
+func WithStreamExpiry(sc StreamExpiryConfig) Option {
+	if sc.Expiry == nil {
+		sc.Expiry = expiryOf
+	}
+	return optionFunc(func(c *config) {
+		c.StreamExpiry = &sc
+	})
+}
+
+// endedError is the cause of contexts canceled by WithStreamExpiry,
+// WithStreamRevalidation, and WithStreamLifetime.
+type endedError struct {
+	err *connect.Error
+}
+
+func (e *endedError) Error() string { return e.err.Error() }
+func (e *endedError) Unwrap() error { return e.err }
+
+// bound limits the context's lifetime to the expiry of the authentication
+// information it carries.
+func (c *StreamExpiryConfig) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	expiry := c.Expiry(GetInfo(ctx))
+	if expiry.IsZero() {
+		return ctx, func() {}
+	}
+	deadline := expiry.Add(c.Grace)
+	if !c.Cancel {
+		return context.WithDeadline(ctx, deadline)
+	}
+	err := NewReasonError(
+		connect.CodeUnauthenticated,
+		ReasonExpired,
+		fmt.Errorf("credentials expired at %s", expiry.UTC().Format(time.RFC3339)),
+	)
+	if detail, detailErr := connect.NewErrorDetail(&errdetails.ErrorInfo{
+		Reason:   "CREDENTIALS_EXPIRED",
+		Domain:   "connectauth",
+		Metadata: map[string]string{"expiry": expiry.UTC().Format(time.RFC3339)},
+	}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	return context.WithDeadlineCause(ctx, deadline, &endedError{err: err})
+}
+
+// endedCause replaces errors caused by [WithStreamExpiry],
+// [WithStreamRevalidation], or [WithStreamLifetime] canceling the context
+// with an explanation.
+func endedCause(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	var ended *endedError
+	if errors.As(context.Cause(ctx), &ended) {
+		return ended.err
+	}
+	return err
+}
+
+// StreamLimiterConfig configures a [StreamLimiter].
+type StreamLimiterConfig struct {
+	// Max is the number of streams each caller may have open at once. The
+	// default is 100.
+	Max int
+	// Key identifies the caller from the authentication information. The
+	// default is [SubjectOf]. Callers with an empty key, including
+	// unauthenticated callers of exempt procedures, aren't limited.
+	Key func(info any) string
+}
+
+// A StreamLimiter caps the number of streams each authenticated caller may
+// have open at once, so a single caller can't monopolize a server with
+// long-lived connections. Streams beyond the cap are rejected with
+// [connect.CodeResourceExhausted].
+//
+// Only [Interceptor] can tell streaming RPCs from unary RPCs, so applications
+// using [Middleware] must also use [Authenticator.HandlerOption]. Counts are
+// kept in memory, so each server enforces its own limit.
+type StreamLimiter struct {
+	max int
+	key func(any) string
+
+	mu   sync.Mutex
+	open map[string]int
+}
+
+// NewStreamLimiter constructs a StreamLimiter. Use it with
+// [WithStreamLimiter].
+func NewStreamLimiter(config StreamLimiterConfig) *StreamLimiter {
+	if config.Max <= 0 {
+		config.Max = 100
+	}
+	if config.Key == nil {
+		config.Key = SubjectOf
+	}
+	return &StreamLimiter{
+		max:  config.Max,
+		key:  config.Key,
+		open: make(map[string]int),
+	}
+}
+
+// WithStreamLimiter limits the number of concurrent streams per caller.
+func WithStreamLimiter(limiter *StreamLimiter) Option {
+	return optionFunc(func(c *config) {
+		c.StreamLimiter = limiter
+	})
+}
+
+// Open returns the number of streams the caller identified by key has open.
+func (l *StreamLimiter) Open(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.open[key]
+}
+
+// acquire reserves a stream for the caller. The returned function releases
+// the reservation.
+func (l *StreamLimiter) acquire(info any) (func(), error) {
+	key := l.key(info)
+	if key == "" {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[key] >= l.max {
+		return nil, NewReasonError(
+			connect.CodeResourceExhausted,
+			ReasonTooManyStreams,
+			fmt.Errorf("too many open streams: limit is %d", l.max),
+		)
+	}
+	l.open[key]++
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.open[key]--; l.open[key] <= 0 {
+			delete(l.open, key)
+		}
+	}, nil
+}
+
+// StreamRevalidationConfig configures [WithStreamRevalidation].
+type StreamRevalidationConfig struct {
+	// Interval is the time between checks. The default is one minute.
+	Interval time.Duration
+	// Revalidate re-checks the credentials of the stream's original request.
+	// The default is the Authenticator's AuthFunc. When credentials are
+	// cached, use [TokenCache.Revalidate] to bypass the cache.
+	Revalidate AuthFunc
+}
+
+// WithStreamRevalidation periodically re-checks the credentials of open
+// streams, so revoking a credential ends connected clients' streams rather
+// than only affecting new requests. When a check fails with
+// [connect.CodeUnauthenticated] or [connect.CodePermissionDenied], the
+// stream's context is canceled and the client receives the check's error.
+// Other failures, like an unavailable introspection endpoint, leave the
+// stream open until the next check.
+//
+// Only [Interceptor] can tell streaming RPCs from unary RPCs, so
+// applications using [Middleware] must also use
+// [Authenticator.HandlerOption]. Checks receive a copy of the [Request] that
+// the AuthFunc accepted, including the TLS state, host, client address, and
+// buffered body seen by the middleware. Exempt streams aren't checked.
+// Checks call the AuthFunc directly, so they're not audited, logged, or
+// counted toward failure limits.
+func WithStreamRevalidation(rc StreamRevalidationConfig) Option {
+	if rc.Interval <= 0 {
+		rc.Interval = time.Minute
+	}
+	return optionFunc(func(c *config) {
+		c.StreamRevalidation = &rc
+	})
+}
+
+// watch revalidates the request's credentials until the returned function is
+// called, canceling the returned context if they're no longer valid.
+func (c *StreamRevalidationConfig) watch(ctx context.Context, auth AuthFunc, req *Request) (context.Context, func()) {
+	revalidate := c.Revalidate
+	if revalidate == nil {
+		revalidate = auth
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	go func() {
+		ticker := time.NewTicker(c.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			_, err := revalidate(ctx, req)
+			var connectErr *connect.Error
+			if isDefinitiveFailure(err) && errors.As(err, &connectErr) {
+				cancel(&endedError{err: connectErr})
+				return
+			}
+		}
+	}()
+	return ctx, func() { cancel(nil) }
+}
+
+// StreamLifetimeConfig configures [WithStreamLifetime].
+type StreamLifetimeConfig struct {
+	// Max is the maximum lifetime of streams to procedures that don't match
+	// any pattern in Procedures. Zero means that they're unlimited.
+	Max time.Duration
+	// Procedures maps procedure patterns to maximum lifetimes, overriding
+	// Max. Patterns use the same syntax as [Router]; overlapping globs are
+	// tried in lexical order.
+	Procedures map[string]time.Duration
+	// Identity returns the maximum lifetime of the caller's streams, so
+	// classes of callers (for example, third-party integrations) can be
+	// forced to reconnect more often. Zero means that the caller has no limit
+	// of its own. If both a procedure and the caller have limits, the
+	// shorter one applies.
+	Identity func(info any) time.Duration
+}
+
+// WithStreamLifetime caps the total lifetime of streams, forcing clients to
+// reconnect and re-authenticate periodically. Streams that reach their limit
+// end with [connect.CodeUnavailable], which well-behaved clients retry.
+//
+// Only [Interceptor] can tell streaming RPCs from unary RPCs, so
+// applications using [Middleware] must also use
+// [Authenticator.HandlerOption]. WithStreamLifetime panics if any pattern is
+// malformed.
+func WithStreamLifetime(lc StreamLifetimeConfig) Option {
+	lifetime := &streamLifetime{max: lc.Max, identity: lc.Identity}
+	patterns := make([]string, 0, len(lc.Procedures))
+	for pattern := range lc.Procedures {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if err := lifetime.procedures.add(pattern, lc.Procedures[pattern]); err != nil {
+			panic("connectauth: " + err.Error())
+		}
+	}
+	return optionFunc(func(c *config) {
+		c.StreamLifetime = lifetime
+	})
+}
+
+type streamLifetime struct {
+	max        time.Duration
+	procedures procedureMatcher[time.Duration]
+	identity   func(any) time.Duration
+}
+
+// bound limits the context's lifetime to the stream's maximum lifetime.
+func (l *streamLifetime) bound(ctx context.Context, procedure string) (context.Context, context.CancelFunc) {
+	limit := l.max
+	if d, ok := l.procedures.match(procedure); ok {
+		limit = d
+	}
+	if l.identity != nil {
+		if d := l.identity(GetInfo(ctx)); d > 0 && (limit <= 0 || d < limit) {
+			limit = d
+		}
+	}
+	if limit <= 0 {
+		return ctx, func() {}
+	}
+	err := connect.NewError(
+		connect.CodeUnavailable,
+		fmt.Errorf("stream reached its maximum lifetime of %s, reconnect to continue", limit),
+	)
+	return context.WithTimeoutCause(ctx, limit, &endedError{err: err})
+}
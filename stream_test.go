@@ -0,0 +1,356 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"go.akshayshah.org/memhttp/memhttptest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestStreamExpiry(t *testing.T) {
+	const procedure = "/empty.v1/Watch"
+	serve := func(t *testing.T, lifetime time.Duration, config StreamExpiryConfig) error {
+		t.Helper()
+		auth := New(func(context.Context, *Request) (any, error) {
+			return expiringInfo{name: hero, expires: time.Now().Add(lifetime)}, nil
+		}, WithStreamExpiry(config))
+		mux := http.NewServeMux()
+		mux.Handle(procedure, connect.NewServerStreamHandler(
+			procedure,
+			func(ctx context.Context, _ *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+				if err := stream.Send(&emptypb.Empty{}); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(200 * time.Millisecond):
+					return nil
+				}
+			},
+			auth.HandlerOption(),
+		))
+		srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+		client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+		stream, err := client.CallServerStream(context.Background(), connect.NewRequest(&emptypb.Empty{}))
+		attest.Ok(t, err)
+		defer stream.Close()
+		for stream.Receive() {
+		}
+		return stream.Err()
+	}
+
+	t.Run("deadline", func(t *testing.T) {
+		start := time.Now()
+		err := serve(t, 10*time.Millisecond, StreamExpiryConfig{Grace: 10 * time.Millisecond})
+		attest.Equal(t, connect.CodeOf(err), connect.CodeDeadlineExceeded)
+		attest.True(t, time.Since(start) >= 20*time.Millisecond)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		err := serve(t, 10*time.Millisecond, StreamExpiryConfig{Cancel: true})
+		attest.Equal(t, connect.CodeOf(err), connect.CodeUnauthenticated)
+		var connectErr *connect.Error
+		attest.True(t, errors.As(err, &connectErr))
+		attest.Subsequence(t, connectErr.Message(), "credentials expired")
+		attest.Equal(t, len(connectErr.Details()), 1)
+		msg, err := connectErr.Details()[0].Value()
+		attest.Ok(t, err)
+		info, ok := msg.(*errdetails.ErrorInfo)
+		attest.True(t, ok)
+		attest.Equal(t, info.GetReason(), "CREDENTIALS_EXPIRED")
+	})
+
+	t.Run("unexpired", func(t *testing.T) {
+		attest.Ok(t, serve(t, time.Hour, StreamExpiryConfig{Cancel: true}))
+	})
+
+	t.Run("no expiry", func(t *testing.T) {
+		err := serve(t, time.Millisecond, StreamExpiryConfig{
+			Expiry: func(any) time.Time { return time.Time{} },
+		})
+		attest.Ok(t, err)
+	})
+}
+
+func TestStreamLimiter(t *testing.T) {
+	const procedure = "/empty.v1/Watch"
+	limiter := NewStreamLimiter(StreamLimiterConfig{Max: 1})
+	auth := New(authenticate, WithStreamLimiter(limiter))
+	started := make(chan struct{}, 1)
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewServerStreamHandler(
+		procedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+			started <- struct{}{}
+			// Sending a message flushes the response headers.
+			if err := stream.Send(&emptypb.Empty{}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return nil
+		},
+		auth.HandlerOption(),
+	))
+	srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+	call := func(ctx context.Context) (*connect.ServerStreamForClient[emptypb.Empty], error) {
+		req := connect.NewRequest(&emptypb.Empty{})
+		req.Header().Set("Authorization", "Bearer "+passphrase)
+		return client.CallServerStream(ctx, req)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := call(ctx)
+	attest.Ok(t, err)
+	<-started
+	attest.True(t, first.Receive())
+	attest.Equal(t, limiter.Open(hero), 1)
+
+	second, err := call(context.Background())
+	attest.Ok(t, err)
+	attest.False(t, second.Receive())
+	attest.Equal(t, connect.CodeOf(second.Err()), connect.CodeResourceExhausted)
+	attest.Equal(t, ReasonOf(second.Err()), ReasonUnknown) // reasons aren't sent to clients
+	second.Close()
+
+	cancel()
+	first.Close()
+	// The server notices the cancellation asynchronously.
+	deadline := time.Now().Add(time.Second)
+	for limiter.Open(hero) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	attest.Zero(t, limiter.Open(hero))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	third, err := call(ctx)
+	attest.Ok(t, err)
+	<-started
+	attest.True(t, third.Receive())
+	cancel()
+	third.Close()
+}
+
+func TestStreamLimiterAcquire(t *testing.T) {
+	limiter := NewStreamLimiter(StreamLimiterConfig{Max: 2})
+	release1, err := limiter.acquire(hero)
+	attest.Ok(t, err)
+	release2, err := limiter.acquire(hero)
+	attest.Ok(t, err)
+	_, err = limiter.acquire(hero)
+	attest.Equal(t, connect.CodeOf(err), connect.CodeResourceExhausted)
+	attest.Equal(t, ReasonOf(err), ReasonTooManyStreams)
+	release, err := limiter.acquire(nil) // no subject, so no limit
+	attest.Ok(t, err)
+	release()
+	release1()
+	attest.Equal(t, limiter.Open(hero), 1)
+	release2()
+	attest.Zero(t, limiter.Open(hero))
+}
+
+func TestStreamRevalidation(t *testing.T) {
+	const procedure = "/empty.v1/Watch"
+	var revoked atomic.Bool
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		if revoked.Load() {
+			return nil, ReasonErrorf(ReasonRevoked, "credential revoked")
+		}
+		return authenticate(ctx, req)
+	}, WithStreamRevalidation(StreamRevalidationConfig{Interval: 5 * time.Millisecond}))
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewServerStreamHandler(
+		procedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+			if err := stream.Send(&emptypb.Empty{}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		auth.HandlerOption(),
+	))
+	srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+passphrase)
+	stream, err := client.CallServerStream(context.Background(), req)
+	attest.Ok(t, err)
+	defer stream.Close()
+	attest.True(t, stream.Receive())
+
+	// Several checks pass before the credential is revoked.
+	time.Sleep(20 * time.Millisecond)
+	revoked.Store(true)
+	attest.False(t, stream.Receive())
+	attest.Equal(t, connect.CodeOf(stream.Err()), connect.CodeUnauthenticated)
+	var connectErr *connect.Error
+	attest.True(t, errors.As(stream.Err(), &connectErr))
+	attest.Equal(t, connectErr.Message(), "credential revoked")
+}
+
+func TestStreamRevalidationMTLS(t *testing.T) {
+	// Checks must see the TLS state, which only the middleware has.
+	const procedure = "/empty.v1/Watch"
+	var checks atomic.Int32
+	trusted := TrustedClientCerts("spiffe://acme.com/frontend")
+	auth := New(func(ctx context.Context, req *Request) (any, error) {
+		checks.Add(1)
+		return trusted(ctx, req)
+	}, WithStreamRevalidation(StreamRevalidationConfig{Interval: 5 * time.Millisecond}))
+	next := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewServerStreamHandler(
+		procedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+			for {
+				if err := stream.Send(&emptypb.Empty{}); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-next:
+				}
+			}
+		},
+		auth.HandlerOption(),
+	))
+
+	clientCert := newClientCert(t, &url.URL{Scheme: "spiffe", Host: "acme.com", Path: "/frontend"})
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert.Leaf)
+	srv := httptest.NewUnstartedServer(auth.Middleware().Wrap(mux))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	httpClient := srv.Client()
+	httpClient.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](httpClient, srv.URL+procedure)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.CallServerStream(ctx, connect.NewRequest(&emptypb.Empty{}))
+	attest.Ok(t, err)
+	defer func() {
+		cancel()
+		stream.Close()
+	}()
+	attest.True(t, stream.Receive())
+
+	deadline := time.Now().Add(time.Second)
+	for checks.Load() < 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	attest.True(t, checks.Load() >= 4) // the first authentication, then several checks
+	next <- struct{}{}
+	attest.True(t, stream.Receive(), attest.Sprintf("stream ended: %v", stream.Err()))
+}
+
+// newClientCert returns a self-signed client certificate for the URI SAN.
+func newClientCert(tb testing.TB, uri *url.URL) tls.Certificate {
+	tb.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	attest.Ok(tb, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		URIs:                  []*url.URL{uri},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	attest.Ok(tb, err)
+	leaf, err := x509.ParseCertificate(der)
+	attest.Ok(tb, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestStreamLifetime(t *testing.T) {
+	const procedure = "/empty.v1/Watch"
+	auth := New(authenticate, WithStreamLifetime(StreamLifetimeConfig{
+		Procedures: map[string]time.Duration{"/empty.v1/*": 10 * time.Millisecond},
+	}))
+	mux := http.NewServeMux()
+	mux.Handle(procedure, connect.NewServerStreamHandler(
+		procedure,
+		func(ctx context.Context, _ *connect.Request[emptypb.Empty], stream *connect.ServerStream[emptypb.Empty]) error {
+			if err := stream.Send(&emptypb.Empty{}); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		auth.HandlerOption(),
+	))
+	srv := memhttptest.New(t, auth.Middleware().Wrap(mux))
+	client := connect.NewClient[emptypb.Empty, emptypb.Empty](srv.Client(), srv.URL()+procedure)
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+passphrase)
+	stream, err := client.CallServerStream(context.Background(), req)
+	attest.Ok(t, err)
+	defer stream.Close()
+	attest.True(t, stream.Receive())
+	attest.False(t, stream.Receive())
+	attest.Equal(t, connect.CodeOf(stream.Err()), connect.CodeUnavailable)
+	attest.Subsequence(t, stream.Err().Error(), "maximum lifetime of 10ms")
+}
+
+func TestStreamLifetimeLimit(t *testing.T) {
+	c := newConfig([]Option{WithStreamLifetime(StreamLifetimeConfig{
+		Max: time.Hour,
+		Procedures: map[string]time.Duration{
+			"/acme.feed.v1.FeedService/*":        10 * time.Minute,
+			"/acme.feed.v1.FeedService/Firehose": time.Minute,
+			"/acme.admin.v1.AdminService/Tail":   0,
+			"/acme.*.v1.*/Watch*":                30 * time.Minute,
+		},
+		Identity: func(info any) time.Duration {
+			if info == "partner" {
+				return 5 * time.Minute
+			}
+			return 0
+		},
+	})})
+	lifetime := c.StreamLifetime
+	limit := func(procedure string, info any) time.Duration {
+		ctx, cancel := lifetime.bound(SetInfo(context.Background(), info), procedure)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return 0
+		}
+		return time.Until(deadline).Round(time.Minute)
+	}
+	attest.Equal(t, limit("/acme.user.v1.UserService/Get", nil), time.Hour)
+	attest.Equal(t, limit("/acme.feed.v1.FeedService/Follow", nil), 10*time.Minute)
+	attest.Equal(t, limit("/acme.feed.v1.FeedService/Firehose", nil), time.Minute)
+	attest.Equal(t, limit("/acme.user.v1.UserService/WatchUsers", nil), 30*time.Minute)
+	attest.Zero(t, limit("/acme.admin.v1.AdminService/Tail", nil))
+	attest.Equal(t, limit("/acme.admin.v1.AdminService/Tail", "partner"), 5*time.Minute)
+	attest.Equal(t, limit("/acme.feed.v1.FeedService/Firehose", "partner"), time.Minute)
+
+	attest.Panics(t, func() {
+		WithStreamLifetime(StreamLifetimeConfig{Procedures: map[string]time.Duration{"no-slash": time.Minute}})
+	})
+}
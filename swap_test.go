@@ -0,0 +1,46 @@
+package connectauth
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"go.akshayshah.org/attest"
+)
+
+func TestSwappable(t *testing.T) {
+	named := func(name string) AuthFunc {
+		return func(context.Context, *Request) (any, error) {
+			return name, nil
+		}
+	}
+	auth := NewSwappable(named("v1"))
+	info, err := auth.Authenticate(context.Background(), &Request{})
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("v1"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, err := auth.Authenticate(context.Background(), &Request{})
+				attest.Ok(t, err)
+			}
+		}()
+	}
+	prev := auth.Swap(named("v2"))
+	wg.Wait()
+
+	info, _ = prev(context.Background(), &Request{})
+	attest.Equal(t, info, any("v1"))
+	info, err = auth.Authenticate(context.Background(), &Request{})
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("v2"))
+
+	defer func() {
+		attest.NotZero(t, recover())
+	}()
+	auth.Swap(nil)
+}
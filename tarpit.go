@@ -0,0 +1,135 @@
+package connectauth
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// WithFailureDelay delays every authentication failure by the duration
+// returned from the supplied function. Delaying failures blunts online
+// brute-force and token-guessing attacks. Use [FixedDelay] for a constant
+// delay or an [EscalatingDelay] to penalize repeat offenders.
+//
+// The delay ends early if the request's context is canceled.
+func WithFailureDelay(delay func(*Request) time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.FailureDelay = delay
+	})
+}
+
+// FixedDelay delays every authentication failure by the same duration.
+func FixedDelay(d time.Duration) func(*Request) time.Duration {
+	return func(*Request) time.Duration {
+		return d
+	}
+}
+
+// An EscalatingDelay delays authentication failures from each client IP by a
+// duration that doubles with each consecutive failure, up to a maximum. A
+// client's history is forgotten once it stops failing for the reset period.
+// To bound memory use when attackers rotate through many addresses, as
+// IPv6 makes easy, only the most recently failing clients are tracked (see
+// [EscalatingDelay.SetMaxClients]); the rest are forgotten early.
+//
+// EscalatingDelays are safe to use concurrently.
+type EscalatingDelay struct {
+	base, max, reset time.Duration
+	now              func() time.Time
+
+	mu         sync.Mutex
+	maxClients int
+	clients    map[string]*list.Element
+	lru        list.List // of *escalation, most recently failed first
+}
+
+type escalation struct {
+	ip    string
+	delay time.Duration
+	last  time.Time
+}
+
+// NewEscalatingDelay constructs an EscalatingDelay. Use its Delay method with
+// [WithFailureDelay]. Doubling a zero delay would never penalize anyone, so
+// NewEscalatingDelay panics if base isn't positive.
+func NewEscalatingDelay(base, max, reset time.Duration) *EscalatingDelay {
+	if base <= 0 {
+		panic("connectauth: EscalatingDelay requires a positive base delay")
+	}
+	return &EscalatingDelay{
+		base:       base,
+		max:        max,
+		reset:      reset,
+		now:        time.Now,
+		maxClients: 10_000,
+		clients:    make(map[string]*list.Element),
+	}
+}
+
+// SetMaxClients limits the number of client IPs tracked. When the limit is
+// reached, the client that failed least recently is forgotten. If n isn't
+// positive, the limit is restored to the default of 10,000.
+func (d *EscalatingDelay) SetMaxClients(n int) {
+	if n <= 0 {
+		n = 10_000
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxClients = n
+	for d.lru.Len() > n {
+		d.remove(d.lru.Back())
+	}
+}
+
+// SetClock replaces the clock used to reset escalation. It's intended for
+// tests, and must be called before the EscalatingDelay is used. A nil Clock
+// restores [SystemClock]. Delays are always real time.
+func (d *EscalatingDelay) SetClock(clock Clock) {
+	d.now = nowFunc(clock)
+}
+
+// Delay records a failure and returns the delay for the request's client IP.
+func (d *EscalatingDelay) Delay(req *Request) time.Duration {
+	ip := clientIP(req.ClientAddr)
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// Clients are ordered by their last failure, so expired histories are
+	// at the back.
+	for el := d.lru.Back(); el != nil && now.Sub(el.Value.(*escalation).last) > d.reset; el = d.lru.Back() {
+		d.remove(el)
+	}
+	el, ok := d.clients[ip]
+	if !ok {
+		d.clients[ip] = d.lru.PushFront(&escalation{ip: ip, delay: d.base, last: now})
+		for d.lru.Len() > d.maxClients {
+			d.remove(d.lru.Back())
+		}
+		return d.base
+	}
+	d.lru.MoveToFront(el)
+	e := el.Value.(*escalation)
+	e.delay *= 2
+	if e.delay > d.max {
+		e.delay = d.max
+	}
+	e.last = now
+	return e.delay
+}
+
+func (d *EscalatingDelay) remove(el *list.Element) {
+	delete(d.clients, d.lru.Remove(el).(*escalation).ip)
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
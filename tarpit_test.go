@@ -0,0 +1,53 @@
+package connectauth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestFailureDelay(t *testing.T) {
+	auth := New(authenticate, WithFailureDelay(FixedDelay(20*time.Millisecond)))
+	req := &Request{Protocol: connect.ProtocolConnect, Header: make(map[string][]string)}
+	start := time.Now()
+	_, err := auth.authenticate(context.Background(), req)
+	attest.Error(t, err)
+	attest.True(t, time.Since(start) >= 20*time.Millisecond)
+
+	// Cancelation cuts the delay short.
+	auth = New(authenticate, WithFailureDelay(FixedDelay(time.Hour)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = auth.authenticate(ctx, req)
+	attest.Error(t, err)
+}
+
+func TestEscalatingDelay(t *testing.T) {
+	d := NewEscalatingDelay(time.Second, 5*time.Second, time.Hour)
+	alice := &Request{ClientAddr: "192.0.2.1:1234"}
+	bob := &Request{ClientAddr: "192.0.2.2:1234"}
+	attest.Equal(t, d.Delay(alice), time.Second)
+	attest.Equal(t, d.Delay(alice), 2*time.Second)
+	attest.Equal(t, d.Delay(bob), time.Second)
+	attest.Equal(t, d.Delay(alice), 4*time.Second)
+	attest.Equal(t, d.Delay(alice), 5*time.Second)
+	attest.Equal(t, d.Delay(&Request{ClientAddr: "192.0.2.1:5678"}), 5*time.Second)
+
+	// Only the most recently failing clients are tracked.
+	d.SetMaxClients(2)
+	carol := &Request{ClientAddr: "192.0.2.3:1234"}
+	attest.Equal(t, d.Delay(carol), time.Second)
+	attest.Equal(t, len(d.clients), 2)
+	attest.Equal(t, d.Delay(bob), time.Second) // forgotten
+	attest.Equal(t, d.Delay(carol), 2*time.Second)
+
+	d = NewEscalatingDelay(time.Second, 5*time.Second, 0)
+	attest.Equal(t, d.Delay(alice), time.Second)
+	time.Sleep(time.Millisecond)
+	attest.Equal(t, d.Delay(alice), time.Second)
+
+	attest.Panics(t, func() { NewEscalatingDelay(0, time.Second, time.Hour) })
+}
@@ -0,0 +1,184 @@
+package connectauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+type tenantKey struct{}
+
+// GetTenant retrieves the tenant attached to the context by a
+// [TenantResolver]. It returns an empty string if the request doesn't have a
+// tenant.
+func GetTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantResolverConfig configures a [TenantResolver].
+type TenantResolverConfig struct {
+	// Header, if set, names a request header selecting the tenant. It's
+	// useful for callers that belong to several tenants.
+	Header string
+	// HostSuffix, if set, derives the tenant from the request's host: with
+	// a suffix of ".example.com", requests to acme.example.com are for the
+	// "acme" tenant. Host-based resolution requires [Middleware] or
+	// [HTTPMiddleware], since interceptors can't see the host.
+	HostSuffix string
+	// Claim is the [Identity] claim listing the caller's tenants, either as
+	// a string or as a list of strings. The default is "tenant".
+	Claim string
+	// Validate reports whether the caller belongs to a tenant selected by
+	// the header or host. By default, the tenant must be among the values
+	// of the caller's Claim.
+	Validate func(ctx context.Context, info any, tenant string) bool
+	// Required rejects requests whose tenant can't be determined.
+	Required bool
+}
+
+// A TenantResolver determines the tenant of each authenticated request and
+// attaches it to the context, where [GetTenant] retrieves it. It runs as an
+// [Enricher]:
+//
+//	tenants := connectauth.NewTenantResolver(connectauth.TenantResolverConfig{
+//		Header: "Acme-Tenant",
+//	})
+//	auth := connectauth.New(authenticate, connectauth.WithEnricher(connectauth.Enricher{
+//		Enrich: tenants.Enrich,
+//	}))
+//
+// The tenant comes from the header, then the host, and then the caller's
+// claim. Tenants chosen by the request are only accepted if the caller
+// belongs to them: otherwise, requests are rejected with
+// [connect.CodePermissionDenied] and [ReasonPolicy]. If the request doesn't
+// choose a tenant and the caller belongs to exactly one, that tenant is
+// used.
+type TenantResolver struct {
+	header     string
+	hostSuffix string
+	claim      string
+	validate   func(context.Context, any, string) bool
+	required   bool
+}
+
+// NewTenantResolver constructs a TenantResolver.
+func NewTenantResolver(config TenantResolverConfig) *TenantResolver {
+	if config.Claim == "" {
+		config.Claim = "tenant"
+	}
+	r := &TenantResolver{
+		header:     http.CanonicalHeaderKey(config.Header),
+		hostSuffix: config.HostSuffix,
+		claim:      config.Claim,
+		validate:   config.Validate,
+		required:   config.Required,
+	}
+	if r.validate == nil {
+		r.validate = func(_ context.Context, info any, tenant string) bool {
+			for _, t := range r.claimed(info) {
+				if t == tenant {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return r
+}
+
+// Enrich resolves the request's tenant and attaches it to the context. Its
+// signature matches [Enricher].Enrich.
+func (r *TenantResolver) Enrich(ctx context.Context, req *Request, info any) (context.Context, error) {
+	tenant := r.requested(req)
+	if tenant != "" && !r.validate(ctx, info, tenant) {
+		return nil, NewReasonError(
+			connect.CodePermissionDenied,
+			ReasonPolicy,
+			fmt.Errorf("%q isn't a member of tenant %q", SubjectOf(info), tenant),
+		)
+	}
+	if tenant == "" {
+		if claimed := r.claimed(info); len(claimed) == 1 {
+			tenant = claimed[0]
+		}
+	}
+	if tenant == "" {
+		if r.required {
+			return nil, NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("tenant required"))
+		}
+		return ctx, nil
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant), nil
+}
+
+// Scope prefixes a key function's results with the tenant chosen by the
+// request's header or host, so caches and rate limits apply per tenant:
+//
+//	limiter := connectauth.NewFailureLimiter(connectauth.FailureLimiterConfig{
+//		Keys: []func(*connectauth.Request) string{
+//			tenants.Scope(connectauth.LimitKeyClientIP),
+//		},
+//	})
+//
+// Keys are scoped before authentication, so the tenant hasn't been validated;
+// scoping only partitions keys and never grants access. Empty keys stay
+// empty.
+func (r *TenantResolver) Scope(key func(*Request) string) func(*Request) string {
+	return func(req *Request) string {
+		k := key(req)
+		if k == "" {
+			return ""
+		}
+		return r.requested(req) + "/" + k
+	}
+}
+
+// requested returns the tenant chosen by the request, if any.
+func (r *TenantResolver) requested(req *Request) string {
+	if r.header != "" {
+		if tenant := strings.TrimSpace(headerValue(req.Header, r.header)); tenant != "" {
+			return tenant
+		}
+	}
+	if r.hostSuffix != "" && req.Host != "" {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tenant, ok := strings.CutSuffix(strings.ToLower(host), strings.ToLower(r.hostSuffix)); ok && tenant != "" && !strings.Contains(tenant, ".") {
+			return tenant
+		}
+	}
+	return ""
+}
+
+// claimed returns the tenants listed in the caller's claim.
+func (r *TenantResolver) claimed(info any) []string {
+	identity := IdentityOf(info)
+	if identity == nil {
+		return nil
+	}
+	switch v := identity.Claims[r.claim].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []any:
+		tenants := make([]string, 0, len(v))
+		for _, t := range v {
+			if s, ok := t.(string); ok && s != "" {
+				tenants = append(tenants, s)
+			}
+		}
+		return tenants
+	}
+	return nil
+}
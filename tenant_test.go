@@ -0,0 +1,106 @@
+package connectauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+)
+
+func TestTenantResolver(t *testing.T) {
+	ctx := context.Background()
+	member := func(tenants ...any) *Identity {
+		return &Identity{Subject: hero, Claims: map[string]any{"tenant": tenants}}
+	}
+	resolver := NewTenantResolver(TenantResolverConfig{
+		Header:     "Acme-Tenant",
+		HostSuffix: ".example.com",
+	})
+	resolve := func(r *TenantResolver, req *Request, info any) (string, error) {
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		ctx, err := r.Enrich(ctx, req, info)
+		if err != nil {
+			return "", err
+		}
+		return GetTenant(ctx), nil
+	}
+
+	t.Run("header", func(t *testing.T) {
+		req := &Request{Header: http.Header{"Acme-Tenant": []string{"globex"}}}
+		tenant, err := resolve(resolver, req, member("acme", "globex"))
+		attest.Ok(t, err)
+		attest.Equal(t, tenant, "globex")
+		_, err = resolve(resolver, req, member("acme"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		attest.Equal(t, ReasonOf(err), ReasonPolicy)
+	})
+
+	t.Run("host", func(t *testing.T) {
+		tenant, err := resolve(resolver, &Request{Host: "Acme.Example.com:8443"}, member("acme"))
+		attest.Ok(t, err)
+		attest.Equal(t, tenant, "acme")
+		_, err = resolve(resolver, &Request{Host: "globex.example.com"}, member("acme"))
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+		// Nested subdomains and the bare domain don't select a tenant.
+		tenant, err = resolve(resolver, &Request{Host: "a.b.example.com"}, member("acme"))
+		attest.Ok(t, err)
+		attest.Equal(t, tenant, "acme")
+	})
+
+	t.Run("claim", func(t *testing.T) {
+		tenant, err := resolve(resolver, &Request{}, &Identity{Subject: hero, Claims: map[string]any{"tenant": "acme"}})
+		attest.Ok(t, err)
+		attest.Equal(t, tenant, "acme")
+		tenant, err = resolve(resolver, &Request{}, member("acme", "globex")) // ambiguous
+		attest.Ok(t, err)
+		attest.Zero(t, tenant)
+		tenant, err = resolve(resolver, &Request{}, hero)
+		attest.Ok(t, err)
+		attest.Zero(t, tenant)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		required := NewTenantResolver(TenantResolverConfig{Required: true})
+		_, err := resolve(required, &Request{}, hero)
+		attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	})
+
+	t.Run("custom validation", func(t *testing.T) {
+		custom := NewTenantResolver(TenantResolverConfig{
+			Header: "Acme-Tenant",
+			Validate: func(_ context.Context, info any, tenant string) bool {
+				return SubjectOf(info) == hero && tenant == "acme"
+			},
+		})
+		tenant, err := resolve(custom, &Request{Header: http.Header{"Acme-Tenant": []string{"acme"}}}, hero)
+		attest.Ok(t, err)
+		attest.Equal(t, tenant, "acme")
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		key := resolver.Scope(LimitKeyClientIP)
+		attest.Equal(t, key(&Request{ClientAddr: "192.0.2.1:1234", Host: "acme.example.com"}), "acme/192.0.2.1")
+		attest.Equal(t, key(&Request{ClientAddr: "192.0.2.1:1234"}), "/192.0.2.1")
+		attest.Zero(t, resolver.Scope(LimitKeyCredential)(&Request{Host: "acme.example.com"}))
+	})
+}
+
+func TestTenantResolverEnricher(t *testing.T) {
+	resolver := NewTenantResolver(TenantResolverConfig{HostSuffix: ".example.com"})
+	auth := New(func(context.Context, *Request) (any, error) {
+		return &Identity{Subject: hero, Claims: map[string]any{"tenant": "acme"}}, nil
+	}, WithAuthenticateAll(), WithEnricher(Enricher{Enrich: resolver.Enrich}))
+	var tenant string
+	handler := auth.Middleware().Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		tenant = GetTenant(r.Context())
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://acme.example.com/admin", nil))
+	attest.Equal(t, rec.Code, http.StatusOK)
+	attest.Equal(t, tenant, "acme")
+}
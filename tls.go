@@ -0,0 +1,242 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+// WithRequireTLS rejects requests that didn't arrive over TLS with
+// [connect.CodePermissionDenied] and [ReasonPolicy]. Sending credentials
+// over cleartext is almost always a deployment mistake, and this option
+// makes it fail loudly rather than leak credentials. It applies to all
+// requests, including calls to exempt procedures.
+//
+// Requests count as encrypted if the server terminated TLS itself, or if
+// the request came from a proxy trusted with [WithTrustedProxies] and the
+// proxy set X-Forwarded-Proto to "https". The header is ignored when the
+// immediate peer isn't trusted, since clients could forge it.
+//
+// Only [Middleware] can observe the connection's TLS state. When using an
+// [Interceptor] alone, only requests from trusted proxies can satisfy this
+// option.
+func WithRequireTLS() Option {
+	return optionFunc(func(c *config) {
+		c.RequireTLS = true
+	})
+}
+
+var errTLSRequired = NewReasonError(
+	connect.CodePermissionDenied,
+	ReasonPolicy,
+	errors.New("TLS required: request arrived over cleartext"),
+)
+
+func (c *config) checkTLS(req *Request) error {
+	if req.TLS != nil {
+		if c.TLSPolicy != nil {
+			return c.TLSPolicy.check(req.TLS)
+		}
+		return nil
+	}
+	if !c.RequireTLS || forwardedHTTPS(c.TrustedProxies, req) {
+		return nil
+	}
+	return errTLSRequired
+}
+
+// Encrypted returns a function that reports whether requests arrived over
+// TLS, using the same rules as [WithRequireTLS]: requests count as encrypted
+// if the server terminated TLS itself, or if their immediate peer is in one of
+// the trusted networks and set X-Forwarded-Proto to "https". It's intended for
+// components that enforce their own transport policies, like session
+// managers; pass the same networks as to [WithTrustedProxies].
+func Encrypted(trustedProxies ...netip.Prefix) func(*Request) bool {
+	var proxies *prefixSet
+	if len(trustedProxies) > 0 {
+		proxies = newPrefixSet(trustedProxies)
+	}
+	return func(req *Request) bool {
+		return req.TLS != nil || forwardedHTTPS(proxies, req)
+	}
+}
+
+// forwardedHTTPS reports whether a trusted proxy received the request over
+// HTTPS.
+func forwardedHTTPS(proxies *prefixSet, req *Request) bool {
+	if proxies == nil {
+		return false
+	}
+	peer, err := netip.ParseAddr(clientIP(req.PeerAddr))
+	if err != nil || !proxies.contains(peer.Unmap()) {
+		return false
+	}
+	// The nearest proxy appends its value last.
+	values := req.Header.Values("X-Forwarded-Proto")
+	if len(values) == 0 {
+		return false
+	}
+	proto := values[len(values)-1]
+	if i := strings.LastIndexByte(proto, ','); i >= 0 {
+		proto = proto[i+1:]
+	}
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// A TLSPolicy describes the TLS connections the server accepts. Enforcing it
+// in the authentication layer, rather than in the [tls.Config], lets the
+// server explain rejections: handshake failures are opaque to clients, but
+// policy violations are reported in a google.rpc.PreconditionFailure error
+// detail.
+type TLSPolicy struct {
+	// MinVersion is the minimum TLS version, like [tls.VersionTLS13].
+	MinVersion uint16
+	// RequireClientCert requires clients to present a certificate.
+	RequireClientCert bool
+	// RequireVerifiedClientCert requires the client's certificate to have
+	// been verified by the server's tls.Config (that is, its ClientAuth is
+	// VerifyClientCertIfGiven or RequireAndVerifyClientCert).
+	RequireVerifiedClientCert bool
+	// ClientCertKeyUsages lists extended key usages that clients'
+	// certificates must all have, usually [x509.ExtKeyUsageClientAuth].
+	// Certificates with [x509.ExtKeyUsageAny] satisfy any requirement.
+	// Setting it requires a client certificate.
+	ClientCertKeyUsages []x509.ExtKeyUsage
+}
+
+// WithTLSPolicy rejects requests whose TLS connection violates the policy
+// with [connect.CodePermissionDenied] and [ReasonPolicy]. The error includes
+// a google.rpc.PreconditionFailure detail listing each violation. It applies
+// to all requests, including calls to exempt procedures.
+//
+// The policy only applies to connections terminated by the server, which
+// [Middleware] can observe. Use [WithRequireTLS] to also reject cleartext
+// requests.
+func WithTLSPolicy(policy TLSPolicy) Option {
+	return optionFunc(func(c *config) {
+		c.TLSPolicy = &policy
+	})
+}
+
+func (p *TLSPolicy) check(state *tls.ConnectionState) error {
+	var violations []*errdetails.PreconditionFailure_Violation
+	violate := func(subject, template string, args ...any) {
+		violations = append(violations, &errdetails.PreconditionFailure_Violation{
+			Type:        "TLS",
+			Subject:     subject,
+			Description: fmt.Sprintf(template, args...),
+		})
+	}
+	if state.Version < p.MinVersion {
+		violate("version", "%s is below the minimum version, %s",
+			tls.VersionName(state.Version), tls.VersionName(p.MinVersion))
+	}
+	var leaf *x509.Certificate
+	if len(state.PeerCertificates) > 0 {
+		leaf = state.PeerCertificates[0]
+	}
+	switch {
+	case leaf == nil && (p.RequireClientCert || p.RequireVerifiedClientCert || len(p.ClientCertKeyUsages) > 0):
+		violate("client_certificate", "client certificate required")
+	case leaf != nil:
+		if p.RequireVerifiedClientCert && len(state.VerifiedChains) == 0 {
+			violate("client_certificate", "client certificate isn't verified")
+		}
+		for _, usage := range p.ClientCertKeyUsages {
+			if !hasKeyUsage(leaf, usage) {
+				violate("client_certificate", "client certificate lacks extended key usage %s", keyUsageName(usage))
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = v.GetDescription()
+	}
+	err := NewReasonError(
+		connect.CodePermissionDenied,
+		ReasonPolicy,
+		fmt.Errorf("TLS policy violated: %s", strings.Join(descriptions, "; ")),
+	)
+	if detail, detailErr := connect.NewErrorDetail(&errdetails.PreconditionFailure{
+		Violations: violations,
+	}); detailErr == nil {
+		err.AddDetail(detail)
+	}
+	return err
+}
+
+// TrustedClientCerts returns an AuthFunc that authenticates peers by the
+// subject alternative names of their TLS client certificates. It accepts
+// requests whose certificate was verified by the server's tls.Config and has
+// any of the allowed DNS names, URIs (like SPIFFE IDs), email addresses, or
+// IP addresses, and returns the first matching SAN. It's designed to
+// identify trusted services, for example with [IdentityPropagatorConfig]:
+//
+//	connectauth.TrustedClientCerts("spiffe://acme.com/ns/prod/sa/frontend")
+//
+// Only [Middleware] can observe the connection's TLS state.
+func TrustedClientCerts(sans ...string) AuthFunc {
+	allowed := make(map[string]struct{}, len(sans))
+	for _, san := range sans {
+		allowed[san] = struct{}{}
+	}
+	return func(_ context.Context, req *Request) (any, error) {
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			return nil, ReasonErrorf(ReasonMissingCredentials, "client certificate required")
+		}
+		if len(req.TLS.VerifiedChains) == 0 {
+			return nil, ReasonErrorf(ReasonInvalidCredentials, "client certificate isn't verified")
+		}
+		leaf := req.TLS.PeerCertificates[0]
+		candidates := append([]string(nil), leaf.DNSNames...)
+		for _, uri := range leaf.URIs {
+			candidates = append(candidates, uri.String())
+		}
+		candidates = append(candidates, leaf.EmailAddresses...)
+		for _, ip := range leaf.IPAddresses {
+			candidates = append(candidates, ip.String())
+		}
+		for _, san := range candidates {
+			if _, ok := allowed[san]; ok {
+				return san, nil
+			}
+		}
+		return nil, NewReasonError(connect.CodePermissionDenied, ReasonPolicy, errors.New("client certificate isn't trusted"))
+	}
+}
+
+func hasKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+var keyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "any",
+	x509.ExtKeyUsageServerAuth:      "serverAuth",
+	x509.ExtKeyUsageClientAuth:      "clientAuth",
+	x509.ExtKeyUsageCodeSigning:     "codeSigning",
+	x509.ExtKeyUsageEmailProtection: "emailProtection",
+	x509.ExtKeyUsageTimeStamping:    "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSPSigning",
+}
+
+func keyUsageName(usage x509.ExtKeyUsage) string {
+	if name, ok := keyUsageNames[usage]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", int(usage))
+}
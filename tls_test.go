@@ -0,0 +1,154 @@
+package connectauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.akshayshah.org/attest"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func TestRequireTLS(t *testing.T) {
+	auth := New(func(context.Context, *Request) (any, error) {
+		return "alice", nil
+	}, WithRequireTLS(), WithTrustedProxies(netip.MustParsePrefix("10.0.0.0/8")))
+	handler := auth.Middleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(peer string, state *tls.ConnectionState, proto ...string) int {
+		r := httptest.NewRequest(http.MethodPost, "/acme.v1.Svc/Get", strings.NewReader("{}"))
+		r.Header.Set("Content-Type", "application/json")
+		r.RemoteAddr = peer
+		r.TLS = state
+		for _, p := range proto {
+			r.Header.Add("X-Forwarded-Proto", p)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	attest.Equal(t, call("192.0.2.1:1234", &tls.ConnectionState{}), http.StatusOK)
+	attest.Equal(t, call("192.0.2.1:1234", nil), http.StatusForbidden)
+	attest.Equal(t, call("10.0.0.1:1234", nil, "HTTPS"), http.StatusOK)
+	attest.Equal(t, call("10.0.0.1:1234", nil, "https, http"), http.StatusForbidden)
+	attest.Equal(t, call("10.0.0.1:1234", nil, "http", "https"), http.StatusOK)
+	attest.Equal(t, call("10.0.0.1:1234", nil), http.StatusForbidden)
+	// Untrusted clients can't forge the header.
+	attest.Equal(t, call("192.0.2.1:1234", nil, "https"), http.StatusForbidden)
+
+	_, err := auth.authenticate(context.Background(), &Request{
+		Procedure:  "/acme.v1.Svc/Get",
+		Protocol:   connect.ProtocolConnect,
+		ClientAddr: "192.0.2.1:1234",
+	})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, ReasonOf(err), ReasonPolicy)
+}
+
+func TestEncrypted(t *testing.T) {
+	request := func(peer string, state *tls.ConnectionState, proto ...string) *Request {
+		return &Request{PeerAddr: peer, TLS: state, Header: http.Header{"X-Forwarded-Proto": proto}}
+	}
+	encrypted := Encrypted(netip.MustParsePrefix("10.0.0.0/8"))
+	attest.True(t, encrypted(request("192.0.2.1:1234", &tls.ConnectionState{})))
+	attest.True(t, encrypted(request("10.0.0.1:1234", nil, "https")))
+	attest.False(t, encrypted(request("10.0.0.1:1234", nil, "http")))
+	attest.False(t, encrypted(request("192.0.2.1:1234", nil, "https")))
+	attest.False(t, Encrypted()(request("10.0.0.1:1234", nil, "https")))
+}
+
+func TestTLSPolicy(t *testing.T) {
+	auth := New(func(context.Context, *Request) (any, error) {
+		return "alice", nil
+	}, WithTLSPolicy(TLSPolicy{
+		MinVersion:                tls.VersionTLS13,
+		RequireVerifiedClientCert: true,
+		ClientCertKeyUsages:       []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}))
+	call := func(state *tls.ConnectionState) error {
+		_, err := auth.authenticate(context.Background(), &Request{
+			Procedure: "/acme.v1.Svc/Get",
+			Protocol:  connect.ProtocolConnect,
+			TLS:       state,
+		})
+		return err
+	}
+	client := &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	server := &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+
+	attest.Ok(t, call(&tls.ConnectionState{
+		Version:          tls.VersionTLS13,
+		PeerCertificates: []*x509.Certificate{client},
+		VerifiedChains:   [][]*x509.Certificate{{client}},
+	}))
+	attest.Ok(t, call(nil)) // use WithRequireTLS to reject cleartext
+
+	err := call(&tls.ConnectionState{
+		Version:          tls.VersionTLS12,
+		PeerCertificates: []*x509.Certificate{server},
+	})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, ReasonOf(err), ReasonPolicy)
+	attest.Subsequence(t, err.Error(), "TLS 1.2 is below the minimum version, TLS 1.3")
+	var connectErr *connect.Error
+	attest.True(t, errors.As(err, &connectErr))
+	attest.Equal(t, len(connectErr.Details()), 1)
+	msg, err := connectErr.Details()[0].Value()
+	attest.Ok(t, err)
+	failure, ok := msg.(*errdetails.PreconditionFailure)
+	attest.True(t, ok)
+	var descriptions []string
+	for _, v := range failure.GetViolations() {
+		descriptions = append(descriptions, v.GetSubject()+": "+v.GetDescription())
+	}
+	attest.Equal(t, descriptions, []string{
+		"version: TLS 1.2 is below the minimum version, TLS 1.3",
+		"client_certificate: client certificate isn't verified",
+		"client_certificate: client certificate lacks extended key usage clientAuth",
+	})
+
+	err = call(&tls.ConnectionState{Version: tls.VersionTLS13})
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Subsequence(t, err.Error(), "client certificate required")
+}
+
+func TestTrustedClientCerts(t *testing.T) {
+	auth := TrustedClientCerts("spiffe://acme.com/frontend", "billing.internal")
+	call := func(state *tls.ConnectionState) (any, error) {
+		return auth(context.Background(), &Request{TLS: state})
+	}
+	verified := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert},
+			VerifiedChains:   [][]*x509.Certificate{{cert}},
+		}
+	}
+	frontend := &x509.Certificate{URIs: []*url.URL{{Scheme: "spiffe", Host: "acme.com", Path: "/frontend"}}}
+	billing := &x509.Certificate{DNSNames: []string{"billing.internal"}}
+	other := &x509.Certificate{DNSNames: []string{"evil.internal"}}
+
+	info, err := call(verified(frontend))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("spiffe://acme.com/frontend"))
+	info, err = call(verified(billing))
+	attest.Ok(t, err)
+	attest.Equal(t, info, any("billing.internal"))
+
+	_, err = call(nil)
+	attest.Equal(t, ReasonOf(err), ReasonMissingCredentials)
+	_, err = call(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{billing}})
+	attest.Equal(t, ReasonOf(err), ReasonInvalidCredentials)
+	_, err = call(verified(other))
+	attest.Equal(t, connect.CodeOf(err), connect.CodePermissionDenied)
+	attest.Equal(t, ReasonOf(err), ReasonPolicy)
+}